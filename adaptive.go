@@ -0,0 +1,84 @@
+package reconnect
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// sizeWindowLen is a number of last message sizes used to compute the read buffer size
+	sizeWindowLen = 256
+	// adaptiveReadBufferPercentile is a percentile of message sizes used as the read buffer size
+	adaptiveReadBufferPercentile = 0.95
+)
+
+// sizeWindow contains sizes of the last messages
+type sizeWindow struct {
+	mu    sync.Mutex
+	sizes [sizeWindowLen]int
+	count int
+}
+
+func (w *sizeWindow) Add(size int) {
+	w.mu.Lock()
+	w.sizes[w.count%sizeWindowLen] = size
+	w.count++
+	w.mu.Unlock()
+}
+
+// Percentile returns the p-th percentile of the sizes. It returns 0 if there are no sizes
+func (w *sizeWindow) Percentile(p float64) int {
+	w.mu.Lock()
+	n := w.count
+	if n > sizeWindowLen {
+		n = sizeWindowLen
+	}
+	sizes := make([]int, n)
+	copy(sizes, w.sizes[:n])
+	w.mu.Unlock()
+
+	if n == 0 {
+		return 0
+	}
+
+	sort.Ints(sizes)
+	i := int(float64(n-1) * p)
+	return sizes[i]
+}
+
+// SetAdaptiveReadBuffer enables adaptive read buffer sizing: on every connect the read buffer size
+// is set to the 95th percentile of recent message sizes clamped to [min, max].
+// After 'Dial' call it does nothing
+func (r *ReConn) SetAdaptiveReadBuffer(min, max int) *ReConn {
+	r.configure(func() {
+		if min > max {
+			min, max = max, min
+		}
+		r.adaptiveReadBufferMin = min
+		r.adaptiveReadBufferMax = max
+	})
+	return r
+}
+
+// ReadBufferSize returns the read buffer size applied to the current connection by
+// the adaptive read buffer. It returns 0 if the adaptive read buffer is disabled
+func (r *ReConn) ReadBufferSize() int {
+	return int(atomic.LoadInt64(&r.appliedReadBufferSize))
+}
+
+func (r *ReConn) adaptiveReadBufferEnabled() bool {
+	return r.adaptiveReadBufferMax > 0
+}
+
+// nextReadBufferSize returns a read buffer size for the next connection
+func (r *ReConn) nextReadBufferSize() int {
+	size := r.messageSizes.Percentile(adaptiveReadBufferPercentile)
+	if size < r.adaptiveReadBufferMin {
+		size = r.adaptiveReadBufferMin
+	}
+	if size > r.adaptiveReadBufferMax {
+		size = r.adaptiveReadBufferMax
+	}
+	return size
+}
@@ -0,0 +1,105 @@
+package reconnect
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAdaptiveReadBufferSize(t *testing.T) {
+	conn := New().SetAdaptiveReadBuffer(1024, 64*1024)
+
+	if size := conn.nextReadBufferSize(); size != 1024 {
+		t.Errorf("without observations the min size must be used, got %d", size)
+	}
+
+	for i := 0; i < 100; i++ {
+		size := 200
+		if i%10 == 0 {
+			size = 1 << 20
+		}
+		conn.messageSizes.Add(size)
+	}
+	if size := conn.nextReadBufferSize(); size != 64*1024 {
+		t.Errorf("size must be clamped to max, got %d", size)
+	}
+
+	for i := 0; i < sizeWindowLen; i++ {
+		conn.messageSizes.Add(4096)
+	}
+	if size := conn.nextReadBufferSize(); size != 4096 {
+		t.Errorf("size must be equal to p95, got %d", size)
+	}
+}
+
+func TestAdaptiveReadBufferStats(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetAdaptiveReadBuffer(1024, 64*1024)
+	if size := conn.Stats().ReadBufferSize; size != 0 {
+		t.Errorf("no size is applied before 'Dial', got %d", size)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if size := conn.Stats().ReadBufferSize; size != 1024 {
+		t.Errorf("expected the applied size 1024, got %d", size)
+	}
+}
+
+// mixedMessages returns a bimodal workload: small ticks and rare large snapshots
+func mixedMessages() [][]byte {
+	var msgs [][]byte
+	for i := 0; i < 100; i++ {
+		size := 200
+		if i%20 == 0 {
+			size = 256 * 1024
+		}
+		msgs = append(msgs, bytes.Repeat([]byte("a"), size))
+	}
+	return msgs
+}
+
+func benchmarkReadBuffer(b *testing.B, min, max int) {
+	msgs := mixedMessages()
+	server := newWsServer(func(conn *websocket.Conn) {
+		for i := 0; ; i++ {
+			if err := conn.WriteMessage(websocket.BinaryMessage, msgs[i%len(msgs)]); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetAdaptiveReadBuffer(min, max)
+	if err := conn.Dial(); err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Warm up and reconnect to apply the observed sizes
+	for i := 0; i < len(msgs); i++ {
+		conn.ReadMessage()
+	}
+	if err := conn.connect(conn.generation, nil); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadBufferFixedSmall(b *testing.B) { benchmarkReadBuffer(b, 256, 256) }
+func BenchmarkReadBufferFixedLarge(b *testing.B) { benchmarkReadBuffer(b, 1<<20, 1<<20) }
+func BenchmarkReadBufferAdaptive(b *testing.B)   { benchmarkReadBuffer(b, 256, 1<<20) }
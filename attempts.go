@@ -0,0 +1,133 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// MaxReconnectsError is returned by all methods after the max number of consecutive failed connect
+// attempts is exceeded. It matches 'ErrMaxReconnectsExceeded' and unwraps to the last connect error
+type MaxReconnectsError struct {
+	Attempts int
+	// Err is the error of the last attempt
+	Err error
+}
+
+func (e *MaxReconnectsError) Error() string {
+	return fmt.Sprintf("%s: %d attempts failed, last error: %s", ErrMaxReconnectsExceeded, e.Attempts, e.Err)
+}
+
+func (e *MaxReconnectsError) Unwrap() error {
+	return e.Err
+}
+
+func (e *MaxReconnectsError) Is(target error) bool {
+	return target == ErrMaxReconnectsExceeded
+}
+
+// SubscribeFailuresError is returned by all methods after the max number of subscribe failures
+// is exceeded (see 'SetMaxSubscribeFailures'). It matches 'ErrMaxSubscribeFailuresExceeded' and unwraps
+// to the last connect error, so it matches 'ErrSubscribe' and the error of the subscribe handler too
+type SubscribeFailuresError struct {
+	Failures int
+	// Err is the error of the last attempt
+	Err error
+}
+
+func (e *SubscribeFailuresError) Error() string {
+	return fmt.Sprintf("%s: %d subscribe attempts failed, last error: %s", ErrMaxSubscribeFailuresExceeded, e.Failures, e.Err)
+}
+
+func (e *SubscribeFailuresError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SubscribeFailuresError) Is(target error) bool {
+	return target == ErrMaxSubscribeFailuresExceeded
+}
+
+// SetMaxSubscribeFailures sets a max number of subscribe handler failures since the last successful
+// connect. After that the instance is in a terminal state like after 'SetMaxReconnectAttempts' is exceeded:
+// all reads and writes return '*SubscribeFailuresError'. It stops reconnects when the server accepts
+// connections, but always rejects the subscription (for example, because of bad credentials).
+// 0 means no limit. After 'Dial' call it does nothing
+func (r *ReConn) SetMaxSubscribeFailures(n int) *ReConn {
+	r.configure(func() {
+		r.maxSubscribeFailures = n
+	})
+	return r
+}
+
+// SetMaxReconnectAttempts sets a max number of consecutive failed connect attempts, including the first
+// dial. After that the instance is in a terminal state: all reads and writes return '*MaxReconnectsError'.
+// The counter is reset after a successful connect. 0 means no limit. After 'Dial' call it does nothing
+func (r *ReConn) SetMaxReconnectAttempts(n int) *ReConn {
+	r.configure(func() {
+		r.maxReconnectAttempts = n
+	})
+	return r
+}
+
+// SetRetryConnect makes every connect, including the first dial, retry failed attempts until a connection
+// is established, the max number of attempts is exceeded (see 'SetMaxReconnectAttempts') or 'Close' is called.
+// So, 'Dial', 'ReadMessage' and 'WriteMessage' block during an outage. The lock isn't held during
+// the waits between attempts. After 'Dial' call it does nothing
+func (r *ReConn) SetRetryConnect(enabled bool) *ReConn {
+	r.configure(func() {
+		r.retryConnect = enabled
+	})
+	return r
+}
+
+// terminalState allows to store errors of different types in 'r.terminalErr'
+type terminalState struct {
+	err error
+}
+
+// terminalError returns '*MaxReconnectsError', '*SubscribeFailuresError' or '*PermanentDialError' if the instance is in the terminal state
+func (r *ReConn) terminalError() error {
+	// 'Redial' resets the terminal state by storing an empty state
+	state, _ := r.terminalErr.Load().(terminalState)
+	return state.err
+}
+
+// enterTerminalState must be called under 'r.mu'
+func (r *ReConn) enterTerminalState(err error) {
+	r.terminalErr.Store(terminalState{err: err})
+	r.log.Error(err.Error())
+	// The summary must contain the terminal error
+	r.stats.setError(err)
+	r.finishLifetime()
+}
+
+// countConnectFailure must be called under 'r.mu' after a failed connect attempt. It returns
+// a non-nil error when the instance enters the terminal state
+func (r *ReConn) countConnectFailure(err error) error {
+	if PhaseOf(err) == PhaseUnknown {
+		// Not a connect failure: for example, 'Close' was called or the context is done
+		return nil
+	}
+	var permanentErr *PermanentDialError
+	if errors.As(err, &permanentErr) {
+		r.enterTerminalState(permanentErr)
+		return permanentErr
+	}
+
+	r.connectFailures++
+	if PhaseOf(err) == PhaseSubscribe && r.maxSubscribeFailures > 0 {
+		// The counter was updated by 'trackSubscribeFailure'
+		if failures := int(atomic.LoadInt32(&r.subscribeFailures)); failures >= r.maxSubscribeFailures {
+			terminalErr := &SubscribeFailuresError{Failures: failures, Err: err}
+			r.enterTerminalState(terminalErr)
+			return terminalErr
+		}
+	}
+	if r.maxReconnectAttempts <= 0 || r.connectFailures < r.maxReconnectAttempts {
+		return nil
+	}
+
+	terminalErr := &MaxReconnectsError{Attempts: r.connectFailures, Err: err}
+	r.enterTerminalState(terminalErr)
+	return terminalErr
+}
@@ -0,0 +1,251 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMaxReconnectAttempts(t *testing.T) {
+	var accept int32 = 1
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&accept) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetMaxReconnectAttempts(3)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	atomic.StoreInt32(&accept, 0)
+	conn.ReadMessage()
+
+	// The connection is dropped, 2 failed attempts
+	for i := 0; i < 2; i++ {
+		if _, _, err := conn.ReadMessage(); errors.Is(err, ErrMaxReconnectsExceeded) {
+			t.Fatalf("unexpected terminal error after %d attempts", i+1)
+		}
+	}
+
+	// A successful connect resets the counter
+	atomic.StoreInt32(&accept, 1)
+	conn.ReadMessage()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	atomic.StoreInt32(&accept, 0)
+
+	var err error
+	for i := 0; i < 3; i++ {
+		_, _, err = conn.ReadMessage()
+	}
+	if !errors.Is(err, ErrMaxReconnectsExceeded) || !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrMaxReconnectsExceeded wrapping ErrDial, got: %v", err)
+	}
+
+	// The state is terminal
+	atomic.StoreInt32(&accept, 1)
+	_, _, err = conn.ReadMessage()
+	var maxErr *MaxReconnectsError
+	if !errors.As(err, &maxErr) || maxErr.Attempts != 3 {
+		t.Fatalf("expected *MaxReconnectsError with 3 attempts, got: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, nil); !errors.Is(err, ErrMaxReconnectsExceeded) {
+		t.Errorf("expected ErrMaxReconnectsExceeded on write, got: %v", err)
+	}
+}
+
+func TestRunManagedMaxReconnectAttempts(t *testing.T) {
+	conn := New().SetURL("ws://127.0.0.1:1").SetMaxReconnectAttempts(2)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.RunManaged(context.Background(), ManagedOptions{
+			Handler: func(int, []byte) error { return nil },
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrMaxReconnectsExceeded) {
+			t.Errorf("expected ErrMaxReconnectsExceeded, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunManaged didn't return")
+	}
+}
+
+func TestRetryConnect(t *testing.T) {
+	var dials int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&dials, 1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetReconnectTimeout(10 * time.Millisecond).SetRetryConnect(true)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if n := atomic.LoadInt32(&dials); n != 4 {
+		t.Errorf("expected 4 dials, got %d", n)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Errorf("unexpected message: %q, error: %v", data, err)
+	}
+}
+
+func TestRetryConnectLimitAndClose(t *testing.T) {
+	t.Run("max attempts", func(t *testing.T) {
+		conn := New().
+			SetURL("ws://127.0.0.1:1").
+			SetReconnectTimeout(time.Millisecond).
+			SetRetryConnect(true).
+			SetMaxReconnectAttempts(3)
+		defer conn.Close()
+
+		var maxErr *MaxReconnectsError
+		if err := conn.Dial(); !errors.As(err, &maxErr) || maxErr.Attempts != 3 {
+			t.Fatalf("expected *MaxReconnectsError with 3 attempts, got: %v", err)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		conn := New().SetURL("ws://127.0.0.1:1").SetReconnectTimeout(time.Hour).SetRetryConnect(true)
+
+		dialErr := make(chan error, 1)
+		go func() {
+			dialErr <- conn.Dial()
+		}()
+
+		// Wait for the first failure
+		for conn.State() != StateDisconnected {
+			time.Sleep(time.Millisecond)
+		}
+
+		// The lock isn't held during the wait
+		getterDone := make(chan struct{})
+		go func() {
+			conn.GetDialBody()
+			close(getterDone)
+		}()
+		select {
+		case <-getterDone:
+		case <-time.After(time.Second):
+			t.Fatal("the lock is held during the wait")
+		}
+
+		conn.Close()
+		select {
+		case err := <-dialErr:
+			if err != ErrConnClosed {
+				t.Errorf("expected ErrConnClosed, got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Close didn't interrupt the retries")
+		}
+	})
+}
+
+type subscribeFailureCounter struct {
+	NoopMetricsCollector
+	failures int32
+}
+
+func (c *subscribeFailureCounter) SubscribeFailure(error) {
+	atomic.AddInt32(&c.failures, 1)
+}
+
+func TestMaxSubscribeFailures(t *testing.T) {
+	var (
+		dialer    FakeDialer
+		metrics   subscribeFailureCounter
+		callbacks = make(chan int, 10)
+		subscribe int32
+		credsErr  = errors.New("bad credentials")
+	)
+	conn := New().SetDialFunc(dialer.Dial).SetReconnectTimeout(time.Millisecond).SetMaxSubscribeFailures(3).
+		SetMetricsCollector(&metrics).
+		SetOnSubscribeFailed(func(failures int, err error) {
+			callbacks <- failures
+		}).
+		SetSubscribeHandler(func(WsConnection) error {
+			// Only the first subscription succeeds
+			if atomic.AddInt32(&subscribe, 1) > 1 {
+				return credsErr
+			}
+			return nil
+		})
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	dialer.Last().Fail(errors.New("broken pipe"))
+
+	var err error
+	for i := 0; i < 10 && !errors.Is(err, ErrMaxSubscribeFailuresExceeded); i++ {
+		_, _, err = conn.ReadMessage()
+	}
+	if !errors.Is(err, ErrMaxSubscribeFailuresExceeded) || !errors.Is(err, ErrSubscribe) || !errors.Is(err, credsErr) {
+		t.Fatalf("expected the terminal error with the handler error, got %v", err)
+	}
+	if n := dialer.Dials(); n != 4 {
+		t.Errorf("expected 4 dials, got %d", n)
+	}
+
+	// The instance doesn't reconnect anymore
+	_, _, err = conn.ReadMessage()
+	if !errors.Is(err, ErrMaxSubscribeFailuresExceeded) || CodeOf(err) != CodeMaxSubscribeFailuresExceeded {
+		t.Errorf("expected the terminal error, got %v", err)
+	}
+	if n := dialer.Dials(); n != 4 {
+		t.Errorf("expected 4 dials, got %d", n)
+	}
+
+	if n := atomic.LoadInt32(&metrics.failures); n != 3 {
+		t.Errorf("expected 3 reported subscribe failures, got %d", n)
+	}
+	for i := 1; i <= 3; i++ {
+		select {
+		case failures := <-callbacks:
+			if failures != i {
+				t.Errorf("expected %d failures, got %d", i, failures)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("callback must be called")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package reconnect
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Backoff describes exponential delays between connect attempts
+type Backoff struct {
+	// Initial is a delay after the first failure
+	Initial time.Duration
+	// Max is a max delay
+	Max time.Duration
+	// Factor is a multiplier of the delay after every consecutive failure
+	Factor float64
+	// Jitter randomizes the second half of every delay
+	Jitter bool
+}
+
+func (b Backoff) enabled() bool {
+	return b.Initial > 0
+}
+
+// delay returns a delay after 'failures' consecutive failures
+func (b Backoff) delay(failures int) time.Duration {
+	factor := b.Factor
+	if factor < 1 {
+		factor = 1
+	}
+
+	delay := float64(b.Initial) * math.Pow(factor, float64(failures-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		return b.Max
+	}
+	if delay > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(delay)
+}
+
+// SetBackoff makes delays between consecutive failed connect attempts grow from 'initial' to 'max'
+// by 'factor'. If 'jitter' is true, the second half of every delay is random. The delay is reset when
+// a message is read or written on an established connection. A connection lost before that doesn't
+// reset it, so the next connect is delayed as well. It overrides 'SetReconnectTimeout', which
+// is a fixed delay. After 'Dial' call it does nothing
+func (r *ReConn) SetBackoff(initial, max time.Duration, factor float64, jitter bool) *ReConn {
+	r.configure(func() {
+		r.backoff = Backoff{
+			Initial: initial,
+			Max:     max,
+			Factor:  factor,
+			Jitter:  jitter,
+		}
+	})
+	return r
+}
+
+// scheduleAfterFailure must be called under 'r.mu' after a failed connect attempt
+func (r *ReConn) scheduleAfterFailure() {
+	if !r.backoff.enabled() {
+		r.scheduleReconnect(ScheduleAfterFailure, r.loadConfig().ReconnectTimeout, 0)
+		return
+	}
+
+	failures := atomic.AddInt32(&r.backoffFailures, 1)
+	r.scheduleBackoff(int(failures))
+}
+
+// scheduleAfterLostConn must be called under 'r.mu' before a lost connection is closed
+func (r *ReConn) scheduleAfterLostConn() {
+	if !r.backoff.enabled() {
+		return
+	}
+	if failures := atomic.LoadInt32(&r.backoffFailures); failures > 0 {
+		// The connection wasn't proven by a message
+		r.scheduleBackoff(int(failures))
+	}
+}
+
+func (r *ReConn) scheduleBackoff(failures int) {
+	delay := r.backoff.delay(failures)
+	if !r.backoff.Jitter {
+		r.scheduleReconnect(ScheduleAfterFailure, delay, 0)
+		return
+	}
+
+	base := delay / 2
+	r.scheduleReconnect(ScheduleAfterFailure, base, randomDuration(delay-base))
+}
+
+// resetBackoff is called after a message is read or written
+func (r *ReConn) resetBackoff() {
+	if atomic.LoadInt32(&r.backoffFailures) != 0 {
+		atomic.StoreInt32(&r.backoffFailures, 0)
+	}
+}
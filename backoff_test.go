@@ -0,0 +1,98 @@
+package reconnect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Factor: 2}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	}
+	for _, tt := range tests {
+		if got := b.delay(tt.failures); got != tt.want {
+			t.Errorf("failures: %d, got %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+
+	// A factor less than 1 means a fixed delay
+	b = Backoff{Initial: 100 * time.Millisecond, Factor: 0.5}
+	if got := b.delay(10); got != 100*time.Millisecond {
+		t.Errorf("got %s, want 100ms", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var accept int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&accept) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetBackoff(5*time.Millisecond, 20*time.Millisecond, 2, false)
+	if err := conn.Dial(); err == nil {
+		t.Fatal("'Dial' must fail")
+	}
+	defer conn.Close()
+
+	checkLastWait := func(want time.Duration) {
+		t.Helper()
+
+		decisions := conn.ExplainLastSchedule()
+		if got := decisions[len(decisions)-1].Wait; got != want {
+			t.Errorf("got wait %s, want %s", got, want)
+		}
+	}
+
+	checkLastWait(5 * time.Millisecond)
+	for _, want := range []time.Duration{10, 20, 20} {
+		conn.ReadMessage()
+		checkLastWait(want * time.Millisecond)
+	}
+
+	// A read message resets the backoff
+	atomic.StoreInt32(&accept, 1)
+	conn.ReadMessage()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	atomic.StoreInt32(&accept, 0)
+	// The first read fails because the server closed the connection, the reconnect fails
+	conn.ReadMessage()
+	checkLastWait(5 * time.Millisecond)
+}
+
+func TestBackoffJitter(t *testing.T) {
+	conn := New().SetURL("ws://127.0.0.1:1").SetBackoff(100*time.Millisecond, time.Second, 2, true)
+	conn.scheduleBackoff(2)
+	d := conn.ExplainLastSchedule()[0]
+	if d.BaseDelay != 100*time.Millisecond || d.Jitter < 0 || d.Jitter >= 100*time.Millisecond {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+}
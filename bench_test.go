@@ -0,0 +1,296 @@
+package reconnect
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// perfTestsEnv enables tests that check the performance contract of the hot paths
+const perfTestsEnv = "RECONNECT_PERF_TESTS"
+
+// 100 instances, 1k messages per instance
+const (
+	benchInstances = 100
+	benchMessages  = 1000
+)
+
+func BenchmarkReadMessage(b *testing.B) {
+	conns := newStaticReConns(benchInstances, bytes.Repeat([]byte("a"), 512))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, conn := range conns {
+			for j := 0; j < benchMessages; j++ {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkReadMessageHandle(b *testing.B) {
+	conns := newStaticReConns(benchInstances, bytes.Repeat([]byte("a"), 512))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, conn := range conns {
+			for j := 0; j < benchMessages; j++ {
+				msg, err := conn.ReadMessageHandle()
+				if err != nil {
+					b.Fatal(err)
+				}
+				msg.Release()
+			}
+		}
+	}
+}
+
+func BenchmarkPlainReadMessage(b *testing.B) {
+	conn := newStaticReConns(1, bytes.Repeat([]byte("a"), 512))[0]
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlainWriteMessage(b *testing.B) {
+	conn := newStaticReConns(1, nil)[0]
+	data := bytes.Repeat([]byte("a"), 512)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := conn.WriteMessage(1, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEcho compares the default read path with tuned buffers and 'ReadMessageHandle'
+// on a local echo server
+func BenchmarkEcho(b *testing.B) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	data := bytes.Repeat([]byte("a"), 32<<10)
+
+	b.Run("default", func(b *testing.B) {
+		conn := New().SetURL(wsURL(server))
+		if err := conn.Dial(); err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+				b.Fatal(err)
+			}
+			if _, _, err := conn.ReadMessage(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("tuned", func(b *testing.B) {
+		conn := New().SetURL(wsURL(server)).
+			SetReadBufferSize(64 << 10).
+			SetWriteBufferSize(64 << 10).
+			SetWriteBufferPool(&sync.Pool{})
+		if err := conn.Dial(); err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+				b.Fatal(err)
+			}
+			msg, err := conn.ReadMessageHandle()
+			if err != nil {
+				b.Fatal(err)
+			}
+			msg.Release()
+		}
+	})
+}
+
+func TestPlainReadAllocs(t *testing.T) {
+	if os.Getenv(perfTestsEnv) == "" {
+		t.Skipf("set %s to run performance tests", perfTestsEnv)
+	}
+
+	conn := newStaticReConns(1, bytes.Repeat([]byte("a"), 512))[0]
+
+	// One allocation is made by the fake connection itself
+	allocs := testing.AllocsPerRun(1000, func() {
+		conn.ReadMessage()
+	})
+	if allocs > 2 {
+		t.Errorf("plain read must make at most 2 allocations, got %.1f", allocs)
+	}
+}
+
+func BenchmarkStats(b *testing.B) {
+	conn := newStaticReConns(1, nil)[0]
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		conn.Stats()
+	}
+}
+
+// identityTransform is the cheapest middleware: it only adds the indirection
+func identityTransform(messageType int, data []byte) (int, []byte, error) {
+	return messageType, data, nil
+}
+
+func BenchmarkPlainReadMessageTransform(b *testing.B) {
+	conn := newStaticReConns(1, bytes.Repeat([]byte("a"), 512))[0]
+	conn.readTransform = identityTransform
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlainWriteMessageTransform(b *testing.B) {
+	conn := newStaticReConns(1, nil)[0]
+	conn.writeTransform = identityTransform
+	data := bytes.Repeat([]byte("a"), 512)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := conn.WriteMessage(1, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkListen measures the pump: messages are read by the goroutine of 'Listen'
+// and received from the channel
+func BenchmarkListen(b *testing.B) {
+	conn := newStaticReConns(1, bytes.Repeat([]byte("a"), 512))[0]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, _ := conn.Listen(ctx)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := <-messages; !ok {
+			b.Fatal("channel was closed")
+		}
+	}
+}
+
+func BenchmarkPayloadHistogramAdd(b *testing.B) {
+	h := &payloadHistogram{}
+
+	for i := 0; i < b.N; i++ {
+		h.Add(i & 0xFFFF)
+	}
+}
+
+func BenchmarkLatencyHistogramAdd(b *testing.B) {
+	h := &latencyHistogram{}
+
+	for i := 0; i < b.N; i++ {
+		h.Add(WriteSourceUser, time.Duration(i&0xFFFF)*time.Microsecond)
+	}
+}
+
+func BenchmarkPlainWriteMessageLatency(b *testing.B) {
+	conn := newStaticReConns(1, nil)[0]
+	conn.writeLatencies = &latencyHistogram{}
+	data := bytes.Repeat([]byte("a"), 512)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := conn.WriteMessage(1, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlainReadMessageTap(b *testing.B) {
+	conn := newStaticReConns(1, bytes.Repeat([]byte("a"), 512))[0]
+	ch, cancel := conn.Tap(0)
+	defer cancel()
+	go drain(ch)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchJSON is a typical market data message
+var benchJSON = []byte(`{"stream":"btcusdt@trade","data":{"e":"trade","E":1672515782136,"s":"BTCUSDT","t":12345,"p":"16500.12","q":"0.015","m":true}}`)
+
+type benchJSONMessage struct {
+	Stream string `json:"stream"`
+	Data   struct {
+		Event    string `json:"e"`
+		Time     int64  `json:"E"`
+		Symbol   string `json:"s"`
+		TradeID  int64  `json:"t"`
+		Price    string `json:"p"`
+		Quantity string `json:"q"`
+		Maker    bool   `json:"m"`
+	} `json:"data"`
+}
+
+func BenchmarkPlainReadJSON(b *testing.B) {
+	conn := newStaticReConns(1, benchJSON)[0]
+
+	var msg benchJSONMessage
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := conn.ReadJSON(&msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPlainReadJSONRaw uses the 'ReadMessage' path: the sequence extractor needs raw messages
+func BenchmarkPlainReadJSONRaw(b *testing.B) {
+	conn := newStaticReConns(1, benchJSON)[0]
+	conn.sequenceExtractor = func(int, []byte) (uint64, bool) { return 0, false }
+
+	var msg benchJSONMessage
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := conn.ReadJSON(&msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
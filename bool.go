@@ -11,13 +11,21 @@ func newAtomicBool() *atomicBool {
 }
 
 func (b *atomicBool) Set(value bool) {
-	var v int32 = 0
-	if value {
-		v = 1
-	}
-	atomic.StoreInt32(&b.value, v)
+	atomic.StoreInt32(&b.value, boolToInt32(value))
 }
 
 func (b *atomicBool) Get() bool {
 	return atomic.LoadInt32(&b.value) != 0
 }
+
+// CompareAndSwap sets the value to 'new' if the current value is 'old'. It reports whether the value was set
+func (b *atomicBool) CompareAndSwap(old, new bool) bool {
+	return atomic.CompareAndSwapInt32(&b.value, boolToInt32(old), boolToInt32(new))
+}
+
+func boolToInt32(value bool) int32 {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -24,3 +24,21 @@ func TestAtomicBool(t *testing.T) {
 		t.Error("'atomicBool.Get' must be false")
 	}
 }
+
+func TestAtomicBoolCompareAndSwap(t *testing.T) {
+	atomicBool := newAtomicBool()
+
+	if !atomicBool.CompareAndSwap(false, true) {
+		t.Error("'atomicBool.CompareAndSwap' must succeed")
+	}
+	if atomicBool.Get() != true {
+		t.Error("'atomicBool.Get' must be true")
+	}
+
+	if atomicBool.CompareAndSwap(false, true) {
+		t.Error("'atomicBool.CompareAndSwap' must fail")
+	}
+	if atomicBool.Get() != true {
+		t.Error("'atomicBool.Get' must be true")
+	}
+}
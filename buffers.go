@@ -0,0 +1,37 @@
+package reconnect
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// SetReadBufferSize sets the size of the read buffer of every connection. The adaptive read buffer
+// (see 'SetAdaptiveReadBuffer') takes precedence. 0 means the default size of gorilla/websocket.
+// Note that the buffer size doesn't limit the message size. To avoid copies of messages on the hot
+// path use 'ReadMessageHandle'. It's used only by the default dialer (see 'SetDialFunc').
+// After 'Dial' call it does nothing
+func (r *ReConn) SetReadBufferSize(n int) *ReConn {
+	r.configure(func() {
+		r.connReadBufferSize = n
+	})
+	return r
+}
+
+// SetWriteBufferSize sets the size of the write buffer of every connection. 0 means the default size
+// of gorilla/websocket. It's used only by the default dialer (see 'SetDialFunc'). After 'Dial' call
+// it does nothing
+func (r *ReConn) SetWriteBufferSize(n int) *ReConn {
+	r.configure(func() {
+		r.connWriteBufferSize = n
+	})
+	return r
+}
+
+// SetWriteBufferPool sets a pool of write buffers shared by all connections, so buffers are reused
+// across reconnects. Connections take a buffer only for the duration of a write. It's used only
+// by the default dialer (see 'SetDialFunc'). After 'Dial' call it does nothing
+func (r *ReConn) SetWriteBufferPool(pool websocket.BufferPool) *ReConn {
+	r.configure(func() {
+		r.connWriteBufferPool = pool
+	})
+	return r
+}
@@ -0,0 +1,22 @@
+package reconnect
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConnBuffers(t *testing.T) {
+	pool := &sync.Pool{}
+	conn := New().SetReadBufferSize(1 << 16).SetWriteBufferSize(1 << 15).SetWriteBufferPool(pool)
+
+	dialer := conn.newDialer(conn.loadConfig())
+	if dialer.ReadBufferSize != 1<<16 || dialer.WriteBufferSize != 1<<15 || dialer.WriteBufferPool != pool {
+		t.Errorf("unexpected dialer buffers: read %d, write %d, pool %v", dialer.ReadBufferSize, dialer.WriteBufferSize, dialer.WriteBufferPool)
+	}
+
+	// The adaptive read buffer takes precedence
+	conn = New().SetReadBufferSize(1<<16).SetAdaptiveReadBuffer(1024, 4096)
+	if dialer := conn.newDialer(conn.loadConfig()); dialer.ReadBufferSize != 1024 {
+		t.Errorf("expected the adaptive read buffer size, got %d", dialer.ReadBufferSize)
+	}
+}
@@ -0,0 +1,203 @@
+package reconnect
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// OnConnectFunc is called after every successful connect
+	OnConnectFunc func()
+	// OnDisconnectFunc is called when an established connection is lost. 'err' is the read or write
+	// error that caused the reconnect
+	OnDisconnectFunc func(err error)
+	// OnReconnectFailedFunc is called after every failed reconnect attempt. 'attempt' is the number
+	// of consecutive failed attempts
+	OnReconnectFailedFunc func(attempt int, err error)
+	// OnSubscribeFailedFunc is called after every failure of the subscribe handler. 'failures' is the number
+	// of subscribe failures since the last successful connect
+	OnSubscribeFailedFunc func(failures int, err error)
+	// OnAttemptFunc is called after every completed connect attempt, successful or not
+	OnAttemptFunc func(attempt AttemptInfo)
+)
+
+// SetOnConnect sets a callback called after every successful connect (including the first one).
+// All connection callbacks are called one by one in a separate goroutine without holding internal
+// locks, so they can use 'ReConn'. After 'Dial' call it does nothing.
+//
+// Callbacks are called in the order of events, and read methods don't return messages of a connection
+// until all callbacks queued before its connect have returned. So the order is guaranteed: 'OnDisconnect'
+// of the lost connection, 'OnReconnectFailed' of failed attempts, 'OnConnect' and only then the first
+// message of the new connection. The subscribe handler of the new connection returns before 'OnConnect'
+// is called, but it can run concurrently with the previous callbacks. Messages read from the lost connection
+// before it was dropped can be returned before 'OnDisconnect'. Callbacks must not wait for read methods,
+// because read methods wait for callbacks
+func (r *ReConn) SetOnConnect(f OnConnectFunc) *ReConn {
+	r.configure(func() {
+		r.onConnect = f
+	})
+	return r
+}
+
+// SetOnDisconnect sets a callback called when an established connection is lost. It isn't called
+// on 'Close'. See 'SetOnConnect' for details. After 'Dial' call it does nothing
+func (r *ReConn) SetOnDisconnect(f OnDisconnectFunc) *ReConn {
+	r.configure(func() {
+		r.onDisconnect = f
+	})
+	return r
+}
+
+// SetOnReconnectFailed sets a callback called after every failed reconnect attempt. A failure
+// of the first dial is returned by 'Dial' instead. See 'SetOnConnect' for details. After 'Dial'
+// call it does nothing
+func (r *ReConn) SetOnReconnectFailed(f OnReconnectFailedFunc) *ReConn {
+	r.configure(func() {
+		r.onReconnectFailed = f
+	})
+	return r
+}
+
+// SetOnSubscribeFailed sets a callback called after every failure of the subscribe handler, including
+// the first dial. So failed subscriptions can be told apart from network problems. See 'SetOnConnect'
+// for details. After 'Dial' call it does nothing
+func (r *ReConn) SetOnSubscribeFailed(f OnSubscribeFailedFunc) *ReConn {
+	r.configure(func() {
+		r.onSubscribeFailed = f
+	})
+	return r
+}
+
+// SetOnAttempt sets a callback called after every completed connect attempt, including the first dial
+// and attempts of 'DialAsync'. 'attempt' is the same as the one returned by 'LastAttempt', and the handshake
+// response of the attempt is already available via 'GetDialBody' and 'GetDialResponse'. See 'SetOnConnect'
+// for details. After 'Dial' call it does nothing
+func (r *ReConn) SetOnAttempt(f OnAttemptFunc) *ReConn {
+	r.configure(func() {
+		r.onAttempt = f
+	})
+	return r
+}
+
+// callbackQueue calls queued callbacks one by one. A goroutine is started only when there
+// are pending callbacks
+type callbackQueue struct {
+	mu      sync.Mutex
+	queue   []func()
+	running bool
+	// pushed and called are numbers of queued and called callbacks
+	pushed uint64
+	called uint64
+	// progress is closed after a callback is called. It's nil if nobody waits
+	progress chan struct{}
+}
+
+// Push queues a callback. It doesn't block, so it can be called under 'r.mu'
+func (q *callbackQueue) Push(f func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.queue = append(q.queue, f)
+	q.pushed++
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *callbackQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.queue) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		f := q.queue[0]
+		q.queue[0] = nil
+		q.queue = q.queue[1:]
+		q.mu.Unlock()
+
+		f()
+
+		q.mu.Lock()
+		q.called++
+		if q.progress != nil {
+			close(q.progress)
+			q.progress = nil
+		}
+		q.mu.Unlock()
+	}
+}
+
+// Pushed returns the number of queued callbacks
+func (q *callbackQueue) Pushed() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.pushed
+}
+
+// WaitCalled waits until 'n' callbacks are called or 'cancel' is closed
+func (q *callbackQueue) WaitCalled(n uint64, cancel <-chan struct{}) {
+	for {
+		q.mu.Lock()
+		if q.called >= n {
+			q.mu.Unlock()
+			return
+		}
+		if q.progress == nil {
+			q.progress = make(chan struct{})
+		}
+		progress := q.progress
+		q.mu.Unlock()
+
+		select {
+		case <-progress:
+		case <-cancel:
+			return
+		}
+	}
+}
+
+// setCallbackBarrier must be called under 'r.mu' after a successful connect: messages of the new
+// connection are returned only after the callbacks queued so far (see 'waitCallbacks')
+func (r *ReConn) setCallbackBarrier() {
+	atomic.StoreUint64(&r.callbackBarrier, r.callbacks.Pushed())
+}
+
+// waitCallbacks must be called by read methods before a read message is returned and without holding 'r.mu'
+func (r *ReConn) waitCallbacks() {
+	if n := atomic.LoadUint64(&r.callbackBarrier); n > 0 {
+		r.callbacks.WaitCalled(n, r.closeSignal())
+	}
+}
+
+// notifyConnect must be called under 'r.mu' after a successful connect
+func (r *ReConn) notifyConnect() {
+	if f := r.onConnect; f != nil {
+		r.callbacks.Push(func() { f() })
+	}
+}
+
+// notifyDisconnect must be called under 'r.mu' after an established connection is dropped
+func (r *ReConn) notifyDisconnect(err error) {
+	if f := r.onDisconnect; f != nil {
+		r.callbacks.Push(func() { f(err) })
+	}
+}
+
+// notifyReconnectFailed must be called under 'r.mu' after a failed connect attempt
+func (r *ReConn) notifyReconnectFailed(err error) {
+	if f := r.onReconnectFailed; f != nil && r.generation > 0 {
+		attempt := r.connectFailures
+		r.callbacks.Push(func() { f(attempt, err) })
+	}
+}
+
+// notifySubscribeFailed must be called under 'r.mu' after a failure of the subscribe handler
+func (r *ReConn) notifySubscribeFailed(failures int, err error) {
+	if f := r.onSubscribeFailed; f != nil {
+		r.callbacks.Push(func() { f(failures, err) })
+	}
+}
@@ -0,0 +1,193 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConnectionCallbacks(t *testing.T) {
+	var (
+		connections int32
+		received    = make(chan string, 4)
+		upgrader    = websocket.Upgrader{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		if n == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if _, data, err := conn.ReadMessage(); err == nil {
+			received <- string(data)
+		}
+		if n == 1 {
+			// Drop the first connection
+			return
+		}
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	var (
+		conn    *ReConn
+		events  = make(chan string, 16)
+		running int32
+	)
+	serialized := func() func() {
+		if atomic.AddInt32(&running, 1) != 1 {
+			t.Error("callbacks must not be called concurrently")
+		}
+		return func() { atomic.AddInt32(&running, -1) }
+	}
+	conn = New().SetURL(wsURL(server)).
+		SetOnConnect(func() {
+			defer serialized()()
+
+			// The callback must be able to use the connection
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+				t.Errorf("unexpected write error: %s", err)
+			}
+			events <- "connect"
+		}).
+		SetOnDisconnect(func(err error) {
+			defer serialized()()
+
+			if err == nil {
+				t.Error("disconnect error must be set")
+			}
+			events <- "disconnect"
+		}).
+		SetOnReconnectFailed(func(attempt int, err error) {
+			defer serialized()()
+
+			if err == nil {
+				t.Error("reconnect error must be set")
+			}
+			events <- fmt.Sprintf("failed:%d", attempt)
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	expect := func(want string) {
+		t.Helper()
+
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("expected event %q, got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected event %q", want)
+		}
+	}
+
+	expect("connect")
+	if data := <-received; data != "hello" {
+		t.Fatalf("unexpected message: %q", data)
+	}
+
+	// The connection is dropped, the first reconnect fails
+	conn.ReadMessage()
+	expect("disconnect")
+	expect("failed:1")
+
+	conn.ReadMessage()
+	expect("connect")
+	if data := <-received; data != "hello" {
+		t.Fatalf("unexpected message: %q", data)
+	}
+}
+
+func TestCallbackOrdering(t *testing.T) {
+	const cycles = 3
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	// Slow callbacks would be overtaken by messages without the ordering guarantee
+	slowRecord := func(event string) {
+		time.Sleep(10 * time.Millisecond)
+		record(event)
+	}
+
+	var (
+		dialer     FakeDialer
+		attempts   int
+		subscribes int32
+	)
+	conn := New().SetReconnectTimeout(time.Millisecond).
+		SetDialFunc(func(url string, header http.Header) (WsConnection, *http.Response, error) {
+			attempts++
+			if attempts%2 == 0 {
+				// Every reconnect fails once
+				return nil, nil, errors.New("dial failed")
+			}
+			c, resp, err := dialer.Dial(url, header)
+			if err == nil {
+				// The message is available right after the connect
+				c.(*FakeConn).Push(websocket.TextMessage, []byte(strconv.Itoa(dialer.Dials())))
+			}
+			return c, resp, err
+		}).
+		SetSubscribeHandler(func(WsConnection) error {
+			atomic.AddInt32(&subscribes, 1)
+			return nil
+		}).
+		SetOnConnect(func() { slowRecord(fmt.Sprintf("connect:%d", atomic.LoadInt32(&subscribes))) }).
+		SetOnDisconnect(func(error) { slowRecord("disconnect") }).
+		SetOnReconnectFailed(func(attempt int, _ error) { slowRecord(fmt.Sprintf("failed:%d", attempt)) })
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for received := 0; received < cycles; {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			continue
+		}
+		record("message:" + string(data))
+		received++
+		if received < cycles {
+			dialer.Last().Fail(errors.New("connection reset"))
+		}
+	}
+
+	var want []string
+	for i := 1; i <= cycles; i++ {
+		if i > 1 {
+			want = append(want, "disconnect", "failed:1")
+		}
+		// 'OnConnect' is called after the subscribe handler of its connection
+		want = append(want, "connect:"+strconv.Itoa(i), "message:"+strconv.Itoa(i))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("unexpected order of events:\ngot:  %v\nwant: %v", events, want)
+	}
+}
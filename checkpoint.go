@@ -0,0 +1,95 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// SequenceExtractor returns a sequence number of a message. 'ok' is false for messages without it
+	SequenceExtractor func(messageType int, data []byte) (seq uint64, ok bool)
+	// CheckpointBuilder builds a checkpoint message for the last processed sequence number
+	CheckpointBuilder func(lastSeq uint64) (messageType int, data []byte)
+)
+
+// SetSequenceExtractor sets a function that extracts sequence numbers of messages returned to the caller.
+// The last one is used by the checkpoint sender. After 'Dial' call it does nothing
+func (r *ReConn) SetSequenceExtractor(extractor SequenceExtractor) *ReConn {
+	r.configure(func() {
+		r.sequenceExtractor = extractor
+	})
+	return r
+}
+
+// LastSequence returns the last sequence number returned by the sequence extractor.
+// 'ok' is false if there were no sequence numbers yet
+func (r *ReConn) LastSequence() (seq uint64, ok bool) {
+	if !r.seqKnown.Get() {
+		return 0, false
+	}
+	return atomic.LoadUint64(&r.lastSeq), true
+}
+
+// SetCheckpointSender enables periodic checkpoints: every 'interval' a message built from the last
+// sequence number (see 'SetSequenceExtractor') is written while connected. Checkpoints are skipped during
+// reconnects, and the latest one is written right after a reconnect before other user messages
+// (see egress.go). Nothing is sent before the first sequence number. After 'Dial' call it does nothing
+func (r *ReConn) SetCheckpointSender(interval time.Duration, build CheckpointBuilder) *ReConn {
+	r.configure(func() {
+		r.checkpointInterval = interval
+		r.checkpointBuilder = build
+	})
+	return r
+}
+
+// startCheckpointSender must be called once after 'r.dialed' is set
+func (r *ReConn) startCheckpointSender() {
+	if r.checkpointInterval <= 0 || r.checkpointBuilder == nil {
+		return
+	}
+	r.scheduler.Every(r.checkpointInterval, r.sendCheckpoint)
+}
+
+// sendCheckpoint is run by the scheduler. The write is bounded by the interval to not stall other tasks
+func (r *ReConn) sendCheckpoint() {
+	messageType, data, ok := r.buildCheckpoint()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.checkpointInterval)
+	defer cancel()
+
+	switch err := r.writeMessage(ctx, WriteSourceHeartbeat, messageType, data); err {
+	case nil, ErrNotConnected:
+		// The checkpoint will be sent after the reconnect
+	default:
+		r.log.Error(fmt.Sprintf("couldn't send checkpoint: %s", err))
+	}
+}
+
+// buildCheckpoint returns a checkpoint message for the last sequence number
+func (r *ReConn) buildCheckpoint() (messageType int, data []byte, ok bool) {
+	if r.checkpointBuilder == nil {
+		return 0, nil, false
+	}
+	seq, ok := r.LastSequence()
+	if !ok {
+		return 0, nil, false
+	}
+	messageType, data = r.checkpointBuilder(seq)
+	return messageType, data, true
+}
+
+// trackSequence is called for every message returned to the caller
+func (r *ReConn) trackSequence(messageType int, data []byte) {
+	if r.sequenceExtractor == nil {
+		return
+	}
+	if seq, ok := r.sequenceExtractor(messageType, data); ok {
+		atomic.StoreUint64(&r.lastSeq, seq)
+		r.seqKnown.Set(true)
+	}
+}
@@ -0,0 +1,96 @@
+package reconnect
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCheckpointSender(t *testing.T) {
+	var connections int32
+	received := make(chan string, 100)
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			conn.WriteMessage(websocket.TextMessage, []byte("seq:1"))
+			conn.WriteMessage(websocket.TextMessage, []byte("seq:2"))
+			// Drop the connection after the first checkpoint
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil || string(data) == "ack:2" {
+					return
+				}
+			}
+		}
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(data)
+		}
+	})
+	defer server.Close()
+
+	conn := New().
+		SetURL(wsURL(server)).
+		SetSubscribeHandler(func(conn WsConnection) error {
+			return conn.WriteMessage(websocket.TextMessage, []byte("sub"))
+		}).
+		SetSequenceExtractor(func(_ int, data []byte) (uint64, bool) {
+			if !strings.HasPrefix(string(data), "seq:") {
+				return 0, false
+			}
+			seq, err := strconv.ParseUint(strings.TrimPrefix(string(data), "seq:"), 10, 64)
+			return seq, err == nil
+		}).
+		SetCheckpointSender(20*time.Millisecond, func(lastSeq uint64) (int, []byte) {
+			return websocket.TextMessage, []byte("ack:" + strconv.FormatUint(lastSeq, 10))
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if seq, ok := conn.LastSequence(); !ok || seq != 2 {
+		t.Fatalf("unexpected last sequence: %d, %t", seq, ok)
+	}
+
+	// The server drops the connection after the periodic checkpoint
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("user")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	next := func() string {
+		select {
+		case msg := <-received:
+			return msg
+		case <-time.After(time.Second):
+			t.Fatal("no messages")
+			return ""
+		}
+	}
+	for _, want := range []string{"sub", "ack:2"} {
+		if got := next(); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	// Periodic checkpoints can be sent before the user message
+	for msg := next(); msg != "user"; msg = next() {
+		if msg != "ack:2" {
+			t.Fatalf("unexpected message %q", msg)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package reconnect
+
+import "time"
+
+// Clock is a source of time for delays between connect attempts and for periodic work (keepalive,
+// watchdogs, checkpoints and etc.). Timeouts of network operations always use the real time.
+// It can be replaced in tests to control time (see 'SetClock')
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a channel that receives the time after 'd' and a function to stop the timer
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
+// SetClock sets a clock for delays between connect attempts and for periodic work. It's intended
+// for tests: a fake clock makes reconnect delays deterministic. If 'c' is nil, the real clock is used.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetClock(c Clock) *ReConn {
+	r.configure(func() {
+		if c == nil {
+			c = realClock{}
+		}
+		r.clock = c
+		r.scheduler.clock = c
+		r.nextReconnectTime = c.Now()
+	})
+	return r
+}
@@ -0,0 +1,172 @@
+package reconnect
+
+// Clone creates a new instance with the configuration of 'r': url, headers, timeouts, handlers, callbacks,
+// logger, TLS config, dial settings, backoff, limits and etc. Settings changed by 'UpdateConfig' are copied
+// as well. The new instance isn't dialed, even if 'r' is. Headers, urls, other lists and the TLS config are
+// copied, so instances don't share them. The write rate limiter and histograms are created anew. The write gate
+// is passed only if it implements 'CloneableWriteGate', the clone gets a new gate. Other objects
+// (the cookie jar, the dial limiter, the metrics collector and etc.) are shared. Runtime state (the connection,
+// subscriptions, buffered messages, stats) isn't copied. A clone of an instance created by 'NewWithOptions'
+// is immutable too. It's safe to call 'Clone' concurrently with reads and writes of 'r'
+func (r *ReConn) Clone() *ReConn {
+	// Setters and the first 'Dial' call hold 'setupMu'. After 'Dial' the copied fields
+	// are read-only, except the ones changed by 'UpdateConfig' that are loaded atomically
+	r.setupMu.Lock()
+	defer r.setupMu.Unlock()
+
+	cfg := r.loadConfig()
+
+	c := New()
+	c.immutable = r.immutable
+
+	c.url = cfg.URL
+	c.header = cfg.Header.Clone()
+	c.urls = append([]string(nil), r.urls...)
+	c.urlFunc = r.urlFunc
+	c.headerFunc = r.headerFunc
+	c.origin = r.origin
+	c.originFromURL = r.originFromURL
+
+	c.handshakeTimeout = cfg.HandshakeTimeout
+	c.reconnectTimeout = cfg.ReconnectTimeout
+	c.subscribeTimeout = r.subscribeTimeout
+	c.connectTimeout = r.connectTimeout
+	c.initialDialDelay = r.initialDialDelay
+	c.readTimeout = r.readTimeout
+	c.writeTimeout = r.writeTimeout
+
+	c.log = r.log
+	c.clock = r.clock
+	c.scheduler.clock = r.clock
+	c.metrics = r.metrics
+	c.debugRelease = r.debugRelease
+	c.strictConfig = r.strictConfig
+	c.dropTracing = r.dropTracing
+
+	c.pingHandler = cfg.PingHandler
+	c.pongHandler = r.pongHandler
+	c.closeHandler = r.closeHandler
+	c.subscribeHandler = cfg.SubscribeHandler
+	c.subscribeHandlerContext = r.subscribeHandlerContext
+	c.resumeStateFunc = r.resumeStateFunc
+	c.resumeDetector = r.resumeDetector
+	c.resumeDetectorUsesFirstMessage = r.resumeDetectorUsesFirstMessage
+
+	c.onConnect = r.onConnect
+	c.onDisconnect = r.onDisconnect
+	c.onReconnectFailed = r.onReconnectFailed
+	c.onSubscribeFailed = r.onSubscribeFailed
+	c.onAttempt = r.onAttempt
+	c.onPressureChange = r.onPressureChange
+	c.onClosed = r.onClosed
+	c.pressureLevels = append([]float64(nil), r.pressureLevels...)
+
+	c.dialBodySink = r.dialBodySink
+	c.maxDialBodySize = r.maxDialBodySize
+	c.skipDialBody = r.skipDialBody
+	c.dialResponseHook = r.dialResponseHook
+	c.responsePropagation = r.responsePropagation
+	c.dialFunc = r.dialFunc
+	c.dialLimiter = r.dialLimiter
+	c.leaderElection = r.leaderElection
+
+	c.tlsConfig = r.tlsConfig.Clone()
+	c.proxy = r.proxy
+	c.subprotocols = append([]string(nil), r.subprotocols...)
+	c.cookieJar = r.cookieJar
+	c.netDial = r.netDial
+	c.connReadBufferSize = r.connReadBufferSize
+	c.connWriteBufferSize = r.connWriteBufferSize
+	c.connWriteBufferPool = r.connWriteBufferPool
+	c.dialerConfigurator = r.dialerConfigurator
+	c.enableCompression = r.enableCompression
+	c.compressionLevel = r.compressionLevel
+	c.compressionLevelSet = r.compressionLevelSet
+	c.maxMessageSize = r.maxMessageSize
+	c.adaptiveReadBufferMin = r.adaptiveReadBufferMin
+	c.adaptiveReadBufferMax = r.adaptiveReadBufferMax
+	c.maxLineSize = r.maxLineSize
+	c.deliverControlFrames = r.deliverControlFrames
+
+	c.flapThreshold = r.flapThreshold
+	c.flapMaxCycles = r.flapMaxCycles
+	c.onFlapping = r.onFlapping
+	c.autoResponders = append([]AutoResponder(nil), r.autoResponders...)
+	c.firstMessageTimeout = r.firstMessageTimeout
+	if gate, ok := r.writeGate.(CloneableWriteGate); ok {
+		c.writeGate = gate.Clone()
+	}
+	c.preCloseHook = r.preCloseHook
+	c.preCloseTimeout = r.preCloseTimeout
+	c.suspendThreshold = r.suspendThreshold
+	c.suspendProbeTimeout = r.suspendProbeTimeout
+	c.onResumeDetected = r.onResumeDetected
+	c.keepAliveInterval = r.keepAliveInterval
+	c.keepAlivePongWait = r.keepAlivePongWait
+	if r.farewell != nil {
+		farewell := *r.farewell
+		farewell.payload = append([]byte(nil), farewell.payload...)
+		c.farewell = &farewell
+	}
+	if r.farewellAck != nil {
+		c.farewellAck = &farewellAck{
+			match:   r.farewellAck.match,
+			timeout: r.farewellAck.timeout,
+			waiting: newAtomicBool(),
+			done:    make(chan struct{}),
+		}
+	}
+	c.emptyMessagePolicy = r.emptyMessagePolicy
+	c.retryReadAfterReconnect = r.retryReadAfterReconnect
+	c.postUpgradeGrace = r.postUpgradeGrace
+	c.postUpgradeRejectHandler = r.postUpgradeRejectHandler
+	c.readerLivenessTimeout = r.readerLivenessTimeout
+	c.readerLivenessClose = r.readerLivenessClose
+	c.onReaderStalled = r.onReaderStalled
+	c.readInactivityTimeout = r.readInactivityTimeout
+	c.failoverTokenExtractor = r.failoverTokenExtractor
+	c.failoverTokenHeader = r.failoverTokenHeader
+
+	if r.writeRateLimiter != nil {
+		c.writeRateLimiter = newRateLimiter(r.writeRateLimiter.interval, int(r.writeRateLimiter.burst))
+	}
+	c.writeRateNonBlocking = r.writeRateNonBlocking
+	c.writeDuringReconnect = r.writeDuringReconnect
+	c.writeDuringReconnectTimeout = r.writeDuringReconnectTimeout
+	c.readTransform = r.readTransform
+	c.writeTransform = r.writeTransform
+	c.strictCloseOrdering = r.strictCloseOrdering
+
+	c.backoff = r.backoff
+	c.maxReconnectAttempts = r.maxReconnectAttempts
+	c.maxSubscribeFailures = r.maxSubscribeFailures
+	c.retryConnect = r.retryConnect
+	if r.permanentDialStatusCodes != nil {
+		c.permanentDialStatusCodes = make(map[int]bool, len(r.permanentDialStatusCodes))
+		for code, permanent := range r.permanentDialStatusCodes {
+			c.permanentDialStatusCodes[code] = permanent
+		}
+	}
+	c.retryPolicy = r.retryPolicy
+	c.maxReconnectDelay = r.maxReconnectDelay
+	c.stopOnNormalClose = r.stopOnNormalClose
+	c.reconnectPredicate = r.reconnectPredicate
+
+	c.sequenceExtractor = r.sequenceExtractor
+	c.checkpointInterval = r.checkpointInterval
+	c.checkpointBuilder = r.checkpointBuilder
+	c.writeBufferSize = r.writeBufferSize
+	c.queueFlushInterval = r.queueFlushInterval
+	c.queueFlushJitter = r.queueFlushJitter
+	c.readAheadSize = r.readAheadSize
+	c.memory.limit = r.memory.limit
+	c.memory.limits = r.memory.limits
+	if r.payloadSizes != nil {
+		c.payloadSizes = &payloadHistogram{}
+	}
+	if r.writeLatencies != nil {
+		c.writeLatencies = &latencyHistogram{}
+	}
+
+	return c
+}
@@ -0,0 +1,197 @@
+package reconnect
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClone(t *testing.T) {
+	var dialer FakeDialer
+	tlsConfig := &tls.Config{ServerName: "example.com"}
+	conn := New().SetDialFunc(dialer.Dial).SetURL("ws://example.com").
+		SetHeader(http.Header{"Token": {"secret"}}).
+		SetURLs("ws://a.example.com", "ws://b.example.com").
+		SetTLSConfig(tlsConfig).
+		SetHandshakeTimeout(time.Second).
+		SetBackoff(time.Millisecond, time.Second, 2, false).
+		SetWriteRateLimit(1, time.Hour, 1)
+	defer conn.Close()
+
+	clone := conn.Clone()
+	defer clone.Close()
+
+	if clone.url != conn.url || clone.handshakeTimeout != time.Second || clone.backoff != conn.backoff ||
+		clone.tlsConfig.ServerName != "example.com" {
+		t.Fatal("configuration must be copied")
+	}
+
+	// Mutable fields aren't shared
+	clone.header["Token"][0] = "changed"
+	clone.urls[0] = "changed"
+	clone.tlsConfig.ServerName = "changed"
+	if conn.header.Get("Token") != "secret" || conn.urls[0] != "ws://a.example.com" || tlsConfig.ServerName != "example.com" {
+		t.Error("changes of the clone must not affect the source")
+	}
+	if clone.writeRateLimiter == conn.writeRateLimiter {
+		t.Error("rate limiter must not be shared")
+	}
+
+	// Both instances can be dialed
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := clone.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The clone of a dialed instance isn't dialed and has the updated settings
+	conn.UpdateConfig(func(c *MutableConfig) {
+		c.Header.Set("Token", "updated")
+	})
+	second := conn.Clone()
+	defer second.Close()
+
+	if second.dialed.Get() {
+		t.Fatal("clone must not be dialed")
+	}
+	if v := second.header.Get("Token"); v != "updated" {
+		t.Errorf("expected the updated header, got %q", v)
+	}
+	second.SetReconnectTimeout(time.Minute)
+	if second.reconnectTimeout != time.Minute {
+		t.Error("clone must be configurable")
+	}
+	if err := second.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := dialer.Dials(); n != 3 {
+		t.Errorf("expected 3 dials, got %d", n)
+	}
+}
+
+func TestCloneWriteGate(t *testing.T) {
+	var srcDialer, cloneDialer FakeDialer
+	src := New().SetDialFunc(srcDialer.Dial).SetWriteGate(NewAfterFirstMessageGate(true))
+	defer src.Close()
+
+	clone := src.Clone().SetDialFunc(cloneDialer.Dial)
+	defer clone.Close()
+
+	if clone.writeGate == nil || clone.writeGate == src.writeGate {
+		t.Fatal("clone must get a new gate")
+	}
+	if err := src.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := clone.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A message read by the source doesn't open the gate of the clone
+	srcDialer.Last().Push(websocket.TextMessage, []byte("msg"))
+	if _, _, err := src.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := src.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := clone.WriteMessage(websocket.TextMessage, []byte("msg")); err != ErrWriteGateClosed {
+		t.Fatalf("expected ErrWriteGateClosed, got %v", err)
+	}
+
+	// 'Close' of the source doesn't close the gate of the clone
+	src.Close()
+	cloneDialer.Last().Push(websocket.TextMessage, []byte("msg"))
+	if _, _, err := clone.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := clone.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// Gates that can't be cloned aren't passed
+	custom := New().SetWriteGate(struct{ WriteGate }{NewAfterFirstMessageGate(true)})
+	defer custom.Close()
+	customClone := custom.Clone()
+	defer customClone.Close()
+	if customClone.writeGate != nil {
+		t.Error("gate that can't be cloned must not be shared")
+	}
+}
+
+func TestCloneImmutable(t *testing.T) {
+	conn, err := NewWithOptions("ws://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	clone := conn.Clone()
+	defer clone.Close()
+
+	clone.SetURL("ws://other.example.com")
+	if clone.url != "ws://example.com" {
+		t.Error("clone of an immutable instance must be immutable")
+	}
+}
+
+func TestCloneConcurrent(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetHeader(http.Header{"Token": {"secret"}})
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		stop = make(chan struct{})
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for {
+			if _, _, err := conn.ReadMessage(); err == ErrConnClosed {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			conn.WriteMessage(websocket.TextMessage, []byte("msg"))
+			dialer.Last().Push(websocket.TextMessage, []byte("msg"))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			conn.UpdateConfig(func(c *MutableConfig) {
+				c.Header.Set("Token", "updated")
+			})
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		clone := conn.Clone()
+		if clone.dialed.Get() || clone.header.Get("Token") == "" {
+			t.Error("clone must have the configuration, but not the state")
+		}
+		clone.Close()
+	}
+
+	close(stop)
+	conn.Close()
+	wg.Wait()
+}
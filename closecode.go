@@ -0,0 +1,77 @@
+package reconnect
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrInvalidCloseCode is returned when a close code can't be sent in a close frame
+var ErrInvalidCloseCode = newCodedError(CodeInvalidCloseCode, "invalid close code")
+
+// maxCloseReasonSize is a max size of a close reason: a control frame payload is limited
+// by 125 bytes, 2 of them are used by the code
+const maxCloseReasonSize = 123
+
+// CloseCode is a websocket close code defined in RFC 6455, section 7.4
+type CloseCode int
+
+const (
+	CloseNormalClosure           CloseCode = websocket.CloseNormalClosure
+	CloseGoingAway               CloseCode = websocket.CloseGoingAway
+	CloseProtocolError           CloseCode = websocket.CloseProtocolError
+	CloseUnsupportedData         CloseCode = websocket.CloseUnsupportedData
+	CloseNoStatusReceived        CloseCode = websocket.CloseNoStatusReceived
+	CloseAbnormalClosure         CloseCode = websocket.CloseAbnormalClosure
+	CloseInvalidFramePayloadData CloseCode = websocket.CloseInvalidFramePayloadData
+	ClosePolicyViolation         CloseCode = websocket.ClosePolicyViolation
+	CloseMessageTooBig           CloseCode = websocket.CloseMessageTooBig
+	CloseMandatoryExtension      CloseCode = websocket.CloseMandatoryExtension
+	CloseInternalServerErr       CloseCode = websocket.CloseInternalServerErr
+	CloseServiceRestart          CloseCode = websocket.CloseServiceRestart
+	CloseTryAgainLater           CloseCode = websocket.CloseTryAgainLater
+	CloseTLSHandshake            CloseCode = websocket.CloseTLSHandshake
+
+	// CloseApplicationMin and CloseApplicationMax define the range of codes for libraries,
+	// frameworks and applications
+	CloseApplicationMin CloseCode = 3000
+	CloseApplicationMax CloseCode = 4999
+	// ClosePrivateMin is the first code for private use (4000-4999)
+	ClosePrivateMin CloseCode = 4000
+)
+
+// IsReservedCloseCode reports whether a code is reserved and must not be sent in a close frame:
+// 1004-1006, 1015 and codes reserved for future use (1016-2999)
+func IsReservedCloseCode(code int) bool {
+	switch CloseCode(code) {
+	case 1004, CloseNoStatusReceived, CloseAbnormalClosure, CloseTLSHandshake:
+		return true
+	}
+	return code >= 1016 && code < int(CloseApplicationMin)
+}
+
+// ValidateCloseCode checks whether a code can be sent in a close frame
+func ValidateCloseCode(code int) error {
+	switch {
+	case code < int(CloseNormalClosure) || code > int(CloseApplicationMax):
+		return fmt.Errorf("%w: %d is outside of the legal range [1000, 4999]", ErrInvalidCloseCode, code)
+	case IsReservedCloseCode(code):
+		return fmt.Errorf("%w: %d is reserved and must not be sent", ErrInvalidCloseCode, code)
+	}
+	return nil
+}
+
+// FormatCloseMessage formats a close frame payload. Use 'ValidateCloseCode' to check the code.
+// A reason longer than 123 bytes is truncated at a rune boundary
+func FormatCloseMessage(code int, reason string) []byte {
+	if len(reason) > maxCloseReasonSize {
+		// Cut at a rune boundary: the reason must be valid UTF-8
+		n := maxCloseReasonSize
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
+	}
+	return websocket.FormatCloseMessage(code, reason)
+}
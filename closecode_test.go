@@ -0,0 +1,52 @@
+package reconnect
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestValidateCloseCode(t *testing.T) {
+	tests := []struct {
+		code  int
+		valid bool
+	}{
+		{code: 999, valid: false},
+		{code: 1000, valid: true},
+		{code: 1001, valid: true},
+		{code: 1005, valid: false},
+		{code: 1006, valid: false},
+		{code: 1008, valid: true},
+		{code: 1015, valid: false},
+		{code: 2000, valid: false},
+		{code: 3000, valid: true},
+		{code: 4001, valid: true},
+		{code: 5000, valid: false},
+	}
+	for _, tt := range tests {
+		err := ValidateCloseCode(tt.code)
+		if tt.valid && err != nil {
+			t.Errorf("%d must be valid: %s", tt.code, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidCloseCode) {
+			t.Errorf("%d must be invalid", tt.code)
+		}
+	}
+}
+
+func TestFormatCloseMessage(t *testing.T) {
+	msg := FormatCloseMessage(int(CloseNormalClosure), strings.Repeat("a", 200))
+	if len(msg) != 125 {
+		t.Errorf("close message must fit into a control frame, got %d bytes", len(msg))
+	}
+	if msg[0] != 0x03 || msg[1] != 0xE8 {
+		t.Errorf("unexpected code bytes: %x", msg[:2])
+	}
+
+	// 2-byte runes don't fit into the limit evenly
+	msg = FormatCloseMessage(int(CloseNormalClosure), strings.Repeat("я", 62))
+	if reason := msg[2:]; !utf8.Valid(reason) || len(reason) != 122 {
+		t.Errorf("reason must be cut at a rune boundary, got %d bytes", len(reason))
+	}
+}
@@ -0,0 +1,44 @@
+package reconnect
+
+// SetStrictCloseOrdering enables the guarantee that no messages are returned by 'ReadMessage'
+// and 'ReadMessageHandle' after 'Close' has returned: a message read concurrently with 'Close' is
+// either delivered before 'Close' returns or dropped with 'ErrConnClosed' (see 'DropFeatureClosed').
+// After 'Dial' call it does nothing
+func (r *ReConn) SetStrictCloseOrdering(enabled bool) *ReConn {
+	r.configure(func() {
+		r.strictCloseOrdering = enabled
+	})
+	return r
+}
+
+// beginDelivery must be called after a message is read without holding 'r.mu'. It waits for the connection
+// callbacks (see 'waitCallbacks'). If it returns false, the message must
+// be dropped. Otherwise, 'endDelivery' must be called after the message is handled
+func (r *ReConn) beginDelivery() bool {
+	r.waitCallbacks()
+	if !r.strictCloseOrdering {
+		return true
+	}
+
+	r.deliveryMu.RLock()
+	if r.closed.Get() {
+		r.deliveryMu.RUnlock()
+		return false
+	}
+	return true
+}
+
+func (r *ReConn) endDelivery() {
+	if r.strictCloseOrdering {
+		r.deliveryMu.RUnlock()
+	}
+}
+
+// waitDeliveries waits for messages that are being delivered. It must be called by 'Close'
+// after 'r.closed' is set and without holding 'r.mu': a delivery can write an auto-reply
+func (r *ReConn) waitDeliveries() {
+	if r.strictCloseOrdering {
+		r.deliveryMu.Lock()
+		r.deliveryMu.Unlock()
+	}
+}
@@ -0,0 +1,122 @@
+package reconnect
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// blockingReadConn is a 'WsConnection' which 'ReadMessage' returns a message after 'release' is closed
+type blockingReadConn struct {
+	readStarted chan struct{}
+	release     chan struct{}
+}
+
+func (c *blockingReadConn) ReadMessage() (int, []byte, error) {
+	close(c.readStarted)
+	<-c.release
+	return websocket.TextMessage, []byte("msg"), nil
+}
+
+func (c *blockingReadConn) WriteMessage(int, []byte) error { return nil }
+func (c *blockingReadConn) Close() error                   { return nil }
+
+func TestStrictCloseOrdering(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		ws := &blockingReadConn{
+			readStarted: make(chan struct{}),
+			release:     make(chan struct{}),
+		}
+		conn := New().SetStrictCloseOrdering(strict)
+		if err := conn.AdoptConnection(ws, nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		readErr := make(chan error, 1)
+		go func() {
+			_, _, err := conn.ReadMessage()
+			readErr <- err
+		}()
+		<-ws.readStarted
+
+		closeDone := make(chan struct{})
+		go func() {
+			conn.Close()
+			close(closeDone)
+		}()
+		for !conn.closed.Get() {
+			time.Sleep(time.Millisecond)
+		}
+
+		// The message is read after 'Close' was called
+		close(ws.release)
+		err := <-readErr
+		<-closeDone
+
+		dropped := conn.DroppedMessages()[DropFeatureClosed]
+		if strict {
+			if err != ErrConnClosed {
+				t.Errorf("expected ErrConnClosed, got: %v", err)
+			}
+			if dropped != 1 {
+				t.Errorf("the message must be counted as dropped, got %d", dropped)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("the message must be delivered without strict ordering, got: %s", err)
+			}
+			if dropped != 0 {
+				t.Errorf("no messages must be dropped, got %d", dropped)
+			}
+		}
+	}
+}
+
+func TestStrictCloseOrderingStress(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	for i := 0; i < 30; i++ {
+		conn := New().SetURL(wsURL(server)).SetStrictCloseOrdering(true)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		var (
+			closeReturned = newAtomicBool()
+			lateReads     int
+			wg            sync.WaitGroup
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// A read started after 'Close' returned must never get a message
+				late := closeReturned.Get()
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+				if late {
+					lateReads++
+				}
+			}
+		}()
+
+		time.Sleep(time.Duration(i%5) * time.Millisecond)
+		conn.Close()
+		closeReturned.Set(true)
+		wg.Wait()
+
+		if lateReads != 0 {
+			t.Fatalf("iteration %d: %d messages were delivered after Close", i, lateReads)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package reconnect
+
+import (
+	"context"
+
+	"github.com/gorilla/websocket"
+)
+
+// MessageType is a type of a data message. It mirrors 'MessageType' of coder/websocket
+type MessageType int
+
+const (
+	MessageText   MessageType = websocket.TextMessage
+	MessageBinary MessageType = websocket.BinaryMessage
+)
+
+// StatusCode is a close code. It mirrors 'StatusCode' of coder/websocket
+type StatusCode = CloseCode
+
+// CoderStyleConn adapts 'ReConn' to the API of coder/websocket (formerly nhooyr.io/websocket)
+// to simplify migration. Reads and writes reconnect as 'ReadMessage' and 'WriteMessage' do.
+//
+// A read or write interrupted by 'ctx' returns 'ctx.Err()'. An interrupted read leaves
+// the connection broken, so the next read reconnects
+type CoderStyleConn struct {
+	r *ReConn
+}
+
+// NewCoderStyleConn creates a new 'CoderStyleConn'. 'r' must be dialed
+func NewCoderStyleConn(r *ReConn) *CoderStyleConn {
+	return &CoderStyleConn{r: r}
+}
+
+// ReConn returns the wrapped connection
+func (c *CoderStyleConn) ReConn() *ReConn {
+	return c.r
+}
+
+// Read reads the next data message
+func (c *CoderStyleConn) Read(ctx context.Context) (MessageType, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	typ, data, err := c.r.ReadMessageContext(ctx)
+	return MessageType(typ), data, err
+}
+
+// Write writes a data message
+func (c *CoderStyleConn) Write(ctx context.Context, typ MessageType, p []byte) error {
+	return c.r.WriteMessageContext(ctx, int(typ), p)
+}
+
+// Close sends a close frame with the code and the reason and closes the connection. Unlike
+// coder/websocket, it doesn't wait for the close frame of the peer
+func (c *CoderStyleConn) Close(code StatusCode, reason string) error {
+	if err := ValidateCloseCode(int(code)); err != nil {
+		return err
+	}
+
+	// Ignore the error: the connection is closed anyway
+	_ = c.r.writeMessage(context.Background(), WriteSourceClose, websocket.CloseMessage, FormatCloseMessage(int(code), reason))
+	return c.r.Close()
+}
@@ -0,0 +1,74 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCoderStyleConn(t *testing.T) {
+	closeCodes := make(chan int, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				// Ignore connections dropped by reconnects
+				if closeErr, ok := err.(*websocket.CloseError); ok && closeErr.Code != websocket.CloseAbnormalClosure {
+					closeCodes <- closeErr.Code
+				}
+				return
+			}
+			if string(data) == "echo" {
+				conn.WriteMessage(typ, data)
+			}
+		}
+	})
+	defer server.Close()
+
+	r := New().SetURL(wsURL(server))
+	if err := r.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn := NewCoderStyleConn(r)
+
+	if err := conn.Write(context.Background(), MessageBinary, []byte("echo")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	typ, data, err := conn.Read(context.Background())
+	if err != nil || typ != MessageBinary || string(data) != "echo" {
+		t.Fatalf("unexpected read result: %d, %q, %v", typ, data, err)
+	}
+
+	// Nothing is sent, so the read must be interrupted by the context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := conn.Read(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+
+	// The interrupted connection is broken, the next read reconnects
+	if _, _, err := conn.Read(context.Background()); err == nil {
+		t.Error("read on the interrupted connection must fail")
+	}
+	if err := conn.Write(context.Background(), MessageText, []byte("echo")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, data, err := conn.Read(context.Background()); err != nil || string(data) != "echo" {
+		t.Fatalf("read after the reconnect must succeed: %q, %v", data, err)
+	}
+
+	if err := conn.Close(StatusCode(CloseNormalClosure), "bye"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	select {
+	case code := <-closeCodes:
+		if code != websocket.CloseNormalClosure {
+			t.Errorf("unexpected close code: %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Error("server must receive the close frame")
+	}
+}
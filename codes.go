@@ -0,0 +1,208 @@
+package reconnect
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrorCode is a stable machine-readable code of an error returned by the package
+type ErrorCode int
+
+const (
+	// CodeUnknown is used for nil and foreign errors
+	CodeUnknown ErrorCode = iota
+	CodeDialFailed
+	CodeSubscribeFailed
+	CodeReconnectFailed
+	CodePostUpgradeRejected
+	CodeMaxReconnectsExceeded
+	CodeConnClosed
+	CodeNotDialed
+	CodeAlreadyDialed
+	CodeNotConnected
+	// CodeConnLost is used for transport errors: a close frame, a reset connection and etc.
+	CodeConnLost
+	CodeTimeout
+	CodeCanceled
+	CodeTooLarge
+	CodeInvalidConfig
+	CodeInvalidCloseCode
+	CodeStreamingNotSupported
+	CodeWriteGateClosed
+	CodeUnconfirmed
+	CodeJSON
+	CodeQueueFull
+	CodeUnhealthy
+	CodePermanentDialFailure
+	CodeReconnectsPaused
+	CodeServerClosed
+	CodeTransform
+	CodeRateLimited
+	CodeReconnecting
+	CodeMaxSubscribeFailuresExceeded
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case CodeDialFailed:
+		return "dial_failed"
+	case CodeSubscribeFailed:
+		return "subscribe_failed"
+	case CodeReconnectFailed:
+		return "reconnect_failed"
+	case CodePostUpgradeRejected:
+		return "post_upgrade_rejected"
+	case CodeMaxReconnectsExceeded:
+		return "max_reconnects_exceeded"
+	case CodeConnClosed:
+		return "conn_closed"
+	case CodeNotDialed:
+		return "not_dialed"
+	case CodeAlreadyDialed:
+		return "already_dialed"
+	case CodeNotConnected:
+		return "not_connected"
+	case CodeConnLost:
+		return "conn_lost"
+	case CodeTimeout:
+		return "timeout"
+	case CodeCanceled:
+		return "canceled"
+	case CodeTooLarge:
+		return "too_large"
+	case CodeInvalidConfig:
+		return "invalid_config"
+	case CodeInvalidCloseCode:
+		return "invalid_close_code"
+	case CodeStreamingNotSupported:
+		return "streaming_not_supported"
+	case CodeWriteGateClosed:
+		return "write_gate_closed"
+	case CodeUnconfirmed:
+		return "unconfirmed"
+	case CodeJSON:
+		return "json"
+	case CodeQueueFull:
+		return "queue_full"
+	case CodeUnhealthy:
+		return "unhealthy"
+	case CodePermanentDialFailure:
+		return "permanent_dial_failure"
+	case CodeReconnectsPaused:
+		return "reconnects_paused"
+	case CodeServerClosed:
+		return "server_closed"
+	case CodeTransform:
+		return "transform"
+	case CodeRateLimited:
+		return "rate_limited"
+	case CodeReconnecting:
+		return "reconnecting"
+	case CodeMaxSubscribeFailuresExceeded:
+		return "max_subscribe_failures_exceeded"
+	default:
+		return "unknown"
+	}
+}
+
+// codedError is a sentinel error with a code
+type codedError struct {
+	code ErrorCode
+	msg  string
+}
+
+func newCodedError(code ErrorCode, msg string) error {
+	return &codedError{code: code, msg: msg}
+}
+
+func (e *codedError) Error() string {
+	return e.msg
+}
+
+func (e *codedError) Code() ErrorCode {
+	return e.code
+}
+
+func (e *ConnectError) Code() ErrorCode {
+	switch e.Phase {
+	case PhaseDial:
+		return CodeDialFailed
+	case PhaseSubscribe:
+		return CodeSubscribeFailed
+	case PhasePostUpgradeRejected:
+		return CodePostUpgradeRejected
+	default:
+		return CodeReconnectFailed
+	}
+}
+
+func (e *ReconnectError) Code() ErrorCode {
+	return CodeReconnectFailed
+}
+
+func (e *MaxReconnectsError) Code() ErrorCode {
+	return CodeMaxReconnectsExceeded
+}
+
+func (e *SubscribeFailuresError) Code() ErrorCode {
+	return CodeMaxSubscribeFailuresExceeded
+}
+
+func (e *PermanentDialError) Code() ErrorCode {
+	return CodePermanentDialFailure
+}
+
+func (e *ReconnectsPausedError) Code() ErrorCode {
+	return CodeReconnectsPaused
+}
+
+func (e *ServerClosedError) Code() ErrorCode {
+	return CodeServerClosed
+}
+
+func (e *TransformError) Code() ErrorCode {
+	return CodeTransform
+}
+
+// CodeOf returns the code of the first error in the chain that has one. Context errors, transport
+// errors of 'websocket.Conn' and too large messages are classified as well. It returns 'CodeUnknown'
+// for nil and other foreign errors
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return CodeUnknown
+	}
+
+	var coded interface{ Code() ErrorCode }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	case errors.Is(err, bufio.ErrTooLong), errors.Is(err, websocket.ErrReadLimit):
+		return CodeTooLarge
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, websocket.ErrCloseSent):
+		return CodeConnLost
+	}
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return CodeConnLost
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return CodeTimeout
+		}
+		return CodeConnLost
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,41 @@
+package reconnect
+
+import (
+	"compress/flate"
+	"fmt"
+)
+
+// SetEnableCompression makes every dial offer per-message compression (permessage-deflate).
+// If the server doesn't negotiate the extension, messages are sent uncompressed. It's used only
+// by the default dialer (see 'SetDialFunc'). After 'Dial' call it does nothing
+func (r *ReConn) SetEnableCompression(enabled bool) *ReConn {
+	r.configure(func() {
+		r.enableCompression = enabled
+	})
+	return r
+}
+
+// SetCompressionLevel sets the compression level of outgoing messages applied to every new connection.
+// Valid levels are in [-2, 9] (see 'compress/flate'). It takes effect only if compression is enabled
+// and negotiated. After 'Dial' call it does nothing
+func (r *ReConn) SetCompressionLevel(level int) *ReConn {
+	r.configure(func() {
+		r.compressionLevel = level
+		r.compressionLevelSet = true
+	})
+	return r
+}
+
+// applyCompressionLevel sets the compression level of a new connection. It must be called under 'r.mu'
+func (r *ReConn) applyCompressionLevel(conn WsConnection) {
+	if !r.compressionLevelSet {
+		return
+	}
+	c, ok := conn.(interface{ SetCompressionLevel(level int) error })
+	if !ok {
+		return
+	}
+	if err := c.SetCompressionLevel(r.compressionLevel); err != nil {
+		r.log.Error(fmt.Sprintf("couldn't set compression level, valid levels are [%d, %d]: %s", flate.HuffmanOnly, flate.BestCompression, err))
+	}
+}
@@ -0,0 +1,115 @@
+package reconnect
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newEchoServer starts a server that echoes messages and closes the connection after 'close' message
+func newEchoServer(upgrader websocket.Upgrader) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil || string(data) == "close" {
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	}))
+}
+
+func TestCompression(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"symbol":"BTCUSD","price":"42000.00"}`, 100))
+
+	echo := func(t *testing.T, conn *ReConn) {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !bytes.Equal(data, payload) {
+			t.Fatalf("message was corrupted: got %d bytes, want %d", len(data), len(payload))
+		}
+	}
+	negotiated := func(conn *ReConn) bool {
+		resp := conn.GetDialResponse()
+		return resp != nil && strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+	}
+
+	t.Run("negotiated", func(t *testing.T) {
+		server := newEchoServer(websocket.Upgrader{EnableCompression: true})
+		defer server.Close()
+
+		log := &recordLogger{}
+		conn := New().SetURL(wsURL(server)).SetLogger(log).SetEnableCompression(true).SetCompressionLevel(9)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		echo(t, conn)
+		if !negotiated(conn) {
+			t.Fatal("compression wasn't negotiated")
+		}
+
+		// The setting survives reconnects
+		conn.WriteMessage(websocket.TextMessage, []byte("close"))
+		if _, _, err := conn.ReadMessage(); err == nil {
+			t.Fatal("expected an error")
+		}
+		echo(t, conn)
+		if !negotiated(conn) {
+			t.Fatal("compression wasn't negotiated after reconnect")
+		}
+		if log.Contains("compression level") {
+			t.Error("valid compression level must be applied without errors")
+		}
+	})
+
+	t.Run("not supported by server", func(t *testing.T) {
+		server := newEchoServer(websocket.Upgrader{})
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetEnableCompression(true).SetCompressionLevel(9)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		echo(t, conn)
+		if negotiated(conn) {
+			t.Fatal("compression must not be negotiated")
+		}
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		server := newEchoServer(websocket.Upgrader{EnableCompression: true})
+		defer server.Close()
+
+		log := &recordLogger{}
+		conn := New().SetURL(wsURL(server)).SetLogger(log).SetEnableCompression(true).SetCompressionLevel(42)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		echo(t, conn)
+		if !log.Contains("couldn't set compression level") {
+			t.Error("invalid compression level must be logged")
+		}
+	})
+}
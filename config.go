@@ -0,0 +1,106 @@
+package reconnect
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MutableConfig contains settings that can be changed after 'Dial' with 'UpdateConfig'
+type MutableConfig struct {
+	URL              string
+	Header           http.Header
+	PingHandler      PingHandler
+	SubscribeHandler SubscribeHandler
+	HandshakeTimeout time.Duration
+	ReconnectTimeout time.Duration
+
+	// ForceReconnect makes the new config take effect immediately: the current connection
+	// is closed and the next read or write reconnects. It isn't a part of the config
+	ForceReconnect bool
+}
+
+// clone returns a copy of the config. Headers are copied deeply
+func (c *MutableConfig) clone() *MutableConfig {
+	res := *c
+	res.Header = c.Header.Clone()
+	if res.Header == nil {
+		res.Header = make(http.Header)
+	}
+	res.ForceReconnect = false
+	return &res
+}
+
+// configUpdateMu serializes 'UpdateConfig' calls of all instances. Updates are rare,
+// so a global mutex is good enough
+var configUpdateMu sync.Mutex
+
+// UpdateConfig atomically replaces the runtime-changeable settings. 'update' is called with
+// a copy of the current settings; the whole set takes effect at the next connect attempt.
+// A connect attempt never observes a mix of old and new settings. Concurrent calls are serialized.
+// Before 'Dial' call it's the same as calling the corresponding setters
+func (r *ReConn) UpdateConfig(update func(c *MutableConfig)) {
+	configUpdateMu.Lock()
+	defer configUpdateMu.Unlock()
+
+	cfg := r.loadConfig().clone()
+	update(cfg)
+
+	forceReconnect := cfg.ForceReconnect
+	cfg.ForceReconnect = false
+
+	applied := r.tryConfigure(func() {
+		r.url = cfg.URL
+		r.header = cfg.Header
+		r.pingHandler = cfg.PingHandler
+		r.subscribeHandler = cfg.SubscribeHandler
+		r.handshakeTimeout = cfg.HandshakeTimeout
+		r.reconnectTimeout = cfg.ReconnectTimeout
+	})
+	if applied {
+		return
+	}
+
+	r.config.Store(cfg)
+
+	if forceReconnect {
+		r.closeCurrentConn(ReconnectCauseConfigUpdate, "config was updated")
+	}
+}
+
+// initConfig must be called once after 'r.dialed' is set
+func (r *ReConn) initConfig() {
+	r.config.Store(r.configFromFields())
+}
+
+// loadConfig returns the current settings. Returned config must not be modified
+func (r *ReConn) loadConfig() *MutableConfig {
+	if cfg, ok := r.config.Load().(*MutableConfig); ok {
+		return cfg
+	}
+	return r.configFromFields()
+}
+
+func (r *ReConn) configFromFields() *MutableConfig {
+	return &MutableConfig{
+		URL:              r.url,
+		Header:           r.header,
+		PingHandler:      r.pingHandler,
+		SubscribeHandler: r.subscribeHandler,
+		HandshakeTimeout: r.handshakeTimeout,
+		ReconnectTimeout: r.reconnectTimeout,
+	}
+}
+
+// closeCurrentConn closes the current connection, so the next read or write reconnects
+func (r *ReConn) closeCurrentConn(cause ReconnectCause, reason string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil {
+		return
+	}
+	r.log.Info("close connection: " + reason)
+	r.setPendingCause(r.generation, cause)
+	r.conn.Close()
+}
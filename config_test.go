@@ -0,0 +1,115 @@
+package reconnect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestUpdateConfigAtomic(t *testing.T) {
+	var mixed, connects int32
+	newServer := func(token string) *httptest.Server {
+		upgrader := websocket.Upgrader{}
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Token") != token {
+				atomic.AddInt32(&mixed, 1)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			atomic.AddInt32(&connects, 1)
+			// Drop the connection, so the reader reconnects all the time
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				conn.Close()
+			}
+		}))
+	}
+	serverA, serverB := newServer("a"), newServer("b")
+	defer serverA.Close()
+	defer serverB.Close()
+
+	conn := New().SetURL(wsURL(serverA)).SetHeader(http.Header{"X-Token": {"a"}})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			conn.ReadMessage()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			url, token := wsURL(serverA), "a"
+			if i%2 == 1 {
+				url, token = wsURL(serverB), "b"
+			}
+			conn.UpdateConfig(func(c *MutableConfig) {
+				c.URL = url
+				// Modify the copy in place: the current config must not be affected
+				c.Header.Set("X-Token", token)
+			})
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	conn.Close()
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&mixed); n != 0 {
+		t.Errorf("%d attempts observed a mixed config", n)
+	}
+	if n := atomic.LoadInt32(&connects); n < 2 {
+		t.Errorf("expected reconnects, got %d connects", n)
+	}
+}
+
+func TestUpdateConfigForceReconnect(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var subscribes int32
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	conn.UpdateConfig(func(c *MutableConfig) {
+		c.SubscribeHandler = func(WsConnection) error {
+			atomic.AddInt32(&subscribes, 1)
+			return nil
+		}
+		c.ForceReconnect = true
+	})
+
+	// The connection was closed, the read reconnects with the new subscribe handler
+	conn.ReadMessage()
+	if n := atomic.LoadInt32(&subscribes); n != 1 {
+		t.Errorf("expected 1 subscribe with the new handler, got %d", n)
+	}
+}
@@ -0,0 +1,23 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+)
+
+// WriteMessageConfirmed writes a message like 'WriteMessage' and then sends a ping and waits for
+// the pong within 'ctx'. If the pong doesn't come back, 'ErrUnconfirmed' is returned: the message
+// may or may not have arrived.
+//
+// It's only a liveness heuristic, not an application-level ack: the pong proves that the server
+// has read the frames up to the ping, not that the message was processed. Pongs are handled by
+// read methods, so somebody must read from the connection
+func (r *ReConn) WriteMessageConfirmed(ctx context.Context, messageType int, data []byte) error {
+	if err := r.WriteMessageContext(ctx, messageType, data); err != nil {
+		return err
+	}
+	if err := r.ping(ctx); err != nil {
+		return fmt.Errorf("%w: %s", ErrUnconfirmed, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWriteMessageConfirmed(t *testing.T) {
+	tests := []struct {
+		name        string
+		answerPings bool
+		wantErr     error
+	}{
+		{name: "confirmed", answerPings: true, wantErr: nil},
+		{name: "unconfirmed", answerPings: false, wantErr: ErrUnconfirmed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := newWsServer(func(conn *websocket.Conn) {
+				go func() {
+					// Unblock the client reader
+					<-release
+					conn.Close()
+				}()
+				if !tt.answerPings {
+					// Don't read, so pings aren't answered
+					<-release
+					return
+				}
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			})
+			defer server.Close()
+
+			conn := New().SetURL(wsURL(server))
+			if err := conn.Dial(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer func() {
+				close(release)
+				conn.Close()
+			}()
+
+			go func() {
+				for !conn.closed.Get() {
+					conn.ReadMessage()
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			err := conn.WriteMessageConfirmed(ctx, websocket.TextMessage, []byte("cancel order"))
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && CodeOf(err) != CodeUnconfirmed {
+				t.Errorf("unexpected code: %s", CodeOf(err))
+			}
+		})
+	}
+}
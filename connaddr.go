@@ -0,0 +1,39 @@
+package reconnect
+
+import (
+	"net"
+)
+
+// RemoteAddr returns the remote address of the current connection. It returns nil if there's no
+// connection or the connection doesn't report its address (see 'SetDialFunc')
+func (r *ReConn) RemoteAddr() net.Addr {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c, ok := r.conn.(interface{ RemoteAddr() net.Addr }); ok {
+		return c.RemoteAddr()
+	}
+	return nil
+}
+
+// LocalAddr returns the local address of the current connection. It returns nil if there's no
+// connection or the connection doesn't report its address (see 'SetDialFunc')
+func (r *ReConn) LocalAddr() net.Addr {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c, ok := r.conn.(interface{ LocalAddr() net.Addr }); ok {
+		return c.LocalAddr()
+	}
+	return nil
+}
+
+// ConnectionGeneration returns the number of the current connection: it's incremented after every
+// successful connect, including 'AdoptConnection'. It returns 0 before the first connect. The value
+// isn't reset after a lost connection, so it can be used to correlate messages with a connection
+func (r *ReConn) ConnectionGeneration() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.connGeneration
+}
@@ -0,0 +1,62 @@
+package reconnect
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConnAddr(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		// Drop the first connection after a message
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if conn.RemoteAddr() != nil || conn.LocalAddr() != nil || conn.ConnectionGeneration() != 0 {
+		t.Error("no addresses and generation are expected before 'Dial'")
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if addr := conn.RemoteAddr(); addr == nil || addr.String() != server.Listener.Addr().String() {
+		t.Errorf("unexpected remote address: %v", addr)
+	}
+	if conn.LocalAddr() == nil {
+		t.Error("local address is expected")
+	}
+	if gen := conn.ConnectionGeneration(); gen != 1 {
+		t.Errorf("expected generation 1, got %d", gen)
+	}
+
+	// Reconnect
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("read must fail")
+	}
+	if gen := conn.ConnectionGeneration(); gen != 2 {
+		t.Errorf("expected generation 2, got %d", gen)
+	}
+}
+
+func TestConnAddrCustomConn(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// 'FakeConn' doesn't report addresses
+	if conn.RemoteAddr() != nil || conn.LocalAddr() != nil {
+		t.Error("nil addresses are expected")
+	}
+	if gen := conn.ConnectionGeneration(); gen != 1 {
+		t.Errorf("expected generation 1, got %d", gen)
+	}
+}
@@ -0,0 +1,99 @@
+package reconnect
+
+import (
+	"sync"
+)
+
+// DisconnectReason describes why a connection was torn down
+type DisconnectReason struct {
+	// Closed is true if the connection was closed by 'Close'
+	Closed bool
+	// Err is the error that caused the reconnect. It's nil if 'Closed' is true
+	Err error
+	// Cause classifies 'Err' (see 'ReconnectTrend'). It's empty if 'Closed' is true or the connection
+	// setup failed
+	Cause ReconnectCause
+}
+
+// connCleanups contains cleanups registered for the current connection (see 'OnThisConnectionClosed')
+type connCleanups struct {
+	mu sync.Mutex
+	// active is true from the start of a connection setup until the connection is torn down
+	active bool
+	list   []func(DisconnectReason)
+	// due contains cleanups of torn down connections that weren't called yet
+	due        []func()
+	lastReason DisconnectReason
+}
+
+// Begin must be called under 'r.mu' before the subscribe handler of a new connection is called
+func (c *connCleanups) Begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active = true
+	c.list = nil
+}
+
+// End must be called under 'r.mu' when the connection is torn down. The cleanups are called by 'RunDue'
+func (c *connCleanups) End(reason DisconnectReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.active {
+		return
+	}
+	c.active = false
+	c.lastReason = reason
+	for _, f := range c.list {
+		f := f
+		c.due = append(c.due, func() { f(reason) })
+	}
+	c.list = nil
+}
+
+// Register adds a cleanup for the current connection. It reports false if there's no connection;
+// in this case the cleanup must be called immediately with the returned reason
+func (c *connCleanups) Register(f func(DisconnectReason)) (reason DisconnectReason, registered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.active {
+		return c.lastReason, false
+	}
+	c.list = append(c.list, f)
+	return DisconnectReason{}, true
+}
+
+// AddDue adds a function called by the next 'RunDue'
+func (c *connCleanups) AddDue(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.due = append(c.due, f)
+}
+
+// RunDue calls cleanups of torn down connections. It must be called without holding any locks
+func (c *connCleanups) RunDue() {
+	c.mu.Lock()
+	due := c.due
+	c.due = nil
+	c.mu.Unlock()
+
+	for _, f := range due {
+		f()
+	}
+}
+
+// OnThisConnectionClosed registers a cleanup of resources tied to the current connection (for example,
+// a server-side listen key requested by the subscribe handler). It should be called from the subscribe
+// handler or while the connection is alive. All cleanups of a connection are called once, when the connection
+// is lost (before the next dial) or closed by 'Close'. They are called without holding internal locks,
+// so they can use 'ReConn'. If there's no connection, for example, it was already torn down, the cleanup
+// is called immediately
+func (r *ReConn) OnThisConnectionClosed(cleanup func(reason DisconnectReason)) {
+	reason, registered := r.connCleanups.Register(cleanup)
+	if !registered {
+		cleanup(reason)
+	}
+}
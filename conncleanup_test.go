@@ -0,0 +1,138 @@
+package reconnect
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOnThisConnectionClosed(t *testing.T) {
+	var (
+		dialer  FakeDialer
+		mu      sync.Mutex
+		reasons []DisconnectReason
+		// dialsAtCleanup is a number of dials when a cleanup was called
+		dialsAtCleanup []int
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	conn.SetSubscribeHandler(func(WsConnection) error {
+		conn.OnThisConnectionClosed(func(reason DisconnectReason) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			reasons = append(reasons, reason)
+			dialsAtCleanup = append(dialsAtCleanup, dialer.Dials())
+		})
+		return nil
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Reconnect
+	connErr := errors.New("connection reset")
+	dialer.Last().Fail(connErr)
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+
+	conn.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(reasons) != 2 {
+		t.Fatalf("expected 2 cleanups, got %d", len(reasons))
+	}
+	if reasons[0].Closed || reasons[0].Err != connErr {
+		t.Errorf("unexpected reason of the first cleanup: %+v", reasons[0])
+	}
+	if dialsAtCleanup[0] != 1 {
+		t.Errorf("the cleanup must be called before the next dial, dials: %d", dialsAtCleanup[0])
+	}
+	if !reasons[1].Closed || reasons[1].Err != nil {
+		t.Errorf("unexpected reason of the second cleanup: %+v", reasons[1])
+	}
+}
+
+func TestOnThisConnectionClosedLateRegistration(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+
+	var reason DisconnectReason
+	conn.OnThisConnectionClosed(func(r DisconnectReason) { reason = r })
+	if reason.Err != ErrNotConnected {
+		t.Errorf("expected ErrNotConnected before dial, got: %+v", reason)
+	}
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.Close()
+
+	reason = DisconnectReason{}
+	conn.OnThisConnectionClosed(func(r DisconnectReason) { reason = r })
+	if !reason.Closed {
+		t.Errorf("the cleanup must be called immediately, got: %+v", reason)
+	}
+}
+
+func TestOnThisConnectionClosedFailedSetup(t *testing.T) {
+	var dialer FakeDialer
+	handlerErr := errors.New("subscribe failed")
+
+	var called int32
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	conn.SetSubscribeHandler(func(WsConnection) error {
+		conn.OnThisConnectionClosed(func(reason DisconnectReason) {
+			if errors.Is(reason.Err, handlerErr) {
+				atomic.AddInt32(&called, 1)
+			}
+		})
+		return handlerErr
+	})
+	defer conn.Close()
+
+	if err := conn.Dial(); !errors.Is(err, handlerErr) {
+		t.Fatalf("expected the handler error, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&called); n != 1 {
+		t.Errorf("expected 1 cleanup call, got %d", n)
+	}
+}
+
+func TestOnThisConnectionClosedRace(t *testing.T) {
+	const registrations = 100
+
+	for i := 0; i < 20; i++ {
+		var dialer FakeDialer
+		conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		var calls [registrations]int32
+		var wg sync.WaitGroup
+		for j := 0; j < registrations; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				conn.OnThisConnectionClosed(func(DisconnectReason) {
+					atomic.AddInt32(&calls[j], 1)
+				})
+			}(j)
+		}
+		conn.Close()
+		wg.Wait()
+
+		for j := range calls {
+			if n := atomic.LoadInt32(&calls[j]); n != 1 {
+				t.Fatalf("cleanup %d was called %d times", j, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SetConnectTimeout sets a max duration of a single connect attempt: name resolution, the dial,
+// the handshake and the subscribe handler. The backoff wait isn't included. When the timeout expires,
+// the attempt fails with 'ErrDial' or 'ErrSubscribe' (depending on the phase) that wraps
+// 'context.DeadlineExceeded', and the next attempt is scheduled as after any other failure.
+// A custom dial function (see 'SetDialFunc') that doesn't return in time is abandoned: a connection
+// it returns later is closed. 0 means no timeout. After 'Dial' call it does nothing
+func (r *ReConn) SetConnectTimeout(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.connectTimeout = d
+	})
+	return r
+}
+
+// connectTimeoutContext returns a context that is done when the connect timeout expires
+func (r *ReConn) connectTimeoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.connectTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.connectTimeout)
+}
+
+// connectTimeoutErr returns an error if the connect timeout has expired. 'ctx' is the context
+// returned by 'connectTimeoutContext', its parent must be checked first
+func (r *ReConn) connectTimeoutErr(ctx context.Context) error {
+	if r.connectTimeout <= 0 || r.attemptContextErr(ctx) == nil {
+		return nil
+	}
+	return fmt.Errorf("connect timeout %s: %w", r.connectTimeout, context.DeadlineExceeded)
+}
+
+// callDialFuncContext calls the custom dial function, but returns when 'ctx' is done. The call
+// isn't interrupted: the goroutine finishes when the function returns and closes the late connection
+func (r *ReConn) callDialFuncContext(ctx context.Context, url string, header http.Header) (WsConnection, *http.Response, error) {
+	type dialResult struct {
+		conn WsConnection
+		resp *http.Response
+		err  error
+	}
+
+	done := make(chan dialResult)
+	go func() {
+		conn, resp, err := r.dialFunc(url, header)
+		select {
+		case done <- dialResult{conn, resp, err}:
+		case <-ctx.Done():
+			// The attempt was abandoned
+			if err == nil {
+				conn.Close()
+			}
+			if resp != nil && resp.Body != nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	select {
+	case res := <-done:
+		return res.conn, res.resp, res.err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
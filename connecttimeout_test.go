@@ -0,0 +1,103 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConnectTimeout(t *testing.T) {
+	t.Run("handshake", func(t *testing.T) {
+		// The server accepts connections, but never responds to the handshake
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer ln.Close()
+		go func() {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer c.Close()
+			}
+		}()
+
+		conn := New().SetURL("ws://" + ln.Addr().String()).SetConnectTimeout(100 * time.Millisecond)
+		defer conn.Close()
+
+		start := time.Now()
+		err = conn.Dial()
+		if !errors.Is(err, ErrDial) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected ErrDial with context.DeadlineExceeded, got %v", err)
+		}
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("dial must be interrupted, took %s", d)
+		}
+		if decisions := conn.ExplainLastSchedule(); len(decisions) != 1 || decisions[0].Source != ScheduleAfterFailure {
+			t.Errorf("reconnect must be scheduled after failure, got %+v", decisions)
+		}
+	})
+
+	t.Run("subscribe", func(t *testing.T) {
+		server := newWsServer(func(conn *websocket.Conn) {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		})
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetConnectTimeout(100 * time.Millisecond).
+			SetSubscribeHandler(func(conn WsConnection) error {
+				// Wait for the ack that never comes
+				_, _, err := conn.ReadMessage()
+				return err
+			})
+		defer conn.Close()
+
+		if err := conn.Dial(); !errors.Is(err, ErrSubscribe) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected ErrSubscribe with context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("dial func", func(t *testing.T) {
+		var (
+			release = make(chan struct{})
+			late    = NewFakeConn()
+		)
+		conn := New().SetConnectTimeout(100 * time.Millisecond).
+			SetDialFunc(func(string, http.Header) (WsConnection, *http.Response, error) {
+				<-release
+				return late, nil, nil
+			})
+		defer conn.Close()
+
+		if err := conn.Dial(); !errors.Is(err, ErrDial) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected ErrDial with context.DeadlineExceeded, got %v", err)
+		}
+
+		// The connection returned after the deadline is closed
+		close(release)
+		done := make(chan error, 1)
+		go func() {
+			_, _, err := late.ReadMessage()
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("late connection must be closed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("late connection must be closed")
+		}
+	})
+}
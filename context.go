@@ -0,0 +1,136 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeContext returns a context that is canceled when 'parent' is done or 'Close' is called
+func (r *ReConn) closeContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	closeCh := r.closeSignal()
+	go func() {
+		select {
+		case <-closeCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// attemptContextErr returns an error if the connect attempt must be aborted
+func (r *ReConn) attemptContextErr(ctx context.Context) error {
+	if r.closed.Get() {
+		// 'Close' was called during the attempt
+		return ErrConnClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		// The dialer applies the deadline to the connection, so the dial can fail
+		// a bit earlier than the context is done
+		return fmt.Errorf("connect: %w", context.DeadlineExceeded)
+	}
+	return nil
+}
+
+// cancelHandshakeOnDone makes 'dialer' close the connection when the dial context is done during
+// the handshake: 'websocket.Dialer' applies only the context deadline to the handshake. The connection
+// is dialed with 'dialer.NetDialContext' if it's set. The returned function must be called after the dial
+func cancelHandshakeOnDone(dialer *websocket.Dialer) (stop func()) {
+	var (
+		mu       sync.Mutex
+		stopConn = func() {}
+	)
+	netDial := dialer.NetDialContext
+	if netDial == nil {
+		netDial = (&net.Dialer{}).DialContext
+	}
+	dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := netDial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		stopConn = onDone(ctx, func() {
+			conn.Close()
+		})
+		mu.Unlock()
+
+		return conn, nil
+	}
+
+	return func() {
+		mu.Lock()
+		stopConn()
+		mu.Unlock()
+	}
+}
+
+// interruptReadOnDone interrupts a blocked read of 'conn' when 'ctx' is done. The returned function
+// must be called after the read. An interrupted connection is broken, so the next read reconnects
+func interruptReadOnDone(ctx context.Context, conn WsConnection) (stop func()) {
+	c, ok := conn.(interface{ SetReadDeadline(t time.Time) error })
+	if !ok {
+		return func() {}
+	}
+	return onDone(ctx, func() {
+		_ = c.SetReadDeadline(time.Now())
+	})
+}
+
+// interruptWriteOnDone is like 'interruptReadOnDone', but for writes. The deadline of '*websocket.Conn'
+// isn't safe for concurrent use, so the deadline is set on the underlying connection if possible
+func interruptWriteOnDone(ctx context.Context, conn WsConnection) (stop func()) {
+	var target interface{} = conn
+	if c, ok := conn.(interface{ UnderlyingConn() net.Conn }); ok {
+		target = c.UnderlyingConn()
+	}
+	c, ok := target.(interface{ SetWriteDeadline(t time.Time) error })
+	if !ok {
+		return func() {}
+	}
+	return onDone(ctx, func() {
+		_ = c.SetWriteDeadline(time.Now())
+	})
+}
+
+// onDone calls 'f' if 'ctx' is done before the returned function is called. 'f' is never called
+// after the returned function returns
+func onDone(ctx context.Context, f func()) (stop func()) {
+	if ctx.Done() == nil {
+		// The context can't be canceled
+		return func() {}
+	}
+
+	var (
+		mu      sync.Mutex
+		stopped bool
+		stopCh  = make(chan struct{})
+	)
+	go func() {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			if !stopped {
+				f()
+			}
+			mu.Unlock()
+		case <-stopCh:
+		}
+	}()
+	return func() {
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		close(stopCh)
+	}
+}
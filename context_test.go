@@ -0,0 +1,136 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newHangingListener accepts connections and never completes the handshake
+func newHangingListener(t *testing.T) (url string, close func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return "ws://" + l.Addr().String(), func() { l.Close() }
+}
+
+func TestDialContextCancelsDial(t *testing.T) {
+	url, closeListener := newHangingListener(t)
+	defer closeListener()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	conn := New().SetURL(url).SetHandshakeTimeout(time.Minute)
+	defer conn.Close()
+
+	start := time.Now()
+	err := conn.DialContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context error, got: %v", err)
+	}
+	if errors.Is(err, ErrDial) {
+		t.Errorf("context error must not match ErrDial: %s", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("dial wasn't canceled: %s", d)
+	}
+}
+
+func TestDialContextReconnect(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	conn := New().SetURL(wsURL(server)).SetReconnectTimeout(time.Minute)
+	if err := conn.DialContext(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	cancel()
+
+	// The server drops the connection, and the reconnect is aborted
+	_, _, err := conn.ReadMessage()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	if errors.Is(err, ErrDial) {
+		t.Errorf("context error must not match ErrDial: %s", err)
+	}
+}
+
+func TestCloseCancelsDial(t *testing.T) {
+	url, closeListener := newHangingListener(t)
+	defer closeListener()
+
+	conn := New().SetURL(url).SetHandshakeTimeout(time.Minute)
+
+	dialErr := make(chan error, 1)
+	go func() {
+		dialErr <- conn.Dial()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(closed)
+	}()
+
+	select {
+	case err := <-dialErr:
+		if err != ErrConnClosed {
+			t.Errorf("expected ErrConnClosed, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't cancel the dial")
+	}
+	<-closed
+}
+
+func TestLockIsNotHeldDuringDial(t *testing.T) {
+	url, closeListener := newHangingListener(t)
+	defer closeListener()
+
+	conn := New().SetURL(url).SetHandshakeTimeout(time.Minute)
+	defer conn.Close()
+
+	go conn.Dial()
+
+	for conn.State() != StateConnecting {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	locked := make(chan struct{})
+	go func() {
+		conn.mu.Lock()
+		conn.mu.Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("the lock is held during the dial")
+	}
+}
@@ -0,0 +1,146 @@
+package reconnect
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxControlPayloadSize is a max size of a control frame payload
+const maxControlPayloadSize = 125
+
+// ControlWriter is implemented by connections that write control frames with a deadline (for example,
+// '*websocket.Conn'). The connection passed to the subscribe handler implements it if the dialed
+// connection does
+type ControlWriter interface {
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+}
+
+type (
+	// PongHandler is called for every pong received by read methods
+	PongHandler func(appData string) error
+	// CloseHandler is called when a read method receives a close frame, before the read error is returned.
+	// 'code' and 'text' are the code and the reason sent by the server
+	CloseHandler func(code int, text string) error
+)
+
+// SetPongHandler sets a handler of pongs. It's applied to every new connection and is called after
+// the internal handler used by 'Ping' and keepalive. The connection must support pong handlers (for
+// example, '*websocket.Conn'). After 'Dial' call it does nothing
+func (r *ReConn) SetPongHandler(f PongHandler) *ReConn {
+	r.configure(func() {
+		r.pongHandler = f
+	})
+	return r
+}
+
+// SetCloseHandler sets a handler of close frames. It's applied to every new connection. The default
+// behavior isn't changed: the close frame is echoed after the handler returns. An error returned by
+// the handler is returned by the read method instead of '*websocket.CloseError'. The connection must
+// support close handlers (for example, '*websocket.Conn'). After 'Dial' call it does nothing
+func (r *ReConn) SetCloseHandler(f CloseHandler) *ReConn {
+	r.configure(func() {
+		r.closeHandler = f
+	})
+	return r
+}
+
+// applyControlHandlers sets handlers of control frames of a new connection. Gorilla's default handlers
+// (pong replies and close echoes) are kept if no handlers are set
+func (r *ReConn) applyControlHandlers(conn WsConnection, cfg *MutableConfig) {
+	if cfg.PingHandler != nil {
+		if c, ok := conn.(interface{ SetPingHandler(h func(string) error) }); ok {
+			c.SetPingHandler(cfg.PingHandler)
+		}
+	}
+
+	if c, ok := conn.(interface{ SetPongHandler(h func(string) error) }); ok {
+		if handler := r.pongHandler; handler != nil {
+			c.SetPongHandler(func(appData string) error {
+				r.handlePong(appData)
+				return handler(appData)
+			})
+		} else {
+			c.SetPongHandler(r.handlePong)
+		}
+	}
+
+	if r.closeHandler != nil {
+		c, ok := conn.(interface {
+			CloseHandler() func(code int, text string) error
+			SetCloseHandler(h func(code int, text string) error)
+		})
+		if ok {
+			handler, defaultHandler := r.closeHandler, c.CloseHandler()
+			c.SetCloseHandler(func(code int, text string) error {
+				err := handler(code, text)
+				// Echo the close frame
+				defaultHandler(code, text)
+				return err
+			})
+		}
+	}
+}
+
+// WriteControl writes a control frame: 'websocket.PingMessage', 'websocket.PongMessage' or 'websocket.CloseMessage'.
+// The payload must not be longer than 125 bytes. It's serialized with other writes, but the write gate and
+// the write pipeline aren't applied, and the frame isn't counted by 'Stats'. Errors trigger a reconnect like
+// 'WriteMessage' errors, except errors of invalid frames. Connections that don't implement 'ControlWriter'
+// get the frame by 'WriteMessage', and the deadline is ignored
+func (r *ReConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if !r.dialed.Get() {
+		return ErrNotDialed
+	}
+	r.writeCalls.Enter()
+	defer r.writeCalls.Exit()
+
+	if err := r.terminalError(); err != nil {
+		return err
+	}
+
+	generation, err := r.writeControl(messageType, data, deadline)
+	if err == nil {
+		if messageType == websocket.CloseMessage {
+			r.closed.Set(true)
+		}
+		return nil
+	}
+	if !isValidControlFrame(messageType, data) {
+		// The error of the connection is returned as is, the frame wasn't written
+		return err
+	}
+	return r.reconnectOnError(generation, err)
+}
+
+// writeControl also returns the generation of the used connection
+func (r *ReConn) writeControl(messageType int, data []byte, deadline time.Time) (generation uint64, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil {
+		return r.generation, ErrNotConnected
+	}
+
+	r.writeMu.Lock(false)
+	defer r.writeMu.Unlock()
+
+	defer r.traceConnRegion(traceRegionWrite)()
+	return r.generation, writeControl(r.conn, messageType, data, deadline)
+}
+
+// writeControl writes a control frame with 'WriteControl' if the connection supports it
+func writeControl(conn WsConnection, messageType int, data []byte, deadline time.Time) error {
+	if c, ok := conn.(ControlWriter); ok {
+		return c.WriteControl(messageType, data, deadline)
+	}
+	return conn.WriteMessage(messageType, data)
+}
+
+func isValidControlFrame(messageType int, data []byte) bool {
+	switch messageType {
+	case websocket.CloseMessage, websocket.PingMessage, websocket.PongMessage:
+		return len(data) <= maxControlPayloadSize
+	default:
+		return false
+	}
+}
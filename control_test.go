@@ -0,0 +1,176 @@
+package reconnect
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestControlHandlers(t *testing.T) {
+	var (
+		serverGotPong  int32
+		serverGotClose = make(chan int, 1)
+		connections    int32
+	)
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			conn.ReadMessage()
+			return
+		}
+
+		conn.SetPongHandler(func(string) error {
+			atomic.StoreInt32(&serverGotPong, 1)
+			return nil
+		})
+		conn.SetCloseHandler(func(code int, text string) error {
+			serverGotClose <- code
+			return nil
+		})
+
+		conn.WriteControl(websocket.PingMessage, []byte("server ping"), time.Now().Add(time.Second))
+		conn.WriteControl(websocket.PongMessage, []byte("unsolicited"), time.Now().Add(time.Second))
+		conn.WriteMessage(websocket.TextMessage, []byte("msg"))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4001, "bye"))
+		// Wait for the echoed close frame
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var (
+		pongs      int32
+		closeCode  int32
+		closeText  atomic.Value
+		readFailed int32
+	)
+	conn := New().SetURL(wsURL(server)).
+		SetPongHandler(func(appData string) error {
+			if appData == "unsolicited" {
+				atomic.AddInt32(&pongs, 1)
+			}
+			return nil
+		}).
+		SetCloseHandler(func(code int, text string) error {
+			if atomic.LoadInt32(&readFailed) != 0 {
+				t.Error("close handler must be called before the read error is returned")
+			}
+			atomic.StoreInt32(&closeCode, int32(code))
+			closeText.Store(text)
+			return nil
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "msg" {
+		t.Fatalf("unexpected read result: %q, %v", data, err)
+	}
+	_, _, err := conn.ReadMessage()
+	atomic.StoreInt32(&readFailed, 1)
+	if err == nil {
+		t.Fatal("read must fail after the close frame")
+	}
+
+	if atomic.LoadInt32(&pongs) != 1 {
+		t.Errorf("expected 1 pong, got %d", atomic.LoadInt32(&pongs))
+	}
+	if code, text := atomic.LoadInt32(&closeCode), closeText.Load(); code != 4001 || text != "bye" {
+		t.Errorf("unexpected close frame: %d %v", code, text)
+	}
+	select {
+	case code := <-serverGotClose:
+		if code != 4001 {
+			t.Errorf("expected the echoed code 4001, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("close frame must be echoed")
+	}
+	// The default ping handler replies with pongs
+	if atomic.LoadInt32(&serverGotPong) != 1 {
+		t.Error("server must get the pong")
+	}
+}
+
+func TestWriteControl(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		// Report pings as data messages
+		conn.SetPingHandler(func(appData string) error {
+			return conn.WriteMessage(websocket.TextMessage, []byte("ping: "+appData))
+		})
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var subscribePingErr error
+	conn := New().
+		SetURL(wsURL(server)).
+		SetWriteGate(NewAfterFirstMessageGate(false)).
+		SetSubscribeHandler(func(c WsConnection) error {
+			w, ok := c.(ControlWriter)
+			if !ok {
+				return errors.New("the connection must implement 'ControlWriter'")
+			}
+			subscribePingErr = w.WriteControl(websocket.PingMessage, []byte("subscribe"), time.Now().Add(time.Second))
+			return nil
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if subscribePingErr != nil {
+		t.Fatalf("unexpected error of the subscribe handler ping: %s", subscribePingErr)
+	}
+	if err := conn.WriteControl(websocket.PingMessage, []byte("seq-1"), time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, want := range []string{"ping: subscribe", "ping: seq-1"} {
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != want {
+			t.Fatalf("got %q (%v), want %q", data, err, want)
+		}
+	}
+
+	// Invalid frames don't cause a reconnect
+	if err := conn.WriteControl(websocket.PingMessage, []byte(strings.Repeat("x", 126)), time.Time{}); err == nil {
+		t.Error("expected an error of the too large payload")
+	}
+	if err := conn.WriteControl(websocket.TextMessage, []byte("msg"), time.Time{}); err == nil {
+		t.Error("expected an error of the data message type")
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("expected 1 connection, got %d", n)
+	}
+	if s := conn.Stats(); s.MessagesWritten != 0 {
+		t.Errorf("control frames must not be counted, got %d", s.MessagesWritten)
+	}
+}
+
+func TestWriteControlReconnect(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteControl(websocket.PongMessage, []byte("1"), time.Time{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if written := dialer.Last().Written(); len(written) != 1 || written[0].Type != websocket.PongMessage {
+		t.Fatalf("unexpected written messages: %v", written)
+	}
+
+	dialer.Last().Fail(errors.New("connection reset"))
+	if err := conn.WriteControl(websocket.PongMessage, []byte("2"), time.Time{}); err == nil {
+		t.Fatal("expected an error of the broken connection")
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Errorf("expected a reconnect, got %d dials", n)
+	}
+}
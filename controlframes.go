@@ -0,0 +1,67 @@
+package reconnect
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// SetDeliverControlFrames makes 'Listen' send received pings, pongs and close frames to the message
+// channel as messages of types 'websocket.PingMessage', 'websocket.PongMessage' and 'websocket.CloseMessage'
+// with their payloads. Control frames are sent in order with data messages: a frame is sent after
+// the data messages received before it, so a close frame is the last message of its connection. Control
+// frames are still handled as usual (pongs are sent, close frames are echoed, handlers are called).
+// Read methods never return control frames. The connection must support control handlers (for example,
+// '*websocket.Conn'). With 'SetReadAheadBuffer' frames can be sent before data messages that were read
+// ahead. It's disabled by default. After 'Dial' call it does nothing
+func (r *ReConn) SetDeliverControlFrames(deliver bool) *ReConn {
+	r.configure(func() {
+		r.deliverControlFrames = deliver
+	})
+	return r
+}
+
+// applyControlFrameDelivery wraps handlers of control frames of a new connection. It must be called
+// after 'applyControlHandlers'
+func (r *ReConn) applyControlFrameDelivery(conn WsConnection) {
+	if !r.deliverControlFrames {
+		return
+	}
+
+	if c, ok := conn.(interface {
+		PingHandler() func(appData string) error
+		SetPingHandler(h func(appData string) error)
+	}); ok {
+		handler := c.PingHandler()
+		c.SetPingHandler(func(appData string) error {
+			r.queueControlFrame(websocket.PingMessage, []byte(appData))
+			return handler(appData)
+		})
+	}
+	if c, ok := conn.(interface {
+		PongHandler() func(appData string) error
+		SetPongHandler(h func(appData string) error)
+	}); ok {
+		handler := c.PongHandler()
+		c.SetPongHandler(func(appData string) error {
+			r.queueControlFrame(websocket.PongMessage, []byte(appData))
+			return handler(appData)
+		})
+	}
+	if c, ok := conn.(interface {
+		CloseHandler() func(code int, text string) error
+		SetCloseHandler(h func(code int, text string) error)
+	}); ok {
+		handler := c.CloseHandler()
+		c.SetCloseHandler(func(code int, text string) error {
+			r.queueControlFrame(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+			return handler(code, text)
+		})
+	}
+}
+
+// queueControlFrame is called by handlers of control frames. Frames are queued only while 'Listen'
+// is running, so they aren't accumulated if nobody sends them
+func (r *ReConn) queueControlFrame(messageType int, data []byte) {
+	if r.listening.Get() {
+		r.controlFrames.Push(bufferedMessage{Type: messageType, Data: data})
+	}
+}
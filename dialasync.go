@@ -0,0 +1,75 @@
+package reconnect
+
+import (
+	"context"
+)
+
+// DialAsync is like 'Dial', but it returns immediately: the first connect is made in the background.
+// Failed attempts are retried with the usual delays (see 'SetReconnectTimeout' and 'SetBackoff') until
+// a connection is established, the terminal state is reached (see 'SetMaxReconnectAttempts') or 'Close'
+// is called. Use 'WaitConnected' or 'SubscribeState' to wait for the connection.
+//
+// Reads and writes called before the first connect behave as during a reconnect: they wait for
+// the attempt in progress and make their own attempt if it fails, so they can return a connect error.
+// 'ErrAlreadyDialed' is returned if the instance was already dialed, 'ErrInvalidConfig' - if
+// the settings are invalid in strict mode
+func (r *ReConn) DialAsync() error {
+	if err := r.markDialed(r.checkConfig); err != nil {
+		return err
+	}
+	r.startFeatures()
+
+	r.goroutines.Go(func() {
+		if err := r.waitInitialDialDelay(context.Background()); err != nil {
+			return
+		}
+		r.connectInBackground(0)
+	})
+	return nil
+}
+
+// connectInBackground makes connect attempts until success, the terminal state, 'Close' or 'PauseReconnects'
+func (r *ReConn) connectInBackground(generation uint64) {
+	for {
+		// Returns nil if a concurrent read or write has already connected
+		err := r.connectOnce(generation, nil)
+		if err == nil || PhaseOf(err) == PhaseUnknown || r.terminalError() != nil {
+			return
+		}
+	}
+}
+
+// WaitConnected blocks until there's a live connection. It returns 'ctx.Err()' if the context is done
+// first, 'ErrConnClosed' if 'Close' is called and the terminal error if the instance reaches the terminal
+// state (see 'SetMaxReconnectAttempts'). It can be used after 'Dial' as well, for example, to wait for
+// a reconnect
+func (r *ReConn) WaitConnected(ctx context.Context) error {
+	if !r.dialed.Get() {
+		return ErrNotDialed
+	}
+
+	// Changes are only wake-ups: the state is checked after each of them, so dropped ones don't matter
+	changes, unsubscribe := r.SubscribeState(1)
+	defer unsubscribe()
+
+	for {
+		if r.IsConnected() {
+			return nil
+		}
+		if r.closed.Get() {
+			return ErrConnClosed
+		}
+		if err := r.terminalError(); err != nil {
+			return err
+		}
+
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return ErrConnClosed
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDialAsync(t *testing.T) {
+	var dialer FakeDialer
+	dialer.SetError(errors.New("connection refused"))
+
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetReconnectTimeout(10 * time.Millisecond)
+	if err := conn.WaitConnected(context.Background()); err != ErrNotDialed {
+		t.Errorf("expected ErrNotDialed, got %v", err)
+	}
+
+	start := time.Now()
+	if err := conn.DialAsync(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("'DialAsync' must not block, took %s", d)
+	}
+	if err := conn.DialAsync(); err != ErrAlreadyDialed {
+		t.Errorf("expected ErrAlreadyDialed, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := conn.WaitConnected(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// The background goroutine keeps retrying
+	dialer.SetError(nil)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.WaitConnected(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if dialer.Dials() != 1 {
+		t.Errorf("expected 1 dial, got %d", dialer.Dials())
+	}
+
+	dialer.Last().Push(1, []byte("msg"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "msg" {
+		t.Errorf("unexpected read result: %q, %v", data, err)
+	}
+}
+
+func TestDialAsyncOnAttempt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("invalid token"))
+	}))
+	defer server.Close()
+
+	type result struct {
+		attempt AttemptInfo
+		body    string
+	}
+	results := make(chan result, 1)
+
+	conn := New().SetURL("ws" + strings.TrimPrefix(server.URL, "http")).SetReconnectTimeout(time.Hour)
+	conn.SetOnAttempt(func(attempt AttemptInfo) {
+		results <- result{attempt: attempt, body: string(conn.GetDialBody())}
+	})
+	if err := conn.DialAsync(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// The artifacts of the failed attempt are available in the callback
+	select {
+	case res := <-results:
+		if res.attempt.StatusCode != http.StatusForbidden || res.attempt.Err == nil || res.attempt.Duration <= 0 {
+			t.Errorf("unexpected attempt: %+v", res.attempt)
+		}
+		if res.body != "invalid token" {
+			t.Errorf("unexpected body: %q", res.body)
+		}
+		if last, ok := conn.LastAttempt(); !ok || last.Time != res.attempt.Time {
+			t.Errorf("'LastAttempt' must return the same attempt, got %+v", last)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback wasn't called")
+	}
+}
+
+func TestDialAsyncClose(t *testing.T) {
+	var dialer FakeDialer
+	dialer.SetError(errors.New("connection refused"))
+
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetReconnectTimeout(time.Hour)
+	if err := conn.DialAsync(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- conn.WaitConnected(context.Background())
+	}()
+
+	conn.Close()
+
+	// The background goroutine waits for the next attempt, 'Close' must stop it
+	done := make(chan struct{})
+	go func() {
+		conn.goroutines.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background goroutine wasn't stopped")
+	}
+	if err := <-waitErr; err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed, got %v", err)
+	}
+}
+
+func TestWaitConnectedTerminalState(t *testing.T) {
+	var dialer FakeDialer
+	dialErr := errors.New("connection refused")
+	dialer.SetError(dialErr)
+
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetMaxReconnectAttempts(2)
+	if err := conn.DialAsync(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.WaitConnected(ctx); !errors.Is(err, ErrMaxReconnectsExceeded) || !errors.Is(err, dialErr) {
+		t.Errorf("expected ErrMaxReconnectsExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package reconnect
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// DialerConfigurator changes a dialer of a connect attempt
+type DialerConfigurator func(dialer *websocket.Dialer)
+
+// SetDialerConfigurator sets a function that can change any field of the dialer (buffer sizes,
+// 'WriteBufferPool' and etc.). It's called before every dial, including reconnects, with a new
+// dialer. The built-in settings ('SetHandshakeTimeout', 'SetTLSConfig', 'SetProxy', 'SetSubprotocols',
+// 'SetNetDialContext' and etc.) are applied first, so the configurator's changes win. 'Dialer.NetDialContext'
+// set by the configurator is still canceled by 'Close' and the connect timeout. It's used only
+// by the default dialer (see 'SetDialFunc'). After 'Dial' call it does nothing
+func (r *ReConn) SetDialerConfigurator(f DialerConfigurator) *ReConn {
+	r.configure(func() {
+		r.dialerConfigurator = f
+	})
+	return r
+}
@@ -0,0 +1,60 @@
+package reconnect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSetDialerConfigurator(t *testing.T) {
+	offered := make(chan []string, 2)
+	upgrader := websocket.Upgrader{Subprotocols: []string{"v1", "v2"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offered <- websocket.Subprotocols(r)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	var calls int32
+	conn := New().SetURL(wsURL(server)).SetHandshakeTimeout(time.Second).SetSubprotocols("v1").
+		SetDialerConfigurator(func(dialer *websocket.Dialer) {
+			atomic.AddInt32(&calls, 1)
+			if dialer.HandshakeTimeout != time.Second {
+				t.Errorf("built-in settings must be applied first, handshake timeout is %s", dialer.HandshakeTimeout)
+			}
+			// The configurator wins
+			dialer.Subprotocols = []string{"v2"}
+			dialer.WriteBufferPool = &sync.Pool{}
+		})
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := <-offered; len(got) != 1 || got[0] != "v2" {
+		t.Errorf("unexpected offered subprotocols: %q", got)
+	}
+
+	// The configurator is called for every reconnect
+	if err := conn.ForceReconnect(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	<-offered
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected 2 calls, got %d", n)
+	}
+	if got := conn.Subprotocol(); got != "v2" {
+		t.Errorf("unexpected subprotocol: %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package reconnect
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// DialFunc establishes a connection. It can be used to dial over unix sockets, pre-established
+// tunnels or to replace the network in tests (see 'FakeDialer' and the scriptable 'reconnecttest.Dialer').
+// 'resp' can be nil
+type DialFunc func(url string, header http.Header) (conn WsConnection, resp *http.Response, err error)
+
+// SetDialFunc replaces the default dialer (gorilla's 'websocket.Dialer') for all connect attempts.
+// The handshake timeout isn't applied to custom dial functions, and an in-flight call isn't
+// interrupted by 'Close' unless the connect timeout is set (see 'SetConnectTimeout'). After 'Dial'
+// call it does nothing
+func (r *ReConn) SetDialFunc(f DialFunc) *ReConn {
+	r.configure(func() {
+		r.dialFunc = f
+	})
+	return r
+}
+
+// callDialFunc dials with the custom dial function or with the default dialer
+func (r *ReConn) callDialFunc(ctx context.Context, dialer *websocket.Dialer, url string, header http.Header) (WsConnection, *http.Response, error) {
+	if r.dialFunc != nil {
+		if r.connectTimeout > 0 {
+			return r.callDialFuncContext(ctx, url, header)
+		}
+		return r.dialFunc(url, header)
+	}
+
+	stopCancel := cancelHandshakeOnDone(dialer)
+	conn, resp, err := dialer.DialContext(ctx, url, header)
+	stopCancel()
+
+	if err != nil {
+		// Don't return a nil '*websocket.Conn' as a non-nil interface
+		return nil, resp, err
+	}
+	return conn, resp, nil
+}
@@ -0,0 +1,74 @@
+package reconnect
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSetDialFunc(t *testing.T) {
+	var (
+		dialer  FakeDialer
+		headers []string
+	)
+	conn := New().SetURL("ws://fake").
+		SetHeader(http.Header{"X-Token": {"abc"}}).
+		SetDialFunc(func(url string, header http.Header) (WsConnection, *http.Response, error) {
+			headers = append(headers, url+" "+header.Get("X-Token"))
+			return dialer.Dial(url, header)
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("world")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if written := dialer.Last().Written(); len(written) != 1 || string(written[0].Data) != "world" {
+		t.Errorf("got unexpected written messages: %v", written)
+	}
+
+	// A broken connection is replaced
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Errorf("expected 2 dials, got %d", n)
+	}
+
+	// Failed dials
+	dialer.SetError(errors.New("no route to host"))
+	dialer.Last().Fail(errors.New("connection reset"))
+	_, _, err := conn.ReadMessage()
+	if PhaseOf(err) != PhaseDial {
+		t.Errorf("expected a dial error, got: %v", err)
+	}
+	if state := conn.State(); state != StateDisconnected {
+		t.Errorf("expected state %s, got %s", StateDisconnected, state)
+	}
+
+	// The next read reconnects
+	dialer.SetError(nil)
+	conn.ReadMessage()
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if n := dialer.Dials(); n != 3 {
+		t.Errorf("expected 3 dials, got %d", n)
+	}
+
+	for _, h := range headers {
+		if h != "ws://fake abc" {
+			t.Errorf("dial func got unexpected url and header: %q", h)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DialLimiter limits dials. It can be shared by many instances, for example, to not reconnect
+// all connections to the same server at once after its restart
+type DialLimiter interface {
+	// Acquire blocks until a dial is allowed or 'ctx' is done
+	Acquire(ctx context.Context) error
+	// Release is called after the dial (the handshake) is finished
+	Release()
+}
+
+// SetDialLimiter makes every dial wait for 'l'. The wait happens without holding the lock of the instance,
+// so reads, writes and other methods aren't blocked, and it's interrupted by 'Close'. The limiter is released
+// right after the handshake, before the subscribe handler. An error of 'Acquire' fails the attempt with
+// 'ErrDial' and 'ErrDialLimiter', so it's retried with the backoff like a dial failure. The connect timeout
+// (see 'SetConnectTimeout') starts after the limiter is acquired. After 'Dial' call it does nothing
+func (r *ReConn) SetDialLimiter(l DialLimiter) *ReConn {
+	r.configure(func() {
+		r.dialLimiter = l
+	})
+	return r
+}
+
+// acquireDialLimiter must be called without holding 'r.mu'. The returned function must be called
+// after the dial
+func (r *ReConn) acquireDialLimiter(ctx context.Context) (release func(), err error) {
+	if r.dialLimiter == nil {
+		return func() {}, nil
+	}
+	if err := r.dialLimiter.Acquire(ctx); err != nil {
+		if ctx.Err() != nil {
+			// The attempt is canceled, 'dial' returns the context error
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrDialLimiter, err)
+	}
+	return r.dialLimiter.Release, nil
+}
+
+// NewDialLimiter returns a limiter that allows up to 'maxConcurrent' dials at the same time and starts them
+// at least 'minInterval' apart. 'maxConcurrent' < 1 means no limit of concurrent dials. It can be passed
+// to 'SetDialLimiter' of many instances
+func NewDialLimiter(maxConcurrent int, minInterval time.Duration) DialLimiter {
+	l := &dialSemaphore{minInterval: minInterval}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// dialSemaphore is returned by 'NewDialLimiter'
+type dialSemaphore struct {
+	// slots is nil if the number of concurrent dials isn't limited
+	slots       chan struct{}
+	minInterval time.Duration
+
+	mu sync.Mutex
+	// next is the earliest start of the next dial
+	next time.Time
+}
+
+func (l *dialSemaphore) Acquire(ctx context.Context) error {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	d := l.reserveStart(time.Now())
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.Release()
+		return ctx.Err()
+	}
+}
+
+// reserveStart reserves a start time of a dial and returns the time to wait for it. So waiting
+// dials are started in order
+func (l *dialSemaphore) reserveStart(now time.Time) time.Duration {
+	if l.minInterval <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.minInterval)
+	return start.Sub(now)
+}
+
+func (l *dialSemaphore) Release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
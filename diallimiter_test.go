@@ -0,0 +1,131 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDialLimiter(t *testing.T) {
+	t.Run("concurrency", func(t *testing.T) {
+		var (
+			limiter = NewDialLimiter(1, 0)
+			entered = make(chan struct{})
+			release = make(chan struct{})
+			dialer  FakeDialer
+		)
+		first := New().SetDialLimiter(limiter).
+			SetDialFunc(func(url string, header http.Header) (WsConnection, *http.Response, error) {
+				close(entered)
+				<-release
+				return dialer.Dial(url, header)
+			})
+		defer first.Close()
+		go first.Dial()
+		<-entered
+
+		second := New().SetDialLimiter(limiter).SetDialFunc(dialer.Dial)
+		done := make(chan error, 1)
+		go func() {
+			done <- second.Dial()
+		}()
+
+		select {
+		case err := <-done:
+			t.Fatalf("dial must wait for the limiter, got %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		// The waiting instance isn't locked
+		if state := second.State(); state != StateConnecting {
+			t.Errorf("unexpected state: %s", state)
+		}
+
+		second.Close()
+		select {
+		case err := <-done:
+			if err != ErrConnClosed {
+				t.Errorf("expected ErrConnClosed, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("'Close' must interrupt the wait")
+		}
+
+		close(release)
+		third := New().SetDialLimiter(limiter).SetDialFunc(dialer.Dial)
+		defer third.Close()
+		if err := third.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n := dialer.Dials(); n != 2 {
+			t.Errorf("expected 2 dials, got %d", n)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		var (
+			limiter = NewDialLimiter(0, 50*time.Millisecond)
+			dialer  FakeDialer
+			wg      sync.WaitGroup
+		)
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				conn := New().SetDialLimiter(limiter).SetDialFunc(dialer.Dial)
+				defer conn.Close()
+				if err := conn.Dial(); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if d := time.Since(start); d < 100*time.Millisecond || d > time.Second {
+			t.Errorf("unexpected duration of dials: %s", d)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		limiterErr := errors.New("quota exceeded")
+		conn := New().SetDialLimiter(failingDialLimiter{err: limiterErr}).SetDialFunc((&FakeDialer{}).Dial)
+		defer conn.Close()
+
+		err := conn.Dial()
+		if !errors.Is(err, ErrDial) || !errors.Is(err, ErrDialLimiter) {
+			t.Fatalf("expected ErrDial and ErrDialLimiter, got %v", err)
+		}
+	})
+}
+
+type failingDialLimiter struct {
+	err error
+}
+
+func (l failingDialLimiter) Acquire(context.Context) error { return l.err }
+func (failingDialLimiter) Release()                        {}
+
+func TestDialSemaphore(t *testing.T) {
+	l := NewDialLimiter(2, 0)
+
+	for i := 0; i < 2; i++ {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	l.Release()
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
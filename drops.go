@@ -0,0 +1,87 @@
+package reconnect
+
+import (
+	"fmt"
+	"sync"
+)
+
+// dropPreviewSize is a max size of a payload preview logged by drop tracing
+const dropPreviewSize = 64
+
+// Names of features that can drop inbound messages. Every discarded message is counted
+// by exactly one of them
+const (
+	DropFeatureEmptyMessage  = "empty_message"
+	DropFeatureAutoResponder = "auto_responder"
+	// DropFeatureAck counts subscription acks consumed by 'SubscribeWithAck'
+	DropFeatureAck = "ack"
+	// DropFeatureFarewellAck counts farewell acks consumed by 'Close' (see 'SetFarewellAck')
+	DropFeatureFarewellAck = "farewell_ack"
+	// DropFeatureClosed counts messages read concurrently with 'Close' (see 'SetStrictCloseOrdering')
+	DropFeatureClosed = "closed"
+	// DropFeatureMemoryBudget counts messages read while waiting for subscription acks that didn't fit
+	// into the memory budget (see 'SetMemoryBudget')
+	DropFeatureMemoryBudget = "memory_budget"
+)
+
+// dropCounters counts dropped inbound messages per feature
+type dropCounters struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func (c *dropCounters) Inc(feature string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil {
+		c.counts = make(map[string]uint64)
+	}
+	c.counts[feature]++
+}
+
+func (c *dropCounters) Get(feature string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.counts[feature]
+}
+
+func (c *dropCounters) Copy() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make(map[string]uint64, len(c.counts))
+	for k, v := range c.counts {
+		res[k] = v
+	}
+	return res
+}
+
+// SetDropTracing enables a debug log line for every dropped inbound message with the feature
+// name and a payload preview. After 'Dial' call it does nothing
+func (r *ReConn) SetDropTracing(enabled bool) *ReConn {
+	r.configure(func() {
+		r.dropTracing = enabled
+	})
+	return r
+}
+
+// DroppedMessages returns the numbers of inbound messages that weren't delivered to the caller,
+// by feature (see 'DropFeature...' constants)
+func (r *ReConn) DroppedMessages() map[string]uint64 {
+	return r.drops.Copy()
+}
+
+// dropMessage must be called for every inbound message discarded by a feature
+func (r *ReConn) dropMessage(feature string, data []byte) {
+	r.drops.Inc(feature)
+
+	if r.dropTracing {
+		preview := data
+		if len(preview) > dropPreviewSize {
+			preview = preview[:dropPreviewSize]
+		}
+		r.log.Debug(fmt.Sprintf("message was dropped by %s: %q (%d bytes)", feature, preview, len(data)))
+	}
+}
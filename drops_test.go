@@ -0,0 +1,77 @@
+package reconnect
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// recordLogger is a 'Logger' that records all messages
+type recordLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *recordLogger) record(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, msg)
+}
+
+func (l *recordLogger) Debug(msg string) { l.record(msg) }
+func (l *recordLogger) Info(msg string)  { l.record(msg) }
+func (l *recordLogger) Error(msg string) { l.record(msg) }
+
+func (l *recordLogger) Contains(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, msg := range l.msgs {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDroppedMessages(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		for _, msg := range []string{"", "ping", "data"} {
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	log := &recordLogger{}
+	conn := New().SetURL(wsURL(server)).SetLogger(log).SetDropTracing(true).
+		SetEmptyMessagePolicy(DropEmptyMessages).
+		SetAutoResponder(func(messageType int, data []byte) ([]byte, int, bool) {
+			return nil, 0, string(data) == "ping"
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "data" {
+		t.Fatalf("unexpected read result: %q, %v", data, err)
+	}
+
+	// Every drop path must be counted exactly once
+	drops := conn.DroppedMessages()
+	for _, feature := range []string{DropFeatureEmptyMessage, DropFeatureAutoResponder} {
+		if drops[feature] != 1 {
+			t.Errorf("expected 1 drop by %s, got %d", feature, drops[feature])
+		}
+	}
+	if len(drops) != 2 {
+		t.Errorf("unexpected drops: %v", drops)
+	}
+
+	if !log.Contains(`dropped by auto_responder: "ping"`) {
+		t.Error("drop tracing must log dropped messages")
+	}
+}
@@ -0,0 +1,153 @@
+package reconnect
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dsnParams contains reserved query parameters of a DSN:
+//
+//	handshake_timeout   - handshake timeout, a duration ('SetHandshakeTimeout')
+//	reconnect           - reconnect timeout, a duration ('SetReconnectTimeout')
+//	initial_dial_delay  - max delay before the first dial, a duration ('SetInitialDialDelay')
+//	max_attempts        - max number of consecutive failed connects, an integer ('SetMaxReconnectAttempts')
+//	keepalive           - ping interval, a duration ('SetKeepAlive'); a pong must arrive within twice the interval
+//	compression         - per-message compression, a boolean ('SetEnableCompression')
+//
+// Durations use the 'time.ParseDuration' format and must not be negative
+var dsnParams = map[string]func(r *ReConn, value string) error{
+	"handshake_timeout": func(r *ReConn, value string) error {
+		d, err := parseDSNDuration(value)
+		if err != nil {
+			return err
+		}
+		r.SetHandshakeTimeout(d)
+		return nil
+	},
+	"reconnect": func(r *ReConn, value string) error {
+		d, err := parseDSNDuration(value)
+		if err != nil {
+			return err
+		}
+		r.SetReconnectTimeout(d)
+		return nil
+	},
+	"max_attempts": func(r *ReConn, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("value must not be negative, got %d", n)
+		}
+		r.SetMaxReconnectAttempts(n)
+		return nil
+	},
+	"keepalive": func(r *ReConn, value string) error {
+		d, err := parseDSNDuration(value)
+		if err != nil {
+			return err
+		}
+		r.SetKeepAlive(d, 2*d)
+		return nil
+	},
+	"compression": func(r *ReConn, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		r.SetEnableCompression(enabled)
+		return nil
+	},
+	"initial_dial_delay": func(r *ReConn, value string) error {
+		d, err := parseDSNDuration(value)
+		if err != nil {
+			return err
+		}
+		r.SetInitialDialDelay(d)
+		return nil
+	},
+}
+
+// ParseDSN creates a new instance configured by a connection string like
+// 'wss://host/path?handshake_timeout=5s&reconnect=2s'. See 'ParseDSNInto'
+func ParseDSN(dsn string) (*ReConn, error) {
+	r := New()
+	if err := ParseDSNInto(dsn, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParseDSNInto configures 'r' by a connection string. All query parameters are treated as options
+// (see 'dsnParams'): unknown ones result in 'ErrInvalidDSN'. They are stripped from the url that is
+// dialed. To pass query parameters to the server, call 'SetURL' after 'ParseDSNInto'.
+// Options are applied in the sorted order, so settings made after the call override them.
+// It returns 'ErrAlreadyDialed' after 'Dial' call
+func ParseDSNInto(dsn string, r *ReConn) error {
+	if r.dialed.Get() {
+		return ErrAlreadyDialed
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDSN, err)
+	}
+	if err := checkWsURL(u); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDSN, err)
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDSN, err)
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		apply, ok := dsnParams[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown parameter '%s', supported: %s", ErrInvalidDSN, key, supportedDSNParams())
+		}
+		values := query[key]
+		if len(values) != 1 {
+			return fmt.Errorf("%w: parameter '%s' must be set once", ErrInvalidDSN, key)
+		}
+		if err := apply(r, values[0]); err != nil {
+			return fmt.Errorf("%w: parameter '%s': %s", ErrInvalidDSN, key, err)
+		}
+	}
+
+	u.RawQuery = ""
+	r.SetURL(u.String())
+
+	return nil
+}
+
+func parseDSNDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must not be negative, got %s", d)
+	}
+	return d, nil
+}
+
+func supportedDSNParams() string {
+	keys := make([]string, 0, len(dsnParams))
+	for key := range dsnParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
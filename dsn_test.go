@@ -0,0 +1,138 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn string
+		//
+		url              string
+		handshakeTimeout time.Duration
+		reconnectTimeout time.Duration
+		initialDialDelay time.Duration
+		maxAttempts      int
+		//
+		wantErr string
+	}{
+		{
+			dsn: "ws://localhost:8080/path",
+			url: "ws://localhost:8080/path",
+		},
+		{
+			dsn:              "wss://host/path?handshake_timeout=5s&reconnect=2s",
+			url:              "wss://host/path",
+			handshakeTimeout: 5 * time.Second,
+			reconnectTimeout: 2 * time.Second,
+		},
+		{
+			dsn:              "wss://host?initial_dial_delay=1m30s&reconnect=0s",
+			url:              "wss://host",
+			initialDialDelay: 90 * time.Second,
+		},
+		{
+			dsn:         "wss://host/path?max_attempts=20",
+			url:         "wss://host/path",
+			maxAttempts: 20,
+		},
+		{
+			dsn:     "wss://host/path?token=abc",
+			wantErr: "invalid dsn: unknown parameter 'token', supported: compression, handshake_timeout, initial_dial_delay, keepalive, max_attempts, reconnect",
+		},
+		{
+			dsn:     "wss://host/path?max_attempts=-1",
+			wantErr: "invalid dsn: parameter 'max_attempts': value must not be negative, got -1",
+		},
+		{
+			dsn:     "wss://host/path?reconnect=2",
+			wantErr: `invalid dsn: parameter 'reconnect': time: missing unit in duration "2"`,
+		},
+		{
+			dsn:     "wss://host/path?reconnect=-1s",
+			wantErr: "invalid dsn: parameter 'reconnect': duration must not be negative, got -1s",
+		},
+		{
+			dsn:     "wss://host/path?reconnect=1s&reconnect=2s",
+			wantErr: "invalid dsn: parameter 'reconnect' must be set once",
+		},
+		{
+			dsn:     "http://host/path",
+			wantErr: "invalid dsn: scheme must be 'ws' or 'wss', got 'http'",
+		},
+		{
+			dsn:     "ws:///path",
+			wantErr: "invalid dsn: host is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			r, err := ParseDSN(tt.dsn)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if !errors.Is(err, ErrInvalidDSN) {
+					t.Errorf("error must match ErrInvalidDSN: %s", err)
+				}
+				if err.Error() != tt.wantErr {
+					t.Errorf("got error %q, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if r.url != tt.url {
+				t.Errorf("got url %q, want %q", r.url, tt.url)
+			}
+			if r.handshakeTimeout != tt.handshakeTimeout {
+				t.Errorf("got handshake timeout %s, want %s", r.handshakeTimeout, tt.handshakeTimeout)
+			}
+			if r.reconnectTimeout != tt.reconnectTimeout {
+				t.Errorf("got reconnect timeout %s, want %s", r.reconnectTimeout, tt.reconnectTimeout)
+			}
+			if r.initialDialDelay != tt.initialDialDelay {
+				t.Errorf("got initial dial delay %s, want %s", r.initialDialDelay, tt.initialDialDelay)
+			}
+			if r.maxReconnectAttempts != tt.maxAttempts {
+				t.Errorf("got max attempts %d, want %d", r.maxReconnectAttempts, tt.maxAttempts)
+			}
+		})
+	}
+}
+
+func TestParseDSNInto(t *testing.T) {
+	r := New().SetHandshakeTimeout(time.Second).SetReconnectTimeout(time.Second)
+	if err := ParseDSNInto("ws://host?reconnect=3s", r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.handshakeTimeout != time.Second {
+		t.Errorf("settings not in the dsn must be kept, got %s", r.handshakeTimeout)
+	}
+	if r.reconnectTimeout != 3*time.Second {
+		t.Errorf("got reconnect timeout %s, want 3s", r.reconnectTimeout)
+	}
+
+	if err := ParseDSNInto("ws://host?keepalive=10s", r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.keepAliveInterval != 10*time.Second || r.keepAlivePongWait != 20*time.Second {
+		t.Errorf("got keepalive %s/%s, want 10s/20s", r.keepAliveInterval, r.keepAlivePongWait)
+	}
+
+	if err := ParseDSNInto("ws://host?compression=true", r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !r.enableCompression {
+		t.Error("compression must be enabled")
+	}
+
+	r.dialed.Set(true)
+	if err := ParseDSNInto("ws://host", r); err != ErrAlreadyDialed {
+		t.Errorf("expected ErrAlreadyDialed, got: %v", err)
+	}
+}
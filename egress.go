@@ -0,0 +1,111 @@
+package reconnect
+
+// Post-connect egress order
+//
+// After every successful dial, messages are written to the new connection in a strict order:
+//
+//  1. the subscribe handler (resume payload);
+//  2. replay of registered subscriptions;
+//  3. the latest sequence checkpoint;
+//  4. flush of user messages queued during the reconnect;
+//  5. new user writes.
+//
+// Stages 1-4 run under 'r.mu' before the connection is published, so new user writes (which need
+// 'r.mu' for reading) can't get ahead of them.
+
+// egressStage is a source of post-connect messages
+type egressStage int
+
+const (
+	egressSubscribe egressStage = iota
+	egressReplay
+	egressCheckpoint
+	egressQueueFlush
+
+	egressStageCount
+)
+
+func (s egressStage) String() string {
+	switch s {
+	case egressSubscribe:
+		return "subscribe"
+	case egressReplay:
+		return "replay"
+	case egressCheckpoint:
+		return "checkpoint"
+	case egressQueueFlush:
+		return "queue flush"
+	default:
+		return "unknown"
+	}
+}
+
+// egressWriter writes messages of a stage to a new connection
+type egressWriter func(conn WsConnection) error
+
+// runEgress runs all post-connect stages in order. It must be called under 'r.mu'
+func (r *ReConn) runEgress(conn WsConnection, cfg *MutableConfig) error {
+	for stage := egressStage(0); stage < egressStageCount; stage++ {
+		write := r.egressWriter(stage, cfg)
+		if write == nil {
+			continue
+		}
+
+		r.log.Debug("run post-connect stage: " + stage.String())
+		if err := write(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// egressWriter returns a writer for a stage. It returns nil if the stage has nothing to write
+func (r *ReConn) egressWriter(stage egressStage, cfg *MutableConfig) egressWriter {
+	switch stage {
+	case egressSubscribe:
+		handler := r.subscribeHandlerOf(cfg)
+		if handler == nil {
+			return nil
+		}
+		return func(conn WsConnection) error {
+			return r.runSubscribeHandler(conn, handler)
+		}
+	case egressReplay:
+		subs := r.subscriptions.Copy()
+		if len(subs) == 0 {
+			return nil
+		}
+		return func(conn WsConnection) error {
+			return r.replaySubscriptions(r.egressConn(conn), subs)
+		}
+	case egressCheckpoint:
+		messageType, data, ok := r.buildCheckpoint()
+		if !ok {
+			return nil
+		}
+		return func(conn WsConnection) error {
+			return conn.WriteMessage(messageType, data)
+		}
+	case egressQueueFlush:
+		if r.writeBuffer.Len() == 0 {
+			return nil
+		}
+		return r.flushWriteBuffer
+	default:
+		return nil
+	}
+}
+
+// egressConn wraps a new connection for post-connect stages
+func (r *ReConn) egressConn(conn WsConnection) WsConnection {
+	if r.readTransform != nil || r.writeTransform != nil {
+		conn = transformConn{WsConnection: conn, r: r}
+	}
+	if r.writeRateLimiter != nil {
+		conn = rateLimitedConn{WsConnection: conn, r: r}
+	}
+	if r.writeGate != nil {
+		conn = gatedConn{WsConnection: conn, gate: r.writeGate}
+	}
+	return conn
+}
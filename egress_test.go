@@ -0,0 +1,70 @@
+package reconnect
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEgressOrder(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received [][]string
+	)
+	server := newWsServer(func(conn *websocket.Conn) {
+		mu.Lock()
+		received = append(received, nil)
+		n := len(received) - 1
+		mu.Unlock()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil || string(data) == "drop" {
+				return
+			}
+			mu.Lock()
+			received[n] = append(received[n], string(data))
+			mu.Unlock()
+		}
+	})
+	defer server.Close()
+
+	var (
+		subscribes   int32
+		resubscribed = make(chan struct{})
+	)
+	conn := New().SetURL(wsURL(server)).SetSubscribeHandler(func(conn WsConnection) error {
+		if atomic.AddInt32(&subscribes, 1) == 2 {
+			close(resubscribed)
+			// Give the user write a chance to get ahead
+			time.Sleep(50 * time.Millisecond)
+		}
+		return conn.WriteMessage(websocket.TextMessage, []byte("subscribe"))
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("drop")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The read fails and triggers a reconnect
+	go conn.ReadMessage()
+
+	<-resubscribed
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("user")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != 2 || len(received[1]) != 2 || received[1][0] != "subscribe" || received[1][1] != "user" {
+		t.Errorf("unexpected messages: %q", received)
+	}
+}
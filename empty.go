@@ -0,0 +1,43 @@
+package reconnect
+
+import "sync/atomic"
+
+// EmptyMessagePolicy defines how zero-length messages are handled
+type EmptyMessagePolicy int
+
+const (
+	// DeliverEmptyMessages delivers empty messages to the caller. It is the default policy
+	DeliverEmptyMessages EmptyMessagePolicy = iota
+	// DropEmptyMessages drops empty messages
+	DropEmptyMessages
+	// TreatEmptyMessagesAsKeepalive drops empty messages, but treats them as connection activity
+	TreatEmptyMessagesAsKeepalive
+)
+
+// SetEmptyMessagePolicy sets the policy for zero-length messages. After 'Dial' call it does nothing
+func (r *ReConn) SetEmptyMessagePolicy(policy EmptyMessagePolicy) *ReConn {
+	r.configure(func() {
+		r.emptyMessagePolicy = policy
+	})
+	return r
+}
+
+// DroppedEmptyMessages returns the number of empty messages dropped according to the policy
+func (r *ReConn) DroppedEmptyMessages() uint64 {
+	return r.drops.Get(DropFeatureEmptyMessage)
+}
+
+// skipEmptyMessage reports whether a message must not be delivered according to the empty message policy
+func (r *ReConn) skipEmptyMessage(size int) bool {
+	if size != 0 || r.emptyMessagePolicy == DeliverEmptyMessages {
+		return false
+	}
+
+	if r.emptyMessagePolicy == TreatEmptyMessagesAsKeepalive {
+		// Only reset the activity timestamp: a keepalive isn't a data message, so it must not
+		// open the write gate, stop first message tracking and etc.
+		atomic.StoreInt64(&r.lastMessageAt, monoNow())
+	}
+	r.dropMessage(DropFeatureEmptyMessage, nil)
+	return true
+}
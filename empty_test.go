@@ -0,0 +1,54 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEmptyMessagePolicy(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, nil)
+		conn.WriteMessage(websocket.TextMessage, []byte("data"))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	tests := []struct {
+		policy    EmptyMessagePolicy
+		first     string
+		dropped   uint64
+		keepalive bool
+	}{
+		{policy: DeliverEmptyMessages, first: "", dropped: 0},
+		{policy: DropEmptyMessages, first: "data", dropped: 1},
+		{policy: TreatEmptyMessagesAsKeepalive, first: "data", dropped: 1, keepalive: true},
+	}
+	for _, tt := range tests {
+		conn := New().SetURL(wsURL(server)).SetEmptyMessagePolicy(tt.policy)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		_, data, err := conn.ReadMessage()
+		if err != nil || string(data) != tt.first {
+			t.Errorf("policy %d: unexpected read result: %q, %v", tt.policy, data, err)
+		}
+		if n := conn.DroppedEmptyMessages(); n != tt.dropped {
+			t.Errorf("policy %d: expected %d dropped messages, got %d", tt.policy, tt.dropped, n)
+		}
+		conn.Close()
+
+		// Check activity tracking separately: the data message also updates it
+		conn = New().SetEmptyMessagePolicy(tt.policy)
+		conn.awaitingFirstMessage.Set(true)
+		conn.skipEmptyMessage(0)
+		if active := atomic.LoadInt64(&conn.lastMessageAt) != 0; active != tt.keepalive {
+			t.Errorf("policy %d: unexpected activity tracking: %t", tt.policy, active)
+		}
+		if !conn.awaitingFirstMessage.Get() {
+			t.Errorf("policy %d: an empty message must not be treated as the first data message", tt.policy)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Phase is a phase of a connect attempt
+type Phase int
+
+const (
+	PhaseUnknown Phase = iota
+	// PhaseDial covers DNS resolution, TCP/TLS connection and websocket handshake
+	PhaseDial
+	// PhaseSubscribe covers the subscribe handler
+	PhaseSubscribe
+	// PhasePostUpgradeRejected means the server closed the connection right after the upgrade.
+	// See 'SetPostUpgradeRejection'
+	PhasePostUpgradeRejected
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseDial:
+		return "dial"
+	case PhaseSubscribe:
+		return "subscribe"
+	case PhasePostUpgradeRejected:
+		return "post-upgrade-rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectError is returned when a connect attempt fails. It matches 'ErrDial', 'ErrSubscribe' or
+// 'ErrPostUpgradeRejected' depending on the phase, and unwraps to the original error
+type ConnectError struct {
+	Phase Phase
+	Err   error
+}
+
+func newConnectError(phase Phase, err error) *ConnectError {
+	return &ConnectError{Phase: phase, Err: err}
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("%s: %s", e.sentinel(), e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConnectError) Is(target error) bool {
+	return target == e.sentinel()
+}
+
+func (e *ConnectError) sentinel() error {
+	switch e.Phase {
+	case PhaseDial:
+		return ErrDial
+	case PhaseSubscribe:
+		return ErrSubscribe
+	case PhasePostUpgradeRejected:
+		return ErrPostUpgradeRejected
+	default:
+		return ErrReconnect
+	}
+}
+
+// ReconnectError is returned by reads and writes when a reconnect after an error failed.
+// It matches 'ErrReconnect' and unwraps to the reconnect error, so 'PhaseOf' and 'errors.Is'
+// see the failed phase. The original error stays inspectable too: 'errors.Is' and 'errors.As'
+// check it after the reconnect error, for example, to get '*websocket.CloseError' of the peer
+type ReconnectError struct {
+	// Err is the original read or write error
+	Err error
+	// ReconnectErr is the error of the failed reconnect
+	ReconnectErr error
+}
+
+func (e *ReconnectError) Error() string {
+	return fmt.Sprintf("%s: original error: '%s', reconnect error: '%s'", ErrReconnect, e.Err, e.ReconnectErr)
+}
+
+func (e *ReconnectError) Unwrap() error {
+	return e.ReconnectErr
+}
+
+func (e *ReconnectError) Is(target error) bool {
+	return target == ErrReconnect || errors.Is(e.Err, target)
+}
+
+func (e *ReconnectError) As(target interface{}) bool {
+	// The reconnect error takes precedence, so 'PhaseOf' and 'CodeOf' aren't affected by the original error
+	return errors.As(e.ReconnectErr, target) || errors.As(e.Err, target)
+}
+
+// PhaseOf returns the phase of a failed connect attempt. It returns 'PhaseUnknown'
+// if the error wasn't caused by a connect attempt
+func PhaseOf(err error) Phase {
+	var connErr *ConnectError
+	if errors.As(err, &connErr) {
+		return connErr.Phase
+	}
+	return PhaseUnknown
+}
@@ -0,0 +1,306 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConnectErrorPhase(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	errSubscribe := errors.New("bad payload")
+
+	tests := []struct {
+		name     string
+		conn     *ReConn
+		phase    Phase
+		sentinel error
+	}{
+		{
+			name:     "dial",
+			conn:     New().SetURL("ws://127.0.0.1:1"),
+			phase:    PhaseDial,
+			sentinel: ErrDial,
+		},
+		{
+			name: "subscribe",
+			conn: New().SetURL(wsURL(server)).SetSubscribeHandler(func(WsConnection) error {
+				return errSubscribe
+			}),
+			phase:    PhaseSubscribe,
+			sentinel: ErrSubscribe,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.conn.Dial()
+			if err == nil {
+				t.Fatal("'Dial' must return an error")
+			}
+
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("error must match %q: %s", tt.sentinel, err)
+			}
+			if phase := PhaseOf(err); phase != tt.phase {
+				t.Errorf("expected phase %s, got %s", tt.phase, phase)
+			}
+			if attempt, _ := tt.conn.LastAttempt(); attempt.Phase != tt.phase {
+				t.Errorf("expected attempt phase %s, got %s", tt.phase, attempt.Phase)
+			}
+		})
+	}
+
+	t.Run("original error", func(t *testing.T) {
+		err := tests[1].conn.connect(tests[1].conn.generation, nil)
+		if !errors.Is(err, errSubscribe) {
+			t.Errorf("error must unwrap to the handler error: %s", err)
+		}
+	})
+}
+
+func TestReconnectErrorPhase(t *testing.T) {
+	var connections int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// Accept only the first connection and drop it
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	_, _, err := conn.ReadMessage()
+	if !errors.Is(err, ErrReconnect) || !errors.Is(err, ErrDial) {
+		t.Errorf("error must match 'ErrReconnect' and 'ErrDial', got %v", err)
+	}
+	if phase := PhaseOf(err); phase != PhaseDial {
+		t.Errorf("expected phase %s, got %s", PhaseDial, phase)
+	}
+}
+
+func TestReconnectErrorOriginal(t *testing.T) {
+	var connections int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// Accept only the first connection and close it with a close frame
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4001, "bye"))
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	_, _, err := conn.ReadMessage()
+
+	var reconnectErr *ReconnectError
+	if !errors.As(err, &reconnectErr) {
+		t.Fatalf("expected ReconnectError, got %v", err)
+	}
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != 4001 || closeErr.Text != "bye" {
+		t.Errorf("the close error of the peer must be recovered, got %v", closeErr)
+	}
+	if !errors.Is(err, reconnectErr.Err) {
+		t.Errorf("error must match the original error: %v", err)
+	}
+	if !errors.Is(err, ErrReconnect) || !errors.Is(err, ErrDial) {
+		t.Errorf("error must match 'ErrReconnect' and 'ErrDial', got %v", err)
+	}
+	if phase := PhaseOf(err); phase != PhaseDial {
+		t.Errorf("expected phase %s, got %s", PhaseDial, phase)
+	}
+	if code := CodeOf(err); code != CodeReconnectFailed {
+		t.Errorf("expected code %s, got %s", CodeReconnectFailed, code)
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		err  func() error
+		code ErrorCode
+	}{
+		{
+			name: "not dialed",
+			err: func() error {
+				_, _, err := New().ReadMessage()
+				return err
+			},
+			code: CodeNotDialed,
+		},
+		{
+			name: "already dialed",
+			err: func() error {
+				conn := New().SetURL(wsURL(server))
+				defer conn.Close()
+
+				conn.Dial()
+				return conn.Dial()
+			},
+			code: CodeAlreadyDialed,
+		},
+		{
+			name: "dial",
+			err: func() error {
+				return New().SetURL("ws://127.0.0.1:1").Dial()
+			},
+			code: CodeDialFailed,
+		},
+		{
+			name: "subscribe",
+			err: func() error {
+				conn := New().SetURL(wsURL(server)).SetSubscribeHandler(func(WsConnection) error {
+					return errors.New("bad payload")
+				})
+				defer conn.Close()
+
+				return conn.Dial()
+			},
+			code: CodeSubscribeFailed,
+		},
+		{
+			name: "max reconnects",
+			err: func() error {
+				return New().SetURL("ws://127.0.0.1:1").SetMaxReconnectAttempts(1).Dial()
+			},
+			code: CodeMaxReconnectsExceeded,
+		},
+		{
+			name: "closed",
+			err: func() error {
+				conn := New().SetURL(wsURL(server))
+				if err := conn.Dial(); err != nil {
+					return err
+				}
+				time.AfterFunc(10*time.Millisecond, func() { conn.Close() })
+
+				return conn.SubscribeWithAck("pending", []byte("sub"), func(int, []byte) (bool, error) {
+					return false, nil
+				}, time.Minute)
+			},
+			code: CodeConnClosed,
+		},
+		{
+			name: "ack timeout",
+			err: func() error {
+				conn := New().SetURL(wsURL(server))
+				if err := conn.Dial(); err != nil {
+					return err
+				}
+				defer conn.Close()
+
+				return conn.SubscribeWithAck("silent", []byte("sub"), func(int, []byte) (bool, error) {
+					return false, nil
+				}, 10*time.Millisecond)
+			},
+			code: CodeTimeout,
+		},
+		{
+			name: "canceled",
+			err: func() error {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return New().SetURL(wsURL(server)).DialContext(ctx)
+			},
+			code: CodeCanceled,
+		},
+		{
+			name: "invalid dsn",
+			err: func() error {
+				_, err := ParseDSN("ws://localhost?reconnect=maybe")
+				return err
+			},
+			code: CodeInvalidConfig,
+		},
+		{
+			name: "invalid close code",
+			err: func() error {
+				return ValidateCloseCode(1005)
+			},
+			code: CodeInvalidCloseCode,
+		},
+		{
+			name: "write gate",
+			err: func() error {
+				return NewAfterFirstMessageGate(true).Wait()
+			},
+			code: CodeWriteGateClosed,
+		},
+		{
+			name: "wrapped",
+			err: func() error {
+				return fmt.Errorf("wrapped: %w", ErrNotConnected)
+			},
+			code: CodeNotConnected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if code := CodeOf(err); code != tt.code {
+				t.Errorf("expected code %s, got %s: %s", tt.code, code, err)
+			}
+		})
+	}
+
+	t.Run("reconnect", func(t *testing.T) {
+		err := &ReconnectError{Err: errors.New("read error"), ReconnectErr: newConnectError(PhaseDial, errors.New("refused"))}
+		if code := CodeOf(err); code != CodeReconnectFailed {
+			t.Errorf("expected code %s, got %s", CodeReconnectFailed, code)
+		}
+	})
+
+	t.Run("foreign", func(t *testing.T) {
+		if code := CodeOf(errors.New("foreign")); code != CodeUnknown {
+			t.Errorf("expected code %s, got %s", CodeUnknown, code)
+		}
+		if code := CodeOf(nil); code != CodeUnknown {
+			t.Errorf("expected code %s, got %s", CodeUnknown, code)
+		}
+	})
+}
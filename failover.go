@@ -0,0 +1,77 @@
+package reconnect
+
+import (
+	"errors"
+
+	"github.com/gorilla/websocket"
+)
+
+// FailoverTokenExtractor extracts a token from the close frame of a connection. The token
+// is passed to the next connect attempt if it targets a different url
+type FailoverTokenExtractor func(closeCode int, reason string) (token string, ok bool)
+
+// closeFrame is a close frame received from the server
+type closeFrame struct {
+	code   int
+	reason string
+}
+
+// SetFailoverTokenExtractor sets an extractor of failover tokens. When a connection is closed by
+// the server and the next connect attempt targets a different url (see 'UpdateConfig'), the token
+// is sent in the header set by 'SetFailoverTokenHeader'. The token is used by one attempt only.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetFailoverTokenExtractor(extractor FailoverTokenExtractor) *ReConn {
+	r.configure(func() {
+		r.failoverTokenExtractor = extractor
+	})
+	return r
+}
+
+// SetFailoverTokenHeader sets a header for failover tokens. After 'Dial' call it does nothing
+func (r *ReConn) SetFailoverTokenHeader(name string) *ReConn {
+	r.configure(func() {
+		r.failoverTokenHeader = name
+	})
+	return r
+}
+
+// saveCloseFrame saves the close frame of the current connection if 'err' contains it
+func (r *ReConn) saveCloseFrame(err error) {
+	if r.failoverTokenExtractor == nil {
+		return
+	}
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		r.lastCloseFrame.Store(&closeFrame{code: closeErr.Code, reason: closeErr.Text})
+	}
+}
+
+// failoverToken returns a token for an attempt to connect to 'url'. The saved close frame is cleared
+// only when it's used by a failover attempt, so the token is used once, and attempts to the same url
+// don't lose it. It must be called under 'r.mu'
+func (r *ReConn) failoverToken(url string) (string, bool) {
+	if r.failoverTokenExtractor == nil || r.failoverTokenHeader == "" {
+		return "", false
+	}
+
+	frame, _ := r.lastCloseFrame.Load().(*closeFrame)
+	if frame == nil {
+		return "", false
+	}
+	if r.connectedURL == "" || r.connectedURL == url {
+		// Not a failover
+		return "", false
+	}
+
+	r.lastCloseFrame.Store((*closeFrame)(nil))
+	return r.failoverTokenExtractor(frame.code, frame.reason)
+}
+
+// clearCloseFrame must be called under 'r.mu' after a successful connect: the close frame
+// of a previous connection can't be used by next failovers
+func (r *ReConn) clearCloseFrame() {
+	if r.failoverTokenExtractor != nil {
+		r.lastCloseFrame.Store((*closeFrame)(nil))
+	}
+}
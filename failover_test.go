@@ -0,0 +1,114 @@
+package reconnect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// failoverServers records failover tokens of handshakes. Every connection is closed by the server
+type failoverServers struct {
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (f *failoverServers) newServer(closeReason string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.tokens = append(f.tokens, r.Header.Get("X-Migration-Token"))
+		f.mu.Unlock()
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		msg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, closeReason)
+		conn.WriteMessage(websocket.CloseMessage, msg)
+		conn.ReadMessage()
+	}))
+}
+
+func (f *failoverServers) Tokens() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.tokens...)
+}
+
+func extractMigrationToken(closeCode int, reason string) (string, bool) {
+	if closeCode != websocket.CloseServiceRestart || !strings.HasPrefix(reason, "migrate:") {
+		return "", false
+	}
+	return strings.TrimPrefix(reason, "migrate:"), true
+}
+
+func TestFailoverToken(t *testing.T) {
+	var servers failoverServers
+	serverA, serverB := servers.newServer("migrate:abc"), servers.newServer("")
+	defer serverA.Close()
+	defer serverB.Close()
+
+	conn := New().SetURL(wsURL(serverA)).
+		SetFailoverTokenHeader("X-Migration-Token").
+		SetFailoverTokenExtractor(extractMigrationToken)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// No token: the reconnect targets the same url
+	conn.ReadMessage()
+
+	// The token is extracted and sent to the new url
+	conn.UpdateConfig(func(c *MutableConfig) {
+		c.URL = wsURL(serverB)
+	})
+	conn.ReadMessage()
+
+	// The token is single-use and server B sends no token
+	conn.UpdateConfig(func(c *MutableConfig) {
+		c.URL = wsURL(serverA)
+	})
+	conn.ReadMessage()
+
+	want := []string{"", "", "abc", ""}
+	if tokens := servers.Tokens(); strings.Join(tokens, ",") != strings.Join(want, ",") {
+		t.Errorf("expected tokens %q, got %q", want, tokens)
+	}
+}
+
+func TestFailoverTokenReadJSON(t *testing.T) {
+	var servers failoverServers
+	serverA, serverB := servers.newServer("migrate:abc"), servers.newServer("")
+	defer serverA.Close()
+	defer serverB.Close()
+
+	conn := New().SetURL(wsURL(serverA)).
+		SetFailoverTokenHeader("X-Migration-Token").
+		SetFailoverTokenExtractor(extractMigrationToken)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// The close frame is read by 'ReadJSON', and the token is sent to the new url
+	conn.UpdateConfig(func(c *MutableConfig) {
+		c.URL = wsURL(serverB)
+	})
+	var v interface{}
+	if err := conn.ReadJSON(&v); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	want := []string{"", "abc"}
+	if tokens := servers.Tokens(); strings.Join(tokens, ",") != strings.Join(want, ",") {
+		t.Errorf("expected tokens %q, got %q", want, tokens)
+	}
+}
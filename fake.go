@@ -0,0 +1,160 @@
+package reconnect
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errFakeConnClosed is returned by a closed 'FakeConn'
+var errFakeConnClosed = errors.New("fake connection is closed")
+
+// FakeConn is an in-memory 'WsConnection' for tests. Messages pushed by 'Push' are returned
+// by 'ReadMessage', written messages are available via 'Written'
+type FakeConn struct {
+	mu      sync.Mutex
+	changed chan struct{}
+	inbox   []Message
+	written []Message
+	err     error
+	closed  bool
+}
+
+var _ WsConnection = (*FakeConn)(nil)
+
+// NewFakeConn creates a new 'FakeConn'
+func NewFakeConn() *FakeConn {
+	return &FakeConn{changed: make(chan struct{})}
+}
+
+// notifyLocked wakes up blocked reads. It must be called under 'c.mu'
+func (c *FakeConn) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Push queues a message for 'ReadMessage'
+func (c *FakeConn) Push(messageType int, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inbox = append(c.inbox, Message{Type: messageType, Data: append([]byte(nil), data...)})
+	c.notifyLocked()
+}
+
+// Fail makes all next reads and writes return 'err', like a broken network connection.
+// Queued messages are still returned first
+func (c *FakeConn) Fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.err = err
+	c.notifyLocked()
+}
+
+// ReadMessage blocks until a message is pushed or the connection fails or is closed
+func (c *FakeConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		c.mu.Lock()
+		switch {
+		case len(c.inbox) > 0:
+			msg := c.inbox[0]
+			c.inbox[0] = Message{}
+			c.inbox = c.inbox[1:]
+			c.mu.Unlock()
+			return msg.Type, msg.Data, nil
+		case c.closed:
+			c.mu.Unlock()
+			return 0, nil, errFakeConnClosed
+		case c.err != nil:
+			err := c.err
+			c.mu.Unlock()
+			return 0, nil, err
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		<-changed
+	}
+}
+
+func (c *FakeConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.closed:
+		return errFakeConnClosed
+	case c.err != nil:
+		return c.err
+	}
+	c.written = append(c.written, Message{Type: messageType, Data: append([]byte(nil), data...)})
+	return nil
+}
+
+// Written returns copies of all written messages
+func (c *FakeConn) Written() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Message(nil), c.written...)
+}
+
+func (c *FakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		c.notifyLocked()
+	}
+	return nil
+}
+
+// FakeDialer creates 'FakeConn' connections. Its 'Dial' method can be passed to 'SetDialFunc'
+type FakeDialer struct {
+	mu    sync.Mutex
+	conns []*FakeConn
+	err   error
+}
+
+// Dial returns a new 'FakeConn' or the error set by 'SetError'. Failed dials aren't counted
+func (d *FakeDialer) Dial(string, http.Header) (WsConnection, *http.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	conn := NewFakeConn()
+	d.conns = append(d.conns, conn)
+	return conn, &http.Response{StatusCode: http.StatusSwitchingProtocols, Header: make(http.Header)}, nil
+}
+
+// SetError makes next dials fail with 'err'. A nil error makes them succeed again
+func (d *FakeDialer) SetError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.err = err
+}
+
+// Dials returns the number of successful dials
+func (d *FakeDialer) Dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.conns)
+}
+
+// Last returns the connection created by the last successful dial. It returns nil if there
+// were no dials
+func (d *FakeDialer) Last() *FakeConn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.conns) == 0 {
+		return nil
+	}
+	return d.conns[len(d.conns)-1]
+}
@@ -0,0 +1,195 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultFarewellTimeout is used when a farewell message or farewell ack timeout isn't set
+const defaultFarewellTimeout = time.Second
+
+type farewellMessage struct {
+	messageType int
+	payload     []byte
+	timeout     time.Duration
+}
+
+// FarewellAckMatcher reports whether a message is an acknowledgment of the farewell message
+type FarewellAckMatcher func(messageType int, data []byte) bool
+
+// farewellAck is used to wait for an acknowledgment of the farewell message
+type farewellAck struct {
+	match   FarewellAckMatcher
+	timeout time.Duration
+
+	// waiting is set after the farewell message is written
+	waiting *atomicBool
+	done    chan struct{}
+	once    sync.Once
+}
+
+// FarewellOutcome describes the result of the farewell message on 'Close'
+type FarewellOutcome int32
+
+const (
+	// FarewellNotSent means the farewell message isn't set, wasn't sent yet or there was no connection
+	FarewellNotSent FarewellOutcome = iota
+	// FarewellSent means the farewell message was written. An ack isn't expected
+	FarewellSent
+	// FarewellFailed means the farewell message couldn't be written
+	FarewellFailed
+	// FarewellAcked means the ack was received and the close frame was written
+	FarewellAcked
+	// FarewellAckTimeout means the ack wasn't received in time and the connection was closed without a close frame
+	FarewellAckTimeout
+)
+
+func (o FarewellOutcome) String() string {
+	switch o {
+	case FarewellNotSent:
+		return "not_sent"
+	case FarewellSent:
+		return "sent"
+	case FarewellFailed:
+		return "failed"
+	case FarewellAcked:
+		return "acked"
+	case FarewellAckTimeout:
+		return "ack_timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// SetFarewellMessage sets a message that 'Close' tries to write before the connection is closed,
+// for example, a logout request. The write is best effort: it is bounded by the timeout and skipped
+// if there's no connection. The outcome is logged. After 'Dial' call it does nothing
+func (r *ReConn) SetFarewellMessage(messageType int, payload []byte, timeout time.Duration) *ReConn {
+	r.configure(func() {
+		r.farewell = &farewellMessage{
+			messageType: messageType,
+			payload:     append([]byte(nil), payload...),
+			timeout:     timeout,
+		}
+	})
+	return r
+}
+
+// SetFarewellAck makes 'Close' wait for an acknowledgment of the farewell message (see 'SetFarewellMessage')
+// for protocols that require the client to close the connection only after a logout ack. After the farewell
+// message is written, 'Close' waits until 'match' reports a read message; then the close frame is written
+// and the connection is closed. The ack is consumed by 'ReadMessage', so the messages must be read
+// concurrently; other messages are returned as usual. If the ack isn't received in 'timeout', the connection
+// is closed as usual. The outcome is reported by 'FarewellOutcome'. After 'Dial' call it does nothing
+func (r *ReConn) SetFarewellAck(match FarewellAckMatcher, timeout time.Duration) *ReConn {
+	r.configure(func() {
+		r.farewellAck = nil
+		if match != nil {
+			r.farewellAck = &farewellAck{
+				match:   match,
+				timeout: timeout,
+				waiting: newAtomicBool(),
+				done:    make(chan struct{}),
+			}
+		}
+	})
+	return r
+}
+
+// FarewellOutcome returns the result of the farewell message sent by 'Close'
+func (r *ReConn) FarewellOutcome() FarewellOutcome {
+	return FarewellOutcome(atomic.LoadInt32(&r.farewellOutcome))
+}
+
+func (r *ReConn) setFarewellOutcome(o FarewellOutcome) {
+	atomic.StoreInt32(&r.farewellOutcome, int32(o))
+}
+
+// sendFarewell writes the farewell message only once. It must be called without holding 'r.mu'
+func (r *ReConn) sendFarewell() {
+	if r.farewell == nil {
+		return
+	}
+	r.farewellOnce.Do(func() {
+		if r.writeFarewell() && r.farewellAck != nil {
+			r.awaitFarewellAck()
+		}
+	})
+}
+
+func (r *ReConn) writeFarewell() (sent bool) {
+	timeout := r.farewell.timeout
+	if timeout <= 0 {
+		timeout = defaultFarewellTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil {
+		r.log.Info("skip farewell message: not connected")
+		return false
+	}
+	if r.farewellAck != nil {
+		// Set the flag before the write: the ack can be read before the write returns
+		r.farewellAck.waiting.Set(true)
+	}
+	if err := r.writeMessageLocked(ctx, WriteSourceClose, r.farewell.messageType, r.farewell.payload); err != nil {
+		r.setFarewellOutcome(FarewellFailed)
+		r.log.Error(fmt.Sprintf("couldn't send farewell message: %s", err))
+		return false
+	}
+	r.setFarewellOutcome(FarewellSent)
+	r.log.Info("farewell message was sent")
+	return true
+}
+
+// awaitFarewellAck waits for the farewell ack and writes the close frame
+func (r *ReConn) awaitFarewellAck() {
+	timeout := r.farewellAck.timeout
+	if timeout <= 0 {
+		timeout = defaultFarewellTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.farewellAck.done:
+	case <-timer.C:
+		r.setFarewellOutcome(FarewellAckTimeout)
+		r.log.Error(fmt.Sprintf("farewell ack wasn't received in %s, close the connection", timeout))
+		return
+	}
+
+	// The server can close the connection right after the close frame: don't reconnect
+	r.closed.Set(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	msg := FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := r.writeMessage(ctx, WriteSourceClose, websocket.CloseMessage, msg); err != nil {
+		r.setFarewellOutcome(FarewellFailed)
+		r.log.Error(fmt.Sprintf("couldn't send close frame after farewell ack: %s", err))
+		return
+	}
+	r.setFarewellOutcome(FarewellAcked)
+	r.log.Info("farewell ack was received, close frame was sent")
+}
+
+// matchFarewellAck reports whether the message is the farewell ack. It's called for every read message
+func (r *ReConn) matchFarewellAck(messageType int, data []byte) bool {
+	ack := r.farewellAck
+	if ack == nil || !ack.waiting.Get() || !ack.match(messageType, data) {
+		return false
+	}
+	ack.once.Do(func() { close(ack.done) })
+	return true
+}
@@ -0,0 +1,161 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestFarewellMessage(t *testing.T) {
+	received := make(chan string, 2)
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(data)
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetFarewellMessage(websocket.TextMessage, []byte(`{"op":"logout"}`), time.Second)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	conn.Close()
+	conn.Close()
+
+	select {
+	case data := <-received:
+		if data != `{"op":"logout"}` {
+			t.Errorf("unexpected message: %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("farewell message wasn't sent")
+	}
+	select {
+	case data := <-received:
+		t.Errorf("farewell message must be sent once, got %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFarewellMessageNotConnected(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetFarewellMessage(websocket.TextMessage, []byte("bye"), time.Second)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.closeConn()
+
+	closed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("'Close' must skip the farewell message without a connection")
+	}
+}
+
+func TestFarewellAck(t *testing.T) {
+	var connections int32
+	closeCodes := make(chan int, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "logout" {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("update"))
+		conn.WriteMessage(websocket.TextMessage, []byte("logout-ack"))
+
+		_, _, err := conn.ReadMessage()
+		if closeErr, ok := err.(*websocket.CloseError); ok {
+			closeCodes <- closeErr.Code
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).
+		SetFarewellMessage(websocket.TextMessage, []byte("logout"), time.Second).
+		SetFarewellAck(func(_ int, data []byte) bool { return string(data) == "logout-ack" }, time.Second)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	messages := make(chan string, 10)
+	go func() {
+		defer close(messages)
+		for !conn.closed.Get() {
+			if _, data, err := conn.ReadMessage(); err == nil {
+				messages <- string(data)
+			}
+		}
+	}()
+
+	conn.Close()
+
+	select {
+	case code := <-closeCodes:
+		if code != websocket.CloseNormalClosure {
+			t.Errorf("unexpected close code: %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("close frame wasn't sent")
+	}
+	var got []string
+	for msg := range messages {
+		got = append(got, msg)
+	}
+	if len(got) != 1 || got[0] != "update" {
+		t.Errorf("only non-ack messages must be delivered, got %q", got)
+	}
+	if o := conn.FarewellOutcome(); o != FarewellAcked {
+		t.Errorf("unexpected outcome: %s", o)
+	}
+	if n := conn.DroppedMessages()[DropFeatureFarewellAck]; n != 1 {
+		t.Errorf("expected 1 consumed ack, got %d", n)
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("expected 1 connection, got %d", n)
+	}
+}
+
+func TestFarewellAckTimeout(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).
+		SetFarewellMessage(websocket.TextMessage, []byte("logout"), time.Second).
+		SetFarewellAck(func(int, []byte) bool { return true }, 50*time.Millisecond)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	start := time.Now()
+	if err := conn.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > time.Second {
+		t.Errorf("unexpected close duration: %s", d)
+	}
+	if o := conn.FarewellOutcome(); o != FarewellAckTimeout {
+		t.Errorf("unexpected outcome: %s", o)
+	}
+}
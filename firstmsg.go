@@ -0,0 +1,159 @@
+package reconnect
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// firstMessageTracker measures the gap between a connect and the first data message
+type firstMessageTracker struct {
+	mu          sync.Mutex
+	conn        WsConnection
+	connectedAt time.Time
+	timer       *time.Timer
+	gap         time.Duration
+	hasGap      bool
+
+	// prevLastMessageAt is a monotonic time of the last message on the previous connection
+	prevLastMessageAt int64
+	outage            Outage
+	hasOutage         bool
+	// worstOutage is the longest outage (see 'LifetimeSummary')
+	worstOutage time.Duration
+
+	// pendingResume is a dial response passed to the resume detector with the first message
+	pendingResume *DialResponse
+}
+
+// SetFirstMessageTimeout sets a max time between a successful connect and the first data message.
+// If no message is received in time, the connection is closed and the next read reconnects.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetFirstMessageTimeout(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.firstMessageTimeout = d
+	})
+	return r
+}
+
+// FirstMessageGap returns the time between the last successful connect and the first data
+// message received on that connection. The second value is false if no message was received yet
+func (r *ReConn) FirstMessageGap() (time.Duration, bool) {
+	r.firstMessage.mu.Lock()
+	defer r.firstMessage.mu.Unlock()
+
+	return r.firstMessage.gap, r.firstMessage.hasGap
+}
+
+// startFirstMessageTracking must be called after every successful connect
+func (r *ReConn) startFirstMessageTracking(conn WsConnection) {
+	t := &r.firstMessage
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.conn = conn
+	t.connectedAt = time.Now()
+	t.hasGap = false
+	t.pendingResume = nil
+	t.prevLastMessageAt = atomic.LoadInt64(&r.lastMessageAt)
+	r.awaitingFirstMessage.Set(true)
+
+	if r.firstMessageTimeout <= 0 {
+		return
+	}
+
+	timeout := r.firstMessageTimeout
+	t.timer = time.AfterFunc(timeout, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if t.conn != conn || t.hasGap {
+			return
+		}
+
+		r.log.Error(fmt.Sprintf("no messages were received in %s after connect, close connection", timeout))
+		// The next read will fail and trigger a reconnect
+		conn.Close()
+	})
+}
+
+// stopFirstMessageTracking is called for every read data message
+func (r *ReConn) stopFirstMessageTracking(data []byte) {
+	if !r.awaitingFirstMessage.CompareAndSwap(true, false) {
+		return
+	}
+
+	t := &r.firstMessage
+
+	t.mu.Lock()
+	pendingResume := t.pendingResume
+	t.pendingResume = nil
+	t.stop(time.Now())
+	t.mu.Unlock()
+
+	if pendingResume != nil {
+		// Call the detector without the lock: it can call 'FirstMessageGap' and etc.
+		r.lastReconnectResumed.Set(r.resumeDetector(pendingResume, data))
+	}
+}
+
+// stop must be called under 't.mu'
+func (t *firstMessageTracker) stop(now time.Time) {
+	t.gap = now.Sub(t.connectedAt)
+	t.hasGap = true
+	if t.prevLastMessageAt != 0 {
+		t.outage = Outage{
+			LastMessageAt:  monoTime(t.prevLastMessageAt),
+			FirstMessageAt: now,
+		}
+		t.hasOutage = true
+		if d := t.outage.Duration(); d > t.worstOutage {
+			t.worstOutage = d
+		}
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// processStart is used to store monotonic timestamps in integers
+var processStart = time.Now()
+
+// monoNow returns the current monotonic time as an offset from 'processStart'
+func monoNow() int64 {
+	return int64(time.Since(processStart))
+}
+
+// monoTime converts an offset returned by 'monoNow' to 'time.Time' with a monotonic clock reading
+func monoTime(mono int64) time.Time {
+	return processStart.Add(time.Duration(mono))
+}
+
+// Outage describes a gap in received messages caused by a reconnect
+type Outage struct {
+	// LastMessageAt is a time of the last message received on the previous connection
+	LastMessageAt time.Time
+	// FirstMessageAt is a time of the first message received on the new connection
+	FirstMessageAt time.Time
+}
+
+// Duration returns the duration of the outage
+func (o Outage) Duration() time.Duration {
+	return o.FirstMessageAt.Sub(o.LastMessageAt)
+}
+
+// LastOutage returns the last gap in received messages caused by a reconnect. The second value
+// is false if there were no reconnects or no messages were received after the last one
+func (r *ReConn) LastOutage() (Outage, bool) {
+	r.firstMessage.mu.Lock()
+	defer r.firstMessage.mu.Unlock()
+
+	return r.firstMessage.outage, r.firstMessage.hasOutage
+}
@@ -0,0 +1,89 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestFirstMessageTimeout(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// The first connection is too slow
+			time.Sleep(500 * time.Millisecond)
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetFirstMessageTimeout(100 * time.Millisecond)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("the first read must fail because of the timeout")
+	}
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("the slow connection wasn't closed in time: %s", d)
+	}
+
+	_, data, err := conn.ReadMessage()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected read result: %q, %v", data, err)
+	}
+
+	gap, ok := conn.FirstMessageGap()
+	if !ok || gap > 100*time.Millisecond {
+		t.Errorf("unexpected first message gap: %s, %t", gap, ok)
+	}
+}
+
+func TestLastOutage(t *testing.T) {
+	const outage = 200 * time.Millisecond
+
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		n := atomic.AddInt32(&connections, 1)
+		if n == 2 {
+			time.Sleep(outage)
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		if n == 1 {
+			// Drop the first connection right after the message
+			return
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, ok := conn.LastOutage(); ok {
+		t.Error("there must be no outage before a reconnect")
+	}
+
+	conn.ReadMessage()
+	conn.ReadMessage() // fails and reconnects
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	o, ok := conn.LastOutage()
+	if !ok {
+		t.Fatal("outage must be reported")
+	}
+	if d := o.Duration(); d < outage || d > outage+time.Second {
+		t.Errorf("unexpected outage duration: %s", d)
+	}
+}
@@ -0,0 +1,94 @@
+package reconnect
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxFlapDelay is a max delay before a reconnect when the connection is flapping
+const maxFlapDelay = time.Minute
+
+// SetFlapDamping enables flap damping: if a connection lives less than 'threshold' for 'cycles'
+// consecutive times, the connection is considered flapping and reconnects are delayed
+// with growing delays. A connection that lives longer than 'threshold' clears the state.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetFlapDamping(threshold time.Duration, cycles int) *ReConn {
+	r.configure(func() {
+		r.flapThreshold = threshold
+		r.flapMaxCycles = cycles
+	})
+	return r
+}
+
+// OnFlappingFunc is called when the connection starts or stops flapping. 'cycles' is the number
+// of consecutive short-lived connections, it's 0 when the flapping stops
+type OnFlappingFunc func(flapping bool, cycles int)
+
+// SetOnFlapping sets a callback called when the connection starts flapping and when a connection
+// outlives the threshold again (see 'SetFlapDamping'). See 'SetOnConnect' for details.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetOnFlapping(f OnFlappingFunc) *ReConn {
+	r.configure(func() {
+		r.onFlapping = f
+	})
+	return r
+}
+
+// IsFlapping reports whether the connection is considered flapping
+func (r *ReConn) IsFlapping() bool {
+	return r.flapping.Get()
+}
+
+// updateFlapState must be called under 'r.mu' before a previous connection is closed
+func (r *ReConn) updateFlapState() {
+	if r.flapThreshold <= 0 || r.flapMaxCycles <= 0 {
+		return
+	}
+
+	if time.Since(r.connectedAt) >= r.flapThreshold {
+		if r.flapping.Get() {
+			r.log.Info("connection is not flapping anymore")
+			r.notifyFlapping(false, 0)
+		}
+		r.flapCycles = 0
+		r.flapping.Set(false)
+		return
+	}
+
+	r.flapCycles++
+	if r.flapCycles < r.flapMaxCycles {
+		return
+	}
+
+	if !r.flapping.Get() {
+		r.log.Error(fmt.Sprintf("connection is flapping: %d consecutive connections lived less than %s", r.flapCycles, r.flapThreshold))
+		r.notifyFlapping(true, r.flapCycles)
+	}
+	r.flapping.Set(true)
+
+	delay := r.flapDelay()
+	r.log.Debug(fmt.Sprintf("delay reconnect by %s because of flapping", delay))
+	r.scheduleReconnect(ScheduleFlapDamping, delay, 0)
+}
+
+// notifyFlapping must be called under 'r.mu'
+func (r *ReConn) notifyFlapping(flapping bool, cycles int) {
+	if f := r.onFlapping; f != nil {
+		r.callbacks.Push(func() { f(flapping, cycles) })
+	}
+}
+
+// flapDelay returns a delay before a reconnect. The delay doubles with every flapping cycle
+func (r *ReConn) flapDelay() time.Duration {
+	delay := r.loadConfig().ReconnectTimeout
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	for i := r.flapMaxCycles; i < r.flapCycles && delay < maxFlapDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxFlapDelay {
+		delay = maxFlapDelay
+	}
+	return delay
+}
@@ -0,0 +1,65 @@
+package reconnect
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFlapDamping(t *testing.T) {
+	events := make(chan string, 10)
+	conn := New().SetReconnectTimeout(time.Second).SetFlapDamping(time.Minute, 3).
+		SetOnFlapping(func(flapping bool, cycles int) {
+			events <- fmt.Sprintf("%t %d", flapping, cycles)
+		})
+
+	shortLived := func() {
+		conn.connectedAt = time.Now().Add(-time.Second)
+		conn.updateFlapState()
+	}
+
+	shortLived()
+	shortLived()
+	if conn.IsFlapping() {
+		t.Fatal("connection must not be flapping before 3 cycles")
+	}
+
+	var delays []time.Duration
+	for i := 0; i < 3; i++ {
+		shortLived()
+		if !conn.IsFlapping() {
+			t.Fatal("connection must be flapping")
+		}
+		delays = append(delays, conn.flapDelay())
+	}
+	if delays[0] != time.Second || delays[1] != 2*time.Second || delays[2] != 4*time.Second {
+		t.Errorf("delays must grow: %v", delays)
+	}
+
+	// Long-lived connection clears the state
+	conn.connectedAt = time.Now().Add(-2 * time.Minute)
+	conn.updateFlapState()
+	if conn.IsFlapping() {
+		t.Error("connection must not be flapping after a long-lived connection")
+	}
+	if conn.flapCycles != 0 {
+		t.Errorf("cycles must be reset, got %d", conn.flapCycles)
+	}
+
+	// The callback is called on enter and exit only
+	for _, want := range []string{"true 3", "false 0"} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("got event %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected event %q", want)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Errorf("unexpected event %q", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,30 @@
+package reconnect
+
+// ForceReconnect closes the current connection and connects again, including the subscribe handler.
+// It can be used when the server requires periodic reconnects or an application-level error means
+// the connection is useless. Reads and writes blocked on the closed connection return its error
+// and don't dial again: the next call uses the new connection. Errors are the same as of the reconnects
+// of 'ReadMessage': '*ConnectError' ('ErrDial', 'ErrSubscribe'), 'ErrConnClosed' and etc. If 'SetRetryConnect'
+// is enabled, failed attempts are retried
+func (r *ReConn) ForceReconnect() error {
+	if !r.dialed.Get() {
+		return ErrNotDialed
+	}
+	if r.closed.Get() {
+		return ErrConnClosed
+	}
+	if err := r.terminalError(); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	generation := r.generation
+	if r.conn != nil {
+		r.log.Info("close connection: forced reconnect")
+		r.setPendingCause(generation, ReconnectCauseForced)
+		r.conn.Close()
+	}
+	r.mu.RUnlock()
+
+	return r.connect(generation, ErrForcedReconnect)
+}
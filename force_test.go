@@ -0,0 +1,89 @@
+package reconnect
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForceReconnect(t *testing.T) {
+	var (
+		dialer     FakeDialer
+		subscribes int32
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetSubscribeHandler(func(WsConnection) error {
+		atomic.AddInt32(&subscribes, 1)
+		return nil
+	})
+
+	if err := conn.ForceReconnect(); err != ErrNotDialed {
+		t.Fatalf("expected ErrNotDialed, got: %v", err)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A blocked reader sees the error of the closed connection and doesn't dial again
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	for atomic.LoadInt32(&conn.activeReads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if err := conn.ForceReconnect(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := <-readErr; err != errFakeConnClosed {
+		t.Fatalf("expected the error of the closed connection, got: %v", err)
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+	if n := atomic.LoadInt32(&subscribes); n != 2 {
+		t.Fatalf("the subscribe handler must be called for the new connection, calls: %d", n)
+	}
+	if n := conn.ReconnectTrend()[ReconnectCauseForced].Last5m; n != 1 {
+		t.Errorf("expected 1 forced reconnect, got %d", n)
+	}
+
+	// Errors of the connect attempt
+	dialer.SetError(errors.New("connection refused"))
+	if err := conn.ForceReconnect(); !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrDial, got: %v", err)
+	}
+
+	conn.Close()
+	if err := conn.ForceReconnect(); err != ErrConnClosed {
+		t.Fatalf("expected ErrConnClosed, got: %v", err)
+	}
+}
+
+func TestForceReconnectSubscribeError(t *testing.T) {
+	var dialer FakeDialer
+	subscribeErr := errors.New("session invalidated")
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetSubscribeHandler(func(WsConnection) error {
+		if dialer.Dials() > 1 {
+			return subscribeErr
+		}
+		return nil
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	var reason DisconnectReason
+	conn.OnThisConnectionClosed(func(r DisconnectReason) { reason = r })
+
+	if err := conn.ForceReconnect(); !errors.Is(err, ErrSubscribe) || !errors.Is(err, subscribeErr) {
+		t.Fatalf("expected ErrSubscribe, got: %v", err)
+	}
+	if reason.Err != ErrForcedReconnect || reason.Cause != ReconnectCauseForced {
+		t.Errorf("unexpected disconnect reason: %+v", reason)
+	}
+}
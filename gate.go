@@ -0,0 +1,162 @@
+package reconnect
+
+import (
+	"sync"
+	"time"
+)
+
+// ErrWriteGateClosed is returned by a non-blocking write gate when writes aren't allowed yet
+var ErrWriteGateClosed = newCodedError(CodeWriteGateClosed, "write gate is closed")
+
+// WriteGate decides whether user writes are allowed on the current connection.
+// Internal writes (for example, auto replies) aren't gated
+type WriteGate interface {
+	// Reset is called after every successful connect before the connection is used
+	Reset()
+	// MessageReceived is called for every data message read by 'ReConn' or by the subscribe handler
+	MessageReceived()
+	// Wait is called before every user write. It blocks or returns an error if the write isn't allowed
+	Wait() error
+	// Close is called by 'ReConn.Close'. Blocked 'Wait' calls must return
+	Close()
+}
+
+// ReopenableWriteGate is a 'WriteGate' that can be used again after 'Close'. 'ReConn.Redial'
+// calls 'Reopen' if the gate implements it. Otherwise, a closed gate keeps rejecting writes
+type ReopenableWriteGate interface {
+	WriteGate
+	// Reopen is called by 'ReConn.Redial' before the new connect
+	Reopen()
+}
+
+// CloneableWriteGate is a 'WriteGate' that can create a new gate with the same settings. 'ReConn.Clone'
+// passes the result of 'Clone' to the new instance. A gate that doesn't implement it isn't passed,
+// because gates have state of the connection
+type CloneableWriteGate interface {
+	WriteGate
+	// Clone returns a new gate with the same settings and the initial state
+	Clone() WriteGate
+}
+
+// AfterFirstMessageGate allows user writes only after at least one data message
+// was read on the current connection
+type AfterFirstMessageGate struct {
+	failFast bool
+
+	mu     sync.Mutex
+	open   chan struct{}
+	opened bool
+	closed bool
+}
+
+var (
+	_ ReopenableWriteGate = (*AfterFirstMessageGate)(nil)
+	_ CloneableWriteGate  = (*AfterFirstMessageGate)(nil)
+)
+
+// NewAfterFirstMessageGate creates a new 'AfterFirstMessageGate'. If 'failFast' is true,
+// writes return 'ErrWriteGateClosed' instead of blocking
+func NewAfterFirstMessageGate(failFast bool) *AfterFirstMessageGate {
+	return &AfterFirstMessageGate{
+		failFast: failFast,
+		open:     make(chan struct{}),
+	}
+}
+
+func (g *AfterFirstMessageGate) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.opened {
+		g.open = make(chan struct{})
+		g.opened = false
+	}
+}
+
+func (g *AfterFirstMessageGate) MessageReceived() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.opened {
+		close(g.open)
+		g.opened = true
+	}
+}
+
+func (g *AfterFirstMessageGate) Wait() error {
+	g.mu.Lock()
+	open, opened, closed := g.open, g.opened, g.closed
+	g.mu.Unlock()
+
+	switch {
+	case closed:
+		return ErrConnClosed
+	case opened:
+		return nil
+	case g.failFast:
+		return ErrWriteGateClosed
+	}
+
+	<-open
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrConnClosed
+	}
+	return nil
+}
+
+func (g *AfterFirstMessageGate) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+	if !g.opened {
+		close(g.open)
+		g.opened = true
+	}
+}
+
+func (g *AfterFirstMessageGate) Reopen() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = false
+	if g.opened {
+		g.open = make(chan struct{})
+		g.opened = false
+	}
+}
+
+func (g *AfterFirstMessageGate) Clone() WriteGate {
+	return NewAfterFirstMessageGate(g.failFast)
+}
+
+// gatedConn is passed to the subscribe handler when a write gate is set. Messages read
+// by the handler are reported to the gate
+type gatedConn struct {
+	WsConnection
+	gate WriteGate
+}
+
+func (c gatedConn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType, data, err = c.WsConnection.ReadMessage()
+	if err == nil {
+		c.gate.MessageReceived()
+	}
+	return messageType, data, err
+}
+
+func (c gatedConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return writeControl(c.WsConnection, messageType, data, deadline)
+}
+
+// SetWriteGate sets a gate for user writes. When the gate is set, the subscribe handler gets
+// a wrapper of the connection, so messages it reads are reported to the gate. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteGate(gate WriteGate) *ReConn {
+	r.configure(func() {
+		r.writeGate = gate
+	})
+	return r
+}
@@ -0,0 +1,97 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAfterFirstMessageGate(t *testing.T) {
+	violations := make(chan bool, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		// The client must not write before the welcome message
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		_, _, err := conn.ReadMessage()
+		violations <- err == nil
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetWriteGate(NewAfterFirstMessageGate(false))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- conn.WriteMessage(websocket.TextMessage, []byte("early"))
+	}()
+
+	if violated := <-violations; violated {
+		t.Error("the write wasn't blocked by the gate")
+	}
+
+	conn.Close()
+	if err := <-writeErr; !errors.Is(err, ErrConnClosed) {
+		t.Errorf("blocked write must return 'ErrConnClosed' after 'Close', got %v", err)
+	}
+}
+
+func TestAfterFirstMessageGateStates(t *testing.T) {
+	gate := NewAfterFirstMessageGate(true)
+	gate.Reset()
+
+	if err := gate.Wait(); !errors.Is(err, ErrWriteGateClosed) {
+		t.Errorf("expected 'ErrWriteGateClosed', got %v", err)
+	}
+
+	gate.MessageReceived()
+	if err := gate.Wait(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// Reconnect closes the gate again
+	gate.Reset()
+	if err := gate.Wait(); !errors.Is(err, ErrWriteGateClosed) {
+		t.Errorf("expected 'ErrWriteGateClosed' after reset, got %v", err)
+	}
+}
+
+func TestAfterFirstMessageGateSubscribeRead(t *testing.T) {
+	received := make(chan string, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte("welcome"))
+		_, data, _ := conn.ReadMessage()
+		received <- string(data)
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).
+		SetWriteGate(NewAfterFirstMessageGate(false)).
+		SetSubscribeHandler(func(conn WsConnection) error {
+			// The welcome message is read on the raw connection
+			_, _, err := conn.ReadMessage()
+			return err
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	}()
+	select {
+	case err := <-writeErr:
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a message read by the subscribe handler must open the gate")
+	}
+	if data := <-received; data != "hello" {
+		t.Errorf("unexpected message: %q", data)
+	}
+}
@@ -0,0 +1,122 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultCloseHandshakeTimeout is used when the timeout passed to 'CloseGracefully' isn't positive
+const defaultCloseHandshakeTimeout = time.Second
+
+// gracefulClose is the state of the close handshake
+type gracefulClose struct {
+	// peerClosed is closed when a reader gets the close frame of the peer or EOF
+	peerClosed chan struct{}
+	once       sync.Once
+}
+
+// CloseGracefully is like 'Close', but performs the websocket close handshake first: the close frame
+// with 'code' and 'reason' is written, then the close frame of the peer or EOF is awaited for up to
+// 'timeout'. The pre-close hook and the farewell message are handled before the handshake. Concurrent
+// reads get 'ErrConnClosed' and never reconnect. A blocked read can be interrupted at the end of
+// the timeout only if the connection supports read deadlines, as '*websocket.Conn' does
+func (r *ReConn) CloseGracefully(code int, reason string, timeout time.Duration) error {
+	if err := ValidateCloseCode(code); err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		timeout = defaultCloseHandshakeTimeout
+	}
+
+	untrack(r)
+
+	r.lifecycleMu.Lock()
+	defer r.lifecycleMu.Unlock()
+
+	if r.dialed.Get() && !r.closed.Get() {
+		r.callPreCloseHook()
+		r.sendFarewell()
+		if !r.closed.Get() {
+			// The close frame wasn't sent after the farewell ack
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			r.closeHandshake(ctx, code, reason)
+			cancel()
+		}
+	}
+	return r.closeLocked()
+}
+
+// closeHandshake writes the close frame and waits for the close frame of the peer until 'ctx' is done
+func (r *ReConn) closeHandshake(ctx context.Context, code int, reason string) {
+	g := &gracefulClose{peerClosed: make(chan struct{})}
+	r.gracefulClose.Store(g)
+	// The peer closes the connection after the close frame: don't reconnect
+	r.closed.Set(true)
+
+	r.mu.RLock()
+	conn := r.conn
+	r.mu.RUnlock()
+	if conn == nil {
+		return
+	}
+
+	if err := r.writeMessage(ctx, WriteSourceClose, websocket.CloseMessage, FormatCloseMessage(code, reason)); err != nil {
+		r.log.Error(fmt.Sprintf("couldn't send close frame: %s", err))
+		return
+	}
+
+	// Blocked reads return after the close frame of the peer, otherwise they are interrupted
+	// when 'ctx' is done. New reads fail immediately, so the lock is taken eventually
+	defer interruptReadOnDone(ctx, conn)()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-g.peerClosed:
+		r.log.Debug("close handshake is completed by a reader")
+		return
+	default:
+	}
+	if _, ok := conn.(interface{ SetReadDeadline(t time.Time) error }); !ok || r.conn != conn {
+		// The read can't be interrupted
+		return
+	}
+
+	if ra := r.readAhead; ra != nil {
+		// The connection is read by the read-ahead goroutine: it exits after the close frame of the peer
+		select {
+		case <-ra.done:
+			r.log.Debug("close handshake is completed")
+		case <-ctx.Done():
+			r.log.Error(fmt.Sprintf("close frame of the peer wasn't received: %s", ctx.Err()))
+		}
+		return
+	}
+
+	// Nobody reads the connection: read until the close frame of the peer
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if ctx.Err() != nil {
+				r.log.Error(fmt.Sprintf("close frame of the peer wasn't received: %s", ctx.Err()))
+			} else {
+				r.log.Debug("close handshake is completed")
+			}
+			return
+		}
+	}
+}
+
+// closingGracefully reports whether the close handshake is in progress. Reads must fail with 'ErrConnClosed'
+func (r *ReConn) closingGracefully() bool {
+	return r.loadGracefulClose() != nil
+}
+
+func (r *ReConn) loadGracefulClose() *gracefulClose {
+	g, _ := r.gracefulClose.Load().(*gracefulClose)
+	return g
+}
@@ -0,0 +1,146 @@
+package reconnect
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newCloseFrameServer starts a server that sends the received close frames to the returned channel.
+// The default close handler of gorilla/websocket replies with a close frame
+func newCloseFrameServer() (url string, closeFrames <-chan *websocket.CloseError, stop func()) {
+	frames := make(chan *websocket.CloseError, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					frames <- closeErr
+				}
+				return
+			}
+		}
+	})
+	return wsURL(server), frames, server.Close
+}
+
+func TestCloseGracefully(t *testing.T) {
+	url, closeFrames, stop := newCloseFrameServer()
+	defer stop()
+
+	conn := New().SetURL(url)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	waitActiveRead(conn)
+
+	start := time.Now()
+	if err := conn.CloseGracefully(4000, "bye", 5*time.Second); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the handshake must be completed by the reader, elapsed: %s", elapsed)
+	}
+	if err := <-readErr; err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed for the concurrent read, got: %v", err)
+	}
+	if frame := <-closeFrames; frame.Code != 4000 || frame.Text != "bye" {
+		t.Errorf("unexpected close frame: %+v", frame)
+	}
+	if state := conn.State(); state != StateClosed {
+		t.Errorf("expected closed state, got %s", state)
+	}
+}
+
+func TestCloseGracefullyWithoutReaders(t *testing.T) {
+	url, closeFrames, stop := newCloseFrameServer()
+	defer stop()
+
+	conn := New().SetURL(url)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	start := time.Now()
+	if err := conn.CloseGracefully(websocket.CloseNormalClosure, "", 5*time.Second); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the close frame of the peer must be read, elapsed: %s", elapsed)
+	}
+	if frame := <-closeFrames; frame.Code != websocket.CloseNormalClosure {
+		t.Errorf("unexpected close frame: %+v", frame)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("read after close must fail")
+	}
+}
+
+func TestCloseGracefullyTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	// The server never reads, so the close frame isn't answered
+	release := make(chan struct{})
+	server := newWsServer(func(conn *websocket.Conn) {
+		<-release
+	})
+	defer server.Close()
+	defer close(release)
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	waitActiveRead(conn)
+
+	start := time.Now()
+	if err := conn.CloseGracefully(websocket.CloseGoingAway, "", timeout); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < timeout || elapsed > 10*timeout {
+		t.Errorf("the handshake must be bounded by the timeout, elapsed: %s", elapsed)
+	}
+	if err := <-readErr; err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed for the interrupted read, got: %v", err)
+	}
+}
+
+func TestCloseGracefullyInvalidCode(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.CloseGracefully(1005, "", time.Second); !errors.Is(err, ErrInvalidCloseCode) {
+		t.Fatalf("expected ErrInvalidCloseCode, got: %v", err)
+	}
+	if !conn.IsConnected() {
+		t.Error("the instance must stay connected after an invalid code")
+	}
+}
+
+// waitActiveRead waits until a read is blocked on the connection
+func waitActiveRead(conn *ReConn) {
+	for atomic.LoadInt32(&conn.activeReads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+}
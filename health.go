@@ -0,0 +1,75 @@
+package reconnect
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// healthMaxSubscribeFailures is a number of subscribe failures since the last successful connect
+// that makes the connection unhealthy
+const healthMaxSubscribeFailures = 2
+
+// Healthy returns nil if the connection is alive and delivers data. Otherwise, it returns an error that
+// matches 'ErrUnhealthy' and names the failing condition:
+//
+//   - the subscribe handler failed at least 2 times since the last successful connect;
+//   - there's no connection;
+//   - the connection is flapping (see 'SetFlapDamping');
+//   - no message was received for 'maxStaleness' since the last message or connect. 0 disables the check.
+//
+// It doesn't block during reconnects, so it can be used by readiness probes (see 'HealthHandler')
+func (r *ReConn) Healthy(maxStaleness time.Duration) error {
+	if failures := atomic.LoadInt32(&r.subscribeFailures); failures >= healthMaxSubscribeFailures {
+		lastErr, _ := r.lastSubscribeErr.Load().(string)
+		return fmt.Errorf("%w: subscribe failed %d times since the last connect: %s", ErrUnhealthy, failures, lastErr)
+	}
+	if state := r.State(); state != StateConnected {
+		return fmt.Errorf("%w: not connected, state is %s", ErrUnhealthy, state)
+	}
+	if r.IsFlapping() {
+		return fmt.Errorf("%w: connection is flapping", ErrUnhealthy)
+	}
+	if maxStaleness > 0 {
+		lastActivity := atomic.LoadInt64(&r.lastConnectAt)
+		if messageAt := atomic.LoadInt64(&r.lastMessageAt); messageAt != 0 && messageAt > lastActivity {
+			lastActivity = messageAt
+		}
+		if staleness := time.Duration(monoNow() - lastActivity); staleness > maxStaleness {
+			return fmt.Errorf("%w: no messages for %s", ErrUnhealthy, staleness.Round(time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// HealthHandler returns an http handler that responds with 200 if 'Healthy' returns nil and
+// with 503 otherwise. The body contains the reason
+func (r *ReConn) HealthHandler(maxStaleness time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := r.Healthy(maxStaleness); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// trackSubscribeFailure must be called under 'r.mu' after every connect attempt
+func (r *ReConn) trackSubscribeFailure(err error) {
+	if err == nil {
+		atomic.StoreInt32(&r.subscribeFailures, 0)
+		return
+	}
+	if PhaseOf(err) == PhaseSubscribe {
+		r.lastSubscribeErr.Store(err.Error())
+		failures := atomic.AddInt32(&r.subscribeFailures, 1)
+		if !r.closed.Get() {
+			// Handlers interrupted by 'Close' aren't reported
+			r.notifySubscribeFailed(int(failures), err)
+			r.reportSubscribeFailure(err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package reconnect
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHealthy(t *testing.T) {
+	const maxStaleness = time.Minute
+
+	newConn := func(t *testing.T) (*ReConn, *FakeDialer) {
+		var dialer FakeDialer
+		conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).
+			SetBackoff(time.Millisecond, time.Millisecond, 1, false)
+		return conn, &dialer
+	}
+	dial := func(t *testing.T, conn *ReConn) {
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// prepare must leave the connection in the tested condition
+		prepare func(t *testing.T, conn *ReConn, dialer *FakeDialer)
+		// wantReason is a part of the error, empty if the connection must be healthy
+		wantReason string
+	}{
+		{
+			name: "healthy",
+			prepare: func(t *testing.T, conn *ReConn, dialer *FakeDialer) {
+				dial(t, conn)
+				dialer.Last().Push(websocket.TextMessage, []byte("msg"))
+				if _, _, err := conn.ReadMessage(); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			},
+		},
+		{
+			name:       "not dialed",
+			prepare:    func(*testing.T, *ReConn, *FakeDialer) {},
+			wantReason: "not connected, state is idle",
+		},
+		{
+			name: "disconnected",
+			prepare: func(t *testing.T, conn *ReConn, dialer *FakeDialer) {
+				dial(t, conn)
+				dialer.SetError(errors.New("connection refused"))
+				dialer.Last().Fail(errors.New("connection reset"))
+				conn.ReadMessage()
+			},
+			wantReason: "not connected, state is disconnected",
+		},
+		{
+			name: "flapping",
+			prepare: func(t *testing.T, conn *ReConn, _ *FakeDialer) {
+				dial(t, conn)
+				conn.flapping.Set(true)
+			},
+			wantReason: "connection is flapping",
+		},
+		{
+			name: "stale",
+			prepare: func(t *testing.T, conn *ReConn, dialer *FakeDialer) {
+				dial(t, conn)
+				dialer.Last().Push(websocket.TextMessage, []byte("msg"))
+				if _, _, err := conn.ReadMessage(); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				past := monoNow() - int64(2*maxStaleness)
+				atomic.StoreInt64(&conn.lastMessageAt, past)
+				atomic.StoreInt64(&conn.lastConnectAt, past)
+			},
+			wantReason: "no messages for 2m",
+		},
+		{
+			name: "no messages after connect",
+			prepare: func(t *testing.T, conn *ReConn, _ *FakeDialer) {
+				dial(t, conn)
+				atomic.StoreInt64(&conn.lastConnectAt, monoNow()-int64(2*maxStaleness))
+			},
+			wantReason: "no messages for 2m",
+		},
+		{
+			name: "subscribe failures",
+			prepare: func(t *testing.T, conn *ReConn, _ *FakeDialer) {
+				conn.SetSubscribeHandler(func(WsConnection) error {
+					return errors.New("invalid channel")
+				})
+				if err := conn.Dial(); !errors.Is(err, ErrSubscribe) {
+					t.Fatalf("expected ErrSubscribe, got: %v", err)
+				}
+				conn.ReadMessage()
+			},
+			wantReason: "subscribe failed 2 times since the last connect: subscribe error: invalid channel",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conn, dialer := newConn(t)
+			defer conn.Close()
+
+			tt.prepare(t, conn, dialer)
+
+			err := conn.Healthy(maxStaleness)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnhealthy) || !strings.Contains(err.Error(), tt.wantReason) {
+				t.Fatalf("expected an error with %q, got: %v", tt.wantReason, err)
+			}
+		})
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	defer conn.Close()
+
+	check := func(wantStatus int, wantBody string) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		conn.HealthHandler(time.Minute).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rec.Code != wantStatus || !strings.Contains(rec.Body.String(), wantBody) {
+			t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), wantStatus, wantBody)
+		}
+	}
+
+	check(http.StatusServiceUnavailable, "not connected")
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check(http.StatusOK, "ok")
+}
@@ -0,0 +1,134 @@
+package reconnect
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// payloadBuckets is a number of payload size buckets. Bucket 'i' contains sizes in [2^(i-1), 2^i),
+// bucket 0 contains empty payloads. The last bucket contains all sizes greater than or equal to 2^30
+const payloadBuckets = 32
+
+// PayloadSizeBuckets contains numbers of read messages per payload size bucket.
+// Bucket 'i' contains sizes in [2^(i-1), 2^i), bucket 0 contains empty payloads
+type PayloadSizeBuckets [payloadBuckets]uint64
+
+// PayloadSizeHistogram is a snapshot of payload sizes of read messages
+type PayloadSizeHistogram struct {
+	// Current contains sizes of messages read on the current connection
+	Current PayloadSizeBuckets
+	// Total contains sizes of all read messages
+	Total PayloadSizeBuckets
+}
+
+// payloadHistogram is a histogram with fixed exponential buckets. It must be allocated
+// separately to guarantee 64-bit alignment of the counters
+type payloadHistogram struct {
+	// current is updated by the read path
+	current PayloadSizeBuckets
+
+	// mu guards 'previous'
+	mu sync.Mutex
+	// previous contains sizes of messages read on the previous connections
+	previous PayloadSizeBuckets
+}
+
+func payloadBucket(size int) int {
+	i := bits.Len(uint(size))
+	if i >= payloadBuckets {
+		i = payloadBuckets - 1
+	}
+	return i
+}
+
+// Add is a single atomic increment
+func (h *payloadHistogram) Add(size int) {
+	atomic.AddUint64(&h.current[payloadBucket(size)], 1)
+}
+
+// NextGeneration must be called when a new connection is established
+func (h *payloadHistogram) NextGeneration() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range h.current {
+		h.previous[i] += atomic.SwapUint64(&h.current[i], 0)
+	}
+}
+
+func (h *payloadHistogram) Snapshot() PayloadSizeHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var res PayloadSizeHistogram
+	for i := range h.current {
+		res.Current[i] = atomic.LoadUint64(&h.current[i])
+		res.Total[i] = h.previous[i] + res.Current[i]
+	}
+	return res
+}
+
+// Percentile returns the upper bound of the bucket that contains the p-th percentile, 'p' is in [0, 1].
+// It returns 0 if there are no messages
+func (b PayloadSizeBuckets) Percentile(p float64) int {
+	var total uint64
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	// The rank of the percentile, starting from 1
+	rank := uint64(p*float64(total-1)) + 1
+
+	var count uint64
+	for i, n := range b {
+		count += n
+		if count >= rank {
+			return bucketBound(i)
+		}
+	}
+	return bucketBound(payloadBuckets - 1)
+}
+
+// bucketBound returns the max size of a bucket
+func bucketBound(i int) int {
+	if i == payloadBuckets-1 {
+		// The last bucket is unbounded
+		return math.MaxInt32
+	}
+	return 1<<uint(i) - 1
+}
+
+// SetPayloadSizeHistogram enables the histogram of payload sizes of read messages. After 'Dial' call
+// it does nothing
+func (r *ReConn) SetPayloadSizeHistogram(enabled bool) *ReConn {
+	r.configure(func() {
+		r.payloadSizes = nil
+		if enabled {
+			r.payloadSizes = &payloadHistogram{}
+		}
+	})
+	return r
+}
+
+// SizePercentile returns the p-th percentile ('p' is in [0, 1]) of payload sizes of all read messages,
+// rounded up to the bucket bound. It returns 0 if the histogram isn't enabled
+func (r *ReConn) SizePercentile(p float64) int {
+	if r.payloadSizes == nil {
+		return 0
+	}
+	return r.payloadSizes.Snapshot().Total.Percentile(p)
+}
+
+// PayloadSizes returns the histogram of payload sizes of read messages. It returns an empty histogram
+// if the histogram isn't enabled
+func (r *ReConn) PayloadSizes() PayloadSizeHistogram {
+	if r.payloadSizes == nil {
+		return PayloadSizeHistogram{}
+	}
+	return r.payloadSizes.Snapshot()
+}
@@ -0,0 +1,83 @@
+package reconnect
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPayloadBuckets(t *testing.T) {
+	var b PayloadSizeBuckets
+	if got := b.Percentile(0.5); got != 0 {
+		t.Errorf("empty histogram: got %d, want 0", got)
+	}
+
+	h := &payloadHistogram{}
+	// 90 small messages and 10 large ones
+	for i := 0; i < 90; i++ {
+		h.Add(100)
+	}
+	for i := 0; i < 10; i++ {
+		h.Add(5000)
+	}
+
+	b = h.Snapshot().Total
+	for _, tt := range []struct {
+		p    float64
+		want int
+	}{
+		{0, 127},
+		{0.5, 127},
+		{0.9, 127},
+		{0.91, 8191},
+		{0.99, 8191},
+		{1, 8191},
+	} {
+		if got := b.Percentile(tt.p); got != tt.want {
+			t.Errorf("p%v: got %d, want %d", tt.p*100, got, tt.want)
+		}
+	}
+
+	if got := payloadBucket(0); got != 0 {
+		t.Errorf("empty payloads must be in bucket 0, got %d", got)
+	}
+	if got := payloadBucket(1 << 40); got != payloadBuckets-1 {
+		t.Errorf("huge payloads must be in the last bucket, got %d", got)
+	}
+}
+
+func TestPayloadSizeHistogram(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		size := 10
+		if atomic.AddInt32(&connections, 1) > 1 {
+			size = 1000
+		}
+		conn.WriteMessage(websocket.BinaryMessage, bytes.Repeat([]byte("a"), size))
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetPayloadSizeHistogram(true)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	conn.ReadMessage()
+	// The connection is dropped
+	conn.ReadMessage()
+	conn.ReadMessage()
+
+	h := conn.PayloadSizes()
+	if h.Current[payloadBucket(1000)] != 1 || h.Current[payloadBucket(10)] != 0 {
+		t.Errorf("the current generation must contain only the last message: %v", h.Current)
+	}
+	if h.Total[payloadBucket(1000)] != 1 || h.Total[payloadBucket(10)] != 1 {
+		t.Errorf("the total histogram must contain both messages: %v", h.Total)
+	}
+	if got := conn.SizePercentile(1); got != 1023 {
+		t.Errorf("got %d, want 1023", got)
+	}
+}
@@ -0,0 +1,37 @@
+package reconnect
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCoreImports checks that the core package depends only on the standard library and gorilla.
+// Features with other dependencies belong to subpackages (see 'jsonrpc' and 'metrics')
+func TestCoreImports(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("couldn't parse %s: %s", name, err)
+		}
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			stdlib := !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+			if !stdlib && path != "github.com/gorilla/websocket" {
+				t.Errorf("%s imports %q", name, path)
+			}
+		}
+	}
+}
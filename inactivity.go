@@ -0,0 +1,61 @@
+package reconnect
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SetReadInactivityTimeout enables detection of dead (half-open) connections that don't return errors.
+// If a read is in progress, but neither a message nor a pong arrives within 'd', the connection is closed:
+// the read returns an error and reconnects as after any other connection error. The window starts at
+// the last message, pong, connect or read call, whichever is the latest. Unlike 'SetKeepAlive', it doesn't
+// need the server to support pings. Unlike 'SetReadTimeout', pongs keep the connection alive. 0 means no
+// timeout. After 'Dial' call it does nothing
+func (r *ReConn) SetReadInactivityTimeout(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.readInactivityTimeout = d
+	})
+	return r
+}
+
+// startReadInactivityCheck must be called once after 'r.dialed' is set
+func (r *ReConn) startReadInactivityCheck() {
+	if r.readInactivityTimeout <= 0 {
+		return
+	}
+	r.scheduler.Every(r.readInactivityTimeout/4, r.checkReadInactivity)
+}
+
+// lastReadActivity returns the monotonic time the inactivity window starts at
+func (r *ReConn) lastReadActivity() int64 {
+	last := atomic.LoadInt64(&r.lastMessageAt)
+	for _, t := range []int64{
+		atomic.LoadInt64(&r.lastPongAt),
+		atomic.LoadInt64(&r.lastConnectAt),
+		atomic.LoadInt64(&r.lastReadStartAt),
+	} {
+		if t > last {
+			last = t
+		}
+	}
+	return last
+}
+
+// checkReadInactivity is run by the scheduler
+func (r *ReConn) checkReadInactivity() {
+	if atomic.LoadInt32(&r.activeReads) == 0 {
+		// Nobody reads, so messages and pongs can't be observed
+		return
+	}
+	inactive := time.Duration(monoNow() - r.lastReadActivity())
+	if inactive < r.readInactivityTimeout {
+		return
+	}
+
+	r.mu.RLock()
+	generation := r.generation
+	r.mu.RUnlock()
+
+	r.closeDeadConn(generation, ReconnectCauseReadInactivity, fmt.Sprintf("no messages for %s", inactive.Round(time.Millisecond)))
+}
@@ -0,0 +1,81 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReadInactivityTimeout(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		conn.WriteMessage(websocket.TextMessage, []byte("1"))
+		conn.WriteMessage(websocket.TextMessage, []byte("2"))
+		// Go silent, but keep the connection open
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	const timeout = 200 * time.Millisecond
+
+	conn := New().SetURL(wsURL(server)).SetReadInactivityTimeout(timeout)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	start := time.Now()
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("read of the dead connection must fail")
+	}
+	if d := time.Since(start); d < timeout || d > 3*timeout {
+		t.Errorf("dead connection must be detected in about %s, got %s", timeout, d)
+	}
+
+	// The new connection has its own window
+	for i := 0; i < 2; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if n := atomic.LoadInt32(&connections); n != 2 {
+		t.Errorf("expected 2 connections, got %d", n)
+	}
+	if counts := conn.ReconnectTrend()[ReconnectCauseReadInactivity]; counts.Last5m != 1 {
+		t.Errorf("expected 1 reconnect because of inactivity, got %+v", counts)
+	}
+}
+
+func TestReadInactivityTimeoutPongs(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		// Pongs keep the connection alive
+		for i := 0; i < 10; i++ {
+			if err := conn.WriteControl(websocket.PongMessage, nil, time.Now().Add(time.Second)); err != nil {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("msg"))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetReadInactivityTimeout(200 * time.Millisecond)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "msg" {
+		t.Errorf("unexpected read result: %q, %v", data, err)
+	}
+}
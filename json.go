@@ -0,0 +1,223 @@
+package reconnect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// errJSONTrailingData is returned for a message with data after the first JSON value, like by 'json.Unmarshal'
+var errJSONTrailingData = errors.New("invalid character after top-level value")
+
+// ReadJSON reads the next message and unmarshals it into 'v'. Read errors are handled as
+// in 'ReadMessage'. An unmarshal error is wrapped into 'ErrJSON', the message is consumed.
+//
+// If the connection supports streaming reads, the message is decoded directly from the connection
+// without an intermediate buffer. Features that need raw messages (auto responders, taps, the sequence
+// extractor, the resume detector, the empty message policy, the farewell ack, read size histograms,
+// the read-ahead buffer and pending subscription acks) switch it to the plain 'ReadMessage' path. The paths differ only in
+// error messages. A subscription ack requested while 'ReadJSON' is blocked is decoded into 'v'
+func (r *ReConn) ReadJSON(v interface{}) error {
+	if r.needRawMessages() {
+		// Not 'ReadJSONFrom': it would use the streaming path of 'NextReader'
+		_, data, err := r.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("%w: %s", ErrJSON, err)
+		}
+		return nil
+	}
+	if !r.dialed.Get() {
+		return ErrNotDialed
+	}
+	if err := r.terminalError(); err != nil {
+		return err
+	}
+	r.enterRead()
+	defer r.exitRead()
+
+	var retried bool
+	generation, readErr, jsonErr := r.streamJSON(v)
+	for readErr != nil {
+		err := r.reconnectOnError(generation, r.checkPostUpgradeRejection(wrapReadLimitError(readErr)))
+		if !r.retryRead(generation, &retried) {
+			return err
+		}
+		generation, readErr, jsonErr = r.streamJSON(v)
+	}
+	if !r.beginDelivery() {
+		r.dropMessage(DropFeatureClosed, nil)
+		return ErrConnClosed
+	}
+	r.endDelivery()
+
+	if jsonErr != nil {
+		return fmt.Errorf("%w: %s", ErrJSON, jsonErr)
+	}
+	return nil
+}
+
+// WriteJSON marshals 'v' and writes it as a text message. Write errors are handled as
+// in 'WriteMessage'. A marshal error is wrapped into 'ErrJSON', nothing is written
+func (r *ReConn) WriteJSON(v interface{}) error {
+	return WriteJSONTo(r, v)
+}
+
+// needRawMessages reports whether read messages must be passed through 'filterMessage'
+func (r *ReConn) needRawMessages() bool {
+	return len(r.autoResponders) > 0 ||
+		r.sequenceExtractor != nil ||
+		r.resumeDetector != nil ||
+		r.emptyMessagePolicy != DeliverEmptyMessages ||
+		r.farewellAck != nil ||
+		r.readTransform != nil ||
+		r.payloadSizes != nil ||
+		r.adaptiveReadBufferEnabled() ||
+		atomic.LoadInt32(&r.taps.active) != 0 ||
+		r.ackWaiters.Len() > 0 ||
+		r.replayBuffer.Len() > 0 ||
+		r.readAheadSize > 0 ||
+		r.readAheadLeft.Len() > 0
+}
+
+// streamJSON returns the generation of the used connection, a transport error and an unmarshal error
+func (r *ReConn) streamJSON(v interface{}) (generation uint64, readErr, jsonErr error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	readErr, jsonErr = r.streamJSONLocked(v)
+	return r.generation, readErr, jsonErr
+}
+
+// streamJSONLocked must be called under 'r.mu' held for reading
+func (r *ReConn) streamJSONLocked(v interface{}) (readErr, jsonErr error) {
+	if r.closingGracefully() {
+		return ErrConnClosed, nil
+	}
+	if r.conn == nil {
+		return ErrNotConnected, nil
+	}
+
+	defer r.traceConnRegion(traceRegionRead)()
+	r.applyReadTimeout(r.conn)
+	n, readErr, jsonErr := readJSON(r.conn, v)
+	if readErr == nil {
+		r.countRead(n)
+		r.onMessageRead(nil)
+	}
+	return readErr, jsonErr
+}
+
+// ReadJSONFrom is like 'ReadJSON', but reads from any connection. For example, it can be
+// used in the subscribe handler
+func ReadJSONFrom(conn WsConnection, v interface{}) error {
+	_, readErr, jsonErr := readJSON(conn, v)
+	if readErr != nil {
+		return readErr
+	}
+	if jsonErr != nil {
+		return fmt.Errorf("%w: %s", ErrJSON, jsonErr)
+	}
+	return nil
+}
+
+// readJSON reads the next message of 'conn' and unmarshals it into 'v'. It decodes the message
+// directly from the connection if the connection supports streaming reads
+func readJSON(conn WsConnection, v interface{}) (n int, readErr, jsonErr error) {
+	c, ok := conn.(nextReader)
+	if !ok {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return 0, err, nil
+		}
+		return len(data), nil, json.Unmarshal(data, v)
+	}
+
+	_, reader, err := c.NextReader()
+	if err != nil {
+		return 0, err, nil
+	}
+	return decodeJSON(reader, v)
+}
+
+// jsonDecoder is a reusable 'json.Decoder'. 'json.Decoder' can't be reset, so it reads
+// from the wrapper that switches the source
+type jsonDecoder struct {
+	dec     *json.Decoder
+	src     io.Reader
+	n       int
+	readErr error
+}
+
+var jsonDecoderPool = sync.Pool{
+	New: func() interface{} {
+		d := &jsonDecoder{}
+		d.dec = json.NewDecoder(d)
+		return d
+	},
+}
+
+func (d *jsonDecoder) Read(p []byte) (int, error) {
+	n, err := d.src.Read(p)
+	d.n += n
+	if err != nil && err != io.EOF {
+		d.readErr = err
+	}
+	return n, err
+}
+
+// decodeJSON decodes a single JSON value from 'src'. Like 'json.Unmarshal', it fails on empty input
+// and data after the value. 'n' is the number of bytes read from 'src', 'readErr' is an error of 'src'
+func decodeJSON(src io.Reader, v interface{}) (n int, readErr, jsonErr error) {
+	d := jsonDecoderPool.Get().(*jsonDecoder)
+	d.src = src
+
+	jsonErr = d.decode(v)
+	readErr, n = d.readErr, d.n
+	reusable := jsonErr == nil && d.n <= maxPooledBufferSize
+
+	d.src, d.n, d.readErr = nil, 0, nil
+	if reusable {
+		// The decoder has consumed the whole input, so it can be reused. A failed decoder
+		// can have unread data in its buffer
+		jsonDecoderPool.Put(d)
+	}
+	if readErr != nil {
+		return n, readErr, nil
+	}
+	return n, nil, jsonErr
+}
+
+func (d *jsonDecoder) decode(v interface{}) error {
+	if err := d.dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	// Check there's nothing but whitespace after the value
+	if _, err := d.dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errJSONTrailingData
+		}
+		return err
+	}
+	return nil
+}
+
+// WriteJSONTo is like 'WriteJSON', but writes to any connection. For example, it can be
+// used in the subscribe handler
+func WriteJSONTo(conn WsConnection, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrJSON, err)
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
@@ -0,0 +1,188 @@
+package reconnect
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestJSON(t *testing.T) {
+	type message struct {
+		Op    string `json:"op"`
+		Value int    `json:"value"`
+	}
+
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetSubscribeHandler(func(conn WsConnection) error {
+		if err := WriteJSONTo(conn, message{Op: "subscribe"}); err != nil {
+			return err
+		}
+		var ack message
+		if err := ReadJSONFrom(conn, &ack); err != nil {
+			return err
+		}
+		if ack.Op != "subscribe" {
+			t.Errorf("got unexpected ack %+v", ack)
+		}
+		return nil
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(message{Op: "set", Value: 1}); err != nil {
+		t.Fatalf("unexpected write error: %s", err)
+	}
+	var msg message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("unexpected read error: %s", err)
+	}
+	if msg != (message{Op: "set", Value: 1}) {
+		t.Errorf("got unexpected message %+v", msg)
+	}
+
+	// Marshal errors: nothing is written
+	if err := conn.WriteJSON(make(chan int)); !errors.Is(err, ErrJSON) {
+		t.Errorf("expected ErrJSON, got: %v", err)
+	}
+
+	// Unmarshal errors: the message is consumed
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		t.Fatalf("unexpected write error: %s", err)
+	}
+	err := conn.ReadJSON(&msg)
+	if !errors.Is(err, ErrJSON) {
+		t.Errorf("expected ErrJSON, got: %v", err)
+	}
+	if CodeOf(err) != CodeJSON {
+		t.Errorf("got code %s, want %s", CodeOf(err), CodeJSON)
+	}
+
+	// The connection is still usable
+	if err := conn.WriteJSON(message{Op: "get"}); err != nil {
+		t.Fatalf("unexpected write error: %s", err)
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("unexpected read error: %s", err)
+	}
+	if msg.Op != "get" {
+		t.Errorf("got unexpected message %+v", msg)
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("json errors must not cause reconnects, got %d connections", n)
+	}
+}
+
+func TestReadJSONPaths(t *testing.T) {
+	type message struct {
+		Op    string `json:"op"`
+		Value int    `json:"value"`
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    message
+		wantErr bool
+	}{
+		{name: "valid", data: `{"op":"set","value":1}`, want: message{Op: "set", Value: 1}},
+		{name: "trailing whitespace", data: "{\"op\":\"set\"} \n\t", want: message{Op: "set"}},
+		{name: "trailing garbage", data: `{"op":"set"} x`, wantErr: true},
+		{name: "trailing delimiter", data: `{"op":"set"}}`, wantErr: true},
+		{name: "multiple documents", data: `{"op":"set"}{"op":"get"}`, wantErr: true},
+		{name: "multiple documents with newline", data: "{\"op\":\"set\"}\n{\"op\":\"get\"}", wantErr: true},
+		{name: "empty", data: ``, wantErr: true},
+		{name: "whitespace", data: ` `, wantErr: true},
+		{name: "truncated", data: `{"op":"se`, wantErr: true},
+		{name: "type mismatch", data: `[1]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// The sequence extractor switches 'ReadJSON' to the 'ReadMessage' path
+			conns := newStaticReConns(2, []byte(tt.data))
+			conns[1].sequenceExtractor = func(int, []byte) (uint64, bool) { return 0, false }
+
+			for i, conn := range conns {
+				// The decoder must be reusable after both successful and failed reads
+				for j := 0; j < 3; j++ {
+					var msg message
+					err := conn.ReadJSON(&msg)
+					if tt.wantErr {
+						if !errors.Is(err, ErrJSON) {
+							t.Fatalf("path %d: expected ErrJSON, got: %v", i, err)
+						}
+						continue
+					}
+					if err != nil {
+						t.Fatalf("path %d: unexpected error: %s", i, err)
+					}
+					if msg != tt.want {
+						t.Fatalf("path %d: got %+v, want %+v", i, msg, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReadJSONRawPath(t *testing.T) {
+	// The connection doesn't support streaming reads, and empty messages must be filtered
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetEmptyMessagePolicy(DropEmptyMessages)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialer.Last().Push(websocket.TextMessage, nil)
+	dialer.Last().Push(websocket.TextMessage, []byte(`"msg"`))
+
+	var v string
+	if err := conn.ReadJSON(&v); err != nil || v != "msg" {
+		t.Errorf("unexpected value: %q, error: %v", v, err)
+	}
+	if n := conn.DroppedEmptyMessages(); n != 1 {
+		t.Errorf("expected 1 dropped message, got %d", n)
+	}
+}
+
+func TestReadJSONFromTransportError(t *testing.T) {
+	conn := NewFakeConn()
+	connErr := errors.New("connection reset")
+	conn.Fail(connErr)
+
+	var v interface{}
+	if err := ReadJSONFrom(conn, &v); err != connErr {
+		t.Fatalf("transport errors must be returned as is, got: %v", err)
+	}
+
+	// An error in the middle of a streamed message
+	src := io.MultiReader(strings.NewReader(`{"op":`), failingReader{connErr})
+	if _, readErr, jsonErr := decodeJSON(src, &v); readErr != connErr || jsonErr != nil {
+		t.Fatalf("expected the transport error, got: %v, %v", readErr, jsonErr)
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
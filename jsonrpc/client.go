@@ -0,0 +1,195 @@
+// Package jsonrpc contains a JSON-RPC 2.0 client on top of 'reconnect.ReConn'. It uses only public
+// methods of the connection, so the core package doesn't depend on it
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+// ErrConnectionLost is returned by calls whose connection was lost before the response. The server
+// could have handled the request, so the call must be retried only if it's idempotent
+var ErrConnectionLost = errors.New("connection was lost before the response")
+
+// Error is an error object of a response
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return "jsonrpc error " + strconv.Itoa(e.Code) + ": " + e.Message
+}
+
+// NotificationFunc is called for every notification sent by the server. It's called by the read loop,
+// so it must not block
+type NotificationFunc func(method string, params json.RawMessage)
+
+type request struct {
+	Version string      `json:"jsonrpc"`
+	ID      *uint64     `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// message is a response or a notification
+type message struct {
+	ID     *uint64         `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// response is a response of a call or an error of its connection
+type response struct {
+	msg message
+	err error
+}
+
+// Client matches responses with calls by ids. Responses are read by 'Run', so messages of the connection
+// must not be read by other goroutines
+type Client struct {
+	rc     *reconnect.ReConn
+	notify NotificationFunc
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan response
+	// stopErr is the error of the stopped 'Run'
+	stopErr error
+}
+
+// NewClient creates a new client. 'notify' can be nil
+func NewClient(rc *reconnect.ReConn, notify NotificationFunc) *Client {
+	return &Client{
+		rc:      rc,
+		notify:  notify,
+		pending: make(map[uint64]chan response),
+	}
+}
+
+// Run reads messages until 'ctx' is done, the connection is closed or the max number of reconnect
+// attempts is exceeded. A failed read fails pending calls with 'ErrConnectionLost', the next read
+// reconnects. Messages that aren't valid JSON-RPC messages are ignored
+func (c *Client) Run(ctx context.Context) (err error) {
+	c.mu.Lock()
+	c.stopErr = nil
+	c.mu.Unlock()
+
+	defer func() {
+		c.failPending(err)
+
+		c.mu.Lock()
+		c.stopErr = err
+		c.mu.Unlock()
+	}()
+
+	for {
+		_, data, err := c.rc.ReadMessageContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, reconnect.ErrConnClosed) || errors.Is(err, reconnect.ErrMaxReconnectsExceeded) {
+				return err
+			}
+			c.failPending(fmt.Errorf("%w: %s", ErrConnectionLost, err))
+			continue
+		}
+
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch {
+		case msg.ID != nil:
+			c.mu.Lock()
+			resp, ok := c.pending[*msg.ID]
+			delete(c.pending, *msg.ID)
+			c.mu.Unlock()
+			if ok {
+				resp <- response{msg: msg}
+			}
+		case msg.Method != "" && c.notify != nil:
+			c.notify(msg.Method, msg.Params)
+		}
+	}
+}
+
+// Call sends a request and waits for the response. The result is decoded into 'result', it can be nil.
+// An error object of the response is returned as '*Error'. 'Run' must be running: after it stops, calls
+// return its error
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	resp := make(chan response, 1)
+
+	c.mu.Lock()
+	if err := c.stopErr; err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = resp
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(ctx, request{Version: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-resp:
+		switch {
+		case resp.err != nil:
+			return resp.err
+		case resp.msg.Error != nil:
+			return resp.msg.Error
+		case result == nil || resp.msg.Result == nil:
+			return nil
+		default:
+			return json.Unmarshal(resp.msg.Result, result)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification: the server doesn't respond to it
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	return c.write(ctx, request{Version: "2.0", Method: method, Params: params})
+}
+
+func (c *Client) write(ctx context.Context, req request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.rc.WriteMessageContext(ctx, websocket.TextMessage, data)
+}
+
+// failPending fails all pending calls. Responses are sent without blocking: every call has
+// a buffered channel and receives a single message
+func (c *Client) failPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, resp := range c.pending {
+		resp <- response{err: err}
+		delete(c.pending, id)
+	}
+}
@@ -0,0 +1,104 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+// newServer starts a server that handles methods 'add', 'fail' and 'drop'. Every response
+// is preceded by a notification
+func newServer() *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req struct {
+				ID     uint64 `json:"id"`
+				Method string `json:"method"`
+				Params []int  `json:"params"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "method": "progress", "params": req.Method})
+
+			resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+			switch req.Method {
+			case "add":
+				resp["result"] = req.Params[0] + req.Params[1]
+			case "fail":
+				resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+			case "drop":
+				return
+			}
+			conn.WriteJSON(resp)
+		}
+	}))
+}
+
+func TestClient(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	notifications := make(chan string, 10)
+	rc := reconnect.New().SetURL("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err := rc.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	client := NewClient(rc, func(method string, params json.RawMessage) {
+		notifications <- method + " " + string(params)
+	})
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- client.Run(context.Background())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var sum int
+	if err := client.Call(ctx, "add", []int{1, 2}, &sum); err != nil || sum != 3 {
+		t.Fatalf("unexpected result %d, error: %v", sum, err)
+	}
+	if n := <-notifications; n != `progress "add"` {
+		t.Errorf("unexpected notification: %s", n)
+	}
+
+	var rpcErr *Error
+	if err := client.Call(ctx, "fail", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("expected an error object, got %v", err)
+	}
+
+	// The connection is dropped before the response
+	if err := client.Call(ctx, "drop", nil, nil); !errors.Is(err, ErrConnectionLost) {
+		t.Errorf("expected ErrConnectionLost, got %v", err)
+	}
+	// The next call uses the new connection
+	if err := client.Call(ctx, "add", []int{2, 2}, &sum); err != nil || sum != 4 {
+		t.Fatalf("unexpected result %d, error: %v", sum, err)
+	}
+
+	rc.Close()
+	if err := <-runErr; !errors.Is(err, reconnect.ErrConnClosed) {
+		t.Errorf("expected ErrConnClosed, got %v", err)
+	}
+	if err := client.Call(ctx, "add", []int{1, 1}, nil); !errors.Is(err, reconnect.ErrConnClosed) {
+		t.Errorf("calls after 'Run' must fail, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SetKeepAlive enables pings every 'pingInterval'. If there's no pong within 'pongWait' after
+// a ping, the connection is closed, so the next read observes the error and reconnects. Pongs are
+// handled by read methods. Pings and user writes don't interleave. After 'Dial' call it does nothing
+func (r *ReConn) SetKeepAlive(pingInterval, pongWait time.Duration) *ReConn {
+	r.configure(func() {
+		r.keepAliveInterval = pingInterval
+		r.keepAlivePongWait = pongWait
+	})
+	return r
+}
+
+// startKeepAlive must be called once after 'r.dialed' is set
+func (r *ReConn) startKeepAlive() {
+	if r.keepAliveInterval <= 0 || r.keepAlivePongWait <= 0 {
+		return
+	}
+	r.scheduler.Every(r.keepAliveInterval, r.sendKeepAlivePing)
+	r.scheduler.Every(r.keepAlivePongWait/4, r.checkKeepAlivePong)
+}
+
+// keepAliveConn returns the generation of the current connection. The ping state is reset
+// when the connection is replaced. It's used only by the scheduler
+func (r *ReConn) keepAliveConn() (generation uint64, ok bool) {
+	r.mu.RLock()
+	generation, ok = r.generation, r.conn != nil
+	r.mu.RUnlock()
+
+	if ok && generation != r.keepAliveGeneration {
+		r.keepAliveGeneration = generation
+		r.keepAlivePingAt = 0
+	}
+	return generation, ok
+}
+
+// sendKeepAlivePing is run by the scheduler. The write is bounded by the interval to not stall other tasks
+func (r *ReConn) sendKeepAlivePing() {
+	if _, ok := r.keepAliveConn(); !ok {
+		// The next read or write reconnects
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.keepAliveInterval)
+	defer cancel()
+
+	sentAt := monoNow()
+	if err := r.writeMessage(ctx, WriteSourceHeartbeat, websocket.PingMessage, nil); err != nil {
+		r.log.Error(fmt.Sprintf("couldn't send keepalive ping: %s", err))
+		return
+	}
+	if r.keepAlivePingAt <= atomic.LoadInt64(&r.lastPongAt) {
+		// Track the oldest unanswered ping
+		r.keepAlivePingAt = sentAt
+	}
+}
+
+// checkKeepAlivePong is run by the scheduler
+func (r *ReConn) checkKeepAlivePong() {
+	generation, ok := r.keepAliveConn()
+	if !ok || r.keepAlivePingAt == 0 || r.keepAlivePingAt <= atomic.LoadInt64(&r.lastPongAt) {
+		return
+	}
+	if wait := time.Duration(monoNow() - r.keepAlivePingAt); wait >= r.keepAlivePongWait {
+		r.keepAlivePingAt = 0
+		r.closeDeadConn(generation, ReconnectCauseKeepAlive, fmt.Sprintf("no pong for %s", wait.Round(time.Millisecond)))
+	}
+}
@@ -0,0 +1,75 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestKeepAlive(t *testing.T) {
+	tests := []struct {
+		name            string
+		answerPings     bool
+		wantConnections int32
+	}{
+		{name: "alive", answerPings: true, wantConnections: 1},
+		{name: "dead", answerPings: false, wantConnections: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				connections int32
+				pings       int32
+				release     = make(chan struct{})
+			)
+			server := newWsServer(func(conn *websocket.Conn) {
+				go func() {
+					// Unblock the client reader
+					<-release
+					conn.Close()
+				}()
+				if atomic.AddInt32(&connections, 1) == 1 && !tt.answerPings {
+					// Don't read, so pings aren't answered
+					<-release
+					return
+				}
+				conn.SetPingHandler(func(appData string) error {
+					atomic.AddInt32(&pings, 1)
+					return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+				})
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			})
+			defer server.Close()
+
+			conn := New().SetURL(wsURL(server)).SetKeepAlive(20*time.Millisecond, 100*time.Millisecond)
+			if err := conn.Dial(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer func() {
+				close(release)
+				conn.Close()
+			}()
+
+			go func() {
+				for !conn.closed.Get() {
+					conn.ReadMessage()
+				}
+			}()
+
+			time.Sleep(500 * time.Millisecond)
+
+			if n := atomic.LoadInt32(&connections); n != tt.wantConnections {
+				t.Errorf("expected %d connections, got %d", tt.wantConnections, n)
+			}
+			if n := atomic.LoadInt32(&pings); n < 5 {
+				t.Errorf("expected pings every interval, got %d", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package reconnect
+
+import (
+	"time"
+)
+
+// WriteSource is a source of a write
+type WriteSource int
+
+const (
+	// WriteSourceUser is used for 'WriteMessage' and other public write methods
+	WriteSourceUser WriteSource = iota
+	// WriteSourceHeartbeat is used for keepalive and liveness pings and checkpoints
+	WriteSourceHeartbeat
+	// WriteSourceReply is used for auto-replies (see 'SetAutoResponder')
+	WriteSourceReply
+	// WriteSourceSubscribe is used for subscribe messages of live subscriptions
+	WriteSourceSubscribe
+	// WriteSourceClose is used for farewell messages and close frames
+	WriteSourceClose
+	// WriteSourceQueueFlush is used for messages buffered during a reconnect (see 'SetWriteBuffer')
+	WriteSourceQueueFlush
+
+	writeSources
+)
+
+func (s WriteSource) String() string {
+	switch s {
+	case WriteSourceUser:
+		return "user"
+	case WriteSourceHeartbeat:
+		return "heartbeat"
+	case WriteSourceReply:
+		return "reply"
+	case WriteSourceSubscribe:
+		return "subscribe"
+	case WriteSourceClose:
+		return "close"
+	case WriteSourceQueueFlush:
+		return "queue_flush"
+	default:
+		return "unknown"
+	}
+}
+
+// LatencyBuckets contains numbers of writes per latency bucket. Bucket 'i' contains latencies
+// in [2^(i-1), 2^i) microseconds, bucket 0 contains latencies less than 1 microsecond
+type LatencyBuckets [payloadBuckets]uint64
+
+// Percentile returns the upper bound of the bucket that contains the p-th percentile, 'p' is in [0, 1].
+// It returns 0 if there are no writes
+func (b LatencyBuckets) Percentile(p float64) time.Duration {
+	return time.Duration(PayloadSizeBuckets(b).Percentile(p)) * time.Microsecond
+}
+
+// latencyHistogram is a histogram of write latencies per source. It uses the same buckets
+// as 'payloadHistogram'. It must be allocated separately to guarantee 64-bit alignment of the counters
+type latencyHistogram struct {
+	sources [writeSources]payloadHistogram
+}
+
+// Add is a single atomic increment
+func (h *latencyHistogram) Add(source WriteSource, d time.Duration) {
+	if source < 0 || source >= writeSources {
+		return
+	}
+	h.sources[source].Add(int(d / time.Microsecond))
+}
+
+// Snapshot returns latencies of all writes
+func (h *latencyHistogram) Snapshot() map[WriteSource]LatencyBuckets {
+	res := make(map[WriteSource]LatencyBuckets, writeSources)
+	for source := range h.sources {
+		res[WriteSource(source)] = LatencyBuckets(h.sources[source].Snapshot().Total)
+	}
+	return res
+}
+
+// SetWriteLatencyHistogram enables the histogram of write latencies. Every write to the underlying
+// connection is timed, including internal ones (see 'WriteSource'). After 'Dial' call it does nothing
+func (r *ReConn) SetWriteLatencyHistogram(enabled bool) *ReConn {
+	r.configure(func() {
+		r.writeLatencies = nil
+		if enabled {
+			r.writeLatencies = &latencyHistogram{}
+		}
+	})
+	return r
+}
+
+// WriteLatencyPercentile returns the p-th percentile ('p' is in [0, 1]) of latencies of all writes,
+// rounded up to the bucket bound. It returns 0 if the histogram isn't enabled
+func (r *ReConn) WriteLatencyPercentile(p float64) time.Duration {
+	if r.writeLatencies == nil {
+		return 0
+	}
+	var total LatencyBuckets
+	for _, buckets := range r.writeLatencies.Snapshot() {
+		for i, n := range buckets {
+			total[i] += n
+		}
+	}
+	return total.Percentile(p)
+}
+
+// WriteLatencies returns the histograms of write latencies per source. It returns nil
+// if the histogram isn't enabled
+func (r *ReConn) WriteLatencies() map[WriteSource]LatencyBuckets {
+	if r.writeLatencies == nil {
+		return nil
+	}
+	return r.writeLatencies.Snapshot()
+}
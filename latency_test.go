@@ -0,0 +1,81 @@
+package reconnect
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slowConn is a 'WsConnection' with slow writes
+type slowConn struct {
+	staticConn
+	delay time.Duration
+}
+
+func (c *slowConn) WriteMessage(int, []byte) error {
+	time.Sleep(c.delay)
+	return nil
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	var b LatencyBuckets
+	if got := b.Percentile(0.5); got != 0 {
+		t.Errorf("empty histogram must return 0, got %s", got)
+	}
+
+	h := &latencyHistogram{}
+	h.Add(WriteSourceUser, 500*time.Nanosecond)
+	h.Add(WriteSourceUser, 3*time.Microsecond)
+	h.Add(WriteSourceUser, 100*time.Millisecond)
+	h.Add(writeSources, time.Second)
+
+	b = h.Snapshot()[WriteSourceUser]
+	if b[0] != 1 || b[2] != 1 {
+		t.Errorf("unexpected buckets: %v", b)
+	}
+	if got := b.Percentile(0); got != 0 {
+		t.Errorf("got p0 %s, want 0", got)
+	}
+	if got := b.Percentile(0.5); got != 3*time.Microsecond {
+		t.Errorf("got p50 %s, want 3µs", got)
+	}
+	// 100ms is in [2^16, 2^17) microseconds
+	if got, want := b.Percentile(1), (1<<17-1)*time.Microsecond; got != want {
+		t.Errorf("got p100 %s, want %s", got, want)
+	}
+}
+
+func TestWriteLatencyHistogram(t *testing.T) {
+	conn := New().SetWriteLatencyHistogram(true)
+	conn.dialed.Set(true)
+	conn.conn = &slowConn{delay: 5 * time.Millisecond}
+
+	if got := New().WriteLatencyPercentile(0.5); got != 0 {
+		t.Errorf("disabled histogram must return 0, got %s", got)
+	}
+	if got := New().WriteLatencies(); got != nil {
+		t.Errorf("disabled histogram must return nil, got %v", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := conn.WriteMessage(1, []byte("hello")); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := conn.writeMessage(context.Background(), WriteSourceHeartbeat, 9, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	counts := make(map[WriteSource]uint64)
+	for source, buckets := range conn.WriteLatencies() {
+		for _, n := range buckets {
+			counts[source] += n
+		}
+	}
+	if counts[WriteSourceUser] != 3 || counts[WriteSourceHeartbeat] != 1 || counts[WriteSourceReply] != 0 {
+		t.Errorf("unexpected counts per source: %v", counts)
+	}
+	if got := conn.WriteLatencyPercentile(0.5); got < 5*time.Millisecond {
+		t.Errorf("got p50 %s, want at least 5ms", got)
+	}
+}
@@ -0,0 +1,49 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+)
+
+// LeaderElectionFunc acquires the right to connect. It should block until the leadership is acquired
+// or 'ctx' is done. 'release' is called when the connection is lost or closed
+type LeaderElectionFunc func(ctx context.Context) (release func(), err error)
+
+// SetLeaderElection makes every dial wait for 'acquire', so only one of several replicas keeps
+// the connection. The leadership is held for the lifetime of the connection and released when
+// the connection is lost (before the next attempt) or closed by 'Close'. An error fails the attempt
+// with 'ErrDial' and 'ErrLeaderElection', so it's retried with the backoff like a dial failure.
+// 'AdoptConnection' doesn't acquire the leadership. After 'Dial' call it does nothing
+func (r *ReConn) SetLeaderElection(acquire LeaderElectionFunc) *ReConn {
+	r.configure(func() {
+		r.leaderElection = acquire
+	})
+	return r
+}
+
+// acquireLeadership calls the leader election callback. It must be called without holding 'r.mu'.
+// 'release' is nil if the leader election isn't set
+func (r *ReConn) acquireLeadership(ctx context.Context) (release func(), err error) {
+	if r.leaderElection == nil {
+		return nil, nil
+	}
+	release, err = r.leaderElection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrLeaderElection, err)
+	}
+	if release == nil {
+		release = func() {}
+	}
+	return release, nil
+}
+
+// holdLeadership ties 'release' to the current connection. It must be called under 'r.mu' after
+// a successful setup
+func (r *ReConn) holdLeadership(release func()) {
+	if release == nil {
+		return
+	}
+	if _, registered := r.connCleanups.Register(func(DisconnectReason) { release() }); !registered {
+		r.connCleanups.AddDue(release)
+	}
+}
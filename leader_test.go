@@ -0,0 +1,145 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestLeaderElection returns a mutex-based leader election shared by several connections
+func newTestLeaderElection() (acquire LeaderElectionFunc, releases *int32) {
+	sem := make(chan struct{}, 1)
+	releases = new(int32)
+	acquire = func(ctx context.Context) (func(), error) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return func() {
+			atomic.AddInt32(releases, 1)
+			<-sem
+		}, nil
+	}
+	return acquire, releases
+}
+
+func TestLeaderElection(t *testing.T) {
+	acquire, releases := newTestLeaderElection()
+
+	var leaderDialer, standbyDialer FakeDialer
+	leader := New().SetURL("ws://fake").SetDialFunc(leaderDialer.Dial).SetLeaderElection(acquire)
+	standby := New().SetURL("ws://fake").SetDialFunc(standbyDialer.Dial).SetLeaderElection(acquire)
+
+	if err := leader.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	standbyErr := make(chan error, 1)
+	go func() { standbyErr <- standby.Dial() }()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := standbyDialer.Dials(); n != 0 {
+		t.Fatalf("the standby must not dial while the leadership is held, dials: %d", n)
+	}
+
+	// The standby takes over after the leader is closed
+	leader.Close()
+	if n := atomic.LoadInt32(releases); n != 1 {
+		t.Fatalf("expected 1 release, got %d", n)
+	}
+
+	select {
+	case err := <-standbyErr:
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the standby hasn't taken over")
+	}
+	if n := standbyDialer.Dials(); n != 1 {
+		t.Fatalf("expected 1 dial of the standby, got %d", n)
+	}
+
+	standby.Close()
+	if n := atomic.LoadInt32(releases); n != 2 {
+		t.Errorf("expected 2 releases, got %d", n)
+	}
+}
+
+func TestLeaderElectionReconnect(t *testing.T) {
+	acquire, releases := newTestLeaderElection()
+
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetLeaderElection(acquire)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The leadership must be released before the next attempt, otherwise the reconnect blocks
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+	if n := atomic.LoadInt32(releases); n != 1 {
+		t.Fatalf("expected 1 release, got %d", n)
+	}
+
+	conn.Close()
+	if n := atomic.LoadInt32(releases); n != 2 {
+		t.Errorf("expected 2 releases, got %d", n)
+	}
+}
+
+func TestLeaderElectionError(t *testing.T) {
+	var dialer FakeDialer
+	acquireErr := errors.New("lease is held by another replica")
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).
+		SetLeaderElection(func(context.Context) (func(), error) {
+			return nil, acquireErr
+		})
+
+	err := conn.Dial()
+	if !errors.Is(err, ErrDial) || !errors.Is(err, ErrLeaderElection) {
+		t.Fatalf("expected ErrDial and ErrLeaderElection, got: %v", err)
+	}
+	if n := dialer.Dials(); n != 0 {
+		t.Errorf("expected no dials, got %d", n)
+	}
+}
+
+func TestLeaderElectionCloseWhileWaiting(t *testing.T) {
+	acquire, _ := newTestLeaderElection()
+
+	var leaderDialer, standbyDialer FakeDialer
+	leader := New().SetURL("ws://fake").SetDialFunc(leaderDialer.Dial).SetLeaderElection(acquire)
+	standby := New().SetURL("ws://fake").SetDialFunc(standbyDialer.Dial).SetLeaderElection(acquire)
+	defer leader.Close()
+
+	if err := leader.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	standbyErr := make(chan error, 1)
+	go func() { standbyErr <- standby.Dial() }()
+
+	time.Sleep(50 * time.Millisecond)
+	standby.Close()
+
+	select {
+	case err := <-standbyErr:
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("'Close' hasn't interrupted the leader election")
+	}
+	if n := standbyDialer.Dials(); n != 0 {
+		t.Errorf("expected no dials, got %d", n)
+	}
+}
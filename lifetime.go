@@ -0,0 +1,121 @@
+package reconnect
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LifetimeSummary describes the whole life of an instance. It's built from the counters of 'Stats' when
+// the instance is closed by 'Close' or 'Shutdown' or enters the terminal state (see 'SetMaxReconnectAttempts').
+// The counters aren't reset by 'Redial', so a summary covers all previous lives too
+type LifetimeSummary struct {
+	// Lifetime is the time from the first successful connect to the end. It's 0 if the instance
+	// has never connected
+	Lifetime time.Duration
+	// Uptime is the total time the instance was connected
+	Uptime     time.Duration
+	Reconnects uint64
+	// MessagesRead, MessagesWritten, BytesRead and BytesWritten are the same as in 'Stats'
+	MessagesRead    uint64
+	MessagesWritten uint64
+	BytesRead       uint64
+	BytesWritten    uint64
+	// WorstOutage is the longest gap in received messages caused by a reconnect (see 'LastOutage')
+	WorstOutage time.Duration
+	// LastError is the cause of the last lost connection or the error of the last failed connect attempt
+	LastError error
+}
+
+// String returns a one-line human-readable rendering of the summary
+func (s LifetimeSummary) String() string {
+	lastErr := "none"
+	if s.LastError != nil {
+		lastErr = s.LastError.Error()
+	}
+	return fmt.Sprintf(
+		"lifetime %s, uptime %s, %d reconnects, read %d messages (%d bytes), written %d messages (%d bytes), worst outage %s, last error: %s",
+		s.Lifetime, s.Uptime, s.Reconnects, s.MessagesRead, s.BytesRead, s.MessagesWritten, s.BytesWritten, s.WorstOutage, lastErr,
+	)
+}
+
+// OnClosedFunc is called once when the instance is closed or enters the terminal state
+type OnClosedFunc func(summary LifetimeSummary)
+
+// SetOnClosed sets a callback called with the lifetime summary when the instance is closed by 'Close'
+// or 'Shutdown' or enters the terminal state. It's called once until 'Redial'. The callback is called
+// as connection callbacks (see 'SetOnConnect'). After 'Dial' call it does nothing
+func (r *ReConn) SetOnClosed(f OnClosedFunc) *ReConn {
+	r.configure(func() {
+		r.onClosed = f
+	})
+	return r
+}
+
+// FinalSummary returns the lifetime summary built when the instance was closed or entered the terminal
+// state. The second value is false if the instance is still alive. The summary is reset by 'Redial'
+func (r *ReConn) FinalSummary() (LifetimeSummary, bool) {
+	r.lifetime.mu.Lock()
+	defer r.lifetime.mu.Unlock()
+
+	if r.lifetime.summary == nil {
+		return LifetimeSummary{}, false
+	}
+	return *r.lifetime.summary, true
+}
+
+// lifetimeTracker contains the final summary
+type lifetimeTracker struct {
+	mu      sync.Mutex
+	summary *LifetimeSummary
+}
+
+// finishLifetime builds the final summary, logs it and calls the callback. It does nothing
+// if the summary was already built
+func (r *ReConn) finishLifetime() {
+	r.lifetime.mu.Lock()
+	defer r.lifetime.mu.Unlock()
+
+	if r.lifetime.summary != nil {
+		return
+	}
+
+	summary := r.buildLifetimeSummary(time.Now())
+	r.lifetime.summary = &summary
+
+	r.log.Info("connection summary: " + summary.String())
+	if f := r.onClosed; f != nil {
+		r.callbacks.Push(func() { f(summary) })
+	}
+}
+
+// resetLifetime must be called on 'Redial'
+func (r *ReConn) resetLifetime() {
+	r.lifetime.mu.Lock()
+	defer r.lifetime.mu.Unlock()
+
+	r.lifetime.summary = nil
+}
+
+func (r *ReConn) buildLifetimeSummary(now time.Time) LifetimeSummary {
+	stats := r.Stats()
+	summary := LifetimeSummary{
+		Uptime:          r.stats.uptime(now),
+		Reconnects:      stats.Reconnects,
+		MessagesRead:    stats.MessagesRead,
+		MessagesWritten: stats.MessagesWritten,
+		BytesRead:       stats.BytesRead,
+		BytesWritten:    stats.BytesWritten,
+		LastError:       stats.LastError,
+	}
+	if first := atomic.LoadInt64(&r.stats.firstConnectedAt); first != 0 {
+		summary.Lifetime = now.Sub(time.Unix(0, first))
+	}
+
+	r.firstMessage.mu.Lock()
+	summary.WorstOutage = r.firstMessage.worstOutage
+	r.firstMessage.mu.Unlock()
+
+	return summary
+}
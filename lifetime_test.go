@@ -0,0 +1,123 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestLifetimeSummary(t *testing.T) {
+	var (
+		dialer  FakeDialer
+		log     recordLogger
+		summary = make(chan LifetimeSummary, 2)
+	)
+	conn := New().SetDialFunc(dialer.Dial).SetLogger(&log).SetOnClosed(func(s LifetimeSummary) {
+		summary <- s
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dialer.Last().Push(websocket.TextMessage, []byte("first"))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The read reconnects, the first message of the new connection ends the outage
+	connErr := errors.New("connection reset")
+	dialer.Last().Fail(connErr)
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	time.Sleep(20 * time.Millisecond)
+	dialer.Last().Push(websocket.TextMessage, []byte("second"))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, ok := conn.FinalSummary(); ok {
+		t.Fatal("no summary is expected before 'Close'")
+	}
+	conn.Close()
+	conn.Close()
+
+	got, ok := conn.FinalSummary()
+	if !ok {
+		t.Fatal("expected a summary after 'Close'")
+	}
+	if got.Reconnects != 1 || got.MessagesRead != 2 || got.BytesRead != 11 || got.MessagesWritten != 1 || got.BytesWritten != 3 {
+		t.Errorf("unexpected counters: %+v", got)
+	}
+	if got.WorstOutage < 20*time.Millisecond || got.Uptime <= 0 || got.Lifetime < got.Uptime {
+		t.Errorf("unexpected durations: %+v", got)
+	}
+	if !errors.Is(got.LastError, connErr) {
+		t.Errorf("unexpected last error: %v", got.LastError)
+	}
+	if !log.Contains("connection summary: lifetime ") || !log.Contains("1 reconnects, read 2 messages (11 bytes)") {
+		t.Error("summary must be logged")
+	}
+
+	// The callback is called once
+	select {
+	case s := <-summary:
+		if s != got {
+			t.Errorf("callback got %+v, want %+v", s, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("callback wasn't called")
+	}
+	select {
+	case s := <-summary:
+		t.Errorf("unexpected second summary: %+v", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 'Redial' starts a new life
+	if err := conn.Redial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := conn.FinalSummary(); ok {
+		t.Error("summary must be reset by 'Redial'")
+	}
+	conn.Close()
+	if s, ok := conn.FinalSummary(); !ok || s.Reconnects != 2 {
+		t.Errorf("unexpected summary after 'Redial': %+v", s)
+	}
+}
+
+func TestLifetimeSummaryTerminalState(t *testing.T) {
+	var (
+		dialer FakeDialer
+		log    recordLogger
+	)
+	conn := New().SetDialFunc(dialer.Dial).SetLogger(&log).SetMaxReconnectAttempts(1)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialErr := errors.New("connection refused")
+	dialer.SetError(dialErr)
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrMaxReconnectsExceeded) {
+		t.Fatalf("expected ErrMaxReconnectsExceeded, got %v", err)
+	}
+
+	got, ok := conn.FinalSummary()
+	if !ok {
+		t.Fatal("expected a summary in the terminal state")
+	}
+	if !errors.Is(got.LastError, dialErr) {
+		t.Errorf("unexpected last error: %v", got.LastError)
+	}
+	if !log.Contains("connection summary:") {
+		t.Error("summary must be logged")
+	}
+}
@@ -0,0 +1,101 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+)
+
+// Listen dials (if 'Dial' wasn't called yet) and starts a goroutine that reads messages and sends
+// them to the returned channel. Failed reads and reconnects don't stop it. The goroutine blocks
+// until the consumer receives a message, so messages aren't buffered. It stops when:
+//
+//   - 'ctx' is done;
+//   - 'Close' is called;
+//   - the max number of reconnect attempts is exceeded (see 'SetMaxReconnectAttempts') or the config
+//     is invalid: the error is sent to the error channel.
+//
+// Both channels are closed when the goroutine stops. Unlike 'RunManaged', 'Listen' doesn't close
+// the instance. Messages must not be read by other goroutines. A canceled 'ctx' doesn't interrupt
+// a pending reconnect wait
+func (r *ReConn) Listen(ctx context.Context) (<-chan Message, <-chan error) {
+	messages := make(chan Message)
+	errs := make(chan error, 1)
+
+	r.goroutines.Go(func() {
+		defer close(messages)
+		defer close(errs)
+
+		if err := r.listen(ctx, messages); err != nil {
+			errs <- err
+		}
+	})
+
+	return messages, errs
+}
+
+// listen reads messages until 'ctx' is done or 'Close' is called. It returns a terminal error
+func (r *ReConn) listen(ctx context.Context, messages chan<- Message) error {
+	if err := r.DialContext(ctx); err != nil && err != ErrAlreadyDialed {
+		if err := r.terminalError(); err != nil {
+			return err
+		}
+		if ctx.Err() != nil || r.closed.Get() {
+			return nil
+		}
+		if !r.dialed.Get() {
+			// Invalid config
+			return err
+		}
+		// The next read reconnects
+		r.log.Error(fmt.Sprintf("listen: dial error: %s", err))
+	}
+
+	// 'Close' interrupts a blocked read and send
+	readCtx, cancel := r.closeContext(ctx)
+	defer cancel()
+
+	if r.deliverControlFrames {
+		r.controlFrames.Reset()
+		r.listening.Set(true)
+		defer r.listening.Set(false)
+	}
+
+	for {
+		messageType, data, err := r.ReadMessageContext(readCtx)
+		if err := r.terminalError(); err != nil {
+			return err
+		}
+		if readCtx.Err() != nil || r.closed.Get() {
+			return nil
+		}
+		// Control frames are handled during the read, so they precede the read message
+		if !r.sendControlFrames(readCtx, messages) {
+			return nil
+		}
+		if err != nil {
+			continue
+		}
+
+		select {
+		case messages <- Message{Type: messageType, Data: data}:
+		case <-readCtx.Done():
+			return nil
+		}
+	}
+}
+
+// sendControlFrames sends control frames queued during the last read (see 'SetDeliverControlFrames').
+// It returns false if 'ctx' is done
+func (r *ReConn) sendControlFrames(ctx context.Context, messages chan<- Message) bool {
+	for {
+		msg, ok := r.controlFrames.Pop()
+		if !ok {
+			return true
+		}
+		select {
+		case messages <- Message{Type: msg.Type, Data: msg.Data}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
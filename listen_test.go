@@ -0,0 +1,217 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// receiveMessage fails the test if no message is received in time
+func receiveMessage(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("the message channel is closed")
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+	}
+	return Message{}
+}
+
+// waitListenStopped fails the test if the channels returned by 'Listen' aren't closed in time
+func waitListenStopped(t *testing.T, messages <-chan Message, errs <-chan error) error {
+	t.Helper()
+
+	var err error
+	timeout := time.After(time.Second)
+	for messages != nil || errs != nil {
+		select {
+		case _, ok := <-messages:
+			if !ok {
+				messages = nil
+			}
+		case e, ok := <-errs:
+			if !ok {
+				errs = nil
+			} else {
+				err = e
+			}
+		case <-timeout:
+			t.Fatal("'Listen' hasn't stopped")
+		}
+	}
+	return err
+}
+
+func TestListen(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	goroutines := conn.InternalGoroutines()
+	messages, errs := conn.Listen(ctx)
+
+	dialer.Last().Push(websocket.TextMessage, []byte("first"))
+	if msg := receiveMessage(t, messages); string(msg.Data) != "first" {
+		t.Fatalf("unexpected message: %q", msg.Data)
+	}
+
+	// Reconnect is transparent
+	dialer.Last().Fail(errors.New("connection reset"))
+	for dialer.Dials() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	dialer.Last().Push(websocket.TextMessage, []byte("second"))
+	if msg := receiveMessage(t, messages); string(msg.Data) != "second" {
+		t.Fatalf("unexpected message: %q", msg.Data)
+	}
+
+	// The goroutine blocked on a send stops too
+	dialer.Last().Push(websocket.TextMessage, []byte("unread"))
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := waitListenStopped(t, messages, errs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for conn.InternalGoroutines() != goroutines {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestListenClose(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+
+	// 'Listen' dials
+	messages, errs := conn.Listen(context.Background())
+	for conn.State() != StateConnected {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 'Close' interrupts the blocked read
+	conn.Close()
+	if err := waitListenStopped(t, messages, errs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestListenMaxReconnects(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).
+		SetMaxReconnectAttempts(2).
+		SetBackoff(time.Millisecond, time.Millisecond, 1, false)
+	defer conn.Close()
+
+	messages, errs := conn.Listen(context.Background())
+	for dialer.Dials() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+
+	err := waitListenStopped(t, messages, errs)
+	if !errors.Is(err, ErrMaxReconnectsExceeded) {
+		t.Fatalf("expected ErrMaxReconnectsExceeded, got: %v", err)
+	}
+}
+
+func TestListenControlFrames(t *testing.T) {
+	var answered int32
+	pongs := make(chan string, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.SetPongHandler(func(appData string) error {
+			pongs <- appData
+			return nil
+		})
+		// Unblock the server if the pong isn't sent
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("a"))
+		conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Time{})
+		conn.WriteMessage(websocket.TextMessage, []byte("b"))
+		conn.WriteControl(websocket.PongMessage, []byte("pong"), time.Time{})
+		select {
+		case appData := <-pongs:
+			if appData == "ping" {
+				atomic.StoreInt32(&answered, 1)
+			}
+		case <-time.After(time.Second):
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4000, "bye"))
+		time.Sleep(time.Second)
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetDeliverControlFrames(true)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, _ := conn.Listen(ctx)
+
+	want := []Message{
+		{Type: websocket.TextMessage, Data: []byte("a")},
+		{Type: websocket.PingMessage, Data: []byte("ping")},
+		{Type: websocket.TextMessage, Data: []byte("b")},
+		{Type: websocket.PongMessage, Data: []byte("pong")},
+		{Type: websocket.CloseMessage, Data: websocket.FormatCloseMessage(4000, "bye")},
+		// The next connection
+		{Type: websocket.TextMessage, Data: []byte("a")},
+	}
+	for i, w := range want {
+		if msg := receiveMessage(t, messages); msg.Type != w.Type || string(msg.Data) != string(w.Data) {
+			t.Fatalf("message %d: got %d %q, want %d %q", i, msg.Type, msg.Data, w.Type, w.Data)
+		}
+	}
+
+	if atomic.LoadInt32(&answered) != 1 {
+		t.Error("the ping must be answered")
+	}
+}
+
+func TestListenNoControlFrames(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Time{})
+		conn.WriteMessage(websocket.TextMessage, []byte("a"))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, _ := conn.Listen(ctx)
+
+	if msg := receiveMessage(t, messages); msg.Type != websocket.TextMessage || string(msg.Data) != "a" {
+		t.Errorf("control frames must not be delivered by default, got %d %q", msg.Type, msg.Data)
+	}
+}
@@ -0,0 +1,90 @@
+package reconnect
+
+import (
+	"time"
+)
+
+// LoggerV2 is a 'Logger' that accepts structured data of events: url, attempt number, elapsed time
+// and etc. If the logger passed to 'SetLogger' implements it, events with data are logged by
+// the '...Fields' methods. 'fields' must not be modified or retained. Loggers that implement
+// only 'Logger' get the same messages without data
+type LoggerV2 interface {
+	Logger
+	DebugFields(msg string, fields map[string]interface{})
+	InfoFields(msg string, fields map[string]interface{})
+	ErrorFields(msg string, fields map[string]interface{})
+}
+
+// WarnLogger can be implemented by a 'Logger' to receive warnings: likely unintended situations that
+// don't break anything, for example, suspicious configuration (see 'Validate'). Loggers that don't
+// implement it get warnings as errors
+type WarnLogger interface {
+	Warn(msg string)
+}
+
+// warn logs 'msg' with 'Warn' if the logger implements 'WarnLogger'
+func (r *ReConn) warn(msg string) {
+	if log, ok := r.log.(WarnLogger); ok {
+		log.Warn(msg)
+		return
+	}
+	r.log.Error(msg)
+}
+
+// Keys of structured log fields
+const (
+	LogFieldURL        = "url"
+	LogFieldAttempt    = "attempt"
+	LogFieldElapsed    = "elapsed"
+	LogFieldError      = "error"
+	LogFieldGeneration = "generation"
+	LogFieldDelay      = "delay"
+)
+
+type logLevel int
+
+const (
+	logLevelDebug logLevel = iota
+	logLevelInfo
+	logLevelError
+)
+
+// attemptLogFields returns fields of a connect attempt started at 'start'. 'err' can be nil.
+// It must be called under 'r.mu'
+func (r *ReConn) attemptLogFields(url string, start time.Time, err error) map[string]interface{} {
+	fields := map[string]interface{}{
+		LogFieldURL:        url,
+		LogFieldAttempt:    r.connectFailures + 1,
+		LogFieldElapsed:    time.Since(start),
+		LogFieldGeneration: r.generation,
+	}
+	if err != nil {
+		fields[LogFieldError] = err
+	}
+	return fields
+}
+
+// logFields logs 'msg' with 'fields' if the logger implements 'LoggerV2'. Otherwise, only 'msg' is logged
+func (r *ReConn) logFields(level logLevel, msg string, fields map[string]interface{}) {
+	log, ok := r.log.(LoggerV2)
+	if !ok {
+		switch level {
+		case logLevelDebug:
+			r.log.Debug(msg)
+		case logLevelInfo:
+			r.log.Info(msg)
+		default:
+			r.log.Error(msg)
+		}
+		return
+	}
+
+	switch level {
+	case logLevelDebug:
+		log.DebugFields(msg, fields)
+	case logLevelInfo:
+		log.InfoFields(msg, fields)
+	default:
+		log.ErrorFields(msg, fields)
+	}
+}
@@ -0,0 +1,109 @@
+package reconnect
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fieldsLogger records events logged with structured fields
+type fieldsLogger struct {
+	recordLogger
+
+	mu     sync.Mutex
+	events []fieldsEvent
+}
+
+type fieldsEvent struct {
+	msg    string
+	fields map[string]interface{}
+}
+
+var _ LoggerV2 = (*fieldsLogger)(nil)
+
+func (l *fieldsLogger) recordFields(msg string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fieldsEvent{msg: msg, fields: fields})
+}
+
+func (l *fieldsLogger) DebugFields(msg string, fields map[string]interface{}) {
+	l.recordFields(msg, fields)
+}
+func (l *fieldsLogger) InfoFields(msg string, fields map[string]interface{}) {
+	l.recordFields(msg, fields)
+}
+func (l *fieldsLogger) ErrorFields(msg string, fields map[string]interface{}) {
+	l.recordFields(msg, fields)
+}
+
+func (l *fieldsLogger) Events() []fieldsEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]fieldsEvent(nil), l.events...)
+}
+
+func TestLoggerV2(t *testing.T) {
+	var (
+		dialer FakeDialer
+		log    fieldsLogger
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetLogger(&log)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialErr := errors.New("connection refused")
+	dialer.SetError(dialErr)
+	dialer.Last().Fail(errors.New("connection reset"))
+	conn.ReadMessage()
+
+	events := log.Events()
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events (connect, connected, close, connect, dial error), got %d: %+v", len(events), events)
+	}
+	if events[0].msg != "connect to 'ws://fake'" || events[0].fields[LogFieldURL] != "ws://fake" || events[0].fields[LogFieldAttempt] != 1 {
+		t.Errorf("unexpected connect event: %+v", events[0])
+	}
+	if _, ok := events[1].fields[LogFieldElapsed].(time.Duration); !ok || events[1].fields[LogFieldGeneration] != uint64(1) {
+		t.Errorf("unexpected connected event: %+v", events[1])
+	}
+	if events[2].msg != "close previous connection" || events[2].fields[LogFieldError] == nil {
+		t.Errorf("unexpected close event: %+v", events[2])
+	}
+
+	// The dial error is logged with the attempt data
+	dialEvent := events[4]
+	if err, ok := dialEvent.fields[LogFieldError].(error); !ok || !errors.Is(err, dialErr) {
+		t.Errorf("dial error event must contain the error: %+v", dialEvent)
+	}
+	if dialEvent.fields[LogFieldURL] != "ws://fake" || dialEvent.fields[LogFieldAttempt] != 1 {
+		t.Errorf("unexpected dial error event: %+v", dialEvent)
+	}
+	if _, ok := dialEvent.fields[LogFieldElapsed].(time.Duration); !ok {
+		t.Errorf("dial error event must contain the elapsed time: %+v", dialEvent)
+	}
+	if log.Contains("connect to") {
+		t.Error("events with fields must not be logged by 'Logger' methods")
+	}
+}
+
+func TestLoggerV1Messages(t *testing.T) {
+	var (
+		dialer FakeDialer
+		log    recordLogger
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetLogger(&log)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{"connect to 'ws://fake'", "connected to 'ws://fake'"} {
+		if !log.Contains(msg) {
+			t.Errorf("message '%s' must be logged", msg)
+		}
+	}
+}
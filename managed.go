@@ -0,0 +1,76 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+)
+
+// ManagedOptions is used by 'RunManaged'
+type ManagedOptions struct {
+	// Handler is called for every read message. A non-nil error stops 'RunManaged'
+	Handler func(messageType int, data []byte) error
+}
+
+// RunManaged dials (if 'Dial' wasn't called yet) and reads messages until one of the following
+// happens:
+//
+//   - 'ctx' is done: 'ctx.Err()' is returned;
+//   - the handler returns an error: the error is returned;
+//   - the max number of reconnect attempts is exceeded: '*MaxReconnectsError' is returned;
+//   - the max number of subscribe failures is exceeded: '*SubscribeFailuresError' is returned;
+//   - 'Close' is called by another goroutine: nil is returned.
+//
+// Failed reads and reconnects don't stop it. Before return, the instance is closed and all internal
+// goroutines have exited, so it can be used as an 'errgroup.Group' member. Concurrent triggers are safe.
+// When 'Dial' was called before, a canceled 'ctx' doesn't interrupt a pending reconnect wait
+func (r *ReConn) RunManaged(ctx context.Context, opts ManagedOptions) error {
+	if opts.Handler == nil {
+		return fmt.Errorf("%w: handler is nil", ErrInvalidConfig)
+	}
+
+	defer func() {
+		// Close is idempotent, so it's safe to call it after an explicit 'Close'
+		r.Close()
+		r.goroutines.Wait()
+	}()
+
+	if err := r.DialContext(ctx); err != nil && err != ErrAlreadyDialed {
+		if done, err := r.managedDone(ctx); done {
+			return err
+		}
+		// The next read reconnects
+		r.log.Error(fmt.Sprintf("managed dial error: %s", err))
+	}
+
+	// 'Close' interrupts a blocked read
+	readCtx, cancel := r.closeContext(ctx)
+	defer cancel()
+
+	for {
+		messageType, data, err := r.ReadMessageContext(readCtx)
+		if done, err := r.managedDone(ctx); done {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+
+		if err := opts.Handler(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+// managedDone reports whether 'RunManaged' must return and the error to return
+func (r *ReConn) managedDone(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return true, err
+	}
+	if err := r.terminalError(); err != nil {
+		return true, err
+	}
+	if r.closed.Get() {
+		return true, nil
+	}
+	return false, nil
+}
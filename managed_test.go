@@ -0,0 +1,114 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRunManaged(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	defer server.Close()
+
+	silentServer := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer silentServer.Close()
+
+	errHandler := errors.New("handler error")
+
+	tests := []struct {
+		name    string
+		url     string
+		handler func(int, []byte) error
+		// trigger is called in another goroutine
+		trigger func(conn *ReConn, cancel context.CancelFunc)
+		// wantErrs contains allowed errors
+		wantErrs []error
+	}{
+		{
+			name:     "context cancel",
+			url:      wsURL(silentServer),
+			trigger:  func(_ *ReConn, cancel context.CancelFunc) { cancel() },
+			wantErrs: []error{context.Canceled},
+		},
+		{
+			name:     "handler error",
+			url:      wsURL(server),
+			handler:  func(int, []byte) error { return errHandler },
+			wantErrs: []error{errHandler},
+		},
+		{
+			name:     "explicit close",
+			url:      wsURL(silentServer),
+			trigger:  func(conn *ReConn, _ context.CancelFunc) { conn.Close() },
+			wantErrs: []error{nil},
+		},
+		{
+			name: "concurrent triggers",
+			url:  wsURL(server),
+			trigger: func(conn *ReConn, cancel context.CancelFunc) {
+				go conn.Close()
+				go cancel()
+				conn.Close()
+			},
+			wantErrs: []error{nil, context.Canceled},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			conn := New().SetURL(tt.url).SetReaderLivenessTimeout(time.Minute, false)
+
+			handler := tt.handler
+			if handler == nil {
+				handler = func(int, []byte) error { return nil }
+			}
+			if tt.trigger != nil {
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					tt.trigger(conn, cancel)
+				}()
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- conn.RunManaged(ctx, ManagedOptions{Handler: handler})
+			}()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("RunManaged didn't return")
+			}
+
+			allowed := false
+			for _, wantErr := range tt.wantErrs {
+				allowed = allowed || err == wantErr
+			}
+			if !allowed {
+				t.Errorf("got error %v, want one of %v", err, tt.wantErrs)
+			}
+
+			if n := conn.InternalGoroutines(); n != 0 {
+				t.Errorf("internal goroutines must be stopped, got %d", n)
+			}
+			if _, _, err := conn.ReadMessage(); err == nil {
+				t.Error("the instance must be closed")
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// SetMaxMessageSize sets a max size of a read message in bytes. The limit is applied to every new
+// connection. If a message exceeds it, the connection is closed with 'CloseMessageTooBig', the read
+// returns 'ErrMessageTooBig' and reconnects. 0 means no limit. After 'Dial' call it does nothing
+func (r *ReConn) SetMaxMessageSize(n int64) *ReConn {
+	r.configure(func() {
+		r.maxMessageSize = n
+	})
+	return r
+}
+
+// applyReadLimit must be called under 'r.mu' for every new connection
+func (r *ReConn) applyReadLimit(conn WsConnection) {
+	if r.maxMessageSize <= 0 {
+		return
+	}
+	if c, ok := conn.(interface{ SetReadLimit(limit int64) }); ok {
+		c.SetReadLimit(r.maxMessageSize)
+	}
+}
+
+// wrapReadLimitError wraps errors caused by the read limit with 'ErrMessageTooBig'
+func wrapReadLimitError(err error) error {
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return fmt.Errorf("%w: %s", ErrMessageTooBig, err)
+	}
+	return err
+}
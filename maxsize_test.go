@@ -0,0 +1,56 @@
+package reconnect
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSetMaxMessageSize(t *testing.T) {
+	var connections int32
+	closeCodes := make(chan int, 2)
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) <= 2 {
+			conn.WriteMessage(websocket.BinaryMessage, bytes.Repeat([]byte("a"), 2048))
+			_, _, err := conn.ReadMessage()
+			if closeErr, ok := err.(*websocket.CloseError); ok {
+				closeCodes <- closeErr.Code
+			}
+			return
+		}
+		conn.WriteMessage(websocket.BinaryMessage, bytes.Repeat([]byte("a"), 1024))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetMaxMessageSize(1024)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// The limit must be applied to the first and to the second connection
+	for i := 0; i < 2; i++ {
+		_, _, err := conn.ReadMessage()
+		if !errors.Is(err, ErrMessageTooBig) || CodeOf(err) != CodeTooLarge {
+			t.Fatalf("read %d: expected ErrMessageTooBig, got: %v", i, err)
+		}
+		if code := <-closeCodes; code != websocket.CloseMessageTooBig {
+			t.Errorf("read %d: unexpected close code: %d", i, code)
+		}
+	}
+
+	_, data, err := conn.ReadMessage()
+	if err != nil || len(data) != 1024 {
+		t.Fatalf("unexpected read result: %d bytes, %v", len(data), err)
+	}
+	if n := atomic.LoadInt32(&connections); n != 3 {
+		t.Errorf("expected 3 connections, got %d", n)
+	}
+	if n := conn.ReconnectTrend()[ReconnectCauseMessageTooBig].Last5m; n != 2 {
+		t.Errorf("expected 2 reconnects because of too big messages, got %d", n)
+	}
+}
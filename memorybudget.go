@@ -0,0 +1,168 @@
+package reconnect
+
+import (
+	"sync"
+)
+
+// MemoryComponent is an internal buffer accounted by the memory budget (see 'SetMemoryBudget')
+type MemoryComponent int
+
+const (
+	// MemoryWriteBuffer is the write buffer (see 'SetWriteBuffer')
+	MemoryWriteBuffer MemoryComponent = iota
+	// MemoryReadAhead is the read-ahead queue including messages kept from dropped connections
+	// (see 'SetReadAheadBuffer')
+	MemoryReadAhead
+	// MemoryReplayBuffer contains messages read while waiting for subscription acks (see 'SubscribeWithAck')
+	MemoryReplayBuffer
+
+	memoryComponents = iota
+)
+
+func (c MemoryComponent) String() string {
+	switch c {
+	case MemoryWriteBuffer:
+		return "write buffer"
+	case MemoryReadAhead:
+		return "read-ahead"
+	case MemoryReplayBuffer:
+		return "replay buffer"
+	default:
+		return "unknown"
+	}
+}
+
+// MemoryUsage contains sizes of payloads held by internal buffers in bytes
+type MemoryUsage struct {
+	WriteBuffer  int
+	ReadAhead    int
+	ReplayBuffer int
+	// Total is the sum of all components
+	Total int
+}
+
+// SetMemoryBudget limits the total size of payloads held by internal buffers: the write buffer
+// ('SetWriteBuffer'), the read-ahead queue ('SetReadAheadBuffer') and the replay buffer (messages read
+// while waiting for subscription acks, see 'SubscribeWithAck'). The budget is shared: any component
+// can use all of it, unless the component is limited by 'SetMemoryComponentLimit'.
+//
+// When the budget is exhausted, every component follows its overflow policy: writes return
+// 'ErrWriteBufferFull', the read-ahead goroutine waits until queued messages are read, and messages
+// read while waiting for acks are dropped and counted as 'DropFeatureMemoryBudget'. An empty read-ahead
+// queue accepts a message of any size, so reads can't stall. Messages kept from dropped connections are
+// accounted, but never dropped. The usage is reported by 'Stats'. 'bytes' <= 0 disables the limit.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetMemoryBudget(bytes int) *ReConn {
+	r.configure(func() {
+		r.memory.limit = bytes
+	})
+	return r
+}
+
+// SetMemoryComponentLimit limits the size of payloads held by a single component. The component is
+// limited by the memory budget as well (see 'SetMemoryBudget'). 'bytes' <= 0 removes the limit.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetMemoryComponentLimit(component MemoryComponent, bytes int) *ReConn {
+	r.configure(func() {
+		if component >= 0 && component < memoryComponents {
+			r.memory.limits[component] = bytes
+		}
+	})
+	return r
+}
+
+// memoryBudget accounts sizes of payloads held by internal buffers. Limits are set before 'Dial'
+type memoryBudget struct {
+	limit  int
+	limits [memoryComponents]int
+
+	mu    sync.Mutex
+	usage [memoryComponents]int
+	total int
+	// released is closed and replaced after every release
+	released chan struct{}
+}
+
+// fitsLocked reports whether 'n' bytes can be reserved for the component. It must be called under 'b.mu'
+func (b *memoryBudget) fitsLocked(c MemoryComponent, n int) bool {
+	if b.limit > 0 && b.total+n > b.limit {
+		return false
+	}
+	if limit := b.limits[c]; limit > 0 && b.usage[c]+n > limit {
+		return false
+	}
+	return true
+}
+
+func (b *memoryBudget) addLocked(c MemoryComponent, n int) {
+	b.usage[c] += n
+	b.total += n
+}
+
+// TryReserve reserves 'n' bytes for the component. It returns false if they don't fit into the limits.
+// If 'force' is true, the bytes are reserved anyway
+func (b *memoryBudget) TryReserve(c MemoryComponent, n int, force bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !force && !b.fitsLocked(c, n) {
+		return false
+	}
+	b.addLocked(c, n)
+	return true
+}
+
+// Reserve reserves 'n' bytes for the component. It waits until the bytes fit into the limits or
+// the component holds nothing. If 'stop' is closed during the wait, the bytes are reserved anyway
+// and false is returned
+func (b *memoryBudget) Reserve(c MemoryComponent, n int, stop <-chan struct{}) bool {
+	for {
+		b.mu.Lock()
+		if b.usage[c] == 0 || b.fitsLocked(c, n) {
+			b.addLocked(c, n)
+			b.mu.Unlock()
+			return true
+		}
+		if b.released == nil {
+			b.released = make(chan struct{})
+		}
+		released := b.released
+		b.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-stop:
+			b.TryReserve(c, n, true)
+			return false
+		}
+	}
+}
+
+// Release returns 'n' bytes reserved for the component
+func (b *memoryBudget) Release(c MemoryComponent, n int) {
+	if n == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.usage[c] -= n
+	b.total -= n
+	if b.released != nil {
+		close(b.released)
+		b.released = nil
+	}
+}
+
+func (b *memoryBudget) Usage() MemoryUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return MemoryUsage{
+		WriteBuffer:  b.usage[MemoryWriteBuffer],
+		ReadAhead:    b.usage[MemoryReadAhead],
+		ReplayBuffer: b.usage[MemoryReplayBuffer],
+		Total:        b.total,
+	}
+}
@@ -0,0 +1,114 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMemoryBudgetWriteBuffer(t *testing.T) {
+	conn := New().SetWriteBuffer(10).SetMemoryBudget(5)
+	conn.dialed.Set(true)
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("abc")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("abc")); !errors.Is(err, ErrWriteBufferFull) {
+		t.Fatalf("expected ErrWriteBufferFull, got %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ab")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if usage := conn.Stats().Memory; usage != (MemoryUsage{WriteBuffer: 5, Total: 5}) {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+
+	conn.Close()
+	if usage := conn.Stats().Memory; usage != (MemoryUsage{}) {
+		t.Errorf("memory must be released on 'Close': %+v", usage)
+	}
+}
+
+func TestMemoryBudgetReadAhead(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetReadAheadBuffer(10).SetMemoryBudget(4)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, msg := range []string{"aa", "bb", "cc", "too large"} {
+		dialer.Last().Push(websocket.TextMessage, []byte(msg))
+	}
+	// The goroutine waits when the budget is exhausted
+	waitReadAhead(t, conn, 2)
+	time.Sleep(20 * time.Millisecond)
+	waitReadAhead(t, conn, 2)
+	if usage := conn.Stats().Memory; usage != (MemoryUsage{ReadAhead: 4, Total: 4}) {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+
+	// A message larger than the budget is read ahead when the queue is empty
+	for _, want := range []string{"aa", "bb", "cc", "too large"} {
+		_, data, err := conn.ReadMessage()
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %q, got %q, error: %v", want, data, err)
+		}
+	}
+	if usage := conn.Stats().Memory; usage != (MemoryUsage{}) {
+		t.Errorf("unexpected usage after reads: %+v", usage)
+	}
+}
+
+func TestMemoryComponentLimit(t *testing.T) {
+	conn := New().SetWriteBuffer(10).SetMemoryComponentLimit(MemoryWriteBuffer, 3)
+	conn.dialed.Set(true)
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("abc")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("a")); !errors.Is(err, ErrWriteBufferFull) {
+		t.Fatalf("expected ErrWriteBufferFull, got %v", err)
+	}
+}
+
+func TestMemoryBudgetReplayBuffer(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		// Neither of the messages is an ack, only the second one fits into the budget
+		conn.WriteMessage(websocket.TextMessage, []byte("large update"))
+		conn.WriteMessage(websocket.TextMessage, []byte("small"))
+		conn.WriteMessage(websocket.TextMessage, []byte("ack:a"))
+		conn.WriteMessage(websocket.TextMessage, []byte("last"))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetMemoryBudget(8)
+	conn.SubscribeWithAck("a", []byte("sub:a"), matchAck("ack:a", ""), time.Second)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if usage := conn.Stats().Memory; usage != (MemoryUsage{ReplayBuffer: 5, Total: 5}) {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+	for _, want := range []string{"small", "last"} {
+		_, data, err := conn.ReadMessage()
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %q, got %q, error: %v", want, data, err)
+		}
+	}
+	if n := conn.DroppedMessages()[DropFeatureMemoryBudget]; n != 1 {
+		t.Errorf("expected 1 dropped message, got %d", n)
+	}
+	if usage := conn.Stats().Memory; usage != (MemoryUsage{}) {
+		t.Errorf("unexpected usage after reads: %+v", usage)
+	}
+}
@@ -0,0 +1,188 @@
+package reconnect
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+const (
+	// poisonByte is used to fill data of released messages in debug mode
+	poisonByte = 0xDD
+	// maxPooledBufferSize is a max capacity of a buffer returned to the pool. Larger buffers
+	// are left to GC, so a single large message doesn't pin memory in the pool
+	maxPooledBufferSize = 64 << 10
+)
+
+// messagePool contains messages with their buffers
+var messagePool = sync.Pool{
+	New: func() interface{} {
+		return &Message{buf: new(bytes.Buffer)}
+	},
+}
+
+// Message is a message read by 'ReadMessageHandle'. Its data can be leased from an internal pool,
+// so it must not be used after 'Release' call
+type Message struct {
+	Type int
+	Data []byte
+
+	buf      *bytes.Buffer
+	released bool
+	debug    bool
+}
+
+// Release returns the message to the pool. Neither the message nor its data must be used after this call
+func (m *Message) Release() {
+	if m.released {
+		if m.debug {
+			panic("reconnect: message was already released")
+		}
+		return
+	}
+	m.released = true
+
+	if m.debug {
+		// Poison the data to make use-after-release visible. The buffer isn't returned
+		// to the pool, so the poisoned data can't be overwritten by another message
+		for i := range m.Data {
+			m.Data[i] = poisonByte
+		}
+		m.Data = nil
+		return
+	}
+
+	m.Data = nil
+	if m.buf != nil && m.buf.Cap() <= maxPooledBufferSize {
+		messagePool.Put(m)
+	}
+}
+
+// nextReader is implemented by connections that support streaming reads (for example, '*websocket.Conn')
+type nextReader interface {
+	NextReader() (messageType int, r io.Reader, err error)
+}
+
+// ReadMessageHandle reads a message like 'ReadMessage', but data is read into a pooled buffer
+// when the underlying connection supports it. 'Release' must be called after the message is processed
+func (r *ReConn) ReadMessageHandle() (*Message, error) {
+	if !r.dialed.Get() {
+		return nil, ErrNotDialed
+	}
+	if err := r.terminalError(); err != nil {
+		return nil, err
+	}
+	r.enterRead()
+	defer r.exitRead()
+
+	var retried bool
+	for {
+		msg, generation, readErr := r.readMessageHandle()
+		if readErr != nil {
+			if isTransformError(readErr) {
+				return nil, readErr
+			}
+			err := r.reconnectOnError(generation, r.checkPostUpgradeRejection(wrapReadLimitError(readErr)))
+			if r.retryRead(generation, &retried) {
+				continue
+			}
+			return nil, err
+		}
+
+		if !r.beginDelivery() {
+			r.dropMessage(DropFeatureClosed, msg.Data)
+			msg.Release()
+			return nil, ErrConnClosed
+		}
+		deliver := r.filterMessage(msg.Type, msg.Data)
+		r.endDelivery()
+
+		if !deliver {
+			msg.Release()
+			continue
+		}
+		return msg, nil
+	}
+}
+
+// readMessageHandle also returns the generation of the used connection
+func (r *ReConn) readMessageHandle() (*Message, uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	msg, err := r.readMessageHandleLocked()
+	return msg, r.generation, err
+}
+
+// readMessageHandleLocked must be called under 'r.mu' held for reading
+func (r *ReConn) readMessageHandleLocked() (*Message, error) {
+	if r.closingGracefully() {
+		return nil, ErrConnClosed
+	}
+	if left, ok := r.readAheadLeft.Pop(); ok {
+		var err error
+		msg := &Message{debug: r.debugRelease}
+		msg.Type, msg.Data, err = r.transformRead(left.Type, left.Data)
+		return msg, err
+	}
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	if msg, ok := r.replayBuffer.Pop(); ok {
+		return &Message{Type: msg.Type, Data: msg.Data, debug: r.debugRelease}, nil
+	}
+
+	msg, err := r.readConnMessageHandle()
+	if err != nil {
+		return nil, err
+	}
+	if msg.Type, msg.Data, err = r.transformRead(msg.Type, msg.Data); err != nil {
+		msg.Release()
+		return nil, err
+	}
+	return msg, nil
+}
+
+// readConnMessageHandle reads a message of the connection. It must be called under 'r.mu' held for reading
+func (r *ReConn) readConnMessageHandle() (*Message, error) {
+	if r.readAhead != nil {
+		messageType, data, err := r.readAhead.Next(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: messageType, Data: data, debug: r.debugRelease}, nil
+	}
+
+	r.applyReadTimeout(r.conn)
+	conn, ok := r.conn.(nextReader)
+	if !ok {
+		// Fallback to the plain read
+		messageType, data, err := r.conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: messageType, Data: data, debug: r.debugRelease}, nil
+	}
+
+	messageType, reader, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := messagePool.Get().(*Message)
+	msg.buf.Reset()
+	if _, err := msg.buf.ReadFrom(reader); err != nil {
+		if msg.buf.Cap() <= maxPooledBufferSize {
+			messagePool.Put(msg)
+		}
+		return nil, err
+	}
+
+	msg.Type = messageType
+	msg.Data = msg.buf.Bytes()
+	msg.released = false
+	msg.debug = r.debugRelease
+	return msg, nil
+}
@@ -0,0 +1,75 @@
+package reconnect
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// staticConn is a 'WsConnection' that always returns the same message
+type staticConn struct {
+	data []byte
+}
+
+func (c *staticConn) ReadMessage() (int, []byte, error) {
+	data := make([]byte, len(c.data))
+	copy(data, c.data)
+	return 1, data, nil
+}
+
+func (c *staticConn) NextReader() (int, io.Reader, error) {
+	return 1, bytes.NewReader(c.data), nil
+}
+
+func (c *staticConn) WriteMessage(int, []byte) error { return nil }
+func (c *staticConn) Close() error                   { return nil }
+
+func newStaticReConns(n int, data []byte) []*ReConn {
+	conns := make([]*ReConn, n)
+	for i := range conns {
+		conns[i] = New()
+		conns[i].dialed.Set(true)
+		conns[i].conn = &staticConn{data: data}
+	}
+	return conns
+}
+
+func TestMessageRelease(t *testing.T) {
+	data := []byte("hello")
+	conn := newStaticReConns(1, data)[0]
+	conn.debugRelease = true
+
+	msg, err := conn.ReadMessageHandle()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(msg.Data, data) {
+		t.Fatalf("got %q, want %q", msg.Data, data)
+	}
+
+	view := msg.Data
+	msg.Release()
+	if !bytes.Equal(view, bytes.Repeat([]byte{poisonByte}, len(data))) {
+		t.Error("data of a released message must be poisoned in debug mode")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("second 'Release' call must panic in debug mode")
+		}
+	}()
+	msg.Release()
+}
+
+func TestMessageReleaseLargeBuffer(t *testing.T) {
+	msg := &Message{buf: bytes.NewBuffer(make([]byte, 0, maxPooledBufferSize+1))}
+	msg.Release()
+
+	// sync.Pool doesn't guarantee anything, so just check that the message isn't reused
+	// within the same goroutine
+	for i := 0; i < 10; i++ {
+		if messagePool.Get().(*Message) == msg {
+			t.Fatal("message with a large buffer must not be returned to the pool")
+		}
+	}
+}
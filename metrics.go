@@ -0,0 +1,115 @@
+package reconnect
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetricsCollector receives events of an instance, for example, to update Prometheus metrics.
+// Methods are called synchronously by readers, writers and reconnects, so they must be fast
+// and safe for concurrent use
+type MetricsCollector interface {
+	// ConnectAttempt is called before every dial
+	ConnectAttempt(url string)
+	// ConnectSuccess is called after a successful connect. 'd' includes the dial and the subscribe handler
+	ConnectSuccess(d time.Duration)
+	// ConnectFailure is called after a failed connect attempt. Attempts aborted by 'Close' aren't reported
+	ConnectFailure(err error)
+	// MessageRead is called for every data message counted by 'Stats.MessagesRead'
+	MessageRead(size int)
+	// MessageWritten is called for every data message counted by 'Stats.MessagesWritten'
+	MessageWritten(size int)
+	// Disconnected is called when a connection is lost or closed. 'reason' is nil for 'Close'
+	Disconnected(reason error)
+}
+
+// SubscribeFailureCollector can be implemented by a 'MetricsCollector' to count failures of the subscribe
+// handler separately from other connect failures. 'SubscribeFailure' is called before 'ConnectFailure'
+type SubscribeFailureCollector interface {
+	SubscribeFailure(err error)
+}
+
+// ReplayCollector can be implemented by a 'MetricsCollector' to measure replays of registered subscriptions
+// after connects (see 'AddSubscription'). 'd' includes the time of waiting for acks, 'err' is nil
+// if all subscriptions were written and acknowledged
+type ReplayCollector interface {
+	SubscriptionsReplayed(count int, d time.Duration, err error)
+}
+
+// PhaseFailureCollector can be implemented by a 'MetricsCollector' to label failed connect attempts
+// by the phase they failed at (see 'Phase'), so, for example, rejected subscriptions can be alerted
+// differently from network problems. 'PhaseFailure' is called before 'ConnectFailure'
+type PhaseFailureCollector interface {
+	PhaseFailure(phase Phase, err error)
+}
+
+// NoopMetricsCollector is a 'MetricsCollector' that does nothing. It's used by default
+type NoopMetricsCollector struct{}
+
+var _ MetricsCollector = NoopMetricsCollector{}
+
+func (NoopMetricsCollector) ConnectAttempt(url string)      {}
+func (NoopMetricsCollector) ConnectSuccess(d time.Duration) {}
+func (NoopMetricsCollector) ConnectFailure(err error)       {}
+func (NoopMetricsCollector) MessageRead(size int)           {}
+func (NoopMetricsCollector) MessageWritten(size int)        {}
+func (NoopMetricsCollector) Disconnected(reason error)      {}
+
+// SetMetricsCollector sets a collector of events. If 'c' is nil, 'NoopMetricsCollector' is used.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetMetricsCollector(c MetricsCollector) *ReConn {
+	r.configure(func() {
+		if c == nil {
+			c = NoopMetricsCollector{}
+		}
+		r.metrics = c
+	})
+	return r
+}
+
+// countRead updates 'Stats' and reports the message to the metrics collector
+func (r *ReConn) countRead(size int) {
+	r.stats.addRead(size)
+	r.metrics.MessageRead(size)
+}
+
+// countWritten is like 'countRead', but for written messages
+func (r *ReConn) countWritten(size int) {
+	r.stats.addWritten(size)
+	r.metrics.MessageWritten(size)
+}
+
+// countDisconnect updates 'Stats' and reports the lost or closed connection to the metrics collector
+func (r *ReConn) countDisconnect(reason error) {
+	r.stats.disconnected(reason)
+	r.metrics.Disconnected(reason)
+}
+
+// reportConnectFailure updates 'Stats' and reports the failed attempt to the metrics collector
+func (r *ReConn) reportConnectFailure(err error) {
+	r.stats.setError(err)
+	if c, ok := r.metrics.(PhaseFailureCollector); ok {
+		c.PhaseFailure(PhaseOf(err), err)
+	}
+	r.metrics.ConnectFailure(err)
+}
+
+// countReplay updates 'Stats' and reports the replay of subscriptions to the metrics collector
+// if it implements 'ReplayCollector'
+func (r *ReConn) countReplay(count int, d time.Duration, err error) {
+	r.stats.replayed(count, d)
+	if err == nil {
+		r.log.Info(fmt.Sprintf("%d subscriptions were replayed in %s", count, d))
+	}
+	if c, ok := r.metrics.(ReplayCollector); ok {
+		c.SubscriptionsReplayed(count, d, err)
+	}
+}
+
+// reportSubscribeFailure reports the failure of the subscribe handler to the metrics collector
+// if it implements 'SubscribeFailureCollector'
+func (r *ReConn) reportSubscribeFailure(err error) {
+	if c, ok := r.metrics.(SubscribeFailureCollector); ok {
+		c.SubscribeFailure(err)
+	}
+}
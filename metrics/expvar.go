@@ -0,0 +1,108 @@
+// Package metrics contains implementations of 'reconnect.MetricsCollector'. It uses only public
+// interfaces of the core package, so the core package doesn't depend on it
+package metrics
+
+import (
+	"expvar"
+	"time"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+// Names of counters of 'ExpvarCollector'
+const (
+	ConnectAttempts       = "connect_attempts"
+	ConnectSuccesses      = "connect_successes"
+	ConnectFailures       = "connect_failures"
+	SubscribeFailures     = "subscribe_failures"
+	MessagesRead          = "messages_read"
+	MessagesWritten       = "messages_written"
+	BytesRead             = "bytes_read"
+	BytesWritten          = "bytes_written"
+	Disconnects           = "disconnects"
+	Replays               = "replays"
+	ReplayedSubscriptions = "replayed_subscriptions"
+	// ConnectDurationMs is the total duration of successful connects in milliseconds
+	ConnectDurationMs = "connect_duration_ms"
+	// ConnectFailuresPhasePrefix is a prefix of counters of failed connect attempts by phase,
+	// for example, 'connect_failures_subscribe'
+	ConnectFailuresPhasePrefix = "connect_failures_"
+)
+
+var (
+	_ reconnect.MetricsCollector          = (*ExpvarCollector)(nil)
+	_ reconnect.SubscribeFailureCollector = (*ExpvarCollector)(nil)
+	_ reconnect.ReplayCollector           = (*ExpvarCollector)(nil)
+	_ reconnect.PhaseFailureCollector     = (*ExpvarCollector)(nil)
+)
+
+// ExpvarCollector counts events in an 'expvar.Map', so they are served by the '/debug/vars' handler
+// after 'Publish'. Counters are named by the constants of the package
+type ExpvarCollector struct {
+	vars *expvar.Map
+}
+
+// NewExpvarCollector creates a collector with an unpublished map
+func NewExpvarCollector() *ExpvarCollector {
+	return &ExpvarCollector{vars: new(expvar.Map).Init()}
+}
+
+// Publish publishes the map with the passed name. Like 'expvar.Publish', it panics if the name
+// is already registered
+func (c *ExpvarCollector) Publish(name string) *ExpvarCollector {
+	expvar.Publish(name, c.vars)
+	return c
+}
+
+// Vars returns the map with the counters
+func (c *ExpvarCollector) Vars() *expvar.Map {
+	return c.vars
+}
+
+// Value returns the value of the counter
+func (c *ExpvarCollector) Value(name string) int64 {
+	if v, ok := c.vars.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func (c *ExpvarCollector) ConnectAttempt(url string) {
+	c.vars.Add(ConnectAttempts, 1)
+}
+
+func (c *ExpvarCollector) ConnectSuccess(d time.Duration) {
+	c.vars.Add(ConnectSuccesses, 1)
+	c.vars.Add(ConnectDurationMs, d.Milliseconds())
+}
+
+func (c *ExpvarCollector) ConnectFailure(err error) {
+	c.vars.Add(ConnectFailures, 1)
+}
+
+func (c *ExpvarCollector) PhaseFailure(phase reconnect.Phase, err error) {
+	c.vars.Add(ConnectFailuresPhasePrefix+phase.String(), 1)
+}
+
+func (c *ExpvarCollector) SubscribeFailure(err error) {
+	c.vars.Add(SubscribeFailures, 1)
+}
+
+func (c *ExpvarCollector) MessageRead(size int) {
+	c.vars.Add(MessagesRead, 1)
+	c.vars.Add(BytesRead, int64(size))
+}
+
+func (c *ExpvarCollector) MessageWritten(size int) {
+	c.vars.Add(MessagesWritten, 1)
+	c.vars.Add(BytesWritten, int64(size))
+}
+
+func (c *ExpvarCollector) Disconnected(reason error) {
+	c.vars.Add(Disconnects, 1)
+}
+
+func (c *ExpvarCollector) SubscriptionsReplayed(count int, d time.Duration, err error) {
+	c.vars.Add(Replays, 1)
+	c.vars.Add(ReplayedSubscriptions, int64(count))
+}
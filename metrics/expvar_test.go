@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+	"github.com/ShoshinNikita/ws-reconnect/reconnecttest"
+)
+
+func TestExpvarCollector(t *testing.T) {
+	var (
+		dialer    = reconnecttest.NewDialer()
+		collector = NewExpvarCollector()
+	)
+	rc := reconnect.New().SetDialFunc(dialer.Dial).SetMetricsCollector(collector)
+	rc.AddSubscription(websocket.TextMessage, []byte("sub"))
+	if err := rc.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rc.Close()
+
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+	if _, _, err := rc.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := rc.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	dialer.Last().FailNextRead(errors.New("connection reset"))
+	if _, _, err := rc.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// A failed reconnect is counted by phase
+	dialer.FailDials(1)
+	dialer.Last().FailNextRead(errors.New("connection reset"))
+	if _, _, err := rc.ReadMessage(); !errors.Is(err, reconnect.ErrDial) {
+		t.Fatalf("expected ErrDial, got %v", err)
+	}
+
+	for name, want := range map[string]int64{
+		ConnectAttempts:                     3,
+		ConnectSuccesses:                    2,
+		ConnectFailures:                     1,
+		ConnectFailuresPhasePrefix + "dial": 1,
+		MessagesRead:                        1,
+		BytesRead:                           5,
+		MessagesWritten:                     1,
+		BytesWritten:                        3,
+		Disconnects:                         2,
+		Replays:                             2,
+		ReplayedSubscriptions:               2,
+	} {
+		if got := collector.Value(name); got != want {
+			t.Errorf("%s: got %d, want %d", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// recordMetrics records events as strings
+type recordMetrics struct {
+	mu        sync.Mutex
+	events    []string
+	durations []time.Duration
+}
+
+func (m *recordMetrics) add(format string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, fmt.Sprintf(format, args...))
+}
+
+func (m *recordMetrics) ConnectAttempt(url string) { m.add("attempt %s", url) }
+func (m *recordMetrics) ConnectSuccess(d time.Duration) {
+	m.mu.Lock()
+	m.durations = append(m.durations, d)
+	m.mu.Unlock()
+	m.add("success")
+}
+func (m *recordMetrics) ConnectFailure(err error)  { m.add("failure %s", err) }
+func (m *recordMetrics) MessageRead(size int)      { m.add("read %d", size) }
+func (m *recordMetrics) MessageWritten(size int)   { m.add("written %d", size) }
+func (m *recordMetrics) Disconnected(reason error) { m.add("disconnected %v", reason) }
+
+func (m *recordMetrics) Events() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.events...)
+}
+
+func TestMetricsCollector(t *testing.T) {
+	const subscribeDelay = 20 * time.Millisecond
+
+	var (
+		dialer  FakeDialer
+		metrics recordMetrics
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetMetricsCollector(&metrics).
+		SetSubscribeHandler(func(WsConnection) error {
+			time.Sleep(subscribeDelay)
+			return nil
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("abc")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+	conn.ReadMessage()
+	conn.Close()
+
+	want := []string{
+		"attempt ws://fake",
+		"success",
+		"read 5",
+		"written 3",
+		"disconnected connection reset",
+		"attempt ws://fake",
+		"failure dial error: connection refused",
+	}
+	got := metrics.Events()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("unexpected events:\ngot:  %q\nwant: %q", got, want)
+	}
+	if d := metrics.durations[0]; d < subscribeDelay {
+		t.Errorf("connect duration must include the subscribe handler, got %s", d)
+	}
+}
+
+func TestMetricsCollectorClose(t *testing.T) {
+	var (
+		dialer  FakeDialer
+		metrics recordMetrics
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetMetricsCollector(&metrics)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.Close()
+
+	if events := metrics.Events(); events[len(events)-1] != "disconnected <nil>" {
+		t.Errorf("Close must be reported with nil reason, events: %q", events)
+	}
+}
@@ -0,0 +1,23 @@
+package reconnect
+
+import (
+	"context"
+	"net"
+)
+
+// NetDialContextFunc creates a network connection for a handshake. It's the same as
+// 'websocket.Dialer.NetDialContext'
+type NetDialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SetNetDialContext sets a function that creates network connections of every dial, including
+// reconnects. It can be used to resolve hosts with a custom resolver or to dial through a tunnel.
+// 'ctx' is derived from the context of the dial, so it's done when the handshake timeout expires
+// or the dial is canceled. The TLS handshake of 'wss' urls is made over the returned connection with
+// the config of 'SetTLSConfig'. It's used only by the default dialer (see 'SetDialFunc').
+// After 'Dial' call it does nothing
+func (r *ReConn) SetNetDialContext(f NetDialContextFunc) *ReConn {
+	r.configure(func() {
+		r.netDial = f
+	})
+	return r
+}
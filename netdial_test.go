@@ -0,0 +1,85 @@
+package reconnect
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSetNetDialContext(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	// The host is resolved by the dial function. The certificate of the test server is valid for 'example.com'
+	var dials int32
+	conn := New().
+		SetURL("wss://example.com/").
+		SetTLSConfig(&tls.Config{RootCAs: roots}).
+		SetNetDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			if addr != "example.com:443" {
+				return nil, errors.New("unexpected addr " + addr)
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, server.Listener.Addr().String())
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+			t.Fatalf("unexpected message: %q, error: %v", data, err)
+		}
+		// The connection is closed by the server, the next read reconnects
+		if _, _, err := conn.ReadMessage(); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if n := atomic.LoadInt32(&dials); n != 3 {
+		t.Errorf("expected 3 dials, got %d", n)
+	}
+}
+
+func TestSetNetDialContextCancel(t *testing.T) {
+	conn := New().
+		SetURL("ws://example.com/").
+		SetNetDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := conn.DialContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the dial must be canceled with the context, it took %s", elapsed)
+	}
+}
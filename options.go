@@ -0,0 +1,103 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Option configures an instance created by 'NewWithOptions'. It returns an error for invalid values
+type Option func(r *ReConn) error
+
+// NewWithOptions creates a new instance of 'ReConn' and applies the options in the passed order.
+// The url and the options are validated, so an error is returned before 'Dial'. The returned instance
+// is immutable: all 'Set...' methods are ignored, it's reported by the logger. 'UpdateConfig' can still
+// be used to change settings at runtime
+func NewWithOptions(rawURL string, opts ...Option) (*ReConn, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("%w: url is empty", ErrInvalidConfig)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid url: %s", ErrInvalidConfig, err)
+	}
+	if err := checkWsURL(u); err != nil {
+		return nil, fmt.Errorf("%w: invalid url '%s': %s", ErrInvalidConfig, rawURL, err)
+	}
+
+	r := New().SetURL(rawURL)
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			untrack(r)
+			return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+		}
+	}
+
+	r.setupMu.Lock()
+	r.immutable = true
+	r.setupMu.Unlock()
+
+	return r, nil
+}
+
+// WithHandshakeTimeout is like 'SetHandshakeTimeout'. 'd' must not be negative
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(r *ReConn) error {
+		if d < 0 {
+			return fmt.Errorf("handshake timeout must not be negative, got %s", d)
+		}
+		r.SetHandshakeTimeout(d)
+		return nil
+	}
+}
+
+// WithReconnectTimeout is like 'SetReconnectTimeout'. 'd' must not be negative
+func WithReconnectTimeout(d time.Duration) Option {
+	return func(r *ReConn) error {
+		if d < 0 {
+			return fmt.Errorf("reconnect timeout must not be negative, got %s", d)
+		}
+		r.SetReconnectTimeout(d)
+		return nil
+	}
+}
+
+// WithSubscribeHandler is like 'SetSubscribeHandler'
+func WithSubscribeHandler(f SubscribeHandler) Option {
+	return func(r *ReConn) error {
+		r.SetSubscribeHandler(f)
+		return nil
+	}
+}
+
+// WithLogger is like 'SetLogger'. 'log' must not be nil
+func WithLogger(log Logger) Option {
+	return func(r *ReConn) error {
+		if log == nil {
+			return errors.New("logger is nil")
+		}
+		r.SetLogger(log)
+		return nil
+	}
+}
+
+// WithSetters calls 'f' before the instance becomes immutable. It allows to use the setters
+// that have no options
+func WithSetters(f func(r *ReConn)) Option {
+	return func(r *ReConn) error {
+		f(r)
+		return nil
+	}
+}
+
+// checkWsURL returns an error if 'u' isn't a websocket url
+func checkWsURL(u *url.URL) error {
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("scheme must be 'ws' or 'wss', got '%s'", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("host is empty")
+	}
+	return nil
+}
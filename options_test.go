@@ -0,0 +1,90 @@
+package reconnect
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	var (
+		dialer     FakeDialer
+		log        recordLogger
+		subscribes int32
+	)
+	conn, err := NewWithOptions(
+		"ws://fake",
+		WithHandshakeTimeout(time.Second),
+		WithReconnectTimeout(2*time.Second),
+		WithLogger(&log),
+		WithSubscribeHandler(func(WsConnection) error {
+			atomic.AddInt32(&subscribes, 1)
+			return nil
+		}),
+		WithSetters(func(r *ReConn) {
+			r.SetDialFunc(dialer.Dial)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Setters of an immutable instance are ignored
+	conn.SetReconnectTimeout(time.Hour)
+	if !log.Contains("setter is ignored") {
+		t.Error("an ignored setter must be logged")
+	}
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	cfg := conn.loadConfig()
+	if cfg.URL != "ws://fake" || cfg.HandshakeTimeout != time.Second || cfg.ReconnectTimeout != 2*time.Second {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if dialer.Dials() != 1 || atomic.LoadInt32(&subscribes) != 1 {
+		t.Errorf("unexpected dials (%d) or subscribes (%d)", dialer.Dials(), atomic.LoadInt32(&subscribes))
+	}
+}
+
+func TestNewWithOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		opts    []Option
+		wantErr string
+	}{
+		{name: "empty url", url: "", wantErr: "url is empty"},
+		{name: "http scheme", url: "http://example.com", wantErr: "scheme must be 'ws' or 'wss', got 'http'"},
+		{name: "no scheme", url: "example.com/ws", wantErr: "scheme must be 'ws' or 'wss', got ''"},
+		{name: "no host", url: "ws:///path", wantErr: "host is empty"},
+		{name: "bad url", url: "ws://%zz", wantErr: "invalid url"},
+		{
+			name: "negative handshake timeout", url: "wss://example.com",
+			opts:    []Option{WithHandshakeTimeout(-time.Second)},
+			wantErr: "handshake timeout must not be negative",
+		},
+		{
+			name: "negative reconnect timeout", url: "ws://example.com",
+			opts:    []Option{WithReconnectTimeout(-time.Second)},
+			wantErr: "reconnect timeout must not be negative",
+		},
+		{name: "nil logger", url: "ws://example.com", opts: []Option{WithLogger(nil)}, wantErr: "logger is nil"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := NewWithOptions(tt.url, tt.opts...)
+			if conn != nil {
+				t.Error("instance must be nil on error")
+			}
+			if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected ErrInvalidConfig with '%s', got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package reconnect
+
+import (
+	"net/url"
+)
+
+// SetOrigin sets the Origin header of the handshake. It overrides the Origin set by 'SetHeader'.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetOrigin(origin string) *ReConn {
+	r.configure(func() {
+		r.origin = origin
+	})
+	return r
+}
+
+// SetOriginFromURL makes every attempt derive the Origin header from its target url:
+// 'wss://host/path' becomes 'https://host'. It takes precedence over 'SetOrigin'.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetOriginFromURL(enabled bool) *ReConn {
+	r.configure(func() {
+		r.originFromURL = enabled
+	})
+	return r
+}
+
+// dialOrigin returns the Origin for an attempt to connect to 'target'. It returns an empty
+// string if the Origin shouldn't be set
+func (r *ReConn) dialOrigin(target string) string {
+	if !r.originFromURL {
+		return r.origin
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		r.log.Error("couldn't derive Origin from url: " + target)
+		return r.origin
+	}
+
+	scheme := "http"
+	if u.Scheme == "wss" || u.Scheme == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host
+}
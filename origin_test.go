@@ -0,0 +1,59 @@
+package reconnect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOrigin(t *testing.T) {
+	var server *httptest.Server
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return r.Header.Get("Origin") == "http://"+strings.TrimPrefix(server.URL, "http://")
+		},
+	}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		conn       *ReConn
+		wantOrigin string
+		wantStatus int
+	}{
+		{
+			name:       "from url",
+			conn:       New().SetURL(wsURL(server) + "/path").SetOrigin("https://ignored.com").SetOriginFromURL(true),
+			wantOrigin: server.URL,
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "static",
+			conn:       New().SetURL(wsURL(server)).SetOrigin("https://example.com"),
+			wantOrigin: "https://example.com",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conn.Dial()
+			defer tt.conn.Close()
+
+			attempt, ok := tt.conn.LastAttempt()
+			if !ok || attempt.Origin != tt.wantOrigin || attempt.StatusCode != tt.wantStatus {
+				t.Errorf("unexpected last attempt: %+v", attempt)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package reconnect
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ReconnectsPausedError is returned by reads and writes that failed while reconnects are paused
+// (see 'PauseReconnects'). It matches 'ErrReconnectsPaused' and unwraps to the original error
+type ReconnectsPausedError struct {
+	// Err is the original read or write error
+	Err error
+}
+
+func (e *ReconnectsPausedError) Error() string {
+	return fmt.Sprintf("%s: original error: '%s'", ErrReconnectsPaused, e.Err)
+}
+
+func (e *ReconnectsPausedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ReconnectsPausedError) Is(target error) bool {
+	return target == ErrReconnectsPaused
+}
+
+// reconnectPause is the state of 'PauseReconnects'. The zero value means that reconnects aren't paused
+type reconnectPause struct {
+	mu sync.Mutex
+	// paused is accessed atomically, so the hot path doesn't take the lock
+	paused int32
+	// ch is closed when reconnects are paused. It's nil if it wasn't requested yet
+	ch chan struct{}
+	// skipBackoff is set by 'ResumeReconnects', the next attempt doesn't wait
+	skipBackoff int32
+}
+
+func (p *reconnectPause) Paused() bool {
+	return atomic.LoadInt32(&p.paused) == 1
+}
+
+// Signal returns a channel that is closed when reconnects are paused
+func (p *reconnectPause) Signal() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil {
+		p.ch = make(chan struct{})
+		if p.Paused() {
+			close(p.ch)
+		}
+	}
+	return p.ch
+}
+
+// Set pauses or resumes reconnects. It reports whether the state was changed
+func (p *reconnectPause) Set(paused bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Paused() == paused {
+		return false
+	}
+	if paused {
+		atomic.StoreInt32(&p.paused, 1)
+		if p.ch != nil {
+			close(p.ch)
+		}
+		return true
+	}
+
+	atomic.StoreInt32(&p.paused, 0)
+	atomic.StoreInt32(&p.skipBackoff, 1)
+	p.ch = nil
+	return true
+}
+
+// TakeSkipBackoff reports whether the backoff must be skipped once after 'ResumeReconnects'
+func (p *reconnectPause) TakeSkipBackoff() bool {
+	return atomic.CompareAndSwapInt32(&p.skipBackoff, 1, 0)
+}
+
+// PauseReconnects stops reconnecting, for example, during planned maintenance of the server. Reads and
+// writes that fail while reconnects are paused return '*ReconnectsPausedError' instead of dialing, the backoff
+// wait in progress is interrupted. Reads and writes of a live connection aren't affected. 'Dial' fails with
+// 'ErrReconnectsPaused' as well. It's safe to call it concurrently with reads and writes
+func (r *ReConn) PauseReconnects() {
+	if r.pause.Set(true) {
+		r.log.Info("reconnects are paused")
+	}
+}
+
+// ResumeReconnects resumes reconnects paused by 'PauseReconnects'. The next attempt is made right away,
+// the backoff delay is skipped. If there's no connection, the instance starts reconnecting in the background,
+// so reads and writes don't have to fail first
+func (r *ReConn) ResumeReconnects() {
+	if !r.pause.Set(false) {
+		return
+	}
+	r.log.Info("reconnects are resumed")
+
+	if !r.dialed.Get() || r.closed.Get() {
+		return
+	}
+
+	r.mu.RLock()
+	generation, connected := r.generation, r.conn != nil
+	r.mu.RUnlock()
+	if !connected {
+		r.goroutines.Go(func() {
+			r.connectInBackground(generation)
+		})
+	}
+}
+
+// ReconnectsPaused reports whether reconnects are paused (see 'PauseReconnects')
+func (r *ReConn) ReconnectsPaused() bool {
+	return r.pause.Paused()
+}
+
+// skipBackoffAfterResume must be called under 'r.mu' before the backoff wait
+func (r *ReConn) skipBackoffAfterResume() {
+	if r.pause.TakeSkipBackoff() {
+		r.setNextReconnectTime(r.clock.Now())
+	}
+}
@@ -0,0 +1,89 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPauseReconnects(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetReconnectTimeout(time.Hour)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	conn.PauseReconnects()
+	if !conn.ReconnectsPaused() {
+		t.Fatal("reconnects must be paused")
+	}
+
+	// A live connection isn't affected
+	dialer.Last().Push(1, []byte("msg"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "msg" {
+		t.Fatalf("unexpected read result: %q, %v", data, err)
+	}
+
+	connErr := errors.New("connection reset")
+	dialer.Last().Fail(connErr)
+	for i := 0; i < 3; i++ {
+		_, _, err := conn.ReadMessage()
+		if !errors.Is(err, ErrReconnectsPaused) || CodeOf(err) != CodeReconnectsPaused {
+			t.Fatalf("expected ErrReconnectsPaused, got %v", err)
+		}
+		if i == 0 && !errors.Is(err, connErr) {
+			t.Errorf("the first error must wrap the original one, got %v", err)
+		}
+	}
+	if err := conn.WriteMessage(1, []byte("msg")); !errors.Is(err, ErrReconnectsPaused) {
+		t.Errorf("expected ErrReconnectsPaused, got %v", err)
+	}
+	if dialer.Dials() != 1 {
+		t.Errorf("no dials are expected while paused, got %d", dialer.Dials()-1)
+	}
+
+	// The reconnect timeout is skipped after the resume, the instance reconnects in the background
+	conn.ResumeReconnects()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.WaitConnected(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if dialer.Dials() != 2 {
+		t.Errorf("expected 2 dials, got %d", dialer.Dials())
+	}
+}
+
+func TestPauseReconnectsInterruptsWait(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetReconnectTimeout(time.Hour)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// The next attempt waits for the reconnect timeout
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+	conn.ReadMessage()
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	conn.PauseReconnects()
+
+	select {
+	case err := <-readErr:
+		if !errors.Is(err, ErrReconnectsPaused) {
+			t.Errorf("expected ErrReconnectsPaused, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backoff wait wasn't interrupted")
+	}
+}
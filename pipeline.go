@@ -0,0 +1,39 @@
+package reconnect
+
+import "time"
+
+// Write pipeline
+//
+// Every write is processed as a single critical section under 'r.writeMu': all stages
+// (middleware, transforms, size checks, stats) and the write itself. So stages of concurrent
+// writes are never interleaved and a stage always sees a consistent state.
+
+// writeStage processes an outgoing message. It's called under 'r.writeMu'
+type writeStage func(messageType int, data []byte) (int, []byte, error)
+
+// runWritePipeline passes a message through all write stages and writes it. It must be called
+// under 'r.writeMu' and 'r.mu'
+func (r *ReConn) runWritePipeline(conn WsConnection, source WriteSource, messageType int, data []byte) (err error) {
+	for _, stage := range r.writeStages {
+		endRegion := r.traceConnRegion(traceRegionStage)
+		messageType, data, err = stage(messageType, data)
+		endRegion()
+		if err != nil {
+			return err
+		}
+	}
+
+	defer r.traceConnRegion(traceRegionWriteConn)()
+	r.applyWriteTimeout(conn)
+	if !r.timeWrites() {
+		err = conn.WriteMessage(messageType, data)
+	} else {
+		start := time.Now()
+		err = conn.WriteMessage(messageType, data)
+		r.observeWriteLatency(source, time.Since(start))
+	}
+	if err == nil {
+		r.countWritten(len(data))
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package reconnect
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWritePipelineIsAtomic(t *testing.T) {
+	const writers = 100
+
+	type payload struct {
+		ID  int `json:"id"`
+		Seq int `json:"seq"`
+	}
+	received := make(chan []byte, writers)
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- data
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+
+	// The stage isn't safe for concurrent use on purpose: both the counter and the buffer
+	// are shared, so interleaved writes would corrupt payloads or be caught by the race detector
+	var (
+		seq int
+		buf bytes.Buffer
+	)
+	conn.writeStages = append(conn.writeStages, func(messageType int, data []byte) (int, []byte, error) {
+		seq++
+		buf.Reset()
+		buf.Write(bytes.TrimSuffix(data, []byte("}")))
+		buf.WriteString(`,"seq":` + strconv.Itoa(seq) + "}")
+		return messageType, buf.Bytes(), nil
+	})
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+
+			data, _ := json.Marshal(struct {
+				ID int `json:"id"`
+			}{id})
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ids, seqs := make(map[int]bool), make(map[int]bool)
+	for i := 0; i < writers; i++ {
+		data := <-received
+
+		var p payload
+		if err := json.Unmarshal(data, &p); err != nil {
+			t.Fatalf("malformed payload %q: %s", data, err)
+		}
+		if ids[p.ID] || seqs[p.Seq] {
+			t.Fatalf("duplicate payload: %q", data)
+		}
+		ids[p.ID], seqs[p.Seq] = true, true
+	}
+}
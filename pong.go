@@ -0,0 +1,71 @@
+package reconnect
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// pongWaiters contains waiters of pongs for sent pings
+type pongWaiters struct {
+	mu      sync.Mutex
+	waiters map[string]chan struct{}
+}
+
+func (w *pongWaiters) Add(payload string) <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.waiters == nil {
+		w.waiters = make(map[string]chan struct{})
+	}
+	ch := make(chan struct{})
+	w.waiters[payload] = ch
+	return ch
+}
+
+func (w *pongWaiters) Remove(payload string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.waiters, payload)
+}
+
+// Done notifies the waiter of a pong
+func (w *pongWaiters) Done(payload string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if ch, ok := w.waiters[payload]; ok {
+		close(ch)
+		delete(w.waiters, payload)
+	}
+}
+
+// handlePong is set as a pong handler of new connections
+func (r *ReConn) handlePong(appData string) error {
+	atomic.StoreInt64(&r.lastPongAt, monoNow())
+	r.pongWaiters.Done(appData)
+	return nil
+}
+
+// ping sends a ping and waits for the pong. Pongs are handled by read methods, so a ping on
+// a connection nobody reads from never succeeds. It returns 'ErrNotConnected' if there's no connection
+func (r *ReConn) ping(ctx context.Context) error {
+	payload := strconv.FormatUint(atomic.AddUint64(&r.pings, 1), 10)
+	pong := r.pongWaiters.Add(payload)
+	defer r.pongWaiters.Remove(payload)
+
+	if err := r.writeMessage(ctx, WriteSourceHeartbeat, websocket.PingMessage, []byte(payload)); err != nil {
+		return err
+	}
+	select {
+	case <-pong:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,61 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// PostUpgradeRejectHandler is called when the server closes a connection right after the upgrade
+type PostUpgradeRejectHandler func(err *websocket.CloseError)
+
+// SetPostUpgradeRejection sets a grace window after a connect. If the server sends a close frame
+// within the window and before any data message, the connect attempt is treated as failed with
+// 'PhasePostUpgradeRejected': the error returned by the read matches 'ErrPostUpgradeRejected',
+// 'LastAttempt' is updated and the next connect is delayed by the reconnect timeout.
+// 'handler' can be nil. After 'Dial' call it does nothing
+func (r *ReConn) SetPostUpgradeRejection(grace time.Duration, handler PostUpgradeRejectHandler) *ReConn {
+	r.configure(func() {
+		r.postUpgradeGrace = grace
+		r.postUpgradeRejectHandler = handler
+	})
+	return r
+}
+
+// checkPostUpgradeRejection classifies a read error. It returns a 'ConnectError' if the server
+// rejected the connection right after the upgrade
+func (r *ReConn) checkPostUpgradeRejection(readErr error) error {
+	if r.postUpgradeGrace <= 0 || !r.awaitingFirstMessage.Get() {
+		return readErr
+	}
+
+	var closeErr *websocket.CloseError
+	if !errors.As(readErr, &closeErr) {
+		return readErr
+	}
+
+	r.firstMessage.mu.Lock()
+	sinceConnect := time.Since(r.firstMessage.connectedAt)
+	r.firstMessage.mu.Unlock()
+
+	if sinceConnect > r.postUpgradeGrace {
+		return readErr
+	}
+
+	err := newConnectError(PhasePostUpgradeRejected, readErr)
+	r.log.Error(fmt.Sprintf("connection was rejected %s after upgrade: %s", sinceConnect, closeErr))
+
+	if attempt, ok := r.lastAttempt.Load().(AttemptInfo); ok {
+		attempt.Err = err
+		attempt.Phase = PhasePostUpgradeRejected
+		r.lastAttempt.Store(attempt)
+	}
+	r.postUpgradeRejected.Set(true)
+
+	if r.postUpgradeRejectHandler != nil {
+		r.postUpgradeRejectHandler(closeErr)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package reconnect
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPostUpgradeRejection(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, ""))
+	})
+	defer server.Close()
+
+	var (
+		conn    *ReConn
+		rejects int32
+	)
+	conn = New().SetURL(wsURL(server)).
+		SetReconnectTimeout(10*time.Millisecond).
+		SetPostUpgradeRejection(time.Second, func(err *websocket.CloseError) {
+			if err.Code == websocket.CloseProtocolError {
+				atomic.AddInt32(&rejects, 1)
+			}
+			if attempt, ok := conn.LastAttempt(); !ok || attempt.Phase != PhasePostUpgradeRejected {
+				t.Errorf("unexpected last attempt: %+v", attempt)
+			}
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	_, _, err := conn.ReadMessage()
+	if !errors.Is(err, ErrPostUpgradeRejected) || PhaseOf(err) != PhasePostUpgradeRejected {
+		t.Errorf("expected a post-upgrade rejection, got %v", err)
+	}
+	if n := atomic.LoadInt32(&rejects); n != 1 {
+		t.Errorf("handler must be called once, got %d", n)
+	}
+}
+
+func TestPostUpgradeRejectionAfterGrace(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		time.Sleep(100 * time.Millisecond)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, ""))
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetPostUpgradeRejection(10*time.Millisecond, nil)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	_, _, err := conn.ReadMessage()
+	if err == nil || errors.Is(err, ErrPostUpgradeRejected) {
+		t.Errorf("a close after the grace window must be a read error, got %v", err)
+	}
+}
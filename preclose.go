@@ -0,0 +1,56 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultPreCloseTimeout is used when a pre-close hook timeout isn't set
+const defaultPreCloseTimeout = 5 * time.Second
+
+// PreCloseHook is called by 'Close' before the connection is closed. The connection is still
+// usable, so the hook can wait for in-flight requests
+type PreCloseHook func(ctx context.Context) error
+
+// SetPreCloseHook sets a hook called by 'Close' before the connection is closed. The hook isn't
+// called when the connection is lost. An error returned by the hook is logged and doesn't
+// prevent the close. After 'Dial' call it does nothing
+func (r *ReConn) SetPreCloseHook(hook PreCloseHook) *ReConn {
+	r.configure(func() {
+		r.preCloseHook = hook
+	})
+	return r
+}
+
+// SetPreCloseTimeout sets a timeout for the pre-close hook. After 'Dial' call it does nothing
+func (r *ReConn) SetPreCloseTimeout(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.preCloseTimeout = d
+	})
+	return r
+}
+
+// callPreCloseHook calls the pre-close hook only once per 'Close' ('Redial' rearms it). Concurrent calls wait for the hook
+// to return. It must be called without holding 'r.mu'
+func (r *ReConn) callPreCloseHook() {
+	if r.preCloseHook == nil {
+		return
+	}
+	r.preCloseOnce.Do(r.runPreCloseHook)
+}
+
+func (r *ReConn) runPreCloseHook() {
+	timeout := r.preCloseTimeout
+	if timeout <= 0 {
+		timeout = defaultPreCloseTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	r.log.Debug("call pre-close hook")
+
+	if err := r.preCloseHook(ctx); err != nil {
+		r.log.Error(fmt.Sprintf("pre-close hook error: %s", err))
+	}
+}
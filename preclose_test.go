@@ -0,0 +1,94 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPreCloseHook(t *testing.T) {
+	received := make(chan string, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		_, data, _ := conn.ReadMessage()
+		received <- string(data)
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var (
+		conn  *ReConn
+		calls int
+	)
+	conn = New().SetURL(wsURL(server)).SetPreCloseHook(func(ctx context.Context) error {
+		calls++
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("hook context must have a deadline")
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("bye")); err != nil {
+			t.Errorf("connection must be usable in the hook: %s", err)
+		}
+		return errors.New("hook error")
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Errorf("hook error must not prevent the close: %s", err)
+	}
+	conn.Close()
+
+	if data := <-received; data != "bye" {
+		t.Errorf("unexpected message: %q", data)
+	}
+	if calls != 1 {
+		t.Errorf("hook must be called once, got %d", calls)
+	}
+}
+
+func TestPreCloseHookConcurrentClose(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var (
+		conn     *ReConn
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		drainErr = make(chan error, 1)
+	)
+	conn = New().SetURL(wsURL(server)).SetPreCloseHook(func(ctx context.Context) error {
+		close(started)
+		<-release
+		drainErr <- conn.WriteMessage(websocket.TextMessage, []byte("bye"))
+		return nil
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	go conn.Close()
+	<-started
+
+	secondClosed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(secondClosed)
+	}()
+
+	select {
+	case <-secondClosed:
+		t.Fatal("the second 'Close' must wait for the running hook")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-drainErr; err != nil {
+		t.Errorf("connection must be usable until the hook returns: %s", err)
+	}
+	<-secondClosed
+}
@@ -0,0 +1,32 @@
+package reconnect
+
+import (
+	"fmt"
+)
+
+// ReconnectPredicate decides whether a read or write error must cause a reconnect
+type ReconnectPredicate func(err error) bool
+
+// SetReconnectPredicate sets a function called after every read and write error of the connection.
+// If it returns false, the original error is returned without reconnecting. The connection isn't
+// replaced, so the next read or write most likely fails with the same error: call 'ForceReconnect'
+// or 'Close'. The predicate isn't called if there's no connection. By default, all errors cause
+// a reconnect. After 'Dial' call it does nothing
+func (r *ReConn) SetReconnectPredicate(predicate ReconnectPredicate) *ReConn {
+	r.configure(func() {
+		r.reconnectPredicate = predicate
+	})
+	return r
+}
+
+// shouldReconnect reports whether a read or write error must cause a reconnect
+func (r *ReConn) shouldReconnect(err error) bool {
+	if r.reconnectPredicate == nil || err == ErrNotConnected {
+		return true
+	}
+	if r.reconnectPredicate(err) {
+		return true
+	}
+	r.log.Debug(fmt.Sprintf("skip reconnect: error was rejected by the predicate: %s", err))
+	return false
+}
@@ -0,0 +1,62 @@
+package reconnect
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSetReconnectPredicate(t *testing.T) {
+	rejectPolicyViolation := func(err error) bool {
+		return !websocket.IsCloseError(err, websocket.ClosePolicyViolation)
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		server, connections := newClosingServer(websocket.ClosePolicyViolation, "banned")
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetReconnectPredicate(rejectPolicyViolation)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		_, _, err := conn.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) || errors.Is(err, ErrReconnect) {
+			t.Fatalf("expected the original close error, got %v", err)
+		}
+		if n := atomic.LoadInt32(connections); n != 1 {
+			t.Errorf("expected 1 connection, got %d", n)
+		}
+
+		// The connection can be replaced manually
+		if err := conn.ForceReconnect(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+			t.Errorf("unexpected message: %q, error: %v", data, err)
+		}
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		server, connections := newClosingServer(websocket.CloseInternalServerErr, "oops")
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetReconnectPredicate(rejectPolicyViolation)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		// The read that got the close frame reconnects
+		conn.ReadMessage()
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+			t.Fatalf("unexpected message: %q, error: %v", data, err)
+		}
+		if n := atomic.LoadInt32(connections); n != 2 {
+			t.Errorf("expected 2 connections, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package reconnect
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// writePressureMaxLatency is a write latency that means the full pressure
+	writePressureMaxLatency = 100 * time.Millisecond
+	// writePressureMaxWaiters is a number of writers waiting for the write lock that means the full pressure
+	writePressureMaxWaiters = 16
+	// writePressureHysteresis is how much the pressure must fall below a level to leave it
+	writePressureHysteresis = 0.05
+	// writeLatencyWeight is a weight of a new write in the moving average of write latencies, 1/n
+	writeLatencyWeight = 4
+)
+
+// OnPressureChangeFunc is called when the write pressure crosses a level. 'level' is the highest
+// level reached by the pressure or 0 if the pressure is below all levels
+type OnPressureChangeFunc func(level float64)
+
+// pressureTracker contains the write pressure state
+type pressureTracker struct {
+	// latency is an exponential moving average of write latencies in nanoseconds.
+	// It's updated under 'r.writeMu'
+	latency int64
+
+	mu sync.Mutex
+	// level is a number of crossed levels
+	level int
+}
+
+// SetOnPressureChange sets a callback called when the write pressure (see 'WritePressure') crosses
+// one of 'levels'. A level is left only when the pressure falls below it by 0.05, so the callback
+// isn't called for every small change. The callback is called as connection callbacks (see 'SetOnConnect').
+// After 'Dial' call it does nothing
+func (r *ReConn) SetOnPressureChange(levels []float64, fn OnPressureChangeFunc) *ReConn {
+	r.configure(func() {
+		r.pressureLevels = append([]float64(nil), levels...)
+		sort.Float64s(r.pressureLevels)
+		r.onPressureChange = fn
+	})
+	return r
+}
+
+// WritePressure returns a signal in [0, 1] that shows how congested the write path is. It's the max of:
+//
+//   - the moving average of recent write latencies relative to 100ms. Writes are timed only
+//     if the write latency histogram or 'SetOnPressureChange' is enabled;
+//   - the number of writers waiting for other writes relative to 16;
+//   - the write buffer fill (see 'SetWriteBuffer').
+//
+// Producers can use it to shed load before writes start to block
+func (r *ReConn) WritePressure() float64 {
+	pressure := float64(atomic.LoadInt64(&r.pressure.latency)) / float64(writePressureMaxLatency)
+
+	if waiters := float64(r.writeMu.Waiting()) / writePressureMaxWaiters; waiters > pressure {
+		pressure = waiters
+	}
+	if r.writeBufferSize > 0 {
+		if fill := float64(r.writeBuffer.Len()) / float64(r.writeBufferSize); fill > pressure {
+			pressure = fill
+		}
+	}
+
+	if pressure > 1 {
+		pressure = 1
+	}
+	return pressure
+}
+
+// timeWrites reports whether writes must be timed
+func (r *ReConn) timeWrites() bool {
+	return r.writeLatencies != nil || r.onPressureChange != nil
+}
+
+// observeWriteLatency must be called under 'r.writeMu' after every timed write
+func (r *ReConn) observeWriteLatency(source WriteSource, d time.Duration) {
+	if r.writeLatencies != nil {
+		r.writeLatencies.Add(source, d)
+	}
+
+	avg := atomic.LoadInt64(&r.pressure.latency)
+	avg += (int64(d) - avg) / writeLatencyWeight
+	atomic.StoreInt64(&r.pressure.latency, avg)
+
+	r.updatePressureLevel()
+}
+
+// updatePressureLevel calls the pressure callback if the pressure crossed a level
+func (r *ReConn) updatePressureLevel() {
+	if r.onPressureChange == nil {
+		return
+	}
+	pressure := r.WritePressure()
+
+	r.pressure.mu.Lock()
+	defer r.pressure.mu.Unlock()
+
+	levels := r.pressureLevels
+	level := r.pressure.level
+	for level < len(levels) && pressure >= levels[level] {
+		level++
+	}
+	for level > 0 && pressure < levels[level-1]-writePressureHysteresis {
+		level--
+	}
+	if level == r.pressure.level {
+		return
+	}
+	r.pressure.level = level
+
+	var value float64
+	if level > 0 {
+		value = levels[level-1]
+	}
+	f := r.onPressureChange
+	r.callbacks.Push(func() { f(value) })
+}
@@ -0,0 +1,124 @@
+package reconnect
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// levelRecorder records levels passed to the pressure callback
+type levelRecorder struct {
+	mu     sync.Mutex
+	levels []float64
+}
+
+func (r *levelRecorder) Record(level float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.levels = append(r.levels, level)
+}
+
+func (r *levelRecorder) Wait(n int) []float64 {
+	for i := 0; i < 200; i++ {
+		r.mu.Lock()
+		levels := append([]float64(nil), r.levels...)
+		r.mu.Unlock()
+		if len(levels) >= n {
+			return levels
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]float64(nil), r.levels...)
+}
+
+func TestWritePressureLatency(t *testing.T) {
+	var rec levelRecorder
+	slow := &slowConn{delay: writePressureMaxLatency}
+	conn := New().SetOnPressureChange([]float64{0.8, 0.5}, rec.Record)
+	conn.dialed.Set(true)
+	conn.conn = slow
+
+	// The moving average grows with every slow write
+	for i := 0; i < 3; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if p := conn.WritePressure(); p < 0.5 || p > 0.8 {
+		t.Errorf("expected pressure in [0.5, 0.8], got %.2f", p)
+	}
+
+	// Fast writes make it fall
+	slow.delay = 0
+	for conn.WritePressure() > 0.1 {
+		if err := conn.WriteMessage(websocket.TextMessage, nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if got, want := rec.Wait(2), []float64{0.5, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got levels %v, want %v", got, want)
+	}
+}
+
+func TestWritePressureWaiters(t *testing.T) {
+	conn := New()
+	conn.dialed.Set(true)
+	conn.conn = &slowConn{delay: 10 * time.Millisecond}
+
+	var wg sync.WaitGroup
+	for i := 0; i < writePressureMaxWaiters+4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn.WriteMessage(websocket.TextMessage, nil)
+		}()
+	}
+
+	var maxPressure float64
+	for i := 0; i < 100 && maxPressure < 1; i++ {
+		if p := conn.WritePressure(); p > maxPressure {
+			maxPressure = p
+		}
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+
+	if maxPressure != 1 {
+		t.Errorf("saturated write lock must mean the full pressure, got %.2f", maxPressure)
+	}
+	if p := conn.WritePressure(); p != 0 {
+		t.Errorf("writes aren't timed, the pressure must be 0 without waiters, got %.2f", p)
+	}
+}
+
+func TestWritePressureBuffer(t *testing.T) {
+	var rec levelRecorder
+	conn := New().SetWriteBuffer(4).SetOnPressureChange([]float64{0.5}, rec.Record)
+	conn.dialed.Set(true)
+
+	for i := 0; i < 2; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if p := conn.WritePressure(); p != 0.5 {
+		t.Errorf("got pressure %.2f, want 0.5", p)
+	}
+
+	// The buffer is flushed to a new connection
+	conn.mu.Lock()
+	err := conn.flushWriteBuffer(&staticConn{})
+	conn.mu.Unlock()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := rec.Wait(2), []float64{0.5, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got levels %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package reconnect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+// progressChunkSize is a max number of bytes read at once by 'ReadMessageWithProgress'
+const progressChunkSize = 32 << 10
+
+// ReadMessageWithProgress reads the next message like 'NextReader', but collects it into a single
+// payload. 'progress' is called with the number of read bytes after every chunk, so a large (for example,
+// fragmented) message can be observed while it's being reassembled. 'progress' can be nil.
+//
+// If 'ctx' is done during the read, the connection is closed and 'ctx.Err()' is returned, the next read
+// reconnects. If the message exceeds the size set by 'SetMaxMessageSize' or the connection breaks, the error
+// is handled as in 'ReadMessage'. The caveats of 'NextReader' apply: the message isn't passed to subscription
+// acks, taps and other features that need the whole message. If the connection doesn't support streaming
+// reads, the message is read by 'ReadMessageContext' and 'progress' is called once
+func (r *ReConn) ReadMessageWithProgress(ctx context.Context, progress func(bytesRead int)) (messageType int, data []byte, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	messageType, reader, err := r.NextReader()
+	if errors.Is(err, ErrStreamingNotSupported) {
+		messageType, data, err = r.ReadMessageContext(ctx)
+		if err == nil && progress != nil {
+			progress(len(data))
+		}
+		return messageType, data, err
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var generation uint64
+	if s, ok := reader.(*streamReader); ok {
+		generation = s.generation
+	}
+
+	// Close the connection to interrupt a blocked read
+	stop := onDone(ctx, func() {
+		r.closeConnOf(generation)
+	})
+	data, err = readWithProgress(reader, progress)
+	stop()
+
+	if err == nil {
+		return messageType, data, nil
+	}
+	if ctx.Err() != nil {
+		return 0, nil, ctx.Err()
+	}
+	if err == ErrStreamReplaced {
+		return 0, nil, err
+	}
+	return 0, nil, r.reconnectOnError(generation, r.checkPostUpgradeRejection(wrapReadLimitError(err)))
+}
+
+// readWithProgress reads 'reader' in chunks and calls 'progress' after every read chunk
+func readWithProgress(reader io.Reader, progress func(bytesRead int)) ([]byte, error) {
+	var (
+		buf   bytes.Buffer
+		chunk = make([]byte, progressChunkSize)
+	)
+	for {
+		n, err := reader.Read(chunk)
+		if n > 0 {
+			buf.Write(chunk[:n])
+			if progress != nil {
+				progress(buf.Len())
+			}
+		}
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// closeConnOf closes the current connection if it has the passed generation
+func (r *ReConn) closeConnOf(generation uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn != nil && r.generation == generation {
+		r.conn.Close()
+	}
+}
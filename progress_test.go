@@ -0,0 +1,153 @@
+package reconnect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReadMessageWithProgress(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 100<<10)
+	server := newWsServer(func(conn *websocket.Conn) {
+		// Send the payload in fragments
+		w, err := conn.NextWriter(websocket.BinaryMessage)
+		if err != nil {
+			return
+		}
+		for i := 0; i < len(payload); i += 10 << 10 {
+			if _, err := w.Write(payload[i : i+10<<10]); err != nil {
+				return
+			}
+		}
+		w.Close()
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var calls []int
+	messageType, data, err := conn.ReadMessageWithProgress(context.Background(), func(bytesRead int) {
+		calls = append(calls, bytesRead)
+	})
+	if err != nil || messageType != websocket.BinaryMessage || !bytes.Equal(data, payload) {
+		t.Fatalf("unexpected message (type %d, %d bytes), error: %v", messageType, len(data), err)
+	}
+	if len(calls) < 2 || calls[len(calls)-1] != len(payload) {
+		t.Errorf("unexpected progress calls: %v", calls)
+	}
+}
+
+func TestReadMessageWithProgressAbort(t *testing.T) {
+	t.Run("context", func(t *testing.T) {
+		server := newWsServer(func(conn *websocket.Conn) {
+			// Send the first fragment and never finish the message
+			w, err := conn.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return
+			}
+			// The payload is larger than the write buffer, so frames are flushed
+			w.Write(bytes.Repeat([]byte("x"), 8<<10))
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		})
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetReconnectTimeout(time.Millisecond)
+		defer conn.Close()
+
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		progress := make(chan int, 10)
+		go func() {
+			<-progress
+			cancel()
+		}()
+
+		_, _, err := conn.ReadMessageWithProgress(ctx, func(bytesRead int) {
+			progress <- bytesRead
+		})
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		// The next read reconnects
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, _, err := conn.ReadMessageContext(ctx); err == nil {
+			t.Fatal("read of the closed connection must fail")
+		}
+		if n := conn.Stats().Reconnects; n != 1 {
+			t.Errorf("expected 1 reconnect, got %d", n)
+		}
+	})
+
+	t.Run("max message size", func(t *testing.T) {
+		server := newWsServer(func(conn *websocket.Conn) {
+			conn.WriteMessage(websocket.BinaryMessage, make([]byte, 64<<10))
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		})
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetMaxMessageSize(1 << 10)
+		defer conn.Close()
+
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		_, _, err := conn.ReadMessageWithProgress(context.Background(), nil)
+		if !errors.Is(err, ErrMessageTooBig) {
+			t.Fatalf("expected ErrMessageTooBig, got %v", err)
+		}
+		if n := conn.Stats().Reconnects; n != 1 {
+			t.Errorf("expected 1 reconnect, got %d", n)
+		}
+	})
+}
+
+func TestReadMessageWithProgressNoStreaming(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+
+	var calls []int
+	_, data, err := conn.ReadMessageWithProgress(context.Background(), func(bytesRead int) {
+		calls = append(calls, bytesRead)
+	})
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected message %q, error: %v", data, err)
+	}
+	if len(calls) != 1 || calls[0] != 5 {
+		t.Errorf("unexpected progress calls: %v", calls)
+	}
+}
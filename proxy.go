@@ -0,0 +1,39 @@
+package reconnect
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ProxyFunc returns a proxy for a handshake request. A nil url means no proxy.
+// It's the same as 'websocket.Dialer.Proxy'
+type ProxyFunc func(req *http.Request) (*url.URL, error)
+
+// SetProxy sets a proxy used by every dial, including reconnects. Proxy errors fail the attempt
+// with 'ErrDial'. It's used only by the default dialer (see 'SetDialFunc'). After 'Dial' call it does nothing
+func (r *ReConn) SetProxy(proxy ProxyFunc) *ReConn {
+	r.configure(func() {
+		r.proxy = proxy
+	})
+	return r
+}
+
+// SetProxyURL is like 'SetProxy', but all dials go through the proxy with the passed url. Supported
+// schemes are 'http', 'https' and 'socks5'. If the url is invalid, every dial fails with 'ErrDial'.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetProxyURL(proxyURL string) *ReConn {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		err = fmt.Errorf("invalid proxy url: %w", err)
+		return r.SetProxy(func(*http.Request) (*url.URL, error) { return nil, err })
+	}
+	return r.SetProxy(http.ProxyURL(u))
+}
+
+// SetProxyFromEnvironment makes dials use the proxy from the environment variables HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY (see 'http.ProxyFromEnvironment'). 'wss' urls use HTTPS_PROXY.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetProxyFromEnvironment() *ReConn {
+	return r.SetProxy(http.ProxyFromEnvironment)
+}
@@ -0,0 +1,101 @@
+package reconnect
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newConnectProxy returns an HTTP CONNECT proxy that counts tunnels
+func newConnectProxy(tunnels *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		client, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer client.Close()
+
+		atomic.AddInt32(tunnels, 1)
+		client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+		done := make(chan struct{}, 2)
+		go func() { io.Copy(upstream, client); done <- struct{}{} }()
+		go func() { io.Copy(client, upstream); done <- struct{}{} }()
+		<-done
+	}))
+}
+
+func TestSetProxy(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		// Force a reconnect after every message
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	})
+	defer server.Close()
+
+	var tunnels int32
+	proxy := newConnectProxy(&tunnels)
+	defer proxy.Close()
+
+	conn := New().SetURL(wsURL(server)).SetProxyURL(proxy.URL)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+			t.Fatalf("unexpected message: %q, error: %v", data, err)
+		}
+		// The connection is closed by the server, the next read reconnects
+		if _, _, err := conn.ReadMessage(); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if n := atomic.LoadInt32(&tunnels); n != 3 {
+		t.Errorf("expected 3 tunnels, got %d", n)
+	}
+}
+
+func TestSetProxyErrors(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {})
+	defer server.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %s", err)
+	}
+	// Nothing listens on the address
+	downProxy := "http://" + l.Addr().String()
+	l.Close()
+
+	for name, proxyURL := range map[string]string{
+		"proxy is down": downProxy,
+		"invalid url":   "http://[::1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn := New().SetURL(wsURL(server)).SetProxyURL(proxyURL)
+			defer conn.Close()
+
+			if err := conn.Dial(); !errors.Is(err, ErrDial) {
+				t.Errorf("expected ErrDial, got: %v", err)
+			}
+		})
+	}
+}
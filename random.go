@@ -0,0 +1,28 @@
+package reconnect
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// rnd is a source of random values for jitters. '*rand.Rand' isn't safe for concurrent use,
+// so it is guarded by a mutex
+var rnd = struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}{
+	r: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+// randomDuration returns a random duration in [0, max)
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	rnd.mu.Lock()
+	defer rnd.mu.Unlock()
+
+	return time.Duration(rnd.r.Int63n(int64(max)))
+}
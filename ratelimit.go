@@ -0,0 +1,171 @@
+package reconnect
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SetWriteRateLimit limits data messages written to 'n' per 'per' with bursts of up to 'burst' messages
+// (a token bucket). User writes ('WriteMessage', 'WriteJSON', 'NextWriter' and etc.) wait for a token
+// or return 'ErrRateLimited' if the non-blocking mode is enabled (see 'SetWriteRateLimitNonBlocking').
+// Messages written by the subscribe handler and subscription replays always wait, because servers
+// count them too. Control frames aren't limited. The limiter state persists across reconnects,
+// so a reconnect doesn't allow a new burst. Waits are interrupted by 'Close'. 'n' < 1 disables
+// the limit, 'burst' < 1 means 1. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteRateLimit(n int, per time.Duration, burst int) *ReConn {
+	r.configure(func() {
+		if n < 1 || per <= 0 {
+			r.writeRateLimiter = nil
+			return
+		}
+		r.writeRateLimiter = newRateLimiter(per/time.Duration(n), burst)
+	})
+	return r
+}
+
+// SetWriteRateLimitNonBlocking makes rate limited user writes return 'ErrRateLimited' instead
+// of waiting. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteRateLimitNonBlocking(nonBlocking bool) *ReConn {
+	r.configure(func() {
+		r.writeRateNonBlocking = nonBlocking
+	})
+	return r
+}
+
+// rateLimiter is a token bucket
+type rateLimiter struct {
+	// interval is the time to get a token
+	interval time.Duration
+	burst    float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(interval time.Duration, burst int) *rateLimiter {
+	if interval <= 0 {
+		interval = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		interval: interval,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+	}
+}
+
+// refillLocked must be called under 'l.mu'
+func (l *rateLimiter) refillLocked(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
+
+// TryTake takes a token if it's available
+func (l *rateLimiter) TryTake(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refillLocked(now)
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Reserve takes a token in advance and returns the time to wait for it. So concurrent writers
+// are served in order
+func (l *rateLimiter) Reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refillLocked(now)
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+// Cancel returns a reserved token that wasn't used
+func (l *rateLimiter) Cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Wait waits for a token. It returns 'ctx.Err()' if 'ctx' is done and 'ErrConnClosed' if 'closeCh'
+// is closed during the wait
+func (l *rateLimiter) Wait(ctx context.Context, closeCh <-chan struct{}) error {
+	d := l.Reserve(time.Now())
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.Cancel()
+		return ctx.Err()
+	case <-closeCh:
+		l.Cancel()
+		return ErrConnClosed
+	}
+}
+
+// waitWriteRate waits for the rate limit before a user write of a message of 'messageType'
+func (r *ReConn) waitWriteRate(ctx context.Context, messageType int) error {
+	if r.writeRateLimiter == nil || !isDataMessage(messageType) {
+		return nil
+	}
+	if r.writeRateNonBlocking {
+		if !r.writeRateLimiter.TryTake(time.Now()) {
+			return ErrRateLimited
+		}
+		return nil
+	}
+	return r.writeRateLimiter.Wait(ctx, r.closeSignal())
+}
+
+func isDataMessage(messageType int) bool {
+	return messageType == websocket.TextMessage || messageType == websocket.BinaryMessage
+}
+
+// rateLimitedConn is passed to the subscribe handler and subscription replays when the write
+// rate limit is set. Its writes always wait for the limit
+type rateLimitedConn struct {
+	WsConnection
+	r *ReConn
+}
+
+func (c rateLimitedConn) WriteMessage(messageType int, data []byte) error {
+	if isDataMessage(messageType) {
+		if err := c.r.writeRateLimiter.Wait(context.Background(), c.r.closeSignal()); err != nil {
+			return err
+		}
+	}
+	return c.WsConnection.WriteMessage(messageType, data)
+}
+
+func (c rateLimitedConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return writeControl(c.WsConnection, messageType, data, deadline)
+}
@@ -0,0 +1,142 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWriteRateLimit(t *testing.T) {
+	var dialer FakeDialer
+	// A token every 50ms
+	conn := New().SetDialFunc(dialer.Dial).SetWriteRateLimit(20, time.Second, 2)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	// The burst is written at once, the rest waits for 2 tokens
+	if d := time.Since(start); d < 90*time.Millisecond || d > time.Second {
+		t.Errorf("unexpected duration of writes: %s", d)
+	}
+	if n := len(dialer.Last().Written()); n != 4 {
+		t.Errorf("expected 4 written messages, got %d", n)
+	}
+
+	// Control frames aren't limited
+	start = time.Now()
+	if err := conn.WriteControl(websocket.PingMessage, nil, time.Time{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d := time.Since(start); d > 30*time.Millisecond {
+		t.Errorf("control frame must not wait, took %s", d)
+	}
+}
+
+func TestWriteRateLimitNonBlocking(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetWriteRateLimit(1, time.Hour, 2).SetWriteRateLimitNonBlocking(true).
+		SetSubscribeHandler(func(conn WsConnection) error {
+			return conn.WriteMessage(websocket.TextMessage, []byte("subscribe"))
+		})
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The subscribe message has taken a token
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("1")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err := conn.WriteMessage(websocket.TextMessage, []byte("2"))
+	if !errors.Is(err, ErrRateLimited) || CodeOf(err) != CodeRateLimited {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if dialer.Dials() != 1 {
+		t.Error("rate limited write must not reconnect")
+	}
+
+	// The state persists across reconnects: the subscribe handler of the new connection waits
+	dialer.Last().Fail(errors.New("broken pipe"))
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if dialer.Dials() != 2 || len(dialer.Last().Written()) != 0 {
+		t.Error("subscribe handler must wait for a token")
+	}
+
+	conn.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("'Close' must interrupt the wait of the subscribe handler")
+	}
+}
+
+func TestWriteRateLimitClose(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetWriteRateLimit(1, time.Hour, 1)
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("1")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.WriteMessage(websocket.TextMessage, []byte("2"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	conn.Close()
+	select {
+	case err := <-done:
+		if err != ErrConnClosed {
+			t.Errorf("expected ErrConnClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("'Close' must interrupt the wait")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(100*time.Millisecond, 2)
+
+	for i := 0; i < 2; i++ {
+		if !l.TryTake(now) {
+			t.Fatalf("burst must be available, token %d", i)
+		}
+	}
+	if l.TryTake(now) {
+		t.Fatal("bucket must be empty")
+	}
+	if d := l.Reserve(now); d != 100*time.Millisecond {
+		t.Errorf("expected 100ms, got %s", d)
+	}
+	if d := l.Reserve(now); d != 200*time.Millisecond {
+		t.Errorf("expected 200ms, got %s", d)
+	}
+	l.Cancel()
+	l.Cancel()
+
+	// The bucket doesn't grow above the burst
+	if !l.TryTake(now.Add(time.Hour)) || !l.TryTake(now.Add(time.Hour)) || l.TryTake(now.Add(time.Hour)) {
+		t.Error("bucket must be refilled up to the burst")
+	}
+}
@@ -0,0 +1,187 @@
+package reconnect
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SetReadAheadBuffer enables reading ahead: a goroutine reads messages of the connection into a queue
+// of up to 'n' messages, and reads are served from the queue. The goroutine waits when the queue is full.
+// Messages are returned in the order they were received. If the connection fails, the queued messages
+// are returned before the error, so messages received right before the failure aren't lost. Messages
+// queued when the connection is dropped for another reason (for example, a write error or 'ForceReconnect')
+// are returned before messages of the next connection. They are dropped by 'Close'.
+//
+// The goroutine is started for every connection and exits when the connection is dropped or closed.
+// The read timeout (see 'SetReadTimeout') is applied to every read of the goroutine. A read interrupted
+// by the context doesn't break the connection. Queued messages are limited by the memory budget as well
+// (see 'SetMemoryBudget'). Streaming reads ('NextReader' and 'StreamLines')
+// return 'ErrStreamingNotSupported'. 'n' < 1 disables reading ahead. After 'Dial' call it does nothing
+func (r *ReConn) SetReadAheadBuffer(n int) *ReConn {
+	r.configure(func() {
+		r.readAheadSize = n
+	})
+	return r
+}
+
+// readAheadItem is a message or an error read by 'readAheadReader'
+type readAheadItem struct {
+	messageType int
+	data        []byte
+	err         error
+}
+
+// readAheadReader reads messages of a single connection
+type readAheadReader struct {
+	conn   WsConnection
+	budget *memoryBudget
+	items  chan readAheadItem
+	// stop is closed when the connection is dropped
+	stop chan struct{}
+	// done is closed when the goroutine exits
+	done chan struct{}
+
+	mu sync.Mutex
+	// err is the error of the connection. It's returned after all queued messages
+	err error
+	// pending is a message that didn't fit into the queue before 'Stop'
+	pending *readAheadItem
+	// stopped is set when 'Stop' has collected the messages
+	stopped bool
+}
+
+func newReadAheadReader(conn WsConnection, size int, budget *memoryBudget) *readAheadReader {
+	return &readAheadReader{
+		conn:   conn,
+		budget: budget,
+		items:  make(chan readAheadItem, size),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// run reads the connection until an error or 'Stop'
+func (ra *readAheadReader) run(applyReadTimeout func(conn WsConnection)) {
+	defer close(ra.done)
+
+	for {
+		applyReadTimeout(ra.conn)
+		messageType, data, err := ra.conn.ReadMessage()
+
+		item := readAheadItem{messageType: messageType, data: data, err: err}
+		if !ra.budget.Reserve(MemoryReadAhead, len(data), ra.stop) {
+			// The memory budget is exhausted
+			ra.keepPending(item)
+			return
+		}
+		select {
+		case ra.items <- item:
+		case <-ra.stop:
+			// The queue is full
+			ra.keepPending(item)
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// keepPending keeps a message read before 'Stop' for 'Stop'. The message is lost if 'Stop'
+// has already returned
+func (ra *readAheadReader) keepPending(item readAheadItem) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	if ra.stopped {
+		ra.budget.Release(MemoryReadAhead, len(item.data))
+		return
+	}
+	ra.pending = &item
+}
+
+// Next returns the next queued message. It blocks until a message is read, the connection fails
+// or 'ctx' is done
+func (ra *readAheadReader) Next(ctx context.Context) (messageType int, data []byte, err error) {
+	ra.mu.Lock()
+	err = ra.err
+	ra.mu.Unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	select {
+	case item := <-ra.items:
+		ra.budget.Release(MemoryReadAhead, len(item.data))
+		if item.err != nil {
+			ra.mu.Lock()
+			ra.err = item.err
+			ra.mu.Unlock()
+		}
+		return item.messageType, item.data, item.err
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// Stop stops the goroutine and returns the queued messages. The connection must be closed before,
+// so the goroutine isn't blocked by a read. If it's still blocked after 'timeout', the message being
+// read is lost. Sizes of the returned messages are released from the memory budget
+func (ra *readAheadReader) Stop(timeout time.Duration) []bufferedMessage {
+	close(ra.stop)
+	select {
+	case <-ra.done:
+	case <-time.After(timeout):
+	}
+
+	var msgs []bufferedMessage
+	add := func(item readAheadItem) {
+		ra.budget.Release(MemoryReadAhead, len(item.data))
+		if item.err == nil {
+			msgs = append(msgs, bufferedMessage{Type: item.messageType, Data: item.data})
+		}
+	}
+	for len(ra.items) > 0 {
+		add(<-ra.items)
+	}
+
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	ra.stopped = true
+	if ra.pending != nil {
+		add(*ra.pending)
+		ra.pending = nil
+	}
+	return msgs
+}
+
+// startReadAhead must be called under 'r.mu' after a new connection is published
+func (r *ReConn) startReadAhead(conn WsConnection) {
+	if r.readAheadSize < 1 {
+		return
+	}
+	ra := newReadAheadReader(conn, r.readAheadSize, &r.memory)
+	r.readAhead = ra
+	r.goroutines.Go(func() {
+		ra.run(r.applyReadTimeout)
+	})
+}
+
+// stopReadAhead must be called under 'r.mu' after the connection is closed. If 'keep' is true,
+// the queued messages are returned by next reads
+func (r *ReConn) stopReadAhead(keep bool) {
+	if r.readAhead == nil {
+		return
+	}
+	msgs := r.readAhead.Stop(prevConnCloseTimeout)
+	r.readAhead = nil
+
+	if !keep {
+		return
+	}
+	for _, msg := range msgs {
+		r.readAheadLeft.Push(msg)
+	}
+}
@@ -0,0 +1,176 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// waitReadAhead waits until 'n' messages are read ahead
+func waitReadAhead(t *testing.T, conn *ReConn, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		conn.mu.RLock()
+		queued := conn.readAhead != nil && len(conn.readAhead.items) == n
+		conn.mu.RUnlock()
+		if queued {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages must be read ahead", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReadAheadBuffer(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetReadAheadBuffer(3)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, msg := range []string{"1", "2", "3", "4"} {
+		dialer.Last().Push(websocket.TextMessage, []byte(msg))
+	}
+	// The goroutine waits when the queue is full
+	waitReadAhead(t, conn, 3)
+
+	// A write error drops the connection with unread messages
+	dialer.Last().Fail(errors.New("broken pipe"))
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err == nil {
+		t.Fatal("write to the broken connection must fail")
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+	dialer.Last().Push(websocket.TextMessage, []byte("5"))
+
+	// The queued messages are returned before messages of the new connection
+	for _, want := range []string{"1", "2", "3", "4", "5"} {
+		_, data, err := conn.ReadMessage()
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %q, got %q, error: %v", want, data, err)
+		}
+	}
+}
+
+func TestReadAheadBufferError(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetReadAheadBuffer(10)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	readErr := errors.New("connection reset")
+	dialer.Last().Push(websocket.TextMessage, []byte("1"))
+	dialer.Last().Push(websocket.TextMessage, []byte("2"))
+	dialer.Last().Fail(readErr)
+	waitReadAhead(t, conn, 3)
+
+	// The messages are returned before the error
+	for _, want := range []string{"1", "2"} {
+		_, data, err := conn.ReadMessage()
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %q, got %q, error: %v", want, data, err)
+		}
+	}
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, readErr) {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Errorf("expected 2 dials, got %d", n)
+	}
+}
+
+func TestReadAheadBufferContext(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetReadAheadBuffer(1)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := conn.ReadMessageContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// The connection isn't broken by the interrupted read
+	dialer.Last().Push(websocket.TextMessage, []byte("1"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "1" {
+		t.Fatalf("unexpected message %q, error: %v", data, err)
+	}
+	if n := dialer.Dials(); n != 1 {
+		t.Errorf("expected 1 dial, got %d", n)
+	}
+	if _, _, err := conn.NextReader(); err != ErrStreamingNotSupported {
+		t.Errorf("expected ErrStreamingNotSupported, got %v", err)
+	}
+}
+
+func TestReadAheadBufferClose(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetReadAheadBuffer(1)
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The goroutine waits for the queue
+	dialer.Last().Push(websocket.TextMessage, []byte("1"))
+	dialer.Last().Push(websocket.TextMessage, []byte("2"))
+	waitReadAhead(t, conn, 1)
+
+	done := make(chan struct{})
+	go func() {
+		conn.goroutines.Wait()
+		close(done)
+	}()
+
+	conn.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine must exit after 'Close'")
+	}
+	if _, _, err := conn.ReadMessage(); err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed, got %v", err)
+	}
+}
+
+func TestReadAheadBufferJSON(t *testing.T) {
+	const messages = 200
+
+	server := newWsServer(func(conn *websocket.Conn) {
+		for i := 0; i < messages; i++ {
+			if err := conn.WriteJSON(i); err != nil {
+				return
+			}
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetReadAheadBuffer(4)
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// 'ReadJSON' must not read the connection concurrently with the read-ahead goroutine
+	for want := 0; want < messages; want++ {
+		var got int
+		if err := conn.ReadJSON(&got); err != nil || got != want {
+			t.Fatalf("expected %d, got %d, error: %v", want, got, err)
+		}
+	}
+}
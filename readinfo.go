@@ -0,0 +1,40 @@
+package reconnect
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ReadInfo describes the connection a message was read from
+type ReadInfo struct {
+	// ConnectionGeneration is the number of the connection (see 'ConnectionGeneration')
+	ConnectionGeneration uint64
+	// Reconnected is true for the first message of a connection after a reconnect: messages
+	// could have been missed between this message and the previous one
+	Reconnected bool
+}
+
+// ReadMessageEx is like 'ReadMessage', but also returns info about the connection the message was read
+// from, so a consumer can detect gaps caused by reconnects without callbacks. The data of the message
+// isn't leased from the pool, 'Release' doesn't have to be called
+func (r *ReConn) ReadMessageEx() (msg Message, info ReadInfo, err error) {
+	return r.ReadMessageExContext(context.Background())
+}
+
+// ReadMessageExContext is like 'ReadMessageEx', but a blocked read is interrupted when 'ctx' is done
+// (see 'ReadMessageContext')
+func (r *ReConn) ReadMessageExContext(ctx context.Context) (msg Message, info ReadInfo, err error) {
+	msg.Type, msg.Data, info, err = r.readMessageContext(ctx)
+	return msg, info, err
+}
+
+// readInfo returns info of a message read from the connection with the passed number. Messages
+// returned by 'ReadMessage' are taken into account too
+func (r *ReConn) readInfo(connGeneration uint64) ReadInfo {
+	prev := atomic.SwapUint64(&r.lastReadConnGeneration, connGeneration)
+	return ReadInfo{
+		ConnectionGeneration: connGeneration,
+		// The first connection isn't a reconnect, even if no messages were read from it
+		Reconnected: prev != connGeneration && connGeneration > 1,
+	}
+}
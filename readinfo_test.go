@@ -0,0 +1,59 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReadMessageEx(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	check := func(want string, wantInfo ReadInfo) {
+		t.Helper()
+
+		msg, info, err := conn.ReadMessageEx()
+		if err != nil || string(msg.Data) != want || msg.Type != websocket.TextMessage {
+			t.Fatalf("unexpected message: %+v, error: %v", msg, err)
+		}
+		if info != wantInfo {
+			t.Errorf("got info %+v, want %+v", info, wantInfo)
+		}
+	}
+
+	dialer.Last().Push(websocket.TextMessage, []byte("1"))
+	dialer.Last().Push(websocket.TextMessage, []byte("2"))
+	check("1", ReadInfo{ConnectionGeneration: 1})
+	check("2", ReadInfo{ConnectionGeneration: 1})
+
+	// The next read reconnects
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessageEx(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if gen := conn.ConnectionGeneration(); gen != 2 {
+		t.Fatalf("expected connection generation 2, got %d", gen)
+	}
+	dialer.Last().Push(websocket.TextMessage, []byte("3"))
+	dialer.Last().Push(websocket.TextMessage, []byte("4"))
+	check("3", ReadInfo{ConnectionGeneration: 2, Reconnected: true})
+
+	// 'ReadMessage' and 'ReadMessageEx' share the state
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "4" {
+		t.Fatalf("unexpected message: %q, error: %v", data, err)
+	}
+	dialer.Last().Fail(errors.New("connection reset"))
+	conn.ReadMessage()
+	dialer.Last().Push(websocket.TextMessage, []byte("5"))
+	dialer.Last().Push(websocket.TextMessage, []byte("6"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "5" {
+		t.Fatalf("unexpected message: %q, error: %v", data, err)
+	}
+	check("6", ReadInfo{ConnectionGeneration: 3})
+}
@@ -1,53 +1,462 @@
 package reconnect
 
 import (
-	"errors"
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"runtime/trace"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 var (
-	ErrNotDialed     = errors.New("method 'Dial' wasn't called")
-	ErrAlreadyDialed = errors.New("method 'Dial' was already called")
+	ErrNotDialed     = newCodedError(CodeNotDialed, "method 'Dial' wasn't called")
+	ErrAlreadyDialed = newCodedError(CodeAlreadyDialed, "method 'Dial' was already called")
 
-	ErrNotConnected = errors.New("not connected")
-	ErrConnClosed   = errors.New("closed")
+	ErrNotConnected = newCodedError(CodeNotConnected, "not connected")
+	ErrConnClosed   = newCodedError(CodeConnClosed, "closed")
 
 	// ErrDial is used when 'websocket.Dial' returns an error
-	ErrDial = errors.New("dial error")
+	ErrDial = newCodedError(CodeDialFailed, "dial error")
 	// ErrSubscribe is used when subscribe handler returns an error
-	ErrSubscribe = errors.New("subscribe error")
+	ErrSubscribe = newCodedError(CodeSubscribeFailed, "subscribe error")
 	// ErrReconnect is used when reconnection wasn't successful
-	ErrReconnect = errors.New("reconnect error")
+	ErrReconnect = newCodedError(CodeReconnectFailed, "reconnect error")
+	// ErrPostUpgradeRejected is used when the server closes a connection right after the upgrade
+	ErrPostUpgradeRejected = newCodedError(CodePostUpgradeRejected, "connection rejected after upgrade")
+	// ErrStreamingNotSupported is used when the connection doesn't support streaming reads
+	ErrStreamingNotSupported = newCodedError(CodeStreamingNotSupported, "connection doesn't support streaming reads")
+	// ErrInvalidConfig is used when 'Validate' reports findings in strict mode
+	ErrInvalidConfig = newCodedError(CodeInvalidConfig, "invalid config")
+	// ErrInvalidDSN is used when 'ParseDSN' can't parse a connection string
+	ErrInvalidDSN = newCodedError(CodeInvalidConfig, "invalid dsn")
+	// ErrHeaderFunc is used when the request header callback fails
+	ErrHeaderFunc = newCodedError(CodeDialFailed, "request header callback error")
+	// ErrURLFunc is used when the url callback fails (see 'SetURLFunc')
+	ErrURLFunc = newCodedError(CodeDialFailed, "url callback error")
+	// ErrForcedReconnect is the cause of a reconnect requested by 'ForceReconnect' (see 'DisconnectReason')
+	ErrForcedReconnect = newCodedError(CodeConnLost, "forced reconnect")
+	// ErrUnhealthy is returned by 'Healthy'
+	ErrUnhealthy = newCodedError(CodeUnhealthy, "connection is unhealthy")
+	// ErrLeaderElection is used when the leadership can't be acquired (see 'SetLeaderElection')
+	ErrLeaderElection = newCodedError(CodeDialFailed, "leader election error")
+	// ErrDialLimiter is used when the dial limiter fails (see 'SetDialLimiter')
+	ErrDialLimiter = newCodedError(CodeDialFailed, "dial limiter error")
+	// ErrMaxReconnectsExceeded is used when the max number of reconnect attempts is exceeded
+	// (see 'SetMaxReconnectAttempts')
+	ErrMaxReconnectsExceeded = newCodedError(CodeMaxReconnectsExceeded, "max reconnect attempts exceeded")
+	// ErrMaxSubscribeFailuresExceeded is used when the max number of subscribe failures is exceeded
+	// (see 'SetMaxSubscribeFailures')
+	ErrMaxSubscribeFailuresExceeded = newCodedError(CodeMaxSubscribeFailuresExceeded, "max subscribe failures exceeded")
+	// ErrPermanentDialFailure is used when a dial fails with a response that must not be retried
+	// (see 'SetPermanentDialStatusCodes' and 'SetRetryPolicy')
+	ErrPermanentDialFailure = newCodedError(CodePermanentDialFailure, "permanent dial failure")
+	// ErrAckTimeout is used when a subscription wasn't acknowledged in time
+	ErrAckTimeout = newCodedError(CodeTimeout, "subscription ack timeout")
+	// ErrUnconfirmed is used when a message was written, but the connection liveness wasn't confirmed
+	// (see 'WriteMessageConfirmed'). The message may or may not have arrived
+	ErrUnconfirmed = newCodedError(CodeUnconfirmed, "write is unconfirmed")
+	// ErrJSON is returned when a message can't be marshaled or unmarshaled. It doesn't cause a reconnect
+	ErrJSON = newCodedError(CodeJSON, "json error")
+	// ErrMessageTooBig is returned by read methods when a message exceeds the limit set by 'SetMaxMessageSize'
+	ErrMessageTooBig = newCodedError(CodeTooLarge, "message is too big")
+	// ErrReconnectsPaused is used when a reconnect is skipped because reconnects are paused (see 'PauseReconnects')
+	ErrReconnectsPaused = newCodedError(CodeReconnectsPaused, "reconnects are paused")
+	// ErrServerClosed is used when the server closed the connection with a normal close code
+	// and reconnects on normal closes are disabled (see 'SetReconnectOnNormalClose')
+	ErrServerClosed = newCodedError(CodeServerClosed, "server closed the connection")
+	// ErrTransform is used when a read or write transform fails (see 'SetReadTransform')
+	ErrTransform = newCodedError(CodeTransform, "transform error")
+	// ErrRateLimited is returned by user writes when the write rate limit is exceeded
+	// and the non-blocking mode is enabled (see 'SetWriteRateLimit'). It doesn't cause a reconnect
+	ErrRateLimited = newCodedError(CodeRateLimited, "write rate limit exceeded")
+	// ErrReconnecting is returned by user writes called during a reconnect when the policy
+	// is 'WriteDuringReconnectFail' (see 'SetWriteDuringReconnect'). It doesn't cause a reconnect
+	ErrReconnecting = newCodedError(CodeReconnecting, "reconnect is in progress")
+	// ErrStreamReplaced is returned by readers and writers of 'NextReader' and 'NextWriter' after
+	// their connection was replaced by a reconnect
+	ErrStreamReplaced = newCodedError(CodeConnLost, "connection of the stream was replaced")
+)
+
+const (
+	// defaultMaxDialBodySize is a default max size of a saved handshake response body
+	defaultMaxDialBodySize = 64 << 10
+	// dialBodyPreviewSize is a max size of a body returned by 'GetDialBody' when a dial body sink is set
+	dialBodyPreviewSize = 256
+	// maxDialBodySinkSize is a max number of bytes written to a dial body sink per dial. Note that
+	// gorilla/websocket itself keeps only the first 1024 bytes of a failed handshake response
+	maxDialBodySinkSize = 1 << 20
+	// dialBodySinkQueueSize is a max number of bodies waiting to be written to a dial body sink.
+	// New bodies are dropped when the queue is full
+	dialBodySinkQueueSize = 16
+	// dialBodySinkTimeout is used as a write deadline for sinks that support it
+	dialBodySinkTimeout = 5 * time.Second
+	// prevConnCloseTimeout is a max time to wait for a previous connection to be closed
+	prevConnCloseTimeout = time.Second
 )
 
 type ReConn struct {
-	mu  sync.RWMutex
-	log Logger
+	// Fields accessed atomically. They are the first fields to guarantee 64-bit alignment
+
+	abandonedCloses       uint64
+	appliedReadBufferSize int64
+	autoReplies           uint64
+	// lastMessageAt is a monotonic time of the last read message (see 'monoNow')
+	lastMessageAt int64
+	// lastReadAt is a monotonic time of the last return from a read method
+	lastReadAt int64
+	// lastReadStartAt is a monotonic time of the last call of a read method
+	lastReadStartAt int64
+	readerStalls    uint64
+	activeReads     int32
+	state           int32
+	// lastSeq is the last sequence number returned by the sequence extractor
+	lastSeq uint64
+	// callbackBarrier is the number of callbacks that must be called before messages of the current
+	// connection are returned (see callbacks.go)
+	callbackBarrier uint64
+	// backoffFailures is a number of consecutive failures since the last proven connection (see backoff.go)
+	backoffFailures  int32
+	suspendsDetected uint64
+	// lastConnectAt is a monotonic time of the last successful connect
+	lastConnectAt int64
+	// subscribeFailures is a number of subscribe failures since the last successful connect (see health.go)
+	subscribeFailures int32
+	// pings is a number of sent pings. It's used as a ping payload
+	pings uint64
+	// lastPongAt is a monotonic time of the last received pong
+	lastPongAt int64
+	// farewellOutcome contains 'FarewellOutcome'
+	farewellOutcome int32
+
+	mu      sync.RWMutex
+	log     Logger
+	metrics MetricsCollector
+	// clock is used for reconnect delays and by the scheduler (see 'SetClock')
+	clock Clock
+
+	// writeMu serializes writes to the connection: gorilla/websocket supports only one concurrent writer.
+	// Heartbeats can't be starved by user writes (see writelock.go)
+	writeMu writeLock
+
+	// closeCh is closed by the first 'Close' call. 'Redial' replaces it, so it must be read
+	// by 'closeSignal'
+	closeCh chan struct{}
+	closeMu sync.Mutex
+	// lifecycleMu serializes 'Close' and 'Redial'
+	lifecycleMu sync.Mutex
+	// setupMu is held by setters and by the first 'Dial' call while 'dialed' is set. So, a setter
+	// either takes effect before 'Dial' or is ignored (see 'configure')
+	setupMu sync.Mutex
+	// immutable is set by 'NewWithOptions' under 'setupMu'. Setters of an immutable instance are ignored
+	immutable bool
+	// deliveryMu is held for reading while a read message is being delivered (see closeorder.go)
+	deliveryMu sync.RWMutex
+	// preCloseOnce makes concurrent 'Close' calls wait for the running pre-close hook
+	preCloseOnce sync.Once
+	// farewellOnce makes concurrent 'Close' calls wait for the farewell message
+	farewellOnce sync.Once
+
+	// dialBodySinkQueue is a queue of bodies to write to the dial body sink
+	dialBodySinkQueue chan []byte
+	dialBodySinkOnce  sync.Once
 
 	conn              WsConnection
 	dialBody          []byte
+	dialBodyTruncated bool
 	nextReconnectTime time.Time
-
-	closed *atomicBool
+	// propagatedHeader contains headers set by the response propagation handler
+	propagatedHeader http.Header
+	connectedAt      time.Time
+	// connectedURL is the url of the last successful connect
+	connectedURL string
+	// connectedSubprotocol is the subprotocol selected by the server for the last successful connect
+	connectedSubprotocol string
+	// urlIndex is an index of the url in 'urls' used by the next connect attempt
+	urlIndex   int
+	flapCycles int
+	// connectFailures is a number of consecutive failed connect attempts
+	connectFailures int
+	// generation is incremented for every new connection and by 'Redial'
+	generation uint64
+	// connGeneration is incremented only for new connections (see 'ConnectionGeneration')
+	connGeneration uint64
+	// lastReadConnGeneration is 'connGeneration' of the last message returned by 'ReadMessage'.
+	// It's accessed atomically
+	lastReadConnGeneration uint64
+	// dialDone is closed when the in-flight dial finishes. It's nil if there's no dial
+	dialDone chan struct{}
+	// dialCtx is a context passed to 'DialContext'. It's used by all connect attempts
+	dialCtx context.Context
+	// reportedStallAt is 'lastReadAt' of the last reported reader stall. It's used only by the scheduler
+	reportedStallAt int64
+	// keepAliveGeneration and keepAlivePingAt (a monotonic time of the oldest unanswered ping)
+	// are used only by the scheduler
+	keepAliveGeneration uint64
+	keepAlivePingAt     int64
+	// traceCtx and traceTask belong to the 'runtime/trace' task of the current connection.
+	// They are nil if trace regions are disabled (see trace.go)
+	traceCtx  context.Context
+	traceTask *trace.Task
+
+	// dialed is set by the first 'Dial' or 'AdoptConnection' call under 'setupMu'
+	dialed               *atomicBool
+	closed               *atomicBool
+	flapping             *atomicBool
+	awaitingFirstMessage *atomicBool
+	lastReconnectResumed *atomicBool
+	postUpgradeRejected  *atomicBool
+	// seqKnown is set after the sequence extractor returns the first sequence number
+	seqKnown *atomicBool
+	pause    reconnectPause
+	// listening is set while 'Listen' is running. controlFrames contains received control frames
+	// that 'Listen' must send (see 'SetDeliverControlFrames')
+	listening     *atomicBool
+	controlFrames messageBuffer
+
+	// lastAttempt contains 'AttemptInfo'
+	lastAttempt atomic.Value
+	// lastSubscribeErr contains the text of the last subscribe error (see health.go)
+	lastSubscribeErr atomic.Value
+	// snapshot contains '*snapshot'
+	snapshot atomic.Value
+	// config contains '*MutableConfig'. It's set by 'Dial'
+	config atomic.Value
+	// lastCloseFrame contains '*closeFrame' used for failover tokens
+	lastCloseFrame atomic.Value
+	// lastServerClose contains '*closeFrame' of the last connection closed by the server (see 'LastCloseCode')
+	lastServerClose atomic.Value
+	// stats contains counters of 'Stats'
+	stats stats
+
+	// gracefulClose contains '*gracefulClose' during the close handshake of 'CloseGracefully' and 'Shutdown'
+	gracefulClose atomic.Value
+	// terminalErr contains 'terminalState'
+	terminalErr atomic.Value
+	// pendingCause contains 'pendingCause' (see trend.go)
+	pendingCause atomic.Value
+
+	scheduler     *scheduler
+	goroutines    goroutineGroup
+	subscriptions subscriptions
+	callbacks     callbackQueue
+	connCleanups  connCleanups
+	trend         reconnectTrend
+	taps          taps
+	pressure      pressureTracker
+	pongWaiters   pongWaiters
+	ackWaiters    ackWaiters
+	// replayBuffer contains messages read while waiting for subscription acks
+	replayBuffer messageBuffer
+	// readAheadLeft contains messages read ahead from dropped connections (see readahead.go)
+	readAheadLeft messageBuffer
+	// writeBuffer contains user messages written while the connection is down (see 'SetWriteBuffer')
+	writeBuffer      messageBuffer
+	memory           memoryBudget
+	stateSubscribers stateSubscribers
+	drops            dropCounters
+	messageSizes     sizeWindow
+	scheduleHistory  scheduleHistory
+	firstMessage     firstMessageTracker
+	// readCalls and writeCalls track calls of read and write methods awaited by 'Shutdown'
+	readCalls  callTracker
+	writeCalls callTracker
 
 	// read-only after 'Dial' call
 
-	dialed bool
-
 	url    string
 	header http.Header
+	// urls is a list of urls for failover (see 'SetURLs')
+	urls []string
 
 	handshakeTimeout time.Duration
 	reconnectTimeout time.Duration
 
 	pingHandler      PingHandler
+	pongHandler      PongHandler
+	closeHandler     CloseHandler
 	subscribeHandler SubscribeHandler
+	// subscribeHandlerContext is used if 'subscribeHandler' is nil
+	subscribeHandlerContext SubscribeHandlerContext
+	subscribeTimeout        time.Duration
+	connectTimeout          time.Duration
+	// attemptDeadline is the deadline of the in-flight connect attempt (see 'SetConnectTimeout').
+	// It's zero if there's no timeout
+	attemptDeadline time.Time
+
+	dialBodySink io.Writer
+	// maxDialBodySize is a max size of a saved handshake response body, 0 means the default size
+	maxDialBodySize int
+	// skipDialBody disables saving of handshake response bodies (see 'SetCaptureDialBody')
+	skipDialBody bool
+
+	responsePropagation ResponsePropagation
+
+	debugRelease bool
+
+	flapThreshold time.Duration
+	flapMaxCycles int
+	onFlapping    OnFlappingFunc
+
+	strictConfig bool
+
+	autoResponders []AutoResponder
+
+	firstMessageTimeout time.Duration
+
+	writeGate WriteGate
+
+	preCloseHook    PreCloseHook
+	preCloseTimeout time.Duration
+
+	suspendThreshold    time.Duration
+	suspendProbeTimeout time.Duration
+	onResumeDetected    OnResumeDetectedFunc
+
+	keepAliveInterval time.Duration
+	keepAlivePongWait time.Duration
+
+	onConnect         OnConnectFunc
+	onDisconnect      OnDisconnectFunc
+	onReconnectFailed OnReconnectFailedFunc
+	onSubscribeFailed OnSubscribeFailedFunc
+	onAttempt         OnAttemptFunc
+	farewell          *farewellMessage
+	farewellAck       *farewellAck
+
+	emptyMessagePolicy EmptyMessagePolicy
+
+	dialResponseHook DialResponseHook
+
+	dialFunc DialFunc
+
+	tlsConfig    *tls.Config
+	proxy        ProxyFunc
+	subprotocols []string
+	cookieJar    http.CookieJar
+	netDial      NetDialContextFunc
+	// Buffers of connections (see buffers.go)
+	connReadBufferSize  int
+	connWriteBufferSize int
+	connWriteBufferPool websocket.BufferPool
+	// dialerConfigurator is called after the built-in settings are applied (see 'newDialer')
+	dialerConfigurator DialerConfigurator
+
+	enableCompression   bool
+	compressionLevel    int
+	compressionLevelSet bool
+
+	maxMessageSize int64
+
+	retryReadAfterReconnect bool
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	initialDialDelay time.Duration
+
+	postUpgradeGrace         time.Duration
+	postUpgradeRejectHandler PostUpgradeRejectHandler
+
+	resumeDetector                 ResumeDetector
+	resumeDetectorUsesFirstMessage bool
+
+	// resumeStateFunc is set by 'SetResumeState'. 'resumeState' is its result captured when
+	// the last connection was lost (see resumestate.go)
+	resumeStateFunc func() interface{}
+	resumeState     interface{}
+
+	adaptiveReadBufferMin int
+	adaptiveReadBufferMax int
+
+	maxLineSize int
+
+	origin        string
+	originFromURL bool
+
+	readerLivenessTimeout time.Duration
+	readerLivenessClose   bool
+	onReaderStalled       OnReaderStalledFunc
+	readInactivityTimeout time.Duration
+
+	failoverTokenExtractor FailoverTokenExtractor
+	failoverTokenHeader    string
+
+	dropTracing bool
+
+	// writeRateLimiter is nil if the write rate isn't limited (see ratelimit.go)
+	writeRateLimiter     *rateLimiter
+	writeRateNonBlocking bool
+
+	// writeDuringReconnect is a policy of user writes called during a reconnect (see writereconnect.go)
+	writeDuringReconnect        WriteDuringReconnectMode
+	writeDuringReconnectTimeout time.Duration
+	reconnectFlight             reconnectFlight
+
+	// writeStages are run for every write (see pipeline.go)
+	writeStages []writeStage
+	// readTransform and writeTransform are applied to data messages (see transform.go)
+	readTransform  TransformFunc
+	writeTransform TransformFunc
+
+	strictCloseOrdering bool
+
+	headerFunc HeaderFunc
+	// urlFunc is called before every dial (see 'SetURLFunc')
+	urlFunc URLFunc
+	// leaderElection is called before every dial (see 'SetLeaderElection')
+	leaderElection LeaderElectionFunc
+	// dialLimiter is shared by instances to limit their dials (see diallimiter.go)
+	dialLimiter DialLimiter
+
+	backoff Backoff
+
+	maxReconnectAttempts int
+	maxSubscribeFailures int
+	retryConnect         bool
+	// permanentDialStatusCodes is nil if the default codes are used
+	permanentDialStatusCodes map[int]bool
+	retryPolicy              PhaseRetryPolicy
+	// maxReconnectDelay caps delays requested with 'Retry-After'. The default value is used if it's 0
+	maxReconnectDelay time.Duration
+	// stopOnNormalClose is the inverse of 'SetReconnectOnNormalClose', so the zero value keeps reconnecting
+	stopOnNormalClose bool
+	// reconnectPredicate is called after read and write errors (see 'SetReconnectPredicate')
+	reconnectPredicate ReconnectPredicate
+
+	sequenceExtractor  SequenceExtractor
+	checkpointInterval time.Duration
+	checkpointBuilder  CheckpointBuilder
+
+	// writeBufferSize is a max number of messages in 'writeBuffer'. Buffering is disabled if it's 0
+	writeBufferSize int
+	// queueFlushInterval is a min interval between messages of the write buffer flush, 0 means no limit
+	queueFlushInterval time.Duration
+	queueFlushJitter   bool
+	// readAheadSize is a max number of messages read ahead. 'readAhead' reads the current connection,
+	// it's nil if reading ahead is disabled or there's no connection
+	readAheadSize int
+	readAhead     *readAheadReader
+
+	// payloadSizes is nil if the histogram is disabled
+	payloadSizes *payloadHistogram
+	// writeLatencies is nil if the histogram is disabled
+	writeLatencies *latencyHistogram
+
+	pressureLevels   []float64
+	onPressureChange OnPressureChangeFunc
+
+	onClosed OnClosedFunc
+	lifetime lifetimeTracker
+
+	deliverControlFrames bool
 }
 
 type WsConnection interface {
@@ -63,19 +472,69 @@ type Logger interface {
 }
 
 type (
+	// HeaderFunc returns headers that are added to the header of a dial. It's called before every dial
+	HeaderFunc func() (http.Header, error)
+	// URLFunc returns the url of a dial. It's called before every dial
+	URLFunc          func() (string, error)
 	PingHandler      func(msg string) error
 	SubscribeHandler func(WsConnection) error
+	// ResponsePropagation is called after every successful dial. Changes of 'nextHeaders'
+	// are merged into the headers used for the next connect attempt
+	ResponsePropagation func(resp *DialResponse, nextHeaders http.Header)
+	// DialResponseHook is called with a raw handshake response after every dial
+	DialResponseHook func(resp *http.Response) error
 )
 
-// New creates a new instance of 'ReConn'. To set url, timeouts and etc. use methods 'Set...'
+// AttemptInfo describes a connect attempt
+type AttemptInfo struct {
+	Time       time.Time
+	Duration   time.Duration
+	StatusCode int
+	// Err is nil if the attempt was successful
+	Err error
+	// Phase is a phase the attempt failed at
+	Phase Phase
+	// Origin is the Origin header sent with the handshake. It's empty if no Origin was set
+	// by 'SetOrigin' or 'SetOriginFromURL'
+	Origin string
+}
+
+// DialResponse is a copy of a handshake response
+type DialResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// New creates a new instance of 'ReConn'. To set url, timeouts and etc. use methods 'Set...'.
+// Setters called after 'Dial' are ignored, it's reported by the logger
 func New() *ReConn {
-	return &ReConn{
-		log: NoopLogger{},
+	r := &ReConn{
+		log:     NoopLogger{},
+		metrics: NoopMetricsCollector{},
+		clock:   realClock{},
 		//
 		nextReconnectTime: time.Now(),
+		closeCh:           make(chan struct{}),
+		dialCtx:           context.Background(),
+		connCleanups:      connCleanups{lastReason: DisconnectReason{Err: ErrNotConnected}},
 		//
-		closed: newAtomicBool(),
+		dialed:               newAtomicBool(),
+		closed:               newAtomicBool(),
+		flapping:             newAtomicBool(),
+		awaitingFirstMessage: newAtomicBool(),
+		lastReconnectResumed: newAtomicBool(),
+		postUpgradeRejected:  newAtomicBool(),
+		seqKnown:             newAtomicBool(),
+		listening:            newAtomicBool(),
 	}
+	r.writeStages = []writeStage{r.transformWrite}
+	r.writeBuffer = messageBuffer{budget: &r.memory, component: MemoryWriteBuffer}
+	r.readAheadLeft = messageBuffer{budget: &r.memory, component: MemoryReadAhead}
+	r.replayBuffer = messageBuffer{budget: &r.memory, component: MemoryReplayBuffer}
+	r.scheduler = newScheduler(r.clock, r.closeCh, &r.goroutines)
+	trackIfEnabled(r)
+	return r
 }
 
 // ----------------------------------------------------
@@ -84,70 +543,311 @@ func New() *ReConn {
 
 // SetURL sets url. After 'Dial' call it does nothing
 func (r *ReConn) SetURL(url string) *ReConn {
-	if !r.dialed {
+	r.configure(func() {
 		r.url = url
-	}
+	})
 	return r
 }
 
-// SetHeader sets header for '(*websocket.Conn).Dial' call. After 'Dial' call it does nothing
+// SetHeader sets header for '(*websocket.Conn).Dial' call. It's used by every dial, including
+// reconnects. The header is copied, so later changes of 'header' don't affect dials. After 'Dial'
+// call it does nothing
 func (r *ReConn) SetHeader(header http.Header) *ReConn {
-	if !r.dialed {
-		r.header = header
-	}
+	r.configure(func() {
+		r.header = header.Clone()
+	})
 	return r
 }
 
-// SetHandshakeTimeout sets handshake timeout. After 'Dial' call it does nothing
+// SetRequestHeader is the same as 'SetHeader'
+func (r *ReConn) SetRequestHeader(header http.Header) *ReConn {
+	return r.SetHeader(header)
+}
+
+// SetRequestHeaderFunc sets a callback called immediately before every dial, including reconnects.
+// Returned headers override the ones set by 'SetHeader'. It can be used for short-lived auth tokens.
+// An error fails the attempt with 'ErrDial' and 'ErrHeaderFunc'. After 'Dial' call it does nothing
+func (r *ReConn) SetRequestHeaderFunc(f HeaderFunc) *ReConn {
+	r.configure(func() {
+		r.headerFunc = f
+	})
+	return r
+}
+
+// SetTLSConfig sets the TLS config for 'wss' urls. It's used as is by every dial, so a session cache
+// of the config works across reconnects. It's used only by the default dialer (see 'SetDialFunc').
+// After 'Dial' call it does nothing
+func (r *ReConn) SetTLSConfig(cfg *tls.Config) *ReConn {
+	r.configure(func() {
+		r.tlsConfig = cfg
+	})
+	return r
+}
+
+// SetCookieJar sets the cookie jar for dials. The same jar is used by every dial, so cookies set by
+// a handshake response are sent with the next connects. It's used only by the default dialer
+// (see 'SetDialFunc'). After 'Dial' call it does nothing
+func (r *ReConn) SetCookieJar(jar http.CookieJar) *ReConn {
+	r.configure(func() {
+		r.cookieJar = jar
+	})
+	return r
+}
+
+// SetHandshakeTimeout sets handshake timeout. It's used only by the default dialer (see 'SetDialFunc').
+// After 'Dial' call it does nothing
 func (r *ReConn) SetHandshakeTimeout(d time.Duration) *ReConn {
-	if !r.dialed {
+	r.configure(func() {
 		r.handshakeTimeout = d
-	}
+	})
 	return r
 }
 
 // SetReconnectTimeout sets reconnect timeout. After 'Dial' call it does nothing
 func (r *ReConn) SetReconnectTimeout(d time.Duration) *ReConn {
-	if !r.dialed {
+	r.configure(func() {
 		r.reconnectTimeout = d
-	}
+	})
 	return r
 }
 
-// SetPingHandler sets ping handler. After 'Dial' call it does nothing
+// SetPingHandler sets ping handler. It's applied to every new connection and replaces the default
+// handler that replies with pongs. After 'Dial' call it does nothing
 func (r *ReConn) SetPingHandler(f PingHandler) *ReConn {
-	if !r.dialed {
+	r.configure(func() {
 		r.pingHandler = f
-	}
+	})
 	return r
 }
 
-// SetSubscribeHandler sets subscribe handler. After 'Dial' call it does nothing
+// SetSubscribeHandler sets subscribe handler. It replaces the handler set by 'SetSubscribeHandlerContext'.
+// After 'Dial' call it does nothing
 func (r *ReConn) SetSubscribeHandler(f SubscribeHandler) *ReConn {
-	if !r.dialed {
+	r.configure(func() {
 		r.subscribeHandler = f
-	}
+		r.subscribeHandlerContext = nil
+	})
 	return r
 }
 
 // SetLogger sets logger. After 'Dial' call it does nothing
 func (r *ReConn) SetLogger(log Logger) *ReConn {
-	if !r.dialed {
+	r.configure(func() {
 		if log == nil {
 			log = NoopLogger{}
 		}
 		r.log = log
+	})
+	return r
+}
+
+// SetDialBodySink sets a writer the handshake response body is streamed to. When the sink is set,
+// 'GetDialBody' returns only the first bytes of the body as a preview. The sink is written
+// by a separate goroutine, so a slow writer doesn't block reconnection. If the sink can't keep up,
+// bodies are dropped.
+//
+// Note that gorilla/websocket keeps only the first 1024 bytes of a failed handshake response,
+// so the sink never receives more. After 'Dial' call it does nothing
+func (r *ReConn) SetDialBodySink(w io.Writer) *ReConn {
+	r.configure(func() {
+		r.dialBodySink = w
+	})
+	return r
+}
+
+// SetMaxDialBodySize sets a max size of a saved handshake response body (see 'GetDialBody').
+// The rest of the body isn't read, 'DialBodyTruncated' reports that the body was cut. 0 means
+// the default size, 64 KiB. After 'Dial' call it does nothing
+func (r *ReConn) SetMaxDialBodySize(n int) *ReConn {
+	r.configure(func() {
+		r.maxDialBodySize = n
+	})
+	return r
+}
+
+// SetCaptureDialBody enables or disables saving of handshake response bodies. It's enabled by default.
+// When it's disabled, 'GetDialBody' returns an empty body, and the body isn't read unless a dial body sink
+// is set (see 'SetDialBodySink'). Retry policies and '*PermanentDialError' get an empty body too.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetCaptureDialBody(capture bool) *ReConn {
+	r.configure(func() {
+		r.skipDialBody = !capture
+	})
+	return r
+}
+
+// SetDebugRelease enables detection of misused messages returned by 'ReadMessageHandle': data of
+// a released message is poisoned and a second 'Release' call panics. After 'Dial' call it does nothing
+func (r *ReConn) SetDebugRelease(enabled bool) *ReConn {
+	r.configure(func() {
+		r.debugRelease = enabled
+	})
+	return r
+}
+
+// SetInitialDialDelay makes the first dial wait a random duration in [0, max) to avoid
+// simultaneous connects of many clients. The wait is interrupted by 'Close'. After 'Dial' call it does nothing
+func (r *ReConn) SetInitialDialDelay(max time.Duration) *ReConn {
+	r.configure(func() {
+		r.initialDialDelay = max
+	})
+	return r
+}
+
+func (r *ReConn) waitInitialDialDelay(ctx context.Context) error {
+	if r.initialDialDelay <= 0 {
+		return nil
+	}
+
+	delay := randomDuration(r.initialDialDelay)
+	r.logFields(logLevelDebug, fmt.Sprintf("wait %s before the first dial", delay), map[string]interface{}{
+		LogFieldDelay: delay,
+	})
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.closeSignal():
+		return ErrConnClosed
+	case <-ctx.Done():
+		return fmt.Errorf("wait before the first dial: %w", ctx.Err())
 	}
+}
+
+// SetDialResponseHook sets a hook called right after every dial (successful or not) that returned
+// a response, before the response body is read. The hook must not retain 'resp'. If the hook
+// consumes the body, 'GetDialBody' reflects that. An error returned by the hook fails the attempt.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetDialResponseHook(hook DialResponseHook) *ReConn {
+	r.configure(func() {
+		r.dialResponseHook = hook
+	})
+	return r
+}
+
+// SetResponsePropagation sets a handler that can propagate values of a handshake response
+// (session cookies, tokens and etc.) into headers of the next connect attempts.
+// The handler must not call methods of 'ReConn'. After 'Dial' call it does nothing
+func (r *ReConn) SetResponsePropagation(f ResponsePropagation) *ReConn {
+	r.configure(func() {
+		r.responsePropagation = f
+	})
 	return r
 }
 
 func (r *ReConn) Dial() error {
-	if r.dialed {
+	return r.DialContext(context.Background())
+}
+
+// DialContext is like 'Dial', but 'ctx' is used by the first connect and by all reconnects.
+// Cancellation of 'ctx' aborts the backoff wait and the in-flight dial, the error wraps 'ctx.Err()'.
+// After 'ctx' is done every reconnect fails immediately, so 'ctx' should live as long as the instance
+func (r *ReConn) DialContext(ctx context.Context) error {
+	if err := r.markDialed(r.checkConfig); err != nil {
+		return err
+	}
+	r.startFeatures()
+
+	r.mu.Lock()
+	r.dialCtx = ctx
+	r.mu.Unlock()
+
+	if err := r.waitInitialDialDelay(ctx); err != nil {
+		return err
+	}
+
+	return r.connect(0, nil)
+}
+
+// AdoptConnection can be used instead of 'Dial' to pass an already established connection
+// to 'ReConn'. The subscribe handler (if set) is called for the adopted connection. All
+// future reconnects use the configured url and headers. 'resp' can be nil
+func (r *ReConn) AdoptConnection(conn WsConnection, resp *http.Response) error {
+	if r.dialed.Get() {
+		return ErrAlreadyDialed
+	}
+	if conn == nil {
+		return ErrNotConnected
+	}
+	if err := r.markDialed(nil); err != nil {
+		return err
+	}
+	r.startFeatures()
+
+	// Cleanups of a failed setup
+	defer r.connCleanups.RunDue()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.log.Info("adopt connection")
+
+	r.saveDialBody(resp)
+	return r.setupConn(conn, resp, r.loadConfig())
+}
+
+// markDialed sets 'r.dialed' and saves the settings. 'check' validates the settings if it isn't nil.
+// Setters are blocked during the call, so the settings can't change after the check
+func (r *ReConn) markDialed(check func() error) error {
+	r.setupMu.Lock()
+	defer r.setupMu.Unlock()
+
+	if r.dialed.Get() {
 		return ErrAlreadyDialed
 	}
-	r.dialed = true
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	if !r.dialed.CompareAndSwap(false, true) {
+		return ErrAlreadyDialed
+	}
+	r.initConfig()
+	return nil
+}
+
+// configure calls 'set' if 'Dial' wasn't called yet and the instance isn't immutable (see 'NewWithOptions').
+// Otherwise, the setter is ignored and the error is logged
+func (r *ReConn) configure(set func()) {
+	r.setupMu.Lock()
+	defer r.setupMu.Unlock()
+
+	switch {
+	case r.immutable:
+		r.log.Error("setter is ignored: the instance was created by 'NewWithOptions'")
+	case r.dialed.Get():
+		r.log.Error("setter is ignored: the instance is already dialed")
+	default:
+		set()
+	}
+}
+
+// tryConfigure calls 'set' if 'Dial' wasn't called yet. It reports whether 'set' was called. Unlike 'configure',
+// it ignores immutability: it's used by 'UpdateConfig' that changes settings even after 'Dial'
+func (r *ReConn) tryConfigure(set func()) bool {
+	r.setupMu.Lock()
+	defer r.setupMu.Unlock()
+
+	if r.dialed.Get() {
+		return false
+	}
+	set()
+	return true
+}
 
-	return r.connect()
+// startFeatures schedules periodic work of all features. It's called by every 'Dial', 'AdoptConnection'
+// and 'Redial' call: 'Close' stops the scheduler
+func (r *ReConn) startFeatures() {
+	r.startReaderWatchdog()
+	r.startCheckpointSender()
+	r.startSuspendDetection()
+	r.startKeepAlive()
+	r.startReadInactivityCheck()
+	r.startReconnectTrend()
 }
 
 // ----------------------------------------------------
@@ -155,147 +855,939 @@ func (r *ReConn) Dial() error {
 // ----------------------------------------------------
 
 func (r *ReConn) ReadMessage() (messageType int, data []byte, readErr error) {
-	if !r.dialed {
-		return 0, nil, ErrNotDialed
-	}
+	return r.ReadMessageContext(context.Background())
+}
 
-	messageType, data, readErr = r.readMessage()
-	if readErr == nil {
-		return messageType, data, nil
+// ReadMessageContext is like 'ReadMessage', but a blocked read is interrupted when 'ctx' is done.
+// In this case 'ctx.Err()' is returned without reconnecting. The interrupted connection can't be read
+// anymore, so the next read reconnects
+func (r *ReConn) ReadMessageContext(ctx context.Context) (messageType int, data []byte, readErr error) {
+	messageType, data, _, readErr = r.readMessageContext(ctx)
+	return messageType, data, readErr
+}
+
+// readMessageContext is like 'ReadMessageContext', but also returns info about the connection
+// the message was read from
+func (r *ReConn) readMessageContext(ctx context.Context) (messageType int, data []byte, info ReadInfo, readErr error) {
+	if !r.dialed.Get() {
+		return 0, nil, info, ErrNotDialed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, nil, info, err
+	}
+	if r.closeCalled() {
+		return 0, nil, info, ErrConnClosed
 	}
+	if err := r.terminalError(); err != nil {
+		return 0, nil, info, err
+	}
+	r.enterRead()
+	defer r.exitRead()
+
+	var retried bool
+	for {
+		var generation, connGeneration uint64
+		messageType, data, generation, connGeneration, readErr = r.readMessage(ctx)
+		if readErr != nil {
+			if ctx.Err() != nil {
+				return 0, nil, info, ctx.Err()
+			}
+			if isTransformError(readErr) {
+				return 0, nil, info, readErr
+			}
+			err := r.reconnectOnError(generation, r.checkPostUpgradeRejection(wrapReadLimitError(readErr)))
+			if r.retryRead(generation, &retried) {
+				continue
+			}
+			return messageType, data, info, err
+		}
 
-	// Try to reconnect
-	if recErr := r.connect(); recErr != nil {
-		if recErr == ErrConnClosed {
-			return messageType, data, readErr
+		if !r.beginDelivery() {
+			r.dropMessage(DropFeatureClosed, data)
+			return 0, nil, info, ErrConnClosed
 		}
+		endRegion := traceRegion(ctx, traceRegionFilter)
+		deliver := r.filterMessage(messageType, data)
+		endRegion()
+		r.endDelivery()
 
-		return messageType, data, fmt.Errorf("%w: original error: '%s', reconnect error: '%s'", ErrReconnect, readErr, recErr)
+		if deliver {
+			return messageType, data, r.readInfo(connGeneration), nil
+		}
 	}
+}
 
-	return messageType, data, readErr
+// filterMessage handles a read message and reports whether it must be returned to the caller
+func (r *ReConn) filterMessage(messageType int, data []byte) (deliver bool) {
+	r.countRead(len(data))
+	if r.ackWaiters.Match(messageType, data) {
+		r.dropMessage(DropFeatureAck, data)
+		return false
+	}
+	if r.matchFarewellAck(messageType, data) {
+		r.dropMessage(DropFeatureFarewellAck, data)
+		return false
+	}
+	if r.skipEmptyMessage(len(data)) {
+		return false
+	}
+	r.onMessageRead(data)
+	if r.autoRespond(messageType, data) {
+		return false
+	}
+	r.trackSequence(messageType, data)
+	r.taps.Publish(messageType, data)
+	return true
+}
+
+// onMessageRead is called after every successfully read message
+func (r *ReConn) onMessageRead(data []byte) {
+	r.stopFirstMessageTracking(data)
+	r.onMessageReadSize(len(data))
+}
+
+// onMessageReadSize is like 'onMessageRead', but it doesn't need the data (see 'NextReader')
+func (r *ReConn) onMessageReadSize(size int) {
+	atomic.StoreInt64(&r.lastMessageAt, monoNow())
+	r.resetBackoff()
+
+	if r.writeGate != nil {
+		r.writeGate.MessageReceived()
+	}
+
+	if r.adaptiveReadBufferEnabled() {
+		r.messageSizes.Add(size)
+	}
+	if r.payloadSizes != nil {
+		r.payloadSizes.Add(size)
+	}
 }
 
-func (r *ReConn) readMessage() (messageType int, p []byte, err error) {
+// readMessage also returns the generation and the number (see 'ConnectionGeneration') of the used connection
+func (r *ReConn) readMessage(ctx context.Context) (messageType int, p []byte, generation, connGeneration uint64, err error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	if r.closingGracefully() {
+		return 0, nil, r.generation, r.connGeneration, ErrConnClosed
+	}
+	if msg, ok := r.readAheadLeft.Pop(); ok {
+		messageType, p, err = r.transformRead(msg.Type, msg.Data)
+		return messageType, p, r.generation, r.connGeneration, err
+	}
 	if r.conn == nil {
-		return 0, nil, ErrNotConnected
+		return 0, nil, r.generation, r.connGeneration, ErrNotConnected
+	}
+	if msg, ok := r.replayBuffer.Pop(); ok {
+		return msg.Type, msg.Data, r.generation, r.connGeneration, nil
 	}
 
-	return r.conn.ReadMessage()
+	defer r.traceConnRegion(traceRegionRead)()
+	if r.readAhead != nil {
+		messageType, p, err = r.readAhead.Next(ctx)
+	} else {
+		r.applyReadTimeout(r.conn)
+		defer interruptReadOnDone(ctx, r.conn)()
+		messageType, p, err = r.conn.ReadMessage()
+	}
+	if err != nil {
+		return messageType, p, r.generation, r.connGeneration, err
+	}
+	messageType, p, err = r.transformRead(messageType, p)
+	return messageType, p, r.generation, r.connGeneration, err
 }
 
+// WriteMessage writes a message. It's safe for concurrent use: all writes, including internal ones
+// (pings, subscribe messages, close frames), are serialized. Reads aren't blocked by writes
 func (r *ReConn) WriteMessage(messageType int, data []byte) error {
-	if !r.dialed {
+	return r.WriteMessageContext(context.Background(), messageType, data)
+}
+
+// WriteMessageContext is like 'WriteMessage', but a blocked write is interrupted when 'ctx' is done.
+// In this case 'ctx.Err()' is returned without reconnecting. The interrupted connection can't be written
+// anymore, so the next write reconnects
+func (r *ReConn) WriteMessageContext(ctx context.Context, messageType int, data []byte) error {
+	if !r.dialed.Get() {
 		return ErrNotDialed
 	}
+	r.writeCalls.Enter()
+	defer r.writeCalls.Exit()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.closeCalled() {
+		return ErrConnClosed
+	}
+	if err := r.terminalError(); err != nil {
+		return err
+	}
+
+	if r.writeGate != nil {
+		if err := r.writeGate.Wait(); err != nil {
+			return err
+		}
+	}
+
+	// The connection known to the caller (see 'WriteDuringReconnectFail')
+	pinnedGeneration := r.loadSnapshot().generation
+	if handled, err := r.applyWriteDuringReconnect(ctx, messageType, data); handled {
+		return err
+	}
+
+	if buffered, err := r.bufferWrite(messageType, data); buffered {
+		return err
+	}
+	if err := r.waitWriteRate(ctx, messageType); err != nil {
+		return err
+	}
 
-	writeErr := r.writeMessage(messageType, data)
+	var (
+		generation uint64
+		writeErr   error
+	)
+	if r.writeDuringReconnect == WriteDuringReconnectFail {
+		generation, writeErr = r.writeMessagePinned(ctx, pinnedGeneration, messageType, data)
+		if writeErr == ErrReconnecting {
+			return writeErr
+		}
+	} else {
+		generation, writeErr = r.writeMessageGeneration(ctx, WriteSourceUser, messageType, data)
+	}
 	if writeErr == nil {
+		r.resetBackoff()
 		if messageType == websocket.CloseMessage {
 			r.closed.Set(true)
 		}
 		return nil
 	}
-
-	// Try to reconnect
-	if recErr := r.connect(); recErr != nil {
-		if recErr == ErrConnClosed {
-			return writeErr
-		}
-
-		return fmt.Errorf("%w: original error: '%s', reconnect error: '%s'", ErrReconnect, writeErr, recErr)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if isTransformError(writeErr) {
+		return writeErr
 	}
 
-	return writeErr
+	return r.reconnectOnError(generation, writeErr)
 }
 
-func (r *ReConn) writeMessage(messageType int, data []byte) error {
+func (r *ReConn) writeMessage(ctx context.Context, source WriteSource, messageType int, data []byte) error {
+	_, err := r.writeMessageGeneration(ctx, source, messageType, data)
+	return err
+}
+
+// writeMessageGeneration is like 'writeMessage', but also returns the generation of the used connection
+func (r *ReConn) writeMessageGeneration(ctx context.Context, source WriteSource, messageType int, data []byte) (generation uint64, err error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	return r.generation, r.writeMessageLocked(ctx, source, messageType, data)
+}
+
+// writeMessageLocked must be called under 'r.mu' held for reading
+func (r *ReConn) writeMessageLocked(ctx context.Context, source WriteSource, messageType int, data []byte) error {
 	if r.conn == nil {
 		return ErrNotConnected
 	}
 
-	return r.conn.WriteMessage(messageType, data)
+	r.writeMu.Lock(source == WriteSourceHeartbeat)
+	defer r.writeMu.Unlock()
+
+	defer r.traceConnRegion(traceRegionWrite)()
+	defer interruptWriteOnDone(ctx, r.conn)()
+	return r.runWritePipeline(r.conn, source, messageType, data)
+}
+
+// reconnectOnError tries to reconnect after a read or write error on the connection of
+// the passed generation. It returns an error that should be passed to the caller
+func (r *ReConn) reconnectOnError(generation uint64, origErr error) error {
+	if g := r.loadGracefulClose(); g != nil {
+		if origErr != ErrConnClosed {
+			// The reader has got the close frame of the peer or the connection was closed
+			g.once.Do(func() { close(g.peerClosed) })
+		}
+		return ErrConnClosed
+	}
+	if r.closed.Get() {
+		// The error is caused by the closed connection ("use of closed network connection" and etc.)
+		return ErrConnClosed
+	}
+	// All read methods get here, so the close frame is saved for any of them
+	r.saveCloseFrame(origErr)
+
+	if !r.shouldReconnect(origErr) {
+		return origErr
+	}
+
+	defer traceRegion(context.Background(), traceRegionReconnect)()
+
+	if recErr := r.connect(generation, origErr); recErr != nil {
+		if recErr == ErrConnClosed {
+			// The original error is likely caused by the closed connection
+			return ErrConnClosed
+		}
+		if recErr == ErrReconnectsPaused {
+			return &ReconnectsPausedError{Err: origErr}
+		}
+		if _, ok := recErr.(*ServerClosedError); ok {
+			return recErr
+		}
+
+		return &ReconnectError{Err: origErr, ReconnectErr: recErr}
+	}
+
+	return origErr
 }
 
-func (r *ReConn) connect() (err error) {
+// connect reconnects. If 'SetRetryConnect' is enabled, it retries failed attempts until success,
+// the terminal state or 'Close'. It does nothing if the current connection is newer than
+// the connection of the passed generation: concurrent readers and writers that failed
+// on the same connection must reconnect only once. 'cause' is the error that caused the reconnect
+func (r *ReConn) connect(generation uint64, cause error) error {
+	for {
+		err := r.connectOnce(generation, cause)
+		if err == nil || !r.retryConnect || PhaseOf(err) == PhaseUnknown || r.terminalError() != nil {
+			return err
+		}
+	}
+}
+
+// connectOnce makes a single connect attempt. The lock isn't held during the backoff wait
+func (r *ReConn) connectOnce(generation uint64, cause error) error {
+	r.reconnectFlight.Begin()
+	defer r.endReconnectFlight()
+
+	// Cleanups of the dropped connection or of a failed setup (see conncleanup.go)
+	defer r.connCleanups.RunDue()
+
+	// The callback must not be called under the lock
+	resumeState := r.captureResumeState(generation)
+
+	dialCtx, waitUntil, reconnected, err := r.dropConn(generation, cause, resumeState)
+	if err != nil || reconnected {
+		return err
+	}
+	r.connCleanups.RunDue()
+
+	// 'Close' cancels the attempt
+	ctx, cancel := r.closeContext(dialCtx)
+	defer cancel()
+
+	// Wait for the backoff
+	timer, stopTimer := r.clock.NewTimer(waitUntil.Sub(r.clock.Now()))
+	select {
+	case <-timer:
+	case <-ctx.Done():
+		stopTimer()
+	case <-r.pause.Signal():
+		stopTimer()
+	}
+
+	return r.dial(ctx)
+}
+
+// dropConn closes the previous connection. It returns the dial context and the time of the next attempt.
+// 'reconnected' is true if the connection of the passed generation was already replaced
+func (r *ReConn) dropConn(generation uint64, cause error, resumeState interface{}) (dialCtx context.Context, waitUntil time.Time, reconnected bool, err error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if r.closed.Get() {
 		// Connection was closed
-		return ErrConnClosed
+		return nil, time.Time{}, false, ErrConnClosed
+	}
+	if err := r.terminalError(); err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if r.generation != generation {
+		r.log.Debug("skip reconnect: connection was already replaced")
+		return nil, time.Time{}, true, nil
 	}
 
+	if r.conn != nil {
+		if r.postUpgradeRejected.CompareAndSwap(true, false) {
+			// The previous connect attempt failed, so back off as after any other failure
+			r.scheduleReconnect(SchedulePostUpgradeRejected, r.loadConfig().ReconnectTimeout, 0)
+		}
+		r.scheduleAfterLostConn()
+		r.updateFlapState()
+
+		r.logFields(logLevelDebug, "close previous connection", map[string]interface{}{
+			LogFieldGeneration: r.generation,
+			LogFieldError:      cause,
+		})
+		// Close previous connection
+		r.closePrevConn(r.conn)
+		r.stopReadAhead(true)
+		r.conn = nil
+		r.resumeState = resumeState
+		r.countDisconnect(cause)
+		reconnectCause := r.reconnectCause(cause)
+		r.trend.Add(reconnectCause)
+		r.connCleanups.End(DisconnectReason{Err: cause, Cause: reconnectCause})
+		r.endConnTrace()
+		r.setState(StateDisconnected, "connection lost")
+		r.notifyDisconnect(cause)
+
+		if err := r.saveServerClose(cause); err != nil {
+			r.enterTerminalState(err)
+			return nil, time.Time{}, false, err
+		}
+	}
+	r.skipBackoffAfterResume()
+
+	return r.dialCtx, r.nextReconnectTime, false, nil
+}
+
+// dial dials after the backoff wait
+func (r *ReConn) dial(ctx context.Context) (err error) {
+	// The callbacks must not be called under the lock
+	funcURL, urlErr := r.callURLFunc()
+	extraHeader, headerErr := r.callHeaderFunc()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.waitDialInFlight(ctx)
+
+	if err := r.terminalError(); err != nil {
+		return err
+	}
+	if r.conn != nil {
+		// Another goroutine has reconnected during the wait
+		return nil
+	}
+	if r.pause.Paused() {
+		return ErrReconnectsPaused
+	}
+
+	// retryAfter is a delay requested by the server, delayOverride is a delay chosen by the retry policy
+	var retryAfter, delayOverride time.Duration
 	defer func() {
+		r.trackSubscribeFailure(err)
+		r.publishDialError(err)
 		if err == nil {
+			r.connectFailures = 0
 			return
 		}
-		r.nextReconnectTime = time.Now().Add(r.reconnectTimeout)
+		if terminalErr := r.countConnectFailure(err); terminalErr != nil {
+			err = terminalErr
+		} else if delayOverride > 0 {
+			r.scheduleDelayOverride(delayOverride)
+		} else if retryAfter > 0 {
+			r.scheduleRetryAfter(retryAfter)
+		} else {
+			r.scheduleAfterFailure()
+		}
+		if err != ErrConnClosed {
+			r.reportConnectFailure(err)
+			r.advanceURL()
+			r.setState(StateDisconnected, err.Error())
+			r.notifyReconnectFailed(err)
+		}
 	}()
 
-	if r.conn != nil {
-		r.log.Debug("close previous connection")
-		// Close previous connection
-		r.conn.Close()
-		r.conn = nil
+	if err := r.attemptContextErr(ctx); err != nil {
+		return err
 	}
 
-	<-time.After(time.Until(r.nextReconnectTime))
+	// Use the same config for the whole attempt
+	cfg := r.attemptConfig(r.loadConfig(), funcURL)
+	if r.generation > 0 {
+		r.setState(StateReconnecting, "")
+	} else {
+		r.setState(StateConnecting, "")
+	}
+
+	r.logFields(logLevelInfo, fmt.Sprintf("connect to '%s'", cfg.URL), map[string]interface{}{
+		LogFieldURL:        cfg.URL,
+		LogFieldAttempt:    r.connectFailures + 1,
+		LogFieldGeneration: r.generation,
+	})
+	r.metrics.ConnectAttempt(cfg.URL)
+
+	attempt := AttemptInfo{Time: time.Now()}
+	defer func() {
+		attempt.Duration = time.Since(attempt.Time)
+		attempt.Err = err
+		attempt.Phase = PhaseOf(err)
+		r.lastAttempt.Store(attempt)
+		if f := r.onAttempt; f != nil {
+			r.callbacks.Push(func() { f(attempt) })
+		}
+	}()
+
+	if urlErr != nil {
+		err = newConnectError(PhaseDial, urlErr)
+		r.logFields(logLevelError, err.Error(), r.attemptLogFields(cfg.URL, attempt.Time, err))
+		return err
+	}
+	if headerErr != nil {
+		err = newConnectError(PhaseDial, headerErr)
+		r.logFields(logLevelError, err.Error(), r.attemptLogFields(cfg.URL, attempt.Time, err))
+		return err
+	}
+	header := r.dialHeader(cfg.Header, extraHeader)
+	if attempt.Origin = r.dialOrigin(cfg.URL); attempt.Origin != "" {
+		header = withHeader(header, "Origin", attempt.Origin)
+	}
+	if token, ok := r.failoverToken(cfg.URL); ok {
+		header = withHeader(header, r.failoverTokenHeader, token)
+	}
 
-	r.log.Info(fmt.Sprintf("connect to '%s'", r.url))
+	dialer := r.newDialer(cfg)
+	generation := r.generation
+
+	// Don't hold the lock during the dial: 'Close' and other methods must not be blocked by a slow handshake
+	dialDone := make(chan struct{})
+	r.dialDone = dialDone
+	r.mu.Unlock()
+	release, err := r.acquireLeadership(ctx)
+	var limiterRelease func()
+	if err == nil {
+		limiterRelease, err = r.acquireDialLimiter(ctx)
+	}
+	attemptCtx, cancelAttempt := r.connectTimeoutContext(ctx)
+	defer cancelAttempt()
+	var (
+		conn WsConnection
+		resp *http.Response
+	)
+	if err == nil {
+		conn, resp, err = r.dialUnlocked(attemptCtx, dialer, cfg.URL, header)
+		limiterRelease()
+	}
+	r.mu.Lock()
+	r.dialDone = nil
+	close(dialDone)
+
+	defer func() {
+		if release != nil {
+			// The attempt has failed: the leadership is released by 'connectOnce' without the lock
+			r.connCleanups.AddDue(release)
+		}
+	}()
 
-	conn, resp, err := r.newDialer().Dial(r.url, r.header)
-	if resp != nil && resp.Body != nil {
-		// Save response body
-		r.dialBody, _ = ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
+	if resp != nil {
+		attempt.StatusCode = resp.StatusCode
+	}
+	r.saveDialBody(resp)
+
+	if err != nil {
+		if ctxErr := r.attemptContextErr(ctx); ctxErr != nil {
+			return ctxErr
+		}
+		if timeoutErr := r.connectTimeoutErr(attemptCtx); timeoutErr != nil {
+			err = timeoutErr
+		}
+		err = newConnectError(PhaseDial, err)
+		r.logFields(logLevelError, err.Error(), r.attemptLogFields(cfg.URL, attempt.Time, err))
+		err, retryAfter, delayOverride = r.classifyDialError(resp, err)
+		return err
 	}
+	if r.generation != generation {
+		// Another goroutine has reconnected during the dial
+		conn.Close()
+		return nil
+	}
+
+	// The subscribe handler gets the rest of the connect timeout
+	r.attemptDeadline, _ = attemptCtx.Deadline()
+	err = r.setupConn(conn, resp, cfg)
+	r.attemptDeadline = time.Time{}
 	if err != nil {
-		err = fmt.Errorf("%w: %s", ErrDial, err)
-		r.log.Error(err.Error())
+		err, delayOverride = r.classifyConnectError(err)
 		return err
 	}
+	r.logFields(logLevelInfo, fmt.Sprintf("connected to '%s'", cfg.URL), r.attemptLogFields(cfg.URL, attempt.Time, nil))
+	r.metrics.ConnectSuccess(time.Since(attempt.Time))
+	r.holdLeadership(release)
+	release = nil
+	return nil
+}
+
+// waitDialInFlight waits until the dial of another goroutine finishes, so concurrent reconnects
+// don't dial twice. It must be called under 'r.mu'
+func (r *ReConn) waitDialInFlight(ctx context.Context) {
+	for r.dialDone != nil && ctx.Err() == nil {
+		done := r.dialDone
 
-	if r.pingHandler != nil {
-		conn.SetPingHandler(r.pingHandler)
+		r.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		r.mu.Lock()
 	}
+}
 
-	if r.subscribeHandler != nil {
-		r.log.Debug("call subscribe handler")
+// dialUnlocked dials and calls the dial response hook. It must be called without holding 'r.mu'
+func (r *ReConn) dialUnlocked(ctx context.Context, dialer *websocket.Dialer, url string, header http.Header) (WsConnection, *http.Response, error) {
+	conn, resp, err := r.callDialFunc(ctx, dialer, url, header)
+
+	if resp != nil && r.dialResponseHook != nil {
+		if hookErr := r.dialResponseHook(resp); hookErr != nil {
+			if err == nil {
+				conn.Close()
+				return nil, resp, fmt.Errorf("dial response hook: %w", hookErr)
+			}
+			r.log.Error(fmt.Sprintf("dial response hook error: %s", hookErr))
+		}
+	}
+	return conn, resp, err
+}
 
-		// Pass raw connection to prevent deadlock
-		if err := r.subscribeHandler(conn); err != nil {
-			err = fmt.Errorf("%w: %s", ErrSubscribe, err)
-			r.log.Error(err.Error())
+// saveDialBody saves the body of a handshake response. It must be called under 'r.mu'
+func (r *ReConn) saveDialBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	defer resp.Body.Close()
 
-			conn.Close()
-			return err
+	if r.dialBodySink == nil {
+		r.dialBody, r.dialBodyTruncated = nil, false
+		if !r.skipDialBody {
+			r.dialBody, r.dialBodyTruncated = readDialBody(resp.Body, r.maxDialBodySizeOrDefault())
+		}
+		r.publishDialBody(resp)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxDialBodySinkSize))
+
+	preview := body
+	if len(preview) > dialBodyPreviewSize {
+		preview = preview[:dialBodyPreviewSize]
+	}
+	truncated := len(preview) < len(body)
+	if r.skipDialBody {
+		preview, truncated = nil, false
+	}
+	r.dialBody, r.dialBodyTruncated = preview, truncated
+	r.publishDialBody(resp)
+
+	r.enqueueDialBody(body)
+}
+
+// publishDialBody must be called under 'r.mu' after 'r.dialBody' is changed
+func (r *ReConn) publishDialBody(resp *http.Response) {
+	body, truncated := r.dialBody, r.dialBodyTruncated
+	dialResp := newDialResponse(resp, body)
+	r.updateSnapshot(func(s *snapshot) {
+		s.dialBody = body
+		s.dialBodyTruncated = truncated
+		s.dialResponse = dialResp
+	})
+}
+
+func (r *ReConn) maxDialBodySizeOrDefault() int {
+	if r.maxDialBodySize <= 0 {
+		return defaultMaxDialBodySize
+	}
+	return r.maxDialBodySize
+}
+
+// readDialBody reads up to 'maxSize' bytes of a body. 'truncated' is true if the body is longer
+func readDialBody(body io.Reader, maxSize int) (data []byte, truncated bool) {
+	data, _ = ioutil.ReadAll(io.LimitReader(body, int64(maxSize)+1))
+	if len(data) > maxSize {
+		return data[:maxSize], true
+	}
+	return data, false
+}
+
+// setupConn prepares a freshly established connection (calls the subscribe handler and etc.)
+// and makes it the current one. It must be called under 'r.mu'
+func (r *ReConn) setupConn(conn WsConnection, resp *http.Response, cfg *MutableConfig) (err error) {
+	if r.closed.Get() {
+		// 'Close' was called during the dial, don't subscribe on the abandoned connection
+		conn.Close()
+		return ErrConnClosed
+	}
+
+	// The subscribe handler can register cleanups of the new connection
+	r.connCleanups.Begin()
+	defer func() {
+		if err != nil {
+			r.connCleanups.End(DisconnectReason{Err: err})
 		}
+	}()
+
+	if r.responsePropagation != nil {
+		nextHeader := r.propagatedHeader.Clone()
+		if nextHeader == nil {
+			nextHeader = make(http.Header)
+		}
+		r.responsePropagation(newDialResponse(resp, r.dialBody), nextHeader)
+		r.propagatedHeader = nextHeader
 	}
 
+	r.applyControlHandlers(conn, cfg)
+	r.applyControlFrameDelivery(conn)
+	r.applyCompressionLevel(conn)
+	r.applyReadLimit(conn)
+
+	if r.writeGate != nil {
+		// Reset the gate before the subscribe handler because it can read the first message
+		r.writeGate.Reset()
+	}
+
+	// Messages buffered for the previous connection are stale
+	r.replayBuffer.Reset()
+
+	// Write the subscribe messages and etc. before the connection is published (see egress.go)
+	if err := r.runEgress(conn, cfg); err != nil {
+		err = newConnectError(PhaseSubscribe, err)
+		r.logFields(logLevelError, err.Error(), map[string]interface{}{
+			LogFieldURL:     cfg.URL,
+			LogFieldAttempt: r.connectFailures + 1,
+			LogFieldError:   err,
+		})
+
+		conn.Close()
+		return err
+	}
+
+	if r.closed.Get() {
+		conn.Close()
+		return ErrConnClosed
+	}
+
+	reconnect := !r.connectedAt.IsZero()
+
 	r.conn = conn
+	r.generation++
+	r.connGeneration++
+	r.publishGeneration()
+	if r.payloadSizes != nil {
+		r.payloadSizes.NextGeneration()
+	}
+	r.connectedAt = time.Now()
+	atomic.StoreInt64(&r.lastConnectAt, monoNow())
+	r.stats.connected(reconnect)
+	r.connectedURL = cfg.URL
+	r.clearCloseFrame()
+	r.connectedSubprotocol = negotiatedSubprotocol(conn, resp)
+	r.setState(StateConnected, "")
+	r.startConnTrace()
+	r.notifyConnect()
+	r.setCallbackBarrier()
+	r.startFirstMessageTracking(conn)
+	r.startReadAhead(conn)
+	if reconnect {
+		r.detectResume(newDialResponse(resp, r.dialBody))
+	}
 
 	return nil
 }
 
-func (r *ReConn) newDialer() *websocket.Dialer {
-	return &websocket.Dialer{
-		HandshakeTimeout: r.handshakeTimeout,
+// closePrevConn closes a connection, but waits no longer than 'prevConnCloseTimeout'. A connection
+// that can't be closed in time (for example, because of a full send buffer) is abandoned and its unsent
+// data is discarded. Healthy connections are closed gracefully
+func (r *ReConn) closePrevConn(conn WsConnection) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(prevConnCloseTimeout):
+		atomic.AddUint64(&r.abandonedCloses, 1)
+		r.log.Error(fmt.Sprintf("previous connection wasn't closed in %s, abandon it", prevConnCloseTimeout))
+
+		if c, ok := conn.(interface{ UnderlyingConn() net.Conn }); ok {
+			if tcpConn, ok := c.UnderlyingConn().(*net.TCPConn); ok {
+				// Discard unsent data of the abandoned connection instead of waiting for it
+				_ = tcpConn.SetLinger(0)
+			}
+		}
+	}
+}
+
+// AbandonedCloses returns the number of previous connections that weren't closed in time
+// during reconnects
+func (r *ReConn) AbandonedCloses() uint64 {
+	return atomic.LoadUint64(&r.abandonedCloses)
+}
+
+// enqueueDialBody passes a body to the sink writer. The body is dropped if the queue is full
+func (r *ReConn) enqueueDialBody(body []byte) {
+	r.dialBodySinkOnce.Do(func() {
+		r.dialBodySinkQueue = make(chan []byte, dialBodySinkQueueSize)
+		queue, stop := r.dialBodySinkQueue, r.closeSignal()
+		r.goroutines.Go(func() {
+			r.runDialBodySink(queue, stop)
+		})
+	})
+
+	select {
+	case r.dialBodySinkQueue <- body:
+	default:
+		r.log.Error("dial body sink is too slow, drop dial body")
+	}
+}
+
+// runDialBodySink writes queued bodies to the sink until 'stop' is closed. 'Redial' creates
+// a new queue, so the queue is passed explicitly
+func (r *ReConn) runDialBodySink(queue <-chan []byte, stop <-chan struct{}) {
+	for {
+		select {
+		case body := <-queue:
+			r.writeDialBodySink(body)
+		case <-stop:
+			return
+		}
 	}
 }
 
-// Close closes connection
+// writeDialBodySink writes a dial body to the sink
+func (r *ReConn) writeDialBodySink(body []byte) {
+	if d, ok := r.dialBodySink.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		// Ignore the error: not all writers with this method support deadlines (for example, regular files)
+		_ = d.SetWriteDeadline(time.Now().Add(dialBodySinkTimeout))
+	}
+
+	if _, err := r.dialBodySink.Write(body); err != nil {
+		r.log.Error(fmt.Sprintf("couldn't write dial body to sink: %s", err))
+	}
+}
+
+// dialHeader returns headers for the next dial
+func (r *ReConn) dialHeader(base, extra http.Header) http.Header {
+	if len(r.propagatedHeader) == 0 && len(extra) == 0 {
+		return base
+	}
+
+	header := base.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for _, h := range []http.Header{r.propagatedHeader, extra} {
+		for k, v := range h {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+	return header
+}
+
+// callHeaderFunc calls the request header callback. It must be called without holding 'r.mu'
+func (r *ReConn) callHeaderFunc() (http.Header, error) {
+	if r.headerFunc == nil {
+		return nil, nil
+	}
+	header, err := r.headerFunc()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHeaderFunc, err)
+	}
+	return header, nil
+}
+
+// withHeader returns a copy of 'header' with the header set. 'header' isn't modified
+func withHeader(header http.Header, key, value string) http.Header {
+	header = header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(key, value)
+	return header
+}
+
+func newDialResponse(resp *http.Response, body []byte) *DialResponse {
+	res := &DialResponse{
+		Body: append([]byte(nil), body...),
+	}
+	if resp != nil {
+		res.StatusCode = resp.StatusCode
+		res.Header = resp.Header.Clone()
+	}
+	return res
+}
+
+func (r *ReConn) newDialer(cfg *MutableConfig) *websocket.Dialer {
+	dialer := &websocket.Dialer{
+		HandshakeTimeout:  cfg.HandshakeTimeout,
+		TLSClientConfig:   r.tlsConfig,
+		Proxy:             r.proxy,
+		Subprotocols:      r.subprotocols,
+		EnableCompression: r.enableCompression,
+		Jar:               r.cookieJar,
+		NetDialContext:    r.netDial,
+		ReadBufferSize:    r.connReadBufferSize,
+		WriteBufferSize:   r.connWriteBufferSize,
+		WriteBufferPool:   r.connWriteBufferPool,
+	}
+	if r.adaptiveReadBufferEnabled() {
+		size := r.nextReadBufferSize()
+		atomic.StoreInt64(&r.appliedReadBufferSize, int64(size))
+		dialer.ReadBufferSize = size
+	}
+	if r.dialerConfigurator != nil {
+		r.dialerConfigurator(dialer)
+	}
+	return dialer
+}
+
+// Close closes connection. A reconnect attempt racing with 'Close' is aborted
+// and never calls the subscribe handler. A pending backoff wait and an in-flight dial are canceled,
+// reads and writes waiting for them fail with 'ErrConnClosed'. Blocked reads and writes and all
+// reads and writes after 'Close' fail with 'ErrConnClosed' too, without a reconnect attempt. The instance
+// doesn't reconnect after 'Close' until 'Redial' is called
 func (r *ReConn) Close() error {
-	if !r.dialed {
+	untrack(r)
+
+	r.lifecycleMu.Lock()
+	defer r.lifecycleMu.Unlock()
+
+	return r.closeLocked()
+}
+
+// closeLocked must be called under 'r.lifecycleMu'
+func (r *ReConn) closeLocked() error {
+	if !r.dialed.Get() {
+		// Close channels of state subscribers and taps anyway
+		r.setState(StateClosed, "")
+		r.taps.CloseAll()
 		return ErrNotDialed
 	}
 
+	// The connection must still be usable in the hook
+	r.callPreCloseHook()
+	r.sendFarewell()
+
+	// Set the flag before taking the lock to abort an in-flight reconnect as early as possible
+	r.closed.Set(true)
+	r.signalClose()
+	r.setState(StateClosed, "")
+
+	if r.writeGate != nil {
+		r.writeGate.Close()
+	}
+
+	r.interruptRead()
+	err := r.closeConn()
+	r.connCleanups.RunDue()
+	r.finishLifetime()
+	r.dropWriteBuffer()
+	r.readAheadLeft.Reset()
+	r.waitDeliveries()
+	r.taps.CloseAll()
+	return err
+}
+
+// interruptRead interrupts a read blocked in the current connection: the reader holds 'r.mu',
+// so 'closeConn' would wait for the next message. Connections without read deadlines aren't interrupted
+func (r *ReConn) interruptRead() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c, ok := r.conn.(interface{ SetReadDeadline(t time.Time) error }); ok {
+		_ = c.SetReadDeadline(time.Now())
+	}
+}
+
+func (r *ReConn) closeConn() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -305,21 +1797,81 @@ func (r *ReConn) Close() error {
 
 	r.log.Debug("close connection")
 
-	r.closed.Set(true)
 	conn := r.conn
 	r.conn = nil
-	return conn.Close()
+	r.countDisconnect(nil)
+	r.connCleanups.End(DisconnectReason{Closed: true})
+	r.endConnTrace()
+	err := conn.Close()
+	r.stopReadAhead(false)
+	return err
 }
 
+// GetDialBody returns a copy of the body of the last handshake response. The body is capped
+// (see 'SetMaxDialBodySize' and 'DialBodyTruncated'). It doesn't block during reconnects
 func (r *ReConn) GetDialBody() []byte {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	body := r.loadSnapshot().dialBody
 
-	bodyCopy := make([]byte, len(r.dialBody))
-	copy(bodyCopy, r.dialBody)
+	bodyCopy := make([]byte, len(body))
+	copy(bodyCopy, body)
 	return bodyCopy
 }
 
+// GetDialResponse returns a copy of the last handshake response: the status code, the headers and
+// the body (see 'GetDialBody'). The response is updated after every dial attempt that received
+// a response, including failed ones. It returns nil if there were no responses. It doesn't block
+// during reconnects
+func (r *ReConn) GetDialResponse() *DialResponse {
+	resp := r.loadSnapshot().dialResponse
+	if resp == nil {
+		return nil
+	}
+	return &DialResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       append([]byte(nil), resp.Body...),
+	}
+}
+
+// LastAttempt returns information about the most recent connect attempt. It doesn't
+// block during reconnects. The second value is false if there were no attempts
+func (r *ReConn) LastAttempt() (AttemptInfo, bool) {
+	attempt, ok := r.lastAttempt.Load().(AttemptInfo)
+	return attempt, ok
+}
+
+// LastDialError returns the error of the last failed connect attempt: '*ConnectError' that matches
+// 'ErrDial' or 'ErrSubscribe', possibly wrapped (for example, into '*PermanentDialError'). It returns nil
+// after a successful connect or if there were no failed attempts. Attempts aborted by 'Close' and etc.
+// don't change it. It doesn't block during reconnects
+func (r *ReConn) LastDialError() error {
+	return r.loadSnapshot().dialErr
+}
+
+// publishDialError updates the error returned by 'LastDialError' after a connect attempt. 'err' is
+// the result of the attempt. It must be called under 'r.mu'
+func (r *ReConn) publishDialError(err error) {
+	if err != nil && PhaseOf(err) == PhaseUnknown {
+		return
+	}
+	r.updateSnapshot(func(s *snapshot) {
+		s.dialErr = err
+	})
+}
+
+// DialBodyTruncated reports whether the body returned by 'GetDialBody' was cut: it exceeded the size
+// set by 'SetMaxDialBodySize' or only a preview is saved because of a dial body sink. It doesn't block
+// during reconnects
+func (r *ReConn) DialBodyTruncated() bool {
+	return r.loadSnapshot().dialBodyTruncated
+}
+
+// ViewDialBody calls 'fn' with the body of the last handshake response without copying it.
+// 'fn' must not modify the slice. It doesn't block during reconnects
+func (r *ReConn) ViewDialBody(fn func(body []byte)) {
+	fn(r.loadSnapshot().dialBody)
+}
+
 // ----------------------------------------------------
 // Noop logger
 // ----------------------------------------------------
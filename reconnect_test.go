@@ -0,0 +1,1007 @@
+package reconnect
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// syncBuffer is a 'bytes.Buffer' safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func wsURL(s *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// newWsServer starts a websocket server that calls 'handler' for every connection
+func newWsServer(handler func(conn *websocket.Conn)) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		handler(conn)
+	}))
+}
+
+func TestDialBodySink(t *testing.T) {
+	// gorilla/websocket keeps only the first 1024 bytes of a failed handshake response
+	const gorillaBodyLimit = 1024
+
+	body := strings.Repeat("x", gorillaBodyLimit*4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	sink := &syncBuffer{}
+	conn := New().SetURL(wsURL(server)).SetDialBodySink(sink)
+	if err := conn.Dial(); err == nil {
+		t.Fatal("'Dial' must return an error")
+	}
+
+	if attempt, ok := conn.LastAttempt(); !ok || attempt.StatusCode != http.StatusInternalServerError || !errors.Is(attempt.Err, ErrDial) {
+		t.Errorf("unexpected last attempt: %+v", attempt)
+	}
+
+	if preview := conn.GetDialBody(); string(preview) != body[:dialBodyPreviewSize] {
+		t.Errorf("'GetDialBody' must return a preview, got %d bytes", len(preview))
+	}
+	if !conn.DialBodyTruncated() {
+		t.Error("preview must be reported as truncated")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for sink.String() != body[:gorillaBodyLimit] {
+		if time.Now().After(deadline) {
+			t.Fatalf("sink must receive the body kept by gorilla/websocket, got %d bytes", len(sink.String()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// countingReader counts read bytes
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	return n, err
+}
+
+func (r *countingReader) Close() error { return nil }
+
+func TestMaxDialBodySize(t *testing.T) {
+	const bodySize = 100 << 10
+
+	newDialFunc := func(body *countingReader) DialFunc {
+		return func(string, http.Header) (WsConnection, *http.Response, error) {
+			resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: make(http.Header), Body: body}
+			return nil, resp, errors.New("bad handshake")
+		}
+	}
+
+	tests := []struct {
+		name          string
+		configure     func(conn *ReConn)
+		wantSize      int
+		wantTruncated bool
+		// wantMaxRead is a max number of bytes read from the body
+		wantMaxRead int64
+	}{
+		{
+			name:          "default size",
+			configure:     func(*ReConn) {},
+			wantSize:      defaultMaxDialBodySize,
+			wantTruncated: true,
+			wantMaxRead:   defaultMaxDialBodySize + 1,
+		},
+		{
+			name:          "custom size",
+			configure:     func(conn *ReConn) { conn.SetMaxDialBodySize(1000) },
+			wantSize:      1000,
+			wantTruncated: true,
+			wantMaxRead:   1001,
+		},
+		{
+			name:          "whole body",
+			configure:     func(conn *ReConn) { conn.SetMaxDialBodySize(bodySize) },
+			wantSize:      bodySize,
+			wantTruncated: false,
+			wantMaxRead:   bodySize,
+		},
+		{
+			name:          "capture disabled",
+			configure:     func(conn *ReConn) { conn.SetCaptureDialBody(false) },
+			wantSize:      0,
+			wantTruncated: false,
+			wantMaxRead:   0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			body := &countingReader{r: strings.NewReader(strings.Repeat("x", bodySize))}
+			conn := New().SetDialFunc(newDialFunc(body))
+			tt.configure(conn)
+			defer conn.Close()
+
+			if err := conn.Dial(); !errors.Is(err, ErrDial) {
+				t.Fatalf("expected ErrDial, got %v", err)
+			}
+			if got := len(conn.GetDialBody()); got != tt.wantSize {
+				t.Errorf("expected body of %d bytes, got %d", tt.wantSize, got)
+			}
+			if got := conn.DialBodyTruncated(); got != tt.wantTruncated {
+				t.Errorf("expected truncated %t, got %t", tt.wantTruncated, got)
+			}
+			if n := atomic.LoadInt64(&body.n); n > tt.wantMaxRead {
+				t.Errorf("expected no more than %d read bytes, got %d", tt.wantMaxRead, n)
+			}
+		})
+	}
+}
+
+// blockingWriter is a writer which 'Write' blocks until 'release' is closed
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestDialBodySinkStall(t *testing.T) {
+	sink := blockingWriter{release: make(chan struct{})}
+	defer close(sink.release)
+
+	conn := New().SetDialBodySink(sink)
+	defer conn.Close()
+
+	goroutines := runtime.NumGoroutine()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < dialBodySinkQueueSize*4; i++ {
+			conn.enqueueDialBody([]byte("body"))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a stalled sink must not block reconnection")
+	}
+
+	// Only the writer goroutine can be left
+	if n := runtime.NumGoroutine() - goroutines; n > 1 {
+		t.Errorf("stalled sink must not pile up goroutines, got %d new ones", n)
+	}
+}
+
+func TestCloseAbortsReconnect(t *testing.T) {
+	// The server drops every connection, so the reader reconnects all the time
+	server := newWsServer(func(conn *websocket.Conn) {})
+	defer server.Close()
+
+	for i := 0; i < 20; i++ {
+		var (
+			closeReturned      int32
+			subscribeAfterStop int32
+		)
+		conn := New().SetURL(wsURL(server)).SetSubscribeHandler(func(WsConnection) error {
+			if atomic.LoadInt32(&closeReturned) == 1 {
+				atomic.AddInt32(&subscribeAfterStop, 1)
+			}
+			return nil
+		})
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				_, _, err := conn.ReadMessage()
+				if errors.Is(err, ErrConnClosed) {
+					return
+				}
+			}
+		}()
+
+		time.Sleep(5 * time.Millisecond)
+		conn.Close()
+		atomic.StoreInt32(&closeReturned, 1)
+		<-done
+
+		if n := atomic.LoadInt32(&subscribeAfterStop); n != 0 {
+			t.Fatalf("subscribe handler was called %d times after 'Close'", n)
+		}
+	}
+}
+
+func TestResponsePropagation(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		sessions []string
+	)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sessions = append(sessions, r.Header.Get("X-Session"))
+		mu.Unlock()
+
+		conn, err := upgrader.Upgrade(w, r, http.Header{"Set-Cookie": {"session=abc"}})
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetResponsePropagation(func(resp *DialResponse, nextHeaders http.Header) {
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		nextHeaders.Set("X-Session", strings.TrimPrefix(resp.Header.Get("Set-Cookie"), "session="))
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// The server closes the connection, so the read triggers a reconnect
+	conn.ReadMessage()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(sessions) != 2 || sessions[0] != "" || sessions[1] != "abc" {
+		t.Errorf("unexpected session headers: %q", sessions)
+	}
+}
+
+// blockingCloseConn is a connection which 'Close' blocks forever
+type blockingCloseConn struct {
+	staticConn
+}
+
+func (blockingCloseConn) Close() error {
+	select {}
+}
+
+func TestReconnectWithHangingClose(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	conn.dialed.Set(true)
+	conn.conn = &blockingCloseConn{}
+
+	start := time.Now()
+	if err := conn.connect(conn.generation, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if d := time.Since(start); d > prevConnCloseTimeout+time.Second {
+		t.Errorf("reconnect took too long: %s", d)
+	}
+	if n := conn.AbandonedCloses(); n != 1 {
+		t.Errorf("expected 1 abandoned close, got %d", n)
+	}
+}
+
+func TestAdoptConnection(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// Drop the adopted connection
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	wsConn, resp, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var subscribes int32
+	conn := New().SetURL(wsURL(server)).SetSubscribeHandler(func(WsConnection) error {
+		atomic.AddInt32(&subscribes, 1)
+		return nil
+	})
+	if err := conn.AdoptConnection(wsConn, resp); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Dial(); !errors.Is(err, ErrAlreadyDialed) {
+		t.Errorf("expected 'ErrAlreadyDialed', got %v", err)
+	}
+
+	// The first read fails and triggers a reconnect
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("the first read must fail")
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected read result: %q, %v", data, err)
+	}
+
+	if n := atomic.LoadInt32(&connections); n != 2 {
+		t.Errorf("expected 2 connections, got %d", n)
+	}
+	if n := atomic.LoadInt32(&subscribes); n != 2 {
+		t.Errorf("expected 2 subscribe calls, got %d", n)
+	}
+}
+
+// failingConn is a connection which reads and writes block until 'fail' is closed and then fail
+type failingConn struct {
+	fail chan struct{}
+}
+
+func (c *failingConn) ReadMessage() (int, []byte, error) {
+	<-c.fail
+	return 0, nil, errors.New("read error")
+}
+
+func (c *failingConn) WriteMessage(int, []byte) error {
+	<-c.fail
+	return errors.New("write error")
+}
+
+func (c *failingConn) Close() error { return nil }
+
+func TestConcurrentReadWriteErrors(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	failing := &failingConn{fail: make(chan struct{})}
+	conn := New().SetURL(wsURL(server))
+	if err := conn.AdoptConnection(failing, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		conn.ReadMessage()
+	}()
+	go func() {
+		defer wg.Done()
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	}()
+
+	// Let both calls block on the connection
+	time.Sleep(50 * time.Millisecond)
+	close(failing.fail)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("expected 1 dial, got %d", n)
+	}
+	if state := conn.State(); state != StateConnected {
+		t.Errorf("expected state %s, got %s", StateConnected, state)
+	}
+
+	// A late reconnect triggered by an error on the replaced connection must be a no-op
+	if err := conn.reconnectOnError(1, errors.New("late error")); err == nil {
+		t.Error("the original error must be returned")
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("expected 1 dial after a late reconnect, got %d", n)
+	}
+}
+
+func TestConcurrentDial(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	defer conn.Close()
+
+	var (
+		wg        sync.WaitGroup
+		successes int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := conn.Dial()
+			switch {
+			case err == nil:
+				atomic.AddInt32(&successes, 1)
+			case !errors.Is(err, ErrAlreadyDialed):
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("expected exactly 1 successful 'Dial', got %d", successes)
+	}
+	// The server handler can be called after 'Dial' returns
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("expected exactly 1 connection, got %d", n)
+	}
+}
+
+func TestSettersRaceWithDial(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		var (
+			dialer FakeDialer
+			log    recordLogger
+		)
+		conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetLogger(&log)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn.SetReconnectTimeout(time.Minute)
+		}()
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		wg.Wait()
+
+		// The setter either takes effect or is reported as ignored
+		applied := conn.loadConfig().ReconnectTimeout == time.Minute
+		ignored := log.Contains("setter is ignored")
+		if applied == ignored {
+			t.Fatalf("unexpected outcome of the setter: applied: %t, ignored: %t", applied, ignored)
+		}
+		conn.Close()
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	const (
+		writers  = 10
+		messages = 100
+	)
+
+	var (
+		connections int32
+		release     = make(chan struct{})
+	)
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		go func() {
+			<-release
+			conn.Close()
+		}()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetKeepAlive(time.Millisecond, time.Second)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() {
+		close(release)
+		conn.Close()
+	}()
+
+	// Reads must not be blocked by writes
+	received := make(chan int)
+	go func() {
+		var n int
+		for n < writers*messages {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+			n++
+		}
+		received <- n
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+
+			for j := 0; j < messages; j++ {
+				var err error
+				switch j % 3 {
+				case 0:
+					err = conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+				case 1:
+					err = conn.WriteJSON(map[string]int{"id": id, "seq": j})
+				case 2:
+					err = conn.WriteMessage(websocket.BinaryMessage, []byte{byte(id), byte(j)})
+				}
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+				if j%10 == 0 {
+					// Pings are written by the same lock as messages
+					conn.WriteMessage(websocket.PingMessage, nil)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	select {
+	case n := <-received:
+		if n != writers*messages {
+			t.Errorf("expected %d echoed messages, got %d", writers*messages, n)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("echoed messages weren't received in time")
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("concurrent writes must not break the connection, got %d connections", n)
+	}
+}
+
+func TestDialResponseHook(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Rate-Limit": {"10"}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	var rateLimit string
+	conn := New().SetURL(wsURL(server)).SetDialResponseHook(func(resp *http.Response) error {
+		rateLimit = resp.Header.Get("X-Rate-Limit")
+		return nil
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.Close()
+	if rateLimit != "10" {
+		t.Errorf("unexpected header value: %q", rateLimit)
+	}
+
+	errHook := errors.New("rejected")
+	conn = New().SetURL(wsURL(server)).SetDialResponseHook(func(resp *http.Response) error {
+		return errHook
+	})
+	if err := conn.Dial(); !errors.Is(err, errHook) || !errors.Is(err, ErrDial) {
+		t.Errorf("hook error must fail the attempt, got %v", err)
+	}
+}
+
+func TestGetDialResponse(t *testing.T) {
+	var connections int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("slow down"))
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Session": {"abc"}})
+		if err != nil {
+			return
+		}
+		// Drop the first connection
+		conn.Close()
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if resp := conn.GetDialResponse(); resp != nil {
+		t.Errorf("response must be nil before 'Dial', got %+v", resp)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	resp := conn.GetDialResponse()
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("X-Session") != "abc" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	// The returned response is a copy
+	resp.Header.Set("X-Session", "modified")
+	if got := conn.GetDialResponse().Header.Get("X-Session"); got != "abc" {
+		t.Errorf("stored response must not be modified, got %q", got)
+	}
+
+	// The read fails, the reconnect is rejected
+	conn.ReadMessage()
+
+	resp = conn.GetDialResponse()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "30" || string(resp.Body) != "slow down" {
+		t.Errorf("response of the failed attempt is expected, got %d %v %q", resp.StatusCode, resp.Header, resp.Body)
+	}
+}
+
+func TestInitialDialDelay(t *testing.T) {
+	conn := New().SetURL("ws://127.0.0.1:1").SetInitialDialDelay(time.Hour)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		conn.Close()
+	}()
+
+	start := time.Now()
+	if err := conn.Dial(); !errors.Is(err, ErrConnClosed) {
+		t.Errorf("expected 'ErrConnClosed', got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("'Close' must interrupt the delay, took %s", d)
+	}
+}
+
+func TestCloseDuringBackoff(t *testing.T) {
+	var connections int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetReconnectTimeout(time.Hour)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The first reconnect fails, so the next one waits for the reconnect timeout
+	conn.ReadMessage()
+	go conn.ReadMessage()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	conn.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("'Close' must interrupt the backoff, took %s", d)
+	}
+}
+
+func TestReadWriteAfterClose(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	var checked int32
+	conn := New().SetURL(wsURL(server)).SetReconnectPredicate(func(error) bool {
+		atomic.AddInt32(&checked, 1)
+		return true
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A read blocked in the connection
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	conn.Close()
+	select {
+	case err := <-readErr:
+		if err != ErrConnClosed {
+			t.Errorf("blocked read: expected ErrConnClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked read must be interrupted")
+	}
+
+	if _, _, err := conn.ReadMessage(); err != ErrConnClosed {
+		t.Errorf("read: expected ErrConnClosed, got %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != ErrConnClosed {
+		t.Errorf("write: expected ErrConnClosed, got %v", err)
+	}
+	if n := atomic.LoadInt32(&checked); n != 0 {
+		t.Errorf("no reconnect must be attempted, the predicate was called %d times", n)
+	}
+}
+
+func TestCloseDuringReconnectWait(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {})
+
+	conn := New().SetURL(wsURL(server)).SetReconnectTimeout(10 * time.Second)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The server is down, so the reconnect after the dropped connection fails
+	server.Close()
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrDial, got %v", err)
+	}
+
+	// The next read waits for the reconnect timeout
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	conn.Close()
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("'Close' must return promptly, took %s", d)
+	}
+	select {
+	case err := <-readErr:
+		if err != ErrConnClosed {
+			t.Errorf("expected ErrConnClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the read must be interrupted by 'Close'")
+	}
+}
+
+func TestRequestHeader(t *testing.T) {
+	var connections int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// Force a reconnect
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	header := http.Header{"Authorization": []string{"Bearer token"}}
+	conn := New().SetURL(wsURL(server)).SetRequestHeader(header)
+	// Must not affect dials
+	header.Set("Authorization", "invalid")
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The first read fails and triggers a reconnect
+	conn.ReadMessage()
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("unexpected error after reconnect: %s", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("got %q, want %q", data, "hello")
+	}
+
+	conn.Close()
+}
+
+func TestRequestHeaderFunc(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") == "" || r.Header.Get("X-Static") != "static" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(r.Header.Get("X-Token")))
+	}))
+	defer server.Close()
+
+	var calls int32
+	conn := New().
+		SetURL(wsURL(server)).
+		SetHeader(http.Header{"X-Static": []string{"static"}}).
+		SetRequestHeaderFunc(func() (http.Header, error) {
+			n := atomic.AddInt32(&calls, 1)
+			if n == 2 {
+				return nil, errors.New("signer is unavailable")
+			}
+			return http.Header{"X-Token": []string{fmt.Sprintf("token-%d", n)}}, nil
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, data, _ := conn.ReadMessage(); string(data) != "token-1" {
+		t.Errorf("got %q, want %q", data, "token-1")
+	}
+
+	// The server dropped the connection, the reconnect fails because of the callback
+	_, _, err := conn.ReadMessage()
+	if !errors.Is(err, ErrHeaderFunc) || !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrHeaderFunc and ErrDial, got: %v", err)
+	}
+
+	// The next attempt gets a fresh token. The read that triggered the reconnect returns an error
+	conn.ReadMessage()
+	if _, data, _ := conn.ReadMessage(); string(data) != "token-3" {
+		t.Errorf("got %q, want %q", data, "token-3")
+	}
+}
+
+func TestSetTLSConfig(t *testing.T) {
+	var resumed int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.DidResume {
+			atomic.AddInt32(&resumed, 1)
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		// Drop the connection, so the next read reconnects
+		conn.Close()
+	}))
+	defer server.Close()
+
+	// The certificate is self-signed
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err == nil {
+		conn.Close()
+		t.Fatal("expected a certificate error")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	tlsConfig := &tls.Config{
+		RootCAs:            roots,
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+	conn = New().SetURL(wsURL(server)).SetTLSConfig(tlsConfig)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// Reconnect. The session cache of the config is used
+	conn.ReadMessage()
+	if conn.State() != StateConnected {
+		t.Fatalf("expected state %s, got %s", StateConnected, conn.State())
+	}
+	if n := atomic.LoadInt32(&resumed); n == 0 {
+		t.Error("expected the TLS session to be resumed on reconnect")
+	}
+}
+
+func TestSetCookieJar(t *testing.T) {
+	var connections int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := http.Header{}
+		if atomic.AddInt32(&connections, 1) == 1 {
+			header.Add("Set-Cookie", (&http.Cookie{Name: "session", Value: "abc"}).String())
+		} else if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, header)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn := New().SetURL(wsURL(server)).SetCookieJar(jar)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, data, _ := conn.ReadMessage(); string(data) != "hello" {
+		t.Fatalf("got %q, want %q", data, "hello")
+	}
+
+	// The server dropped the connection. The reconnect must present the cookie
+	conn.ReadMessage()
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("got %q (%v), want %q", data, err, "hello")
+	}
+	if n := atomic.LoadInt32(&connections); n != 2 {
+		t.Errorf("expected 2 connections, got %d", n)
+	}
+}
@@ -0,0 +1,72 @@
+// Package reconnecttest contains helpers for testing code that uses 'reconnect.ReConn'
+package reconnecttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+// capturedMessage is a line of a capture. Text messages are stored as is, other messages
+// are encoded with base64
+type capturedMessage struct {
+	Type int     `json:"type"`
+	Text *string `json:"text,omitempty"`
+	Data []byte  `json:"data,omitempty"`
+}
+
+// ReadCapture reads a capture: inbound messages of a session in JSON lines. Every line is
+// '{"type":1,"text":"..."}' for text messages or '{"type":2,"data":"<base64>"}' for other messages.
+// Empty lines are skipped
+func ReadCapture(r io.Reader) ([]reconnect.Message, error) {
+	var res []reconnect.Message
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var msg capturedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		data := msg.Data
+		if msg.Text != nil {
+			data = []byte(*msg.Text)
+		}
+		if data == nil {
+			data = []byte{}
+		}
+		res = append(res, reconnect.Message{Type: msg.Type, Data: data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// WriteCapture writes messages in the format of 'ReadCapture'. It can be used to record
+// a session or to write golden files
+func WriteCapture(w io.Writer, messages []reconnect.Message) error {
+	enc := json.NewEncoder(w)
+	for _, m := range messages {
+		msg := capturedMessage{Type: m.Type}
+		if m.Type == websocket.TextMessage {
+			text := string(m.Data)
+			msg.Text = &text
+		} else {
+			msg.Data = m.Data
+		}
+		if err := enc.Encode(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package reconnecttest
+
+import (
+	"sync"
+	"time"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+// Clock is a fake 'reconnect.Clock'. The time changes only by 'Advance'
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+var _ reconnect.Clock = (*Clock)(nil)
+
+type timer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewClock creates a new 'Clock' that starts at 'start'
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t.ch, func() bool { return false }
+	}
+	c.timers = append(c.timers, t)
+
+	stop := func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i := range c.timers {
+			if c.timers[i] == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+	return t.ch, stop
+}
+
+// Advance moves the clock forward and fires expired timers
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			active = append(active, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	for i := len(active); i < len(c.timers); i++ {
+		c.timers[i] = nil
+	}
+	c.timers = active
+}
@@ -0,0 +1,71 @@
+package reconnecttest
+
+import (
+	"time"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+// StateDriver scripts scenarios of a 'reconnect.ReConn': failed dials, dropped connections, received
+// messages and the passage of time. It uses only public seams: the dial function ('SetDialFunc')
+// and the clock ('SetClock'). So, for example, "reconnecting after 3 failed attempts" can be reached
+// without real failures and sleeps
+type StateDriver struct {
+	rc     *reconnect.ReConn
+	dialer *Dialer
+	clock  *Clock
+}
+
+// NewStateDriver sets a fake dialer and a fake clock of 'rc'. It must be called before 'rc.Dial'.
+// The clock starts at a fixed time, so runs are reproducible
+func NewStateDriver(rc *reconnect.ReConn) *StateDriver {
+	d := &StateDriver{
+		rc:     rc,
+		dialer: NewDialer(),
+		clock:  NewClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	rc.SetDialFunc(d.dialer.Dial).SetClock(d.clock)
+	return d
+}
+
+// ReConn returns the driven instance
+func (d *StateDriver) ReConn() *reconnect.ReConn {
+	return d.rc
+}
+
+// Dialer returns the fake dialer, for example, to script new connections with 'Dialer.OnDial'
+func (d *StateDriver) Dialer() *Dialer {
+	return d.dialer
+}
+
+// Clock returns the fake clock
+func (d *StateDriver) Clock() *Clock {
+	return d.clock
+}
+
+// FailNextDials makes the next 'n' dials fail with 'ErrDialRefused'
+func (d *StateDriver) FailNextDials(n int) {
+	d.dialer.FailDials(n)
+}
+
+// DropConnectionNow drops the current connection like a network failure: the next read or write
+// gets the abnormal closure error and reconnects. It does nothing if there were no successful dials
+func (d *StateDriver) DropConnectionNow() {
+	if conn := d.dialer.Last(); conn != nil {
+		conn.DropAfter(0)
+	}
+}
+
+// DeliverMessage queues a message of 'messageType' on the current connection. It does nothing
+// if there were no successful dials
+func (d *StateDriver) DeliverMessage(messageType int, data []byte) {
+	if conn := d.dialer.Last(); conn != nil {
+		conn.Push(messageType, data)
+	}
+}
+
+// AdvanceClock moves the fake clock forward. Delays between connect attempts and periodic work
+// that are due are run
+func (d *StateDriver) AdvanceClock(delay time.Duration) {
+	d.clock.Advance(delay)
+}
@@ -0,0 +1,100 @@
+package reconnecttest
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+func TestStateDriver(t *testing.T) {
+	var failedAttempts int32
+	rc := reconnect.New().SetReconnectTimeout(time.Minute).
+		SetOnReconnectFailed(func(attempt int, err error) {
+			atomic.StoreInt32(&failedAttempts, int32(attempt))
+		})
+	defer rc.Close()
+
+	d := NewStateDriver(rc)
+	if err := rc.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	d.DeliverMessage(websocket.TextMessage, []byte("hello"))
+	if _, data, err := rc.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected message %q, error: %v", data, err)
+	}
+
+	// Reconnecting with 3 failed attempts
+	d.FailNextDials(3)
+	d.DropConnectionNow()
+	d.Dialer().OnDial(func(conn *Conn) {
+		conn.Push(websocket.TextMessage, []byte("resumed"))
+	})
+
+	read := make(chan error, 1)
+	go func() {
+		for {
+			_, data, err := rc.ReadMessage()
+			if err == nil {
+				if string(data) != "resumed" {
+					err = errors.New("unexpected message: " + string(data))
+				}
+				read <- err
+				return
+			}
+			if !errors.Is(err, ErrDialRefused) && !errors.Is(err, reconnect.ErrNotConnected) {
+				var closeErr *websocket.CloseError
+				if !errors.As(err, &closeErr) {
+					read <- err
+					return
+				}
+			}
+		}
+	}()
+
+	// Every delay passes only when the clock is advanced
+	for attempt := 1; attempt <= 3; attempt++ {
+		waitFor(t, func() bool { return atomic.LoadInt32(&failedAttempts) == int32(attempt) })
+		if n := d.Dialer().Dials(); n != 1 {
+			t.Fatalf("attempt %d: expected no new connections, got %d dials", attempt, n)
+		}
+		if d := rc.NextReconnectIn(); d != time.Minute {
+			t.Fatalf("attempt %d: expected the delay of 1m, got %s", attempt, d)
+		}
+		select {
+		case err := <-read:
+			t.Fatalf("read must wait for the delay, got %v", err)
+		default:
+		}
+		d.AdvanceClock(time.Minute)
+	}
+
+	select {
+	case err := <-read:
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read must return the message of the new connection")
+	}
+	if n := d.Dialer().Dials(); n != 2 {
+		t.Errorf("expected 2 successful dials, got %d", n)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition wasn't met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,306 @@
+package reconnecttest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+var (
+	// ErrDialRefused is returned by dials failed with 'Dialer.FailDials'
+	ErrDialRefused = errors.New("dial refused")
+	// errConnClosed is returned by a closed 'Conn'
+	errConnClosed = errors.New("connection is closed")
+)
+
+// Conn is a scriptable in-memory 'reconnect.WsConnection'. Messages pushed by 'Push' are returned
+// by 'ReadMessage', written messages are available via 'Written'. Failures are injected with
+// 'FailNextRead', 'FailNextWrite', 'DropAfter' and 'CloseFromServer'. Errors of a dropped connection
+// and of a close frame are the ones gorilla's connection returns, so the reconnect logic handles them
+// as in production
+type Conn struct {
+	mu      sync.Mutex
+	changed chan struct{}
+	inbox   []reconnect.Message
+	written []reconnect.Message
+	echo    bool
+
+	readErrs  []error
+	writeErrs []error
+	// dropAfter is the number of messages left to read before the connection is dropped.
+	// It's negative if the drop isn't scripted
+	dropAfter int
+	// closeErr is returned after a close frame
+	closeErr *websocket.CloseError
+	closed   bool
+}
+
+var _ reconnect.WsConnection = (*Conn)(nil)
+
+// NewConn creates a new 'Conn'
+func NewConn() *Conn {
+	return &Conn{
+		changed:   make(chan struct{}),
+		dropAfter: -1,
+	}
+}
+
+// notifyLocked wakes up blocked reads. It must be called under 'c.mu'
+func (c *Conn) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Push queues a message for 'ReadMessage'. The data is copied
+func (c *Conn) Push(messageType int, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pushLocked(messageType, data)
+}
+
+func (c *Conn) pushLocked(messageType int, data []byte) {
+	c.inbox = append(c.inbox, reconnect.Message{Type: messageType, Data: append([]byte(nil), data...)})
+	c.notifyLocked()
+}
+
+// SetEcho makes the connection return written text and binary messages by 'ReadMessage'. The data
+// is returned byte for byte, so the echo is safe for binary messages
+func (c *Conn) SetEcho(echo bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.echo = echo
+}
+
+// FailNextRead makes the next read return 'err' before queued messages. Every call fails one read
+func (c *Conn) FailNextRead(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readErrs = append(c.readErrs, err)
+	c.notifyLocked()
+}
+
+// FailNextWrite makes the next write return 'err'. Every call fails one write. It can be used to fail
+// the subscribe handler
+func (c *Conn) FailNextWrite(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeErrs = append(c.writeErrs, err)
+}
+
+// DropAfter drops the connection after 'n' more messages are read: next reads and writes fail
+// with the abnormal closure error (1006) like after a network failure
+func (c *Conn) DropAfter(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	c.dropAfter = n
+	c.notifyLocked()
+}
+
+// CloseFromServer simulates a close frame sent by the server: after queued messages reads fail
+// with '*websocket.CloseError' with 'code', writes fail with 'websocket.ErrCloseSent'
+func (c *Conn) CloseFromServer(code int, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closeErr = &websocket.CloseError{Code: code, Text: text}
+	c.notifyLocked()
+}
+
+// ReadMessage blocks until a message is pushed or the connection fails or is closed
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		c.mu.Lock()
+		switch {
+		case c.closed:
+			c.mu.Unlock()
+			return 0, nil, errConnClosed
+		case len(c.readErrs) > 0:
+			err := c.readErrs[0]
+			c.readErrs = c.readErrs[1:]
+			c.mu.Unlock()
+			return 0, nil, err
+		case c.dropAfter == 0:
+			c.mu.Unlock()
+			return 0, nil, droppedConnError()
+		case len(c.inbox) > 0:
+			msg := c.inbox[0]
+			c.inbox[0] = reconnect.Message{}
+			c.inbox = c.inbox[1:]
+			if c.dropAfter > 0 {
+				c.dropAfter--
+			}
+			c.mu.Unlock()
+			return msg.Type, msg.Data, nil
+		case c.closeErr != nil:
+			err := c.closeErr
+			c.mu.Unlock()
+			return 0, nil, err
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		<-changed
+	}
+}
+
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.closed:
+		return errConnClosed
+	case len(c.writeErrs) > 0:
+		err := c.writeErrs[0]
+		c.writeErrs = c.writeErrs[1:]
+		return err
+	case c.dropAfter == 0:
+		return droppedConnError()
+	case c.closeErr != nil:
+		return websocket.ErrCloseSent
+	}
+
+	c.written = append(c.written, reconnect.Message{Type: messageType, Data: append([]byte(nil), data...)})
+	if c.echo && (messageType == websocket.TextMessage || messageType == websocket.BinaryMessage) {
+		c.pushLocked(messageType, data)
+	}
+	return nil
+}
+
+// Written returns copies of all written messages
+func (c *Conn) Written() []reconnect.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	written := make([]reconnect.Message, 0, len(c.written))
+	for _, msg := range c.written {
+		written = append(written, reconnect.Message{Type: msg.Type, Data: append([]byte(nil), msg.Data...)})
+	}
+	return written
+}
+
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		c.notifyLocked()
+	}
+	return nil
+}
+
+// Closed reports whether 'Close' was called
+func (c *Conn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// droppedConnError returns the error of gorilla's connection after an unexpected EOF
+func droppedConnError() error {
+	return &websocket.CloseError{Code: websocket.CloseAbnormalClosure, Text: io.ErrUnexpectedEOF.Error()}
+}
+
+// Dialer creates 'Conn' connections. Its 'Dial' method must be passed to 'ReConn.SetDialFunc'
+type Dialer struct {
+	mu             sync.Mutex
+	conns          []*Conn
+	failDials      int
+	handshakeDelay time.Duration
+	onDial         func(conn *Conn)
+}
+
+// NewDialer creates a new 'Dialer'
+func NewDialer() *Dialer {
+	return &Dialer{}
+}
+
+// Dial waits for the handshake delay and returns a new 'Conn' or 'ErrDialRefused'.
+// Failed dials aren't counted
+func (d *Dialer) Dial(string, http.Header) (reconnect.WsConnection, *http.Response, error) {
+	d.mu.Lock()
+	delay := d.handshakeDelay
+	d.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.failDials > 0 {
+		d.failDials--
+		return nil, nil, ErrDialRefused
+	}
+
+	conn := NewConn()
+	if d.onDial != nil {
+		// Script the connection before it's returned, so the subscribe handler sees the script
+		d.onDial(conn)
+	}
+	d.conns = append(d.conns, conn)
+	return conn, &http.Response{StatusCode: http.StatusSwitchingProtocols, Header: make(http.Header)}, nil
+}
+
+// FailDials makes the next 'n' dials fail with 'ErrDialRefused'
+func (d *Dialer) FailDials(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failDials = n
+}
+
+// SetHandshakeDelay delays every dial. A dial function can't be interrupted, use
+// 'ReConn.SetConnectTimeout' to bound delayed dials
+func (d *Dialer) SetHandshakeDelay(delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handshakeDelay = delay
+}
+
+// OnDial sets a function that scripts every new connection, for example, to push messages
+// for the subscribe handler or to fail its write with 'Conn.FailNextWrite'
+func (d *Dialer) OnDial(f func(conn *Conn)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onDial = f
+}
+
+// Dials returns the number of successful dials
+func (d *Dialer) Dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.conns)
+}
+
+// Last returns the connection created by the last successful dial. It returns nil if there
+// were no dials
+func (d *Dialer) Last() *Conn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.conns) == 0 {
+		return nil
+	}
+	return d.conns[len(d.conns)-1]
+}
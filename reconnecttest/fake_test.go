@@ -0,0 +1,178 @@
+package reconnecttest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+func TestDialerFailDials(t *testing.T) {
+	dialer := NewDialer()
+	dialer.FailDials(2)
+
+	rc := reconnect.New().SetDialFunc(dialer.Dial).SetReconnectTimeout(time.Millisecond)
+	defer rc.Close()
+
+	if err := rc.Dial(); !errors.Is(err, ErrDialRefused) || !errors.Is(err, reconnect.ErrDial) {
+		t.Fatalf("expected ErrDialRefused, got %v", err)
+	}
+	if _, _, err := rc.ReadMessage(); !errors.Is(err, ErrDialRefused) {
+		t.Fatalf("expected ErrDialRefused, got %v", err)
+	}
+	if n := dialer.Dials(); n != 0 {
+		t.Fatalf("failed dials must not be counted, got %d", n)
+	}
+
+	// The third dial succeeds
+	dialer.OnDial(func(conn *Conn) {
+		conn.Push(websocket.TextMessage, []byte("hello"))
+	})
+	// The read that finds no connection reconnects
+	if _, _, err := rc.ReadMessage(); !errors.Is(err, reconnect.ErrNotConnected) {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+	if _, data, err := rc.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected message %q, error: %v", data, err)
+	}
+	if n := dialer.Dials(); n != 1 {
+		t.Errorf("expected 1 dial, got %d", n)
+	}
+}
+
+func TestConnEcho(t *testing.T) {
+	dialer := NewDialer()
+	dialer.OnDial(func(conn *Conn) {
+		conn.SetEcho(true)
+	})
+
+	rc := reconnect.New().SetDialFunc(dialer.Dial)
+	defer rc.Close()
+
+	if err := rc.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	payload := []byte{0x00, 0xff, '\n', 0x80, 0x00}
+	if err := rc.WriteMessage(websocket.BinaryMessage, payload); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	messageType, data, err := rc.ReadMessage()
+	if err != nil || messageType != websocket.BinaryMessage || string(data) != string(payload) {
+		t.Errorf("unexpected message (type %d) %v, error: %v", messageType, data, err)
+	}
+	if written := dialer.Last().Written(); len(written) != 1 || string(written[0].Data) != string(payload) {
+		t.Errorf("unexpected written messages: %v", written)
+	}
+}
+
+func TestConnFailures(t *testing.T) {
+	t.Run("drop after", func(t *testing.T) {
+		dialer := NewDialer()
+		dialer.OnDial(func(conn *Conn) {
+			conn.Push(websocket.TextMessage, []byte("1"))
+			conn.Push(websocket.TextMessage, []byte("2"))
+			conn.DropAfter(1)
+		})
+
+		rc := reconnect.New().SetDialFunc(dialer.Dial)
+		defer rc.Close()
+
+		if err := rc.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, data, err := rc.ReadMessage(); err != nil || string(data) != "1" {
+			t.Fatalf("unexpected message %q, error: %v", data, err)
+		}
+		// The read that finds the dropped connection reconnects
+		var closeErr *websocket.CloseError
+		if _, _, err := rc.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseAbnormalClosure {
+			t.Fatalf("expected the abnormal closure, got %v", err)
+		}
+		if _, data, err := rc.ReadMessage(); err != nil || string(data) != "1" {
+			t.Fatalf("unexpected message %q, error: %v", data, err)
+		}
+		if n := dialer.Dials(); n != 2 {
+			t.Errorf("expected 2 dials, got %d", n)
+		}
+	})
+
+	t.Run("fail next read", func(t *testing.T) {
+		dialer := NewDialer()
+		rc := reconnect.New().SetDialFunc(dialer.Dial).
+			SetReconnectPredicate(func(error) bool { return false })
+		defer rc.Close()
+
+		if err := rc.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		readErr := errors.New("read failure")
+		dialer.Last().FailNextRead(readErr)
+		if _, _, err := rc.ReadMessage(); !errors.Is(err, readErr) {
+			t.Errorf("expected the injected error, got %v", err)
+		}
+	})
+
+	t.Run("subscribe failure", func(t *testing.T) {
+		writeErr := errors.New("write failure")
+		dialer := NewDialer()
+		dialer.OnDial(func(conn *Conn) {
+			conn.FailNextWrite(writeErr)
+		})
+
+		rc := reconnect.New().SetDialFunc(dialer.Dial).SetSubscribeHandler(func(conn reconnect.WsConnection) error {
+			return conn.WriteMessage(websocket.TextMessage, []byte("subscribe"))
+		})
+		defer rc.Close()
+
+		if err := rc.Dial(); !errors.Is(err, reconnect.ErrSubscribe) || !errors.Is(err, writeErr) {
+			t.Errorf("expected ErrSubscribe with the injected error, got %v", err)
+		}
+		if !dialer.Last().Closed() {
+			t.Error("connection must be closed")
+		}
+	})
+
+	t.Run("close from server", func(t *testing.T) {
+		dialer := NewDialer()
+		dialer.OnDial(func(conn *Conn) {
+			conn.Push(websocket.TextMessage, []byte("bye"))
+			conn.CloseFromServer(websocket.CloseNormalClosure, "maintenance")
+		})
+
+		rc := reconnect.New().SetDialFunc(dialer.Dial).SetReconnectOnNormalClose(false)
+		defer rc.Close()
+
+		if err := rc.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, data, err := rc.ReadMessage(); err != nil || string(data) != "bye" {
+			t.Fatalf("unexpected message %q, error: %v", data, err)
+		}
+		if _, _, err := rc.ReadMessage(); !errors.Is(err, reconnect.ErrServerClosed) {
+			t.Fatalf("expected ErrServerClosed, got %v", err)
+		}
+		if code, text, ok := rc.LastCloseCode(); !ok || code != websocket.CloseNormalClosure || text != "maintenance" {
+			t.Errorf("unexpected close code %d (%q)", code, text)
+		}
+	})
+}
+
+func TestDialerHandshakeDelay(t *testing.T) {
+	dialer := NewDialer()
+	dialer.SetHandshakeDelay(time.Second)
+
+	rc := reconnect.New().SetDialFunc(dialer.Dial).SetConnectTimeout(50 * time.Millisecond)
+	defer rc.Close()
+
+	start := time.Now()
+	if err := rc.Dial(); !errors.Is(err, reconnect.ErrDial) {
+		t.Fatalf("expected ErrDial, got %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("delayed dial must be abandoned, took %s", d)
+	}
+}
@@ -0,0 +1,104 @@
+package reconnecttest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+// errCaptureEnd is returned by 'replayConn' after the last captured message
+var errCaptureEnd = errors.New("end of capture")
+
+// replayConn returns captured messages. Written messages are discarded
+type replayConn struct {
+	mu       sync.Mutex
+	messages []reconnect.Message
+	// ended is closed when all messages were read
+	ended     chan struct{}
+	endedOnce sync.Once
+}
+
+func newReplayConn(messages []reconnect.Message) *replayConn {
+	return &replayConn{
+		messages: messages,
+		ended:    make(chan struct{}),
+	}
+}
+
+func (c *replayConn) ReadMessage() (messageType int, p []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.messages) == 0 {
+		c.endedOnce.Do(func() { close(c.ended) })
+		return 0, nil, errCaptureEnd
+	}
+	msg := c.messages[0]
+	c.messages = c.messages[1:]
+	return msg.Type, msg.Data, nil
+}
+
+func (c *replayConn) WriteMessage(int, []byte) error { return nil }
+
+func (c *replayConn) Close() error { return nil }
+
+// ReplayThrough drives a captured session (see 'ReadCapture') through the full read pipeline of 'rc'
+// and returns the messages that reached the caller of 'ReadMessage'. 'rc' must be configured as in
+// production, but not dialed. Its dial function is replaced (see 'ReConn.SetDialFunc'): the first dial
+// returns a connection that replays the capture, so the messages consumed by the subscribe handler and
+// subscription acks must be in the capture as well. All messages are read in the calling goroutine.
+// After the last message 'rc' is closed
+func ReplayThrough(rc *reconnect.ReConn, capture io.Reader) (delivered []reconnect.Message, err error) {
+	messages, err := ReadCapture(capture)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read capture: %w", err)
+	}
+
+	conn := newReplayConn(messages)
+	var dials int32
+	rc.SetDialFunc(func(string, http.Header) (reconnect.WsConnection, *http.Response, error) {
+		if atomic.AddInt32(&dials, 1) > 1 {
+			return nil, nil, errCaptureEnd
+		}
+		return conn, &http.Response{StatusCode: http.StatusSwitchingProtocols, Header: make(http.Header)}, nil
+	})
+
+	if err := rc.Dial(); err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("couldn't dial: %w", err)
+	}
+
+	// The reconnect after the end of the capture can wait for a delay, 'Close' cancels it
+	stop := make(chan struct{})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		select {
+		case <-conn.ended:
+		case <-stop:
+		}
+		rc.Close()
+	}()
+	defer func() {
+		close(stop)
+		<-closed
+	}()
+
+	for {
+		messageType, data, readErr := rc.ReadMessage()
+		if readErr != nil {
+			select {
+			case <-conn.ended:
+				return delivered, nil
+			default:
+				return delivered, readErr
+			}
+		}
+		delivered = append(delivered, reconnect.Message{Type: messageType, Data: append([]byte(nil), data...)})
+	}
+}
@@ -0,0 +1,104 @@
+package reconnecttest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	reconnect "github.com/ShoshinNikita/ws-reconnect"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func newProductionConn() *reconnect.ReConn {
+	rc := reconnect.New().
+		SetURL("wss://example.com/stream").
+		// Must not delay the end of the replay
+		SetReconnectTimeout(time.Hour).
+		SetEmptyMessagePolicy(reconnect.DropEmptyMessages).
+		SetAutoResponder(func(_ int, data []byte) ([]byte, int, bool) {
+			if string(data) == "ping" {
+				return []byte("pong"), websocket.TextMessage, true
+			}
+			return nil, 0, false
+		})
+	rc.SubscribeWithAck("trades", []byte("sub:trades"), func(_ int, data []byte) (bool, error) {
+		return string(data) == "ack:trades", nil
+	}, time.Second)
+	return rc
+}
+
+func TestReplayThrough(t *testing.T) {
+	capture, err := os.Open(filepath.Join("testdata", "session.jsonl"))
+	if err != nil {
+		t.Fatalf("couldn't open capture: %s", err)
+	}
+	defer capture.Close()
+
+	start := time.Now()
+	delivered, err := ReplayThrough(newProductionConn(), capture)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("replay took too long: %s", d)
+	}
+
+	var got bytes.Buffer
+	if err := WriteCapture(&got, delivered); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	golden := filepath.Join("testdata", "session.golden")
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+			t.Fatalf("couldn't update golden file: %s", err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("couldn't read golden file: %s", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("delivered messages changed. If it's intended, run the test with -update.\ngot:\n%s\nwant:\n%s", got.Bytes(), want)
+	}
+}
+
+func TestReplayThroughInvalidCapture(t *testing.T) {
+	rc := reconnect.New()
+	if _, err := ReplayThrough(rc, strings.NewReader("{\"type\":1}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for line 2, got: %v", err)
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	messages := []reconnect.Message{
+		{Type: websocket.TextMessage, Data: []byte(`{"a":1}`)},
+		{Type: websocket.TextMessage, Data: []byte{}},
+		{Type: websocket.BinaryMessage, Data: []byte{0, 1, 2}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCapture(&buf, messages); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := ReadCapture(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) != len(messages) {
+		t.Fatalf("expected %d messages, got %d", len(messages), len(got))
+	}
+	for i := range messages {
+		if got[i].Type != messages[i].Type || !bytes.Equal(got[i].Data, messages[i].Data) {
+			t.Errorf("message %d: got %d %q, want %d %q", i, got[i].Type, got[i].Data, messages[i].Type, messages[i].Data)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package reconnect
+
+import (
+	"context"
+	"sync"
+)
+
+// Redial is like 'RedialContext', but uses 'context.Background()'
+func (r *ReConn) Redial() error {
+	return r.RedialContext(context.Background())
+}
+
+// RedialContext makes a closed instance usable again. The connection is dialed as by 'DialContext'
+// with the same settings, the subscribe handler is called, and the instance reconnects as usual until
+// the next 'Close'. The terminal state (see 'SetMaxReconnectAttempts') is reset. Channels returned by
+// 'SubscribeState' and 'Tap' before 'Close' stay closed, goroutines of 'WatchConfig' and 'Listen'
+// aren't restarted.
+//
+// Reads and writes that failed because of 'Close' never dial, even if they race with 'RedialContext':
+// they fail with 'ErrConnClosed'. 'ErrNotDialed' is returned if neither 'Dial' nor 'AdoptConnection'
+// was called, 'ErrAlreadyDialed' - if the instance isn't closed
+func (r *ReConn) RedialContext(ctx context.Context) error {
+	if !r.dialed.Get() {
+		return ErrNotDialed
+	}
+
+	generation, err := r.reopen(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.waitInitialDialDelay(ctx); err != nil {
+		return err
+	}
+
+	// A concurrent reader may have already connected. Then the generation differs, and the call does nothing
+	return r.connect(generation, nil)
+}
+
+// reopen resets everything 'Close' has shut down. It returns the generation the first connect must use:
+// the generation is increased, so connects of reads and writes failed before 'Close' are skipped
+func (r *ReConn) reopen(ctx context.Context) (generation uint64, err error) {
+	r.lifecycleMu.Lock()
+	defer r.lifecycleMu.Unlock()
+
+	if !r.closed.Get() {
+		return 0, ErrAlreadyDialed
+	}
+
+	r.log.Info("redial")
+
+	r.closeMu.Lock()
+	r.closeCh = make(chan struct{})
+	r.closeMu.Unlock()
+
+	// 'Close' has returned, so the hooks aren't running
+	r.preCloseOnce = sync.Once{}
+	r.farewellOnce = sync.Once{}
+
+	// The previous scheduler was stopped by 'Close'
+	r.scheduler = newScheduler(r.scheduler.clock, r.closeSignal(), &r.goroutines)
+	r.startFeatures()
+
+	if gate, ok := r.writeGate.(ReopenableWriteGate); ok {
+		gate.Reopen()
+	}
+	r.taps.Reopen()
+
+	r.mu.Lock()
+	r.dialCtx = ctx
+	r.generation++
+	r.publishGeneration()
+	generation = r.generation
+	r.connectFailures = 0
+	r.setNextReconnectTime(r.clock.Now())
+	r.terminalErr.Store(terminalState{})
+	r.gracefulClose.Store((*gracefulClose)(nil))
+	// The writer of the dial body sink was stopped by 'Close'. 'saveDialBody' is called under the lock
+	r.dialBodySinkOnce = sync.Once{}
+	r.mu.Unlock()
+
+	r.reopenState()
+	r.resetLifetime()
+	r.closed.Set(false)
+	trackIfEnabled(r)
+
+	return generation, nil
+}
+
+// closeSignal returns a channel that is closed by 'Close'
+func (r *ReConn) closeSignal() <-chan struct{} {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+
+	return r.closeCh
+}
+
+// closeCalled reports whether 'Close' was called. Unlike 'r.closed', it isn't set by a close frame
+// written by the user: such a connection can still be read until the peer closes it
+func (r *ReConn) closeCalled() bool {
+	select {
+	case <-r.closeSignal():
+		return true
+	default:
+		return false
+	}
+}
+
+// signalClose closes the channel returned by 'closeSignal'. It can be called multiple times
+func (r *ReConn) signalClose() {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+
+	select {
+	case <-r.closeCh:
+	default:
+		close(r.closeCh)
+	}
+}
@@ -0,0 +1,193 @@
+package reconnect
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRedial(t *testing.T) {
+	var (
+		dialer     FakeDialer
+		subscribes int32
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetSubscribeHandler(func(WsConnection) error {
+		atomic.AddInt32(&subscribes, 1)
+		return nil
+	})
+
+	if err := conn.Redial(); err != ErrNotDialed {
+		t.Fatalf("expected ErrNotDialed, got: %v", err)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.Redial(); err != ErrAlreadyDialed {
+		t.Fatalf("expected ErrAlreadyDialed for an open instance, got: %v", err)
+	}
+
+	conn.Close()
+	conn.goroutines.Wait()
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("read after Close must fail")
+	}
+	if n := dialer.Dials(); n != 1 {
+		t.Fatalf("a closed instance must not reconnect, dials: %d", n)
+	}
+
+	states, _ := conn.SubscribeState(1)
+	if _, ok := <-states; ok {
+		t.Fatal("the state channel of a closed instance must be closed")
+	}
+
+	if err := conn.Redial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&subscribes); n != 2 {
+		t.Fatalf("the subscribe handler must be called after Redial, calls: %d", n)
+	}
+	if state := conn.State(); state != StateConnected {
+		t.Fatalf("expected connected state, got %s", state)
+	}
+	if n := conn.InternalGoroutines(); n == 0 {
+		t.Error("the scheduler must be restarted")
+	}
+
+	// The instance reconnects as usual
+	states, unsubscribe := conn.SubscribeState(4)
+	defer unsubscribe()
+
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected read: %q, %v", data, err)
+	}
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected the error of the broken connection")
+	}
+	if n := dialer.Dials(); n != 3 {
+		t.Fatalf("expected 3 dials, got %d", n)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("after redial")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if change := <-states; change.New != StateDisconnected {
+		t.Errorf("unexpected state change: %+v", change)
+	}
+
+	// The next Close stops the instance again
+	conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, nil); err == nil {
+		t.Fatal("write after Close must fail")
+	}
+	if n := dialer.Dials(); n != 3 {
+		t.Fatalf("a closed instance must not reconnect, dials: %d", n)
+	}
+	conn.goroutines.Wait()
+}
+
+func TestRedialLateRead(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	currentGeneration := func() uint64 {
+		conn.mu.RLock()
+		defer conn.mu.RUnlock()
+		return conn.generation
+	}
+	readErr := errors.New("use of closed network connection")
+
+	// A read failed on the connection closed by 'Close' reports the error after 'Redial'
+	generation := currentGeneration()
+	conn.Close()
+	if err := conn.Redial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.reconnectOnError(generation, readErr); err != readErr {
+		t.Fatalf("expected the read error, got: %v", err)
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Fatalf("the late read must not dial, dials: %d", n)
+	}
+
+	// The same read racing with 'Close' and 'Redial'
+	for i := 0; i < 50; i++ {
+		generation := currentGeneration()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			conn.reconnectOnError(generation, readErr)
+		}()
+
+		conn.Close()
+		if err := conn.Redial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		<-done
+
+		if !conn.IsConnected() {
+			t.Fatalf("expected connected state, got %s", conn.State())
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestRedialTerminalState(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetMaxReconnectAttempts(1)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+	var maxErr *MaxReconnectsError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &maxErr) {
+		t.Fatalf("expected MaxReconnectsError, got: %v", err)
+	}
+	conn.Close()
+
+	dialer.SetError(nil)
+	if err := conn.Redial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("after redial")); err != nil {
+		t.Fatalf("the terminal state must be reset, got: %v", err)
+	}
+}
+
+func TestRedialWriteGate(t *testing.T) {
+	var dialer FakeDialer
+	gate := NewAfterFirstMessageGate(true)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetWriteGate(gate)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.Close()
+
+	if err := conn.Redial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, nil); err != ErrWriteGateClosed {
+		t.Fatalf("expected ErrWriteGateClosed before the first message, got: %v", err)
+	}
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, nil); err != nil {
+		t.Fatalf("the gate must be reopened, got: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var registry = struct {
+	mu        sync.Mutex
+	instances map[*ReConn]struct{}
+	// auto enables tracking of all instances created by 'New'
+	auto bool
+}{
+	instances: make(map[*ReConn]struct{}),
+}
+
+// TrackInstances enables or disables automatic tracking of instances created by 'New'
+func TrackInstances(enabled bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.auto = enabled
+}
+
+// Track adds an instance to the registry used by 'CloseAll'. Instances are removed
+// from the registry on 'Close'
+func Track(r *ReConn) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.instances[r] = struct{}{}
+}
+
+func trackIfEnabled(r *ReConn) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.auto {
+		registry.instances[r] = struct{}{}
+	}
+}
+
+func untrack(r *ReConn) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	delete(registry.instances, r)
+}
+
+// multiError contains several errors
+type multiError []error
+
+func (e multiError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches the target
+func (e multiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// CloseAll closes all tracked instances using at most 'parallelism' goroutines. It returns when
+// all instances are closed or the context is done. The returned error contains close errors
+// and the urls of instances that weren't closed in time
+func CloseAll(ctx context.Context, parallelism int) error {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	registry.mu.Lock()
+	instances := make([]*ReConn, 0, len(registry.instances))
+	for r := range registry.instances {
+		instances = append(instances, r)
+	}
+	registry.mu.Unlock()
+
+	var (
+		mu      sync.Mutex
+		errs    multiError
+		pending = make(map[*ReConn]struct{}, len(instances))
+
+		wg   sync.WaitGroup
+		jobs = make(chan *ReConn)
+	)
+	for _, r := range instances {
+		pending[r] = struct{}{}
+	}
+	for i := 0; i < parallelism && i < len(instances); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for r := range jobs {
+				err := r.Close()
+
+				mu.Lock()
+				delete(pending, r)
+				if err != nil && !errors.Is(err, ErrNotConnected) && !errors.Is(err, ErrNotDialed) {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer wg.Wait()
+		defer close(jobs)
+
+		for _, r := range instances {
+			select {
+			case jobs <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(pending) > 0 {
+		urls := make([]string, 0, len(pending))
+		for r := range pending {
+			urls = append(urls, "'"+r.loadConfig().URL+"'")
+		}
+		sort.Strings(urls)
+		errs = append(errs, fmt.Errorf("%d instances weren't closed (%s): %w", len(pending), strings.Join(urls, ", "), ctx.Err()))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
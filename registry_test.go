@@ -0,0 +1,62 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCloseAll(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	TrackInstances(true)
+	defer TrackInstances(false)
+
+	for i := 0; i < 10; i++ {
+		conn := New().SetURL(wsURL(server))
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// The straggler
+	slow := New().SetURL(wsURL(server) + "/slow").SetPreCloseHook(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}).SetPreCloseTimeout(time.Second)
+	if err := slow.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := CloseAll(ctx, 4)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error for the straggler, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "1 instances weren't closed ('"+wsURL(server)+"/slow')") {
+		t.Errorf("error must list the straggler, got %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("'CloseAll' must respect the context deadline, took %s", d)
+	}
+
+	// Wait for the straggler
+	time.Sleep(time.Second)
+
+	registry.mu.Lock()
+	n := len(registry.instances)
+	registry.mu.Unlock()
+	if n != 0 {
+		t.Errorf("closed instances must be untracked, %d left", n)
+	}
+}
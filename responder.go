@@ -0,0 +1,48 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// AutoResponder is called for every read message. If 'handled' is true, the message isn't
+// returned to the caller and 'reply' (if not nil) is written to the connection
+type AutoResponder func(messageType int, data []byte) (reply []byte, replyType int, handled bool)
+
+// SetAutoResponder sets responders for protocol-level messages (for example, application pings).
+// Responders are called in the passed order, the first one that handles a message wins.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetAutoResponder(responders ...AutoResponder) *ReConn {
+	r.configure(func() {
+		r.autoResponders = responders
+	})
+	return r
+}
+
+// AutoReplies returns the number of messages handled by the auto responders
+func (r *ReConn) AutoReplies() uint64 {
+	return atomic.LoadUint64(&r.autoReplies)
+}
+
+// autoRespond reports whether a message was handled by an auto responder
+func (r *ReConn) autoRespond(messageType int, data []byte) bool {
+	for _, responder := range r.autoResponders {
+		reply, replyType, handled := responder(messageType, data)
+		if !handled {
+			continue
+		}
+
+		atomic.AddUint64(&r.autoReplies, 1)
+		r.dropMessage(DropFeatureAutoResponder, data)
+
+		if reply != nil {
+			// Write errors are ignored: a broken connection will be detected by the next read
+			if err := r.writeMessage(context.Background(), WriteSourceReply, replyType, reply); err != nil {
+				r.log.Error(fmt.Sprintf("couldn't write auto reply: %s", err))
+			}
+		}
+		return true
+	}
+	return false
+}
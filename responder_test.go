@@ -0,0 +1,60 @@
+package reconnect
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAutoResponder(t *testing.T) {
+	pongs := make(chan string, 1)
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"op":"ping"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte("data"))
+
+		_, data, _ := conn.ReadMessage()
+		pongs <- string(data)
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var calls []string
+	conn := New().SetURL(wsURL(server)).SetAutoResponder(
+		func(_ int, data []byte) ([]byte, int, bool) {
+			calls = append(calls, "first")
+			if string(data) == `{"op":"ping"}` {
+				return []byte(`{"op":"pong"}`), websocket.TextMessage, true
+			}
+			return nil, 0, false
+		},
+		func(int, []byte) ([]byte, int, bool) {
+			calls = append(calls, "second")
+			return nil, 0, false
+		},
+	)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	_, data, err := conn.ReadMessage()
+	if err != nil || string(data) != "data" {
+		t.Fatalf("unexpected read result: %q, %v", data, err)
+	}
+	if pong := <-pongs; pong != `{"op":"pong"}` {
+		t.Errorf("unexpected reply: %q", pong)
+	}
+	if n := conn.AutoReplies(); n != 1 {
+		t.Errorf("expected 1 auto reply, got %d", n)
+	}
+
+	want := []string{"first", "first", "second"}
+	if len(calls) != len(want) {
+		t.Fatalf("unexpected responder calls: %v", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("unexpected responder calls: %v", calls)
+		}
+	}
+}
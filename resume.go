@@ -0,0 +1,41 @@
+package reconnect
+
+// ResumeDetector reports whether a reconnect restored the server-side session. 'firstMsg'
+// is nil if the detector doesn't wait for the first message
+type ResumeDetector func(resp *DialResponse, firstMsg []byte) bool
+
+// SetResumeDetector sets a detector evaluated once per reconnect (not for the first connect).
+// If 'useFirstMessage' is true, the detector is called when the first message on the new connection
+// is read. Otherwise, it is called right after the connect. After 'Dial' call it does nothing
+func (r *ReConn) SetResumeDetector(detector ResumeDetector, useFirstMessage bool) *ReConn {
+	r.configure(func() {
+		r.resumeDetector = detector
+		r.resumeDetectorUsesFirstMessage = useFirstMessage
+	})
+	return r
+}
+
+// LastReconnectResumed returns the result of the resume detector for the last reconnect. It's false
+// until the detector is evaluated
+func (r *ReConn) LastReconnectResumed() bool {
+	return r.lastReconnectResumed.Get()
+}
+
+// detectResume must be called under 'r.mu' after a reconnect
+func (r *ReConn) detectResume(resp *DialResponse) {
+	if r.resumeDetector == nil {
+		return
+	}
+
+	// Reset the previous result: the new connection can die before the first message
+	r.lastReconnectResumed.Set(false)
+
+	if !r.resumeDetectorUsesFirstMessage {
+		r.lastReconnectResumed.Set(r.resumeDetector(resp, nil))
+		return
+	}
+
+	r.firstMessage.mu.Lock()
+	r.firstMessage.pendingResume = resp
+	r.firstMessage.mu.Unlock()
+}
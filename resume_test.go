@@ -0,0 +1,64 @@
+package reconnect
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestResumeDetector(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		switch atomic.AddInt32(&connections, 1) {
+		case 1, 3:
+			// Drop the connection without messages
+			return
+		case 2:
+			conn.WriteMessage(websocket.TextMessage, []byte("resumed"))
+			return
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var (
+		conn  *ReConn
+		calls int32
+	)
+	conn = New().SetURL(wsURL(server)).SetResumeDetector(func(resp *DialResponse, firstMsg []byte) bool {
+		atomic.AddInt32(&calls, 1)
+		// Must not deadlock
+		conn.FirstMessageGap()
+		conn.LastOutage()
+		return resp.StatusCode == http.StatusSwitchingProtocols && string(firstMsg) == "resumed"
+	}, true)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if conn.LastReconnectResumed() {
+		t.Error("the first connect must not be treated as a resume")
+	}
+
+	// The first read fails and triggers a reconnect
+	conn.ReadMessage()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("detector must be called once, got %d", n)
+	}
+	if !conn.LastReconnectResumed() {
+		t.Error("reconnect must be detected as resumed")
+	}
+
+	// The read fails and the next connection is dropped before the first message
+	conn.ReadMessage()
+	if conn.LastReconnectResumed() {
+		t.Error("the result of the previous reconnect must be reset")
+	}
+}
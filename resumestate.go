@@ -0,0 +1,91 @@
+package reconnect
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ResumeInfo describes the lost connection. It's passed to the subscribe handler on reconnects,
+// so the handler can resume a stream where it left off
+type ResumeInfo struct {
+	// PrevGeneration is the number of the lost connection (see 'ConnectionGeneration')
+	PrevGeneration uint64
+	// State is the value returned by the function set by 'SetResumeState' when the connection was lost.
+	// It's nil if the function isn't set
+	State interface{}
+	// LastMessageAt is a time of the last message read before the reconnect. It's zero if no messages
+	// were read
+	LastMessageAt time.Time
+}
+
+// ResumeSubscribeHandler is like 'SubscribeHandlerContext', but it also gets 'ResumeInfo'.
+// 'resume' is nil on the first connect
+type ResumeSubscribeHandler func(ctx context.Context, conn WsConnection, resume *ResumeInfo) error
+
+// SetResumeSubscribeHandler sets subscribe handler that gets 'ResumeInfo'. It replaces the handler
+// set by 'SetSubscribeHandler', 'SetSubscribeHandlerContext' and 'SetSubscribeHandlerV2' and vice versa.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetResumeSubscribeHandler(f ResumeSubscribeHandler) *ReConn {
+	r.configure(func() {
+		r.subscribeHandler = nil
+		r.subscribeHandlerContext = func(ctx context.Context, conn WsConnection) error {
+			return f(ctx, conn, ResumeInfoFromContext(ctx))
+		}
+	})
+	return r
+}
+
+// SetResumeState sets a function that captures resume data (for example, the last sequence number
+// or a resume token) when the connection is lost. The value is passed to the subscribe handler
+// of the next connection as 'ResumeInfo.State'. It's called before the reconnect, so it must not
+// block. After 'Dial' call it does nothing
+func (r *ReConn) SetResumeState(f func() interface{}) *ReConn {
+	r.configure(func() {
+		r.resumeStateFunc = f
+	})
+	return r
+}
+
+type resumeInfoKey struct{}
+
+// ResumeInfoFromContext returns 'ResumeInfo' from the context passed to the subscribe handler.
+// It returns nil on the first connect, so handlers set by 'SetSubscribeHandlerContext' and
+// 'SetSubscribeHandlerV2' can resume as well
+func ResumeInfoFromContext(ctx context.Context) *ResumeInfo {
+	info, _ := ctx.Value(resumeInfoKey{}).(*ResumeInfo)
+	return info
+}
+
+func withResumeInfo(ctx context.Context, info *ResumeInfo) context.Context {
+	if info == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, resumeInfoKey{}, info)
+}
+
+// captureResumeState calls the function set by 'SetResumeState' if the connection of the passed
+// generation is about to be dropped
+func (r *ReConn) captureResumeState(generation uint64) interface{} {
+	if r.resumeStateFunc == nil || !r.isCurrentGeneration(generation) || !r.IsConnected() {
+		return nil
+	}
+	return r.resumeStateFunc()
+}
+
+// resumeInfoLocked returns 'ResumeInfo' for the next connection. It returns nil before the first
+// connect. It must be called under 'r.mu'
+func (r *ReConn) resumeInfoLocked() *ResumeInfo {
+	if r.connGeneration == 0 {
+		return nil
+	}
+
+	info := &ResumeInfo{
+		PrevGeneration: r.connGeneration,
+		State:          r.resumeState,
+	}
+	if last := atomic.LoadInt64(&r.lastMessageAt); last != 0 {
+		info.LastMessageAt = monoTime(last)
+	}
+	return info
+}
@@ -0,0 +1,81 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestResumeSubscribeHandler(t *testing.T) {
+	var (
+		dialer  FakeDialer
+		lastSeq int64
+		resumes = make(chan *ResumeInfo, 2)
+	)
+	conn := New().SetDialFunc(dialer.Dial).
+		SetResumeState(func() interface{} {
+			return atomic.LoadInt64(&lastSeq)
+		}).
+		SetResumeSubscribeHandler(func(_ context.Context, _ WsConnection, resume *ResumeInfo) error {
+			resumes <- resume
+			return nil
+		})
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resume := <-resumes; resume != nil {
+		t.Fatalf("resume info must be nil on the first connect, got %+v", resume)
+	}
+
+	dialer.Last().Push(websocket.TextMessage, []byte("5"))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	atomic.StoreInt64(&lastSeq, 5)
+
+	dialer.Last().Fail(errors.New("broken pipe"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("read of the broken connection must fail")
+	}
+
+	resume := <-resumes
+	if resume == nil {
+		t.Fatal("resume info must be passed on reconnects")
+	}
+	if resume.PrevGeneration != 1 || resume.State != int64(5) || resume.LastMessageAt.IsZero() {
+		t.Errorf("unexpected resume info: %+v", resume)
+	}
+}
+
+func TestResumeInfoFromContext(t *testing.T) {
+	var (
+		dialer  FakeDialer
+		resumes = make(chan *ResumeInfo, 2)
+	)
+	conn := New().SetDialFunc(dialer.Dial).
+		SetSubscribeHandlerContext(func(ctx context.Context, _ WsConnection) error {
+			resumes <- ResumeInfoFromContext(ctx)
+			return nil
+		})
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resume := <-resumes; resume != nil {
+		t.Fatalf("resume info must be nil on the first connect, got %+v", resume)
+	}
+
+	dialer.Last().Fail(errors.New("broken pipe"))
+	conn.ReadMessage()
+
+	resume := <-resumes
+	if resume == nil || resume.PrevGeneration != 1 || resume.State != nil || !resume.LastMessageAt.IsZero() {
+		t.Errorf("unexpected resume info: %+v", resume)
+	}
+}
@@ -0,0 +1,258 @@
+package reconnect
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMaxReconnectDelay is a default cap of delays requested with 'Retry-After'
+const defaultMaxReconnectDelay = 5 * time.Minute
+
+// defaultPermanentDialStatusCodes are status codes of handshake responses that aren't retried by default
+var defaultPermanentDialStatusCodes = []int{
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusGone,
+}
+
+// RetryPolicy decides whether a failed dial should be retried. 'resp' is nil if the server didn't respond,
+// its body can be read again. 'err' is the dial error
+type RetryPolicy func(resp *http.Response, err error) bool
+
+// RetryDelayPolicy is like 'RetryPolicy', but it can also choose the delay before the next attempt.
+// 'attempt' is the number of consecutive failed connect attempts including this one, it starts from 1.
+// A positive 'delayOverride' replaces the delay of the backoff (or the reconnect timeout) and the 'Retry-After'
+// header for this attempt only. The failure is still counted by the backoff, so the following delays grow
+// as usual. 'delayOverride' is ignored if 'retry' is false
+type RetryDelayPolicy func(resp *http.Response, err error, attempt int) (retry bool, delayOverride time.Duration)
+
+// PhaseRetryPolicy is like 'RetryDelayPolicy', but it's consulted for failures of all phases of connect
+// attempts: 'PhaseDial' and 'PhaseSubscribe' (including failed subscription acks). 'resp' is nil for failures
+// after the dial. So, for example, a rejected subscription can stop reconnects, while network errors are retried
+type PhaseRetryPolicy func(phase Phase, resp *http.Response, err error, attempt int) (retry bool, delayOverride time.Duration)
+
+// withDelay adapts the policy to 'RetryDelayPolicy' that never overrides the delay
+func (p RetryPolicy) withDelay() RetryDelayPolicy {
+	return func(resp *http.Response, err error, _ int) (bool, time.Duration) {
+		return p(resp, err), 0
+	}
+}
+
+// dialOnly adapts the policy to 'PhaseRetryPolicy' that retries failures of other phases as usual
+func (p RetryDelayPolicy) dialOnly() PhaseRetryPolicy {
+	return func(phase Phase, resp *http.Response, err error, attempt int) (bool, time.Duration) {
+		if phase != PhaseDial {
+			return true, 0
+		}
+		return p(resp, err, attempt)
+	}
+}
+
+// PermanentDialError is returned by all methods after a dial failed with a response that must not be
+// retried (see 'SetPermanentDialStatusCodes' and 'SetRetryPolicy') or a failure of another phase was
+// rejected by a retry policy (see 'SetPhaseRetryPolicy'). The instance is in a terminal state
+// like after 'SetMaxReconnectAttempts' is exceeded. It matches 'ErrPermanentDialFailure' and unwraps
+// to the dial error, so it matches 'ErrDial' too
+type PermanentDialError struct {
+	// StatusCode is 0 if the server didn't respond
+	StatusCode int
+	// Body is the saved body of the handshake response (see 'DialBody')
+	Body []byte
+	Err  error
+}
+
+func (e *PermanentDialError) Error() string {
+	return fmt.Sprintf("%s: status code %d: %s", ErrPermanentDialFailure, e.StatusCode, e.Err)
+}
+
+func (e *PermanentDialError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PermanentDialError) Is(target error) bool {
+	return target == ErrPermanentDialFailure
+}
+
+// SetPermanentDialStatusCodes sets status codes of handshake responses that stop reconnects: the dial
+// fails with '*PermanentDialError'. The default codes are 401, 403, 404 and 410. No codes means that all
+// responses are retried. It's ignored if a retry policy is set (see 'SetRetryPolicy'). After 'Dial' call
+// it does nothing
+func (r *ReConn) SetPermanentDialStatusCodes(codes ...int) *ReConn {
+	r.configure(func() {
+		r.permanentDialStatusCodes = make(map[int]bool, len(codes))
+		for _, code := range codes {
+			r.permanentDialStatusCodes[code] = true
+		}
+	})
+	return r
+}
+
+// SetRetryPolicy sets a function that classifies failed dials. If it returns false, the dial fails with
+// '*PermanentDialError'. Retried responses with the 'Retry-After' header delay the next attempt for
+// the indicated time (see 'SetMaxReconnectDelay'). It replaces a policy set by 'SetRetryDelayPolicy'.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetRetryPolicy(policy RetryPolicy) *ReConn {
+	r.configure(func() {
+		r.retryPolicy = nil
+		if policy != nil {
+			r.retryPolicy = policy.withDelay().dialOnly()
+		}
+	})
+	return r
+}
+
+// SetRetryDelayPolicy is like 'SetRetryPolicy', but the policy can also override the delay before
+// the next attempt (see 'RetryDelayPolicy'). It replaces a policy set by 'SetRetryPolicy'.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetRetryDelayPolicy(policy RetryDelayPolicy) *ReConn {
+	r.configure(func() {
+		r.retryPolicy = nil
+		if policy != nil {
+			r.retryPolicy = policy.dialOnly()
+		}
+	})
+	return r
+}
+
+// SetPhaseRetryPolicy is like 'SetRetryDelayPolicy', but the policy also classifies failures after
+// the dial (see 'PhaseRetryPolicy'). If it returns false, the attempt fails with '*PermanentDialError'
+// that unwraps to the '*ConnectError' of the phase. It replaces a policy set by 'SetRetryPolicy' or
+// 'SetRetryDelayPolicy'. After 'Dial' call it does nothing
+func (r *ReConn) SetPhaseRetryPolicy(policy PhaseRetryPolicy) *ReConn {
+	r.configure(func() {
+		r.retryPolicy = policy
+	})
+	return r
+}
+
+// SetMaxReconnectDelay caps delays requested by the server: if a dial fails with a response that has
+// the 'Retry-After' header (seconds or a http date), the next attempt is made after the indicated delay,
+// but no later than 'd'. The default value is 5 minutes. The backoff and the reconnect timeout are used
+// for responses without the header. After 'Dial' call it does nothing
+func (r *ReConn) SetMaxReconnectDelay(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.maxReconnectDelay = d
+	})
+	return r
+}
+
+// shouldRetryDial must be called under 'r.mu' after 'saveDialBody'
+func (r *ReConn) shouldRetryDial(resp *http.Response, err error) (retry bool, delayOverride time.Duration) {
+	if r.retryPolicy == nil {
+		if resp == nil {
+			return true, 0
+		}
+		if r.permanentDialStatusCodes == nil {
+			for _, code := range defaultPermanentDialStatusCodes {
+				if resp.StatusCode == code {
+					return false, 0
+				}
+			}
+			return true, 0
+		}
+		return !r.permanentDialStatusCodes[resp.StatusCode], 0
+	}
+
+	if resp != nil {
+		// The body was consumed by 'saveDialBody'
+		copied := *resp
+		copied.Body = ioutil.NopCloser(bytes.NewReader(r.dialBody))
+		resp = &copied
+	}
+	return r.retryPolicy(PhaseDial, resp, err, r.connectFailures+1)
+}
+
+// classifyDialError returns '*PermanentDialError' if the dial must not be retried. Otherwise, it returns
+// 'err', a delay from the 'Retry-After' header and a delay chosen by the retry policy. It must be called
+// under 'r.mu' after 'saveDialBody'
+func (r *ReConn) classifyDialError(resp *http.Response, err error) (_ error, retryAfter, delayOverride time.Duration) {
+	retry, delayOverride := r.shouldRetryDial(resp, err)
+	if !retry {
+		permanentErr := &PermanentDialError{Err: err}
+		if resp != nil {
+			permanentErr.StatusCode = resp.StatusCode
+			permanentErr.Body = append([]byte(nil), r.dialBody...)
+		}
+		return permanentErr, 0, 0
+	}
+	if resp == nil {
+		return err, 0, delayOverride
+	}
+	return err, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), delayOverride
+}
+
+// classifyConnectError is like 'classifyDialError', but for failures after the dial. It must be called
+// under 'r.mu'
+func (r *ReConn) classifyConnectError(err error) (_ error, delayOverride time.Duration) {
+	phase := PhaseOf(err)
+	if r.retryPolicy == nil || phase == PhaseUnknown || phase == PhaseDial {
+		return err, 0
+	}
+	retry, delayOverride := r.retryPolicy(phase, nil, err, r.connectFailures+1)
+	if !retry {
+		return &PermanentDialError{Err: err}, 0
+	}
+	return err, delayOverride
+}
+
+// parseRetryAfter parses the value of the 'Retry-After' header: seconds or a http date.
+// It returns 0 for invalid values
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		if maxSeconds := int64(math.MaxInt64 / time.Second); int64(seconds) > maxSeconds {
+			// Avoid the overflow, the delay is capped anyway
+			seconds = int(maxSeconds)
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// scheduleRetryAfter replaces the delay of 'scheduleAfterFailure' with the delay requested by the server.
+// It must be called under 'r.mu'
+func (r *ReConn) scheduleRetryAfter(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	maxDelay := r.maxReconnectDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxReconnectDelay
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+
+	r.logFields(logLevelInfo, fmt.Sprintf("server requested to reconnect in %s", d), map[string]interface{}{
+		LogFieldDelay: d,
+	})
+	r.scheduleReconnect(ScheduleRetryAfter, d, 0)
+}
+
+// scheduleDelayOverride replaces the delay of 'scheduleAfterFailure' with the delay chosen by the retry
+// policy. The failure is counted by the backoff. It must be called under 'r.mu'
+func (r *ReConn) scheduleDelayOverride(d time.Duration) {
+	if r.backoff.enabled() {
+		atomic.AddInt32(&r.backoffFailures, 1)
+	}
+
+	r.logFields(logLevelInfo, fmt.Sprintf("retry policy requested to reconnect in %s", d), map[string]interface{}{
+		LogFieldDelay: d,
+	})
+	r.scheduleReconnect(ScheduleRetryPolicy, d, 0)
+}
@@ -0,0 +1,317 @@
+package reconnect
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newStatusServer returns a server that responds with 'code' to every handshake
+func newStatusServer(code int, header http.Header, body string) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+	return server, &requests
+}
+
+func TestPermanentDialFailure(t *testing.T) {
+	server, requests := newStatusServer(http.StatusUnauthorized, nil, "bad token")
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetRetryConnect(true)
+	defer conn.Close()
+
+	err := conn.Dial()
+	var permanentErr *PermanentDialError
+	if !errors.As(err, &permanentErr) {
+		t.Fatalf("expected PermanentDialError, got %v", err)
+	}
+	if !errors.Is(err, ErrPermanentDialFailure) || !errors.Is(err, ErrDial) || CodeOf(err) != CodePermanentDialFailure {
+		t.Errorf("unexpected error chain: %v", err)
+	}
+	if permanentErr.StatusCode != http.StatusUnauthorized || string(permanentErr.Body) != "bad token" {
+		t.Errorf("unexpected status code (%d) or body (%q)", permanentErr.StatusCode, permanentErr.Body)
+	}
+
+	// Reads and writes return the error without dialing
+	if _, _, err := conn.ReadMessage(); err != permanentErr {
+		t.Errorf("read must return the permanent error, got %v", err)
+	}
+	if err := conn.WriteMessage(1, []byte("msg")); err != permanentErr {
+		t.Errorf("write must return the permanent error, got %v", err)
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+}
+
+func TestPermanentDialStatusCodes(t *testing.T) {
+	server, requests := newStatusServer(http.StatusNotFound, nil, "")
+	defer server.Close()
+
+	// 404 is retried, 503 isn't
+	conn := New().SetURL(wsURL(server)).SetPermanentDialStatusCodes(http.StatusServiceUnavailable)
+	defer conn.Close()
+
+	if err := conn.Dial(); errors.Is(err, ErrPermanentDialFailure) || !errors.Is(err, ErrDial) {
+		t.Fatalf("expected a retryable dial error, got %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); errors.Is(err, ErrPermanentDialFailure) {
+		t.Fatalf("expected a retryable dial error, got %v", err)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	server, _ := newStatusServer(http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}}, "slow down")
+	defer server.Close()
+
+	var body []byte
+	conn := New().SetURL(wsURL(server)).SetRetryPolicy(func(resp *http.Response, err error) bool {
+		body, _ = ioutil.ReadAll(resp.Body)
+		return resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("Retry-After") != ""
+	})
+	defer conn.Close()
+
+	if err := conn.Dial(); errors.Is(err, ErrPermanentDialFailure) || !errors.Is(err, ErrDial) {
+		t.Fatalf("expected a retryable dial error, got %v", err)
+	}
+	if string(body) != "slow down" {
+		t.Errorf("policy must get the body, got %q", body)
+	}
+
+	decisions := conn.ExplainLastSchedule()
+	last := decisions[len(decisions)-1]
+	if last.Source != ScheduleRetryAfter || last.Wait != 30*time.Second {
+		t.Errorf("unexpected decision: %+v", last)
+	}
+
+	server2, _ := newStatusServer(http.StatusTooManyRequests, nil, "")
+	defer server2.Close()
+
+	conn2 := New().SetURL(wsURL(server2)).SetRetryPolicy(func(resp *http.Response, err error) bool {
+		return resp != nil && resp.Header.Get("Retry-After") != ""
+	})
+	defer conn2.Close()
+
+	if err := conn2.Dial(); !errors.Is(err, ErrPermanentDialFailure) {
+		t.Errorf("expected ErrPermanentDialFailure, got %v", err)
+	}
+}
+
+func TestRetryDelayPolicy(t *testing.T) {
+	const override = 150 * time.Millisecond
+
+	var (
+		dialer   FakeDialer
+		attempts []int
+	)
+	conn := New().SetDialFunc(dialer.Dial).SetBackoff(time.Millisecond, time.Second, 2, false).
+		SetRetryDelayPolicy(func(resp *http.Response, err error, attempt int) (bool, time.Duration) {
+			attempts = append(attempts, attempt)
+			if attempt == 2 {
+				return true, override
+			}
+			return true, 0
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+	for i := 0; i < 3; i++ {
+		if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrDial) {
+			t.Fatalf("expected ErrDial, got %v", err)
+		}
+		if i == 1 {
+			if d := conn.NextReconnectIn(); d <= override/2 || d > override {
+				t.Errorf("the next attempt must be delayed by the policy, got %s", d)
+			}
+		}
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(attempts, want) {
+		t.Errorf("got attempts %v, want %v", attempts, want)
+	}
+
+	// Only the second delay is overridden, the third one continues the backoff
+	type decision struct {
+		Source ScheduleSource
+		Wait   time.Duration
+	}
+	var got []decision
+	for _, d := range conn.ExplainLastSchedule() {
+		got = append(got, decision{Source: d.Source, Wait: d.Wait})
+	}
+	want := []decision{
+		{Source: ScheduleAfterFailure, Wait: time.Millisecond},
+		{Source: ScheduleRetryPolicy, Wait: override},
+		{Source: ScheduleAfterFailure, Wait: 4 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got decisions %+v, want %+v", got, want)
+	}
+
+	// A simple policy is adapted and never overrides the delay
+	conn2 := New().SetRetryPolicy(func(*http.Response, error) bool { return true })
+	if retry, delay := conn2.shouldRetryDial(nil, errors.New("connection refused")); !retry || delay != 0 {
+		t.Errorf("unexpected result of the adapted policy: %t, %s", retry, delay)
+	}
+}
+
+// phaseMetrics records phases of failed connect attempts
+type phaseMetrics struct {
+	NoopMetricsCollector
+
+	mu     sync.Mutex
+	phases []Phase
+}
+
+func (m *phaseMetrics) PhaseFailure(phase Phase, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.phases = append(m.phases, phase)
+}
+
+func (m *phaseMetrics) Phases() []Phase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Phase(nil), m.phases...)
+}
+
+func TestPhaseRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		dialErr      error
+		subscribeErr error
+		phase        Phase
+	}{
+		{name: "dial", dialErr: errors.New("connection refused"), phase: PhaseDial},
+		{name: "subscribe", subscribeErr: errors.New("permission denied"), phase: PhaseSubscribe},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				dialer  FakeDialer
+				metrics phaseMetrics
+				phases  []Phase
+			)
+			conn := New().SetDialFunc(dialer.Dial).SetMetricsCollector(&metrics).
+				SetSubscribeHandler(func(WsConnection) error {
+					if dialer.Dials() > 1 {
+						return tt.subscribeErr
+					}
+					return nil
+				}).
+				SetPhaseRetryPolicy(func(phase Phase, resp *http.Response, err error, attempt int) (bool, time.Duration) {
+					phases = append(phases, phase)
+					return false, 0
+				})
+			if err := conn.Dial(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer conn.Close()
+
+			dialer.SetError(tt.dialErr)
+			dialer.Last().Fail(errors.New("connection reset"))
+			_, _, err := conn.ReadMessage()
+			if !errors.Is(err, ErrPermanentDialFailure) || PhaseOf(err) != tt.phase {
+				t.Fatalf("expected a permanent failure of phase %s, got %v", tt.phase, err)
+			}
+			if want := []Phase{tt.phase}; !reflect.DeepEqual(phases, want) {
+				t.Errorf("policy got phases %v, want %v", phases, want)
+			}
+			if want := []Phase{tt.phase}; !reflect.DeepEqual(metrics.Phases(), want) {
+				t.Errorf("metrics got phases %v, want %v", metrics.Phases(), want)
+			}
+		})
+	}
+}
+
+func TestRetryDelayPolicySubscribeFailure(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).
+		SetSubscribeHandler(func(WsConnection) error {
+			if dialer.Dials() > 1 {
+				return errors.New("permission denied")
+			}
+			return nil
+		}).
+		SetRetryDelayPolicy(func(*http.Response, error, int) (bool, time.Duration) {
+			t.Error("dial policies must not be called for subscribe failures")
+			return false, 0
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrSubscribe) || errors.Is(err, ErrPermanentDialFailure) {
+		t.Fatalf("expected a retried subscribe failure, got %v", err)
+	}
+}
+
+func TestMaxReconnectDelay(t *testing.T) {
+	server, _ := newStatusServer(http.StatusServiceUnavailable, http.Header{"Retry-After": {"3600"}}, "")
+	defer server.Close()
+
+	var log recordLogger
+	conn := New().SetURL(wsURL(server)).SetLogger(&log).SetMaxReconnectDelay(time.Minute)
+	defer conn.Close()
+
+	if d := conn.NextReconnectIn(); d != 0 {
+		t.Errorf("no delay is expected before 'Dial', got %s", d)
+	}
+	if err := conn.Dial(); !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrDial, got %v", err)
+	}
+	if d := conn.NextReconnectIn(); d <= 50*time.Second || d > time.Minute {
+		t.Errorf("delay must be capped by 1 minute, got %s", d)
+	}
+	if !log.Contains("server requested to reconnect in 1m0s") {
+		t.Error("delay must be logged")
+	}
+
+	decisions := conn.ExplainLastSchedule()
+	if last := decisions[len(decisions)-1]; last.Source != ScheduleRetryAfter || last.Wait != time.Minute {
+		t.Errorf("unexpected decision: %+v", last)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "10", want: 10 * time.Second},
+		{value: "-1", want: 0},
+		{value: "soon", want: 0},
+		{value: "99999999999999999", want: math.MaxInt64 / time.Second * time.Second},
+		{value: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q): expected %s, got %s", tt.value, tt.want, got)
+		}
+	}
+}
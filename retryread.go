@@ -0,0 +1,29 @@
+package reconnect
+
+// SetRetryReadAfterReconnect changes what a failed read returns. By default, 'ReadMessage' reconnects
+// and returns the read error, so the caller must read again. If the mode is enabled, after a successful
+// reconnect (including the subscribe handler) the read is retried on the new connection. A read is retried
+// only once per call, so a server that breaks every connection can't make the call spin: the error of
+// the retried read is returned. Failed reconnects are returned as usual. It's used by 'ReadMessage',
+// 'ReadMessageContext', 'ReadMessageHandle' and 'ReadJSON'. After 'Dial' call it does nothing
+func (r *ReConn) SetRetryReadAfterReconnect(enabled bool) *ReConn {
+	r.configure(func() {
+		r.retryReadAfterReconnect = enabled
+	})
+	return r
+}
+
+// retryRead must be called after a failed read of the connection of the passed generation was handled
+// by 'reconnectOnError'. It reports whether the read must be retried. 'retried' is set to true
+func (r *ReConn) retryRead(generation uint64, retried *bool) bool {
+	if !r.retryReadAfterReconnect || *retried || r.closed.Get() {
+		return false
+	}
+
+	r.mu.RLock()
+	reconnected := r.conn != nil && r.generation != generation
+	r.mu.RUnlock()
+
+	*retried = reconnected
+	return reconnected
+}
@@ -0,0 +1,82 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRetryReadAfterReconnect(t *testing.T) {
+	connErr := errors.New("connection reset")
+
+	tests := []struct {
+		name  string
+		retry bool
+		// brokenServer makes every new connection fail
+		brokenServer bool
+		wantErr      bool
+		wantDials    int
+	}{
+		{name: "disabled", retry: false, wantErr: true, wantDials: 2},
+		{name: "enabled", retry: true, wantDials: 2},
+		{name: "enabled, broken server", retry: true, brokenServer: true, wantErr: true, wantDials: 3},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var dialer FakeDialer
+			conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetRetryReadAfterReconnect(tt.retry)
+			conn.SetSubscribeHandler(func(c WsConnection) error {
+				if dialer.Dials() == 1 {
+					return nil
+				}
+				if tt.brokenServer {
+					c.(*FakeConn).Fail(connErr)
+				} else {
+					c.(*FakeConn).Push(websocket.TextMessage, []byte("snapshot"))
+				}
+				return nil
+			})
+			if err := conn.Dial(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer conn.Close()
+
+			dialer.Last().Fail(connErr)
+			_, data, err := conn.ReadMessage()
+			if tt.wantErr {
+				if err != connErr {
+					t.Fatalf("expected the read error, got: %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if string(data) != "snapshot" {
+					t.Fatalf("unexpected message: %q", data)
+				}
+			}
+			if n := dialer.Dials(); n != tt.wantDials {
+				t.Errorf("expected %d dials, got %d", tt.wantDials, n)
+			}
+		})
+	}
+}
+
+func TestRetryReadAfterFailedReconnect(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetRetryReadAfterReconnect(true)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+
+	var reconnectErr *ReconnectError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &reconnectErr) {
+		t.Fatalf("expected a reconnect error, got: %v", err)
+	}
+}
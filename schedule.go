@@ -0,0 +1,105 @@
+package reconnect
+
+import (
+	"sync"
+	"time"
+)
+
+// scheduleHistoryLen is a number of the last schedule decisions returned by 'ExplainLastSchedule'
+const scheduleHistoryLen = 32
+
+// ScheduleSource is a reason of a reconnect delay
+type ScheduleSource string
+
+const (
+	// ScheduleAfterFailure is used after a failed connect attempt
+	ScheduleAfterFailure ScheduleSource = "after failure"
+	// ScheduleFlapDamping is used when the connection is flapping (see 'SetFlapDamping')
+	ScheduleFlapDamping ScheduleSource = "flap damping"
+	// SchedulePostUpgradeRejected is used after a close right after the upgrade (see 'SetPostUpgradeRejection')
+	SchedulePostUpgradeRejected ScheduleSource = "post-upgrade rejection"
+	// ScheduleRetryAfter is used when the server asks to wait with the 'Retry-After' header (see 'SetRetryPolicy')
+	ScheduleRetryAfter ScheduleSource = "retry-after"
+	// ScheduleRetryPolicy is used when the retry policy overrides the delay (see 'SetRetryDelayPolicy')
+	ScheduleRetryPolicy ScheduleSource = "retry policy"
+)
+
+// ScheduleDecision describes how a delay before the next connect attempt was computed
+type ScheduleDecision struct {
+	Time time.Time
+	// BaseDelay is a delay before the jitter is applied
+	BaseDelay time.Duration
+	Jitter    time.Duration
+	Source    ScheduleSource
+	// Wait is the final delay
+	Wait time.Duration
+}
+
+// scheduleHistory is a ring of the last schedule decisions
+type scheduleHistory struct {
+	mu        sync.Mutex
+	decisions [scheduleHistoryLen]ScheduleDecision
+	count     int
+}
+
+func (h *scheduleHistory) Add(d ScheduleDecision) {
+	h.mu.Lock()
+	h.decisions[h.count%scheduleHistoryLen] = d
+	h.count++
+	h.mu.Unlock()
+}
+
+// List returns the decisions from the oldest to the newest
+func (h *scheduleHistory) List() []ScheduleDecision {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.count
+	if n > scheduleHistoryLen {
+		n = scheduleHistoryLen
+	}
+	res := make([]ScheduleDecision, 0, n)
+	for i := h.count - n; i < h.count; i++ {
+		res = append(res, h.decisions[i%scheduleHistoryLen])
+	}
+	return res
+}
+
+// ExplainLastSchedule returns the last decisions about reconnect delays, from the oldest
+// to the newest. It doesn't depend on the log level, so it can be used for support bundles
+func (r *ReConn) ExplainLastSchedule() []ScheduleDecision {
+	return r.scheduleHistory.List()
+}
+
+// scheduleReconnect delays the next connect attempt and records the decision. It must be called under 'r.mu'
+func (r *ReConn) scheduleReconnect(source ScheduleSource, baseDelay, jitter time.Duration) {
+	decision := ScheduleDecision{
+		Time:      r.clock.Now(),
+		BaseDelay: baseDelay,
+		Jitter:    jitter,
+		Source:    source,
+		Wait:      baseDelay + jitter,
+	}
+	r.setNextReconnectTime(decision.Time.Add(decision.Wait))
+	r.scheduleHistory.Add(decision)
+}
+
+// setNextReconnectTime must be called under 'r.mu'
+func (r *ReConn) setNextReconnectTime(t time.Time) {
+	r.nextReconnectTime = t
+	r.updateSnapshot(func(s *snapshot) {
+		s.nextReconnectTime = t
+	})
+}
+
+// NextReconnectIn returns the time left until the next connect attempt is allowed. It returns 0 if
+// the instance is connected or an attempt can be made right now. It doesn't block during reconnects
+func (r *ReConn) NextReconnectIn() time.Duration {
+	if r.IsConnected() {
+		return 0
+	}
+	if d := r.loadSnapshot().nextReconnectTime.Sub(r.clock.Now()); d > 0 {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExplainLastSchedule(t *testing.T) {
+	conn := New().SetURL("ws://127.0.0.1:1").SetReconnectTimeout(10 * time.Millisecond)
+	if err := conn.Dial(); err == nil {
+		t.Fatal("'Dial' must fail")
+	}
+	defer conn.Close()
+
+	// Every failed read tries to reconnect
+	conn.ReadMessage()
+	conn.ReadMessage()
+
+	decisions := conn.ExplainLastSchedule()
+	if len(decisions) != 3 {
+		t.Fatalf("expected 3 decisions, got %d", len(decisions))
+	}
+	for i, d := range decisions {
+		if d.Source != ScheduleAfterFailure || d.BaseDelay != 10*time.Millisecond || d.Wait != d.BaseDelay+d.Jitter {
+			t.Errorf("unexpected decision #%d: %+v", i, d)
+		}
+		if i > 0 && d.Time.Before(decisions[i-1].Time) {
+			t.Errorf("decisions must be ordered from the oldest to the newest")
+		}
+	}
+}
+
+func TestNextReconnectInAndLastDialError(t *testing.T) {
+	var dialer FakeDialer
+	refused := errors.New("connection refused")
+	dialer.SetError(refused)
+
+	conn := New().SetDialFunc(dialer.Dial).SetReconnectTimeout(100 * time.Millisecond)
+	defer conn.Close()
+
+	if err := conn.LastDialError(); err != nil {
+		t.Errorf("no error is expected before 'Dial', got %v", err)
+	}
+	if err := conn.Dial(); !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrDial, got %v", err)
+	}
+	if err := conn.LastDialError(); !errors.Is(err, ErrDial) || !errors.Is(err, refused) {
+		t.Errorf("expected the dial error, got %v", err)
+	}
+	if d := conn.NextReconnectIn(); d <= 0 || d > 100*time.Millisecond {
+		t.Errorf("unexpected delay: %s", d)
+	}
+
+	// Both values are reset after a successful connect
+	dialer.SetError(nil)
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+	if !conn.IsConnected() {
+		t.Fatal("instance must be connected")
+	}
+	if err := conn.LastDialError(); err != nil {
+		t.Errorf("error must be reset, got %v", err)
+	}
+	if d := conn.NextReconnectIn(); d != 0 {
+		t.Errorf("no delay is expected when connected, got %s", d)
+	}
+}
+
+func TestScheduleHistoryRing(t *testing.T) {
+	var h scheduleHistory
+	for i := 0; i < scheduleHistoryLen+5; i++ {
+		h.Add(ScheduleDecision{Wait: time.Duration(i)})
+	}
+
+	decisions := h.List()
+	if len(decisions) != scheduleHistoryLen {
+		t.Fatalf("expected %d decisions, got %d", scheduleHistoryLen, len(decisions))
+	}
+	if decisions[0].Wait != 5 || decisions[len(decisions)-1].Wait != scheduleHistoryLen+4 {
+		t.Errorf("unexpected decisions: first %d, last %d", decisions[0].Wait, decisions[len(decisions)-1].Wait)
+	}
+}
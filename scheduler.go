@@ -0,0 +1,227 @@
+package reconnect
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scheduledTask is a periodic task run by the scheduler
+type scheduledTask struct {
+	next     time.Time
+	interval time.Duration
+	fn       func()
+	// index is an index in the heap. It's -1 for canceled tasks
+	index int
+}
+
+// taskHeap is a heap of tasks ordered by the next run time
+type taskHeap []*scheduledTask
+
+func (h taskHeap) Len() int {
+	return len(h)
+}
+
+func (h taskHeap) Less(i, j int) bool {
+	return h[i].next.Before(h[j].next)
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	t := x.(*scheduledTask)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// scheduler runs periodic work of all features of an instance in a single goroutine
+type scheduler struct {
+	clock Clock
+	// stop stops the scheduler goroutine
+	stop <-chan struct{}
+	// goroutines is a group of internal goroutines of the instance
+	goroutines *goroutineGroup
+
+	mu      sync.Mutex
+	tasks   taskHeap
+	wake    chan struct{}
+	started bool
+	// gapThreshold and onGap are used to detect suspends of the process (see 'OnGap')
+	gapThreshold time.Duration
+	onGap        func(gap time.Duration)
+}
+
+func newScheduler(clock Clock, stop <-chan struct{}, goroutines *goroutineGroup) *scheduler {
+	return &scheduler{
+		clock:      clock,
+		stop:       stop,
+		goroutines: goroutines,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Every runs 'fn' every 'interval' starting after the first interval. 'fn' must not block.
+// The returned function cancels the task
+func (s *scheduler) Every(interval time.Duration, fn func()) (cancel func()) {
+	task := &scheduledTask{
+		next:     s.clock.Now().Add(interval),
+		interval: interval,
+		fn:       fn,
+	}
+
+	s.mu.Lock()
+	heap.Push(&s.tasks, task)
+	if !s.started {
+		s.started = true
+		s.goroutines.Go(s.run)
+	}
+	s.mu.Unlock()
+	s.notify()
+
+	return func() {
+		s.mu.Lock()
+		if task.index >= 0 {
+			heap.Remove(&s.tasks, task.index)
+		}
+		s.mu.Unlock()
+		s.notify()
+	}
+}
+
+// OnGap makes the scheduler call 'fn' instead of due tasks when a timer fires later than 'threshold'
+// after the expected time. It usually means that the process or the VM was suspended, so the missed
+// runs are skipped and the tasks are rescheduled. 'fn' must not block
+func (s *scheduler) OnGap(threshold time.Duration, fn func(gap time.Duration)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gapThreshold = threshold
+	s.onGap = fn
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) run() {
+	for {
+		s.mu.Lock()
+		var timerCh <-chan time.Time
+		stopTimer := func() bool { return false }
+		if len(s.tasks) > 0 {
+			timerCh, stopTimer = s.clock.NewTimer(s.tasks[0].next.Sub(s.clock.Now()))
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-timerCh:
+			s.runDue()
+		case <-s.wake:
+			stopTimer()
+		case <-s.stop:
+			stopTimer()
+			return
+		}
+	}
+}
+
+// runDue runs all tasks which time has come
+func (s *scheduler) runDue() {
+	// Monotonic time is used, so wall clock changes aren't treated as gaps
+	now := s.clock.Now()
+
+	if onGap, gap := s.skipGap(now); onGap != nil {
+		onGap(gap)
+		return
+	}
+
+	var due []func()
+	s.mu.Lock()
+	for len(s.tasks) > 0 && !s.tasks[0].next.After(now) {
+		task := s.tasks[0]
+		due = append(due, task.fn)
+
+		task.next = task.next.Add(task.interval)
+		if !task.next.After(now) {
+			// Don't try to catch up missed runs
+			task.next = now.Add(task.interval)
+		}
+		heap.Fix(&s.tasks, 0)
+	}
+	s.mu.Unlock()
+
+	for _, fn := range due {
+		fn()
+	}
+}
+
+// skipGap reschedules all tasks without running them if the earliest task is late for more than
+// the gap threshold. It returns the gap handler and the gap if a gap was detected
+func (s *scheduler) skipGap(now time.Time) (onGap func(gap time.Duration), gap time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.onGap == nil || len(s.tasks) == 0 {
+		return nil, 0
+	}
+	gap = now.Sub(s.tasks[0].next)
+	if gap <= s.gapThreshold {
+		return nil, 0
+	}
+
+	for _, task := range s.tasks {
+		task.next = now.Add(task.interval)
+	}
+	heap.Init(&s.tasks)
+	return s.onGap, gap
+}
+
+// goroutineGroup tracks long-lived internal goroutines of an instance
+type goroutineGroup struct {
+	count int32
+	wg    sync.WaitGroup
+}
+
+// Go runs 'f' in a new goroutine
+func (g *goroutineGroup) Go(f func()) {
+	atomic.AddInt32(&g.count, 1)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer atomic.AddInt32(&g.count, -1)
+
+		f()
+	}()
+}
+
+func (g *goroutineGroup) Count() int {
+	return int(atomic.LoadInt32(&g.count))
+}
+
+// Wait waits for all goroutines to return. Internal goroutines return after 'Close' is called
+func (g *goroutineGroup) Wait() {
+	g.wg.Wait()
+}
+
+// InternalGoroutines returns the number of long-lived goroutines started by the instance. Periodic
+// work of all features is run by a single scheduler goroutine
+func (r *ReConn) InternalGoroutines() int {
+	return r.goroutines.Count()
+}
@@ -0,0 +1,226 @@
+package reconnect
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t.ch, func() bool { return false }
+	}
+	c.timers = append(c.timers, t)
+	return t.ch, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i := range c.timers {
+			if c.timers[i] == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Advance moves the clock forward and fires expired timers
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if !t.at.After(c.now) {
+			t.ch <- c.now
+			continue
+		}
+		timers = append(timers, t)
+	}
+	c.timers = timers
+}
+
+func TestScheduler(t *testing.T) {
+	var (
+		clock      = &fakeClock{now: time.Unix(0, 0)}
+		stop       = make(chan struct{})
+		goroutines goroutineGroup
+		s          = newScheduler(clock, stop, &goroutines)
+		runs       = make(chan string, 16)
+	)
+	defer close(stop)
+
+	// Emulate keepalive, watchdog and heartbeat with different intervals
+	s.Every(2*time.Second, func() { runs <- "keepalive" })
+	s.Every(3*time.Second, func() { runs <- "watchdog" })
+	cancelHeartbeat := s.Every(5*time.Second, func() { runs <- "heartbeat" })
+
+	expect := func(want ...string) {
+		t.Helper()
+
+		got := make(map[string]int)
+		for range want {
+			select {
+			case name := <-runs:
+				got[name]++
+			case <-time.After(time.Second):
+				t.Fatalf("expected runs: %v, got: %v", want, got)
+			}
+		}
+		for _, name := range want {
+			got[name]--
+		}
+		for name, n := range got {
+			if n != 0 {
+				t.Fatalf("unexpected number of '%s' runs: %d", name, n)
+			}
+		}
+		select {
+		case name := <-runs:
+			t.Fatalf("unexpected run: %s", name)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	advance := func(d time.Duration) {
+		// Wait for the scheduler to set a timer
+		for i := 0; i < 100; i++ {
+			clock.mu.Lock()
+			n := len(clock.timers)
+			clock.mu.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(d)
+	}
+
+	advance(2 * time.Second) // 2s
+	expect("keepalive")
+	advance(time.Second) // 3s
+	expect("watchdog")
+	advance(time.Second) // 4s
+	expect("keepalive")
+	advance(time.Second) // 5s
+	expect("heartbeat")
+	advance(time.Second) // 6s
+	expect("keepalive", "watchdog")
+
+	cancelHeartbeat()
+	advance(4 * time.Second) // 10s, the missed runs are not caught up
+	expect("keepalive", "watchdog")
+
+	if n := goroutines.Count(); n != 1 {
+		t.Errorf("all tasks must be run by a single goroutine, got %d", n)
+	}
+}
+
+// waitTimer waits until the scheduler sets a timer
+func (c *fakeClock) waitTimer() {
+	for i := 0; i < 100; i++ {
+		c.mu.Lock()
+		n := len(c.timers)
+		c.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerGap(t *testing.T) {
+	var (
+		clock      = &fakeClock{now: time.Unix(0, 0)}
+		stop       = make(chan struct{})
+		goroutines goroutineGroup
+		s          = newScheduler(clock, stop, &goroutines)
+		runs       = make(chan string, 256)
+		gaps       = make(chan time.Duration, 16)
+	)
+	defer close(stop)
+
+	s.OnGap(10*time.Second, func(gap time.Duration) { gaps <- gap })
+	s.Every(time.Minute, func() { runs <- "heartbeat" })
+	s.Every(2*time.Minute, func() { runs <- "refresh" })
+
+	clock.waitTimer()
+	clock.Advance(time.Minute)
+	if run := <-runs; run != "heartbeat" {
+		t.Fatalf("unexpected run: %s", run)
+	}
+
+	// Emulate a suspend for 2 hours
+	clock.waitTimer()
+	clock.Advance(2 * time.Hour)
+	select {
+	case gap := <-gaps:
+		if gap != 2*time.Hour-time.Minute {
+			t.Errorf("unexpected gap: %s", gap)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("gap wasn't detected")
+	}
+	select {
+	case run := <-runs:
+		t.Fatalf("missed runs must be skipped, got %s", run)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// The tasks are rescheduled relative to the resume
+	clock.waitTimer()
+	clock.Advance(time.Minute)
+	if run := <-runs; run != "heartbeat" {
+		t.Fatalf("unexpected run: %s", run)
+	}
+	if len(gaps) != 0 {
+		t.Errorf("gap must be reported once, got %d more", len(gaps))
+	}
+}
+
+func TestInternalGoroutines(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetReaderLivenessTimeout(time.Minute, false)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := conn.InternalGoroutines(); n != 1 {
+		t.Errorf("expected 1 goroutine, got %d", n)
+	}
+
+	conn.Close()
+	for i := 0; i < 100 && conn.InternalGoroutines() != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := conn.InternalGoroutines(); n != 0 {
+		t.Errorf("goroutines must be stopped after Close, got %d", n)
+	}
+}
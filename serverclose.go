@@ -0,0 +1,78 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// ServerClosedError is returned by all methods after the server closed the connection with a normal
+// close code, if reconnects on normal closes are disabled (see 'SetReconnectOnNormalClose'). The instance
+// is in a terminal state like after 'SetMaxReconnectAttempts' is exceeded. It matches 'ErrServerClosed'
+// and unwraps to the read error, so '*websocket.CloseError' can be extracted with 'errors.As'
+type ServerClosedError struct {
+	CloseCode int
+	CloseText string
+	Err       error
+}
+
+func (e *ServerClosedError) Error() string {
+	return fmt.Sprintf("%s: close code %d, text: '%s'", ErrServerClosed, e.CloseCode, e.CloseText)
+}
+
+func (e *ServerClosedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ServerClosedError) Is(target error) bool {
+	return target == ErrServerClosed
+}
+
+// SetReconnectOnNormalClose sets whether the instance reconnects after the server closed the connection
+// with 'CloseNormalClosure' (1000) or 'CloseGoingAway' (1001). If it's false, the read fails with
+// '*ServerClosedError' and the instance doesn't reconnect until 'Redial'. Other close codes and
+// transport errors always cause a reconnect. The default value is true. After 'Dial' call it does nothing
+func (r *ReConn) SetReconnectOnNormalClose(reconnect bool) *ReConn {
+	r.configure(func() {
+		r.stopOnNormalClose = !reconnect
+	})
+	return r
+}
+
+// LastCloseCode returns the code and the text of the last close frame sent by the server.
+// 'ok' is false if the server has never closed a connection with a close frame
+func (r *ReConn) LastCloseCode() (code int, text string, ok bool) {
+	frame, _ := r.lastServerClose.Load().(*closeFrame)
+	if frame == nil {
+		return 0, "", false
+	}
+	return frame.code, frame.reason, true
+}
+
+// saveServerClose saves the close frame of the server if 'cause' contains it. It must be called
+// under 'r.mu' when the connection is dropped. It returns '*ServerClosedError' if the instance
+// must not reconnect
+func (r *ReConn) saveServerClose(cause error) error {
+	if PhaseOf(cause) != PhaseUnknown {
+		// The close frame fails the connect attempt (see 'SetPostUpgradeRejection')
+		return nil
+	}
+
+	var closeErr *websocket.CloseError
+	// gorilla/websocket reports a connection closed without a close frame as 'CloseAbnormalClosure'
+	if !errors.As(cause, &closeErr) || closeErr.Code == websocket.CloseAbnormalClosure {
+		return nil
+	}
+	r.lastServerClose.Store(&closeFrame{code: closeErr.Code, reason: closeErr.Text})
+
+	if !r.stopOnNormalClose {
+		return nil
+	}
+	switch closeErr.Code {
+	case websocket.CloseNormalClosure, websocket.CloseGoingAway:
+		return &ServerClosedError{CloseCode: closeErr.Code, CloseText: closeErr.Text, Err: cause}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package reconnect
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newClosingServer returns a server that closes the first connection with 'code' and sends "hello"
+// to the next ones
+func newClosingServer(code int, text string) (*httptest.Server, *int32) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+		} else {
+			conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		}
+		conn.ReadMessage()
+	})
+	return server, &connections
+}
+
+func TestReconnectOnNormalClose(t *testing.T) {
+	server, connections := newClosingServer(websocket.CloseNormalClosure, "maintenance")
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, _, ok := conn.LastCloseCode(); ok {
+		t.Error("no close code is expected before the server closes the connection")
+	}
+
+	// The read that got the close frame reconnects
+	if _, _, err := conn.ReadMessage(); errors.Is(err, ErrServerClosed) {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected message: %q, error: %v", data, err)
+	}
+	if n := atomic.LoadInt32(connections); n != 2 {
+		t.Errorf("expected 2 connections, got %d", n)
+	}
+
+	code, text, ok := conn.LastCloseCode()
+	if !ok || code != websocket.CloseNormalClosure || text != "maintenance" {
+		t.Errorf("unexpected close code: %d, text: %q, ok: %t", code, text, ok)
+	}
+}
+
+func TestSetReconnectOnNormalClose(t *testing.T) {
+	t.Run("normal close", func(t *testing.T) {
+		server, connections := newClosingServer(websocket.CloseGoingAway, "bye")
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetReconnectOnNormalClose(false)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		_, _, err := conn.ReadMessage()
+
+		var closedErr *ServerClosedError
+		if !errors.As(err, &closedErr) || closedErr.CloseCode != websocket.CloseGoingAway || closedErr.CloseText != "bye" {
+			t.Fatalf("expected ServerClosedError, got %v", err)
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+			t.Errorf("error must unwrap to the close error, got %v", err)
+		}
+		if CodeOf(err) != CodeServerClosed || errors.Is(err, ErrReconnect) {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		// The instance doesn't reconnect
+		if _, _, err := conn.ReadMessage(); err != closedErr {
+			t.Errorf("read must return the same error, got %v", err)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != closedErr {
+			t.Errorf("write must return the same error, got %v", err)
+		}
+		if n := atomic.LoadInt32(connections); n != 1 {
+			t.Errorf("expected 1 connection, got %d", n)
+		}
+		if code, _, _ := conn.LastCloseCode(); code != websocket.CloseGoingAway {
+			t.Errorf("expected close code %d, got %d", websocket.CloseGoingAway, code)
+		}
+
+		// 'Redial' resets the state
+		conn.Close()
+		if err := conn.Redial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+			t.Errorf("unexpected message: %q, error: %v", data, err)
+		}
+	})
+
+	t.Run("abnormal close", func(t *testing.T) {
+		server, connections := newClosingServer(4000, "restart")
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetReconnectOnNormalClose(false)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		if _, _, err := conn.ReadMessage(); errors.Is(err, ErrServerClosed) {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+			t.Fatalf("unexpected message: %q, error: %v", data, err)
+		}
+		if n := atomic.LoadInt32(connections); n != 2 {
+			t.Errorf("expected 2 connections, got %d", n)
+		}
+		if code, text, _ := conn.LastCloseCode(); code != 4000 || text != "restart" {
+			t.Errorf("unexpected close code: %d, text: %q", code, text)
+		}
+	})
+}
@@ -0,0 +1,309 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// shadowBufferSize is a size of the channel returned by 'Shadow.Messages'
+	shadowBufferSize = 256
+	// shadowSequenceWindow is a number of recent sequence numbers used to compute the sequence coverage
+	shadowSequenceWindow = 4096
+)
+
+// Shadow is an observe-only mirror of a primary connection: it connects to a candidate url with
+// the primary's configuration and subscriptions, but its messages are delivered only to 'Messages'.
+// Subscriptions registered on the primary after 'NewShadow' are mirrored automatically.
+// Nothing is written by the shadow except subscribe messages, pings and other protocol messages
+type Shadow struct {
+	primaryMessages uint64
+	shadowMessages  uint64
+	dropped         uint64
+
+	primary *ReConn
+	conn    *ReConn
+
+	messages chan Message
+
+	// started is set after the initial subscriptions were copied
+	started         *atomicBool
+	stopObserving   func()
+	stopPrimaryTap  func()
+	cancel          context.CancelFunc
+	ctx             context.Context
+	goroutines      sync.WaitGroup
+	callbacks       callbackQueue
+	closeOnce       sync.Once
+	primarySeqs     sequenceWindow
+	shadowSeqs      sequenceWindow
+	sequenceEnabled bool
+}
+
+// ShadowStats compares message streams of the primary and shadow connections
+type ShadowStats struct {
+	// PrimaryMessages is a number of messages returned by the primary connection since 'NewShadow'
+	PrimaryMessages uint64
+	// ShadowMessages is a number of messages received by the shadow connection
+	ShadowMessages uint64
+	// Dropped is a number of shadow messages dropped because the 'Messages' channel was full
+	Dropped uint64
+	// RateDelta is (ShadowMessages - PrimaryMessages) / PrimaryMessages. It's 0 if the primary
+	// connection hasn't returned any messages
+	RateDelta float64
+	// SequenceCoverage is a share of the recent sequence numbers of the primary connection also received
+	// by the shadow one, in [0, 1]. It's -1 if the primary connection has no sequence extractor
+	// (see 'SetSequenceExtractor') or there were no sequence numbers yet
+	SequenceCoverage float64
+}
+
+// NewShadow creates a shadow connection to 'candidateURL' and starts its reconnect loop. The shadow copies
+// the configuration of 'primary' (headers, handlers, transforms, timeouts, TLS config, proxy, dial functions, backoff and keepalive),
+// so it must be called after the primary is configured. The shadow retries failed connects until 'Close'
+// is called (see 'SetRetryConnect'). The primary connection isn't affected, except that its returned
+// messages are counted for 'Stats'
+func NewShadow(primary *ReConn, candidateURL string) *Shadow {
+	cfg := primary.loadConfig()
+
+	conn := New()
+	conn.url = candidateURL
+	conn.header = cfg.Header.Clone()
+	conn.pingHandler = cfg.PingHandler
+	conn.pongHandler = primary.pongHandler
+	conn.closeHandler = primary.closeHandler
+	conn.subscribeHandler = cfg.SubscribeHandler
+	conn.subscribeHandlerContext = primary.subscribeHandlerContext
+	conn.subscribeTimeout = primary.subscribeTimeout
+	conn.connectTimeout = primary.connectTimeout
+	conn.handshakeTimeout = cfg.HandshakeTimeout
+	conn.reconnectTimeout = cfg.ReconnectTimeout
+	conn.log = primary.log
+	conn.tlsConfig = primary.tlsConfig
+	conn.proxy = primary.proxy
+	conn.netDial = primary.netDial
+	conn.dialLimiter = primary.dialLimiter
+	conn.connReadBufferSize = primary.connReadBufferSize
+	conn.connWriteBufferSize = primary.connWriteBufferSize
+	conn.connWriteBufferPool = primary.connWriteBufferPool
+	conn.dialerConfigurator = primary.dialerConfigurator
+	conn.subprotocols = primary.subprotocols
+	conn.enableCompression = primary.enableCompression
+	conn.compressionLevel = primary.compressionLevel
+	conn.compressionLevelSet = primary.compressionLevelSet
+	conn.maxMessageSize = primary.maxMessageSize
+	conn.readTimeout = primary.readTimeout
+	conn.writeTimeout = primary.writeTimeout
+	conn.dialFunc = primary.dialFunc
+	conn.headerFunc = primary.headerFunc
+	conn.readTransform = primary.readTransform
+	conn.writeTransform = primary.writeTransform
+	conn.origin = primary.origin
+	conn.originFromURL = primary.originFromURL
+	conn.backoff = primary.backoff
+	conn.keepAliveInterval = primary.keepAliveInterval
+	conn.keepAlivePongWait = primary.keepAlivePongWait
+	conn.retryConnect = true
+
+	s := &Shadow{
+		primary:         primary,
+		conn:            conn,
+		messages:        make(chan Message, shadowBufferSize),
+		started:         newAtomicBool(),
+		sequenceEnabled: primary.sequenceExtractor != nil,
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.primarySeqs.Init(shadowSequenceWindow)
+	s.shadowSeqs.Init(shadowSequenceWindow)
+
+	s.stopObserving = primary.subscriptions.Observe(s.mirrorSubscription)
+	s.started.Set(true)
+
+	tap, stopTap := primary.Tap(0)
+	s.stopPrimaryTap = stopTap
+
+	s.goroutines.Add(2)
+	go s.countPrimary(tap)
+	go s.run()
+
+	return s
+}
+
+// mirrorSubscription is called by the subscription registry of the primary connection. It must not block
+func (s *Shadow) mirrorSubscription(sub subscription) {
+	if sub.removed {
+		s.conn.subscriptions.Remove(sub)
+		return
+	}
+	if !s.started.Get() {
+		// Initial subscriptions are registered before the first dial
+		s.conn.subscriptions.Set(sub)
+		return
+	}
+
+	s.callbacks.Push(func() {
+		err := s.conn.subscribe(sub)
+		if err != nil && err != ErrConnClosed {
+			s.conn.log.Error(fmt.Sprintf("shadow: couldn't mirror subscription '%s': %s", sub, err))
+		}
+	})
+}
+
+// countPrimary counts messages returned by the primary connection
+func (s *Shadow) countPrimary(tap <-chan Message) {
+	defer s.goroutines.Done()
+
+	for msg := range tap {
+		atomic.AddUint64(&s.primaryMessages, 1)
+		if s.sequenceEnabled {
+			if seq, ok := s.primary.sequenceExtractor(msg.Type, msg.Data); ok {
+				s.primarySeqs.Add(seq)
+			}
+		}
+	}
+}
+
+// run dials the candidate and reads messages until 'Close' is called
+func (s *Shadow) run() {
+	defer s.goroutines.Done()
+	defer close(s.messages)
+
+	if err := s.conn.DialContext(s.ctx); err != nil {
+		if s.ctx.Err() == nil {
+			s.conn.log.Error(fmt.Sprintf("shadow: couldn't dial: %s", err))
+		}
+		return
+	}
+
+	for {
+		messageType, data, err := s.conn.ReadMessageContext(s.ctx)
+		if err != nil {
+			if s.ctx.Err() != nil || s.conn.closed.Get() || s.conn.terminalError() != nil {
+				return
+			}
+			// The next read reconnects
+			continue
+		}
+
+		atomic.AddUint64(&s.shadowMessages, 1)
+		if s.sequenceEnabled {
+			if seq, ok := s.primary.sequenceExtractor(messageType, data); ok {
+				s.shadowSeqs.Add(seq)
+			}
+		}
+
+		msg := Message{Type: messageType, Data: append([]byte(nil), data...)}
+		select {
+		case s.messages <- msg:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// Messages returns a channel with messages of the shadow connection. Messages that don't fit are dropped
+// and counted by 'Stats'. The channel is closed after 'Close'
+func (s *Shadow) Messages() <-chan Message {
+	return s.messages
+}
+
+// Conn returns the shadow connection. It can be used to inspect its state; messages must be read
+// only from 'Messages'
+func (s *Shadow) Conn() *ReConn {
+	return s.conn
+}
+
+// Stats returns counters of both connections and their comparison
+func (s *Shadow) Stats() ShadowStats {
+	stats := ShadowStats{
+		PrimaryMessages:  atomic.LoadUint64(&s.primaryMessages),
+		ShadowMessages:   atomic.LoadUint64(&s.shadowMessages),
+		Dropped:          atomic.LoadUint64(&s.dropped),
+		SequenceCoverage: -1,
+	}
+	if stats.PrimaryMessages > 0 {
+		stats.RateDelta = (float64(stats.ShadowMessages) - float64(stats.PrimaryMessages)) / float64(stats.PrimaryMessages)
+	}
+	if s.sequenceEnabled {
+		if seqs := s.primarySeqs.Copy(); len(seqs) > 0 {
+			var covered int
+			for _, seq := range seqs {
+				if s.shadowSeqs.Has(seq) {
+					covered++
+				}
+			}
+			stats.SequenceCoverage = float64(covered) / float64(len(seqs))
+		}
+	}
+	return stats
+}
+
+// Close stops mirroring and closes the shadow connection. The primary connection isn't affected
+func (s *Shadow) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.stopObserving()
+		s.stopPrimaryTap()
+
+		// Interrupt a blocked read, so 'Close' doesn't wait for the next message
+		s.cancel()
+		err = s.conn.Close()
+		s.goroutines.Wait()
+		if err == ErrNotDialed {
+			// 'Close' was called before the first dial: the dial could have been started
+			// after the check, so close the connection again
+			err = s.conn.Close()
+		}
+	})
+	if err == ErrNotDialed || err == ErrNotConnected {
+		// The shadow connection is down, nothing to close
+		err = nil
+	}
+	return err
+}
+
+// sequenceWindow contains the last added sequence numbers
+type sequenceWindow struct {
+	mu   sync.Mutex
+	seen map[uint64]struct{}
+	ring []uint64
+	next int
+}
+
+func (w *sequenceWindow) Init(size int) {
+	w.seen = make(map[uint64]struct{}, size)
+	w.ring = make([]uint64, 0, size)
+}
+
+func (w *sequenceWindow) Add(seq uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.seen[seq]; ok {
+		return
+	}
+	if len(w.ring) < cap(w.ring) {
+		w.ring = append(w.ring, seq)
+	} else {
+		delete(w.seen, w.ring[w.next])
+		w.ring[w.next] = seq
+		w.next = (w.next + 1) % len(w.ring)
+	}
+	w.seen[seq] = struct{}{}
+}
+
+func (w *sequenceWindow) Has(seq uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.seen[seq]
+	return ok
+}
+
+func (w *sequenceWindow) Copy() []uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]uint64(nil), w.ring...)
+}
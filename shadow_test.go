@@ -0,0 +1,170 @@
+package reconnect
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newSubscriptionServer returns a server that responds to a subscription with the given messages
+func newSubscriptionServer(responses map[string][]string) func(conn *websocket.Conn) {
+	return func(conn *websocket.Conn) {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			for _, msg := range responses[string(data)] {
+				conn.WriteMessage(websocket.TextMessage, []byte(msg))
+			}
+		}
+	}
+}
+
+func extractSeq(_ int, data []byte) (uint64, bool) {
+	i := strings.IndexByte(string(data), ':')
+	seq, err := strconv.ParseUint(string(data[i+1:]), 10, 64)
+	return seq, err == nil
+}
+
+func TestShadow(t *testing.T) {
+	primaryServer := newWsServer(newSubscriptionServer(map[string][]string{
+		"sub:a": {"primary:1", "primary:2"},
+		"sub:b": {"primary:3", "primary:4"},
+	}))
+	defer primaryServer.Close()
+
+	candidateServer := newWsServer(newSubscriptionServer(map[string][]string{
+		"sub:a": {"candidate:1", "candidate:2"},
+		"sub:b": {"candidate:3"},
+	}))
+	defer candidateServer.Close()
+
+	primary := New().SetURL(wsURL(primaryServer)).SetSequenceExtractor(extractSeq)
+	primary.SubscribeWithAck("a", []byte("sub:a"), nil, 0)
+	if err := primary.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer primary.Close()
+
+	shadow := NewShadow(primary, wsURL(candidateServer))
+	defer shadow.Close()
+
+	readPrimary := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			_, data, err := primary.ReadMessage()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(data) != w {
+				t.Fatalf("primary: got %q, want %q", data, w)
+			}
+		}
+	}
+	readShadow := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case msg := <-shadow.Messages():
+				if string(msg.Data) != w {
+					t.Fatalf("shadow: got %q, want %q", msg.Data, w)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("shadow: timeout waiting for %q", w)
+			}
+		}
+	}
+
+	// The existing subscription is copied
+	readPrimary("primary:1", "primary:2")
+	readShadow("candidate:1", "candidate:2")
+
+	// A new subscription is mirrored
+	if err := primary.SubscribeWithAck("b", []byte("sub:b"), nil, time.Second); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	readPrimary("primary:3", "primary:4")
+	readShadow("candidate:3")
+
+	deadline := time.Now().Add(time.Second)
+	for shadow.Stats().PrimaryMessages < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stats := shadow.Stats()
+	if stats.PrimaryMessages != 4 || stats.ShadowMessages != 3 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.RateDelta != -0.25 {
+		t.Errorf("expected rate delta -0.25, got %v", stats.RateDelta)
+	}
+	if stats.SequenceCoverage != 0.75 {
+		t.Errorf("expected sequence coverage 0.75, got %v", stats.SequenceCoverage)
+	}
+
+	if err := shadow.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, ok := <-shadow.Messages(); ok {
+		t.Error("channel must be closed")
+	}
+
+	// The primary connection isn't affected
+	if err := primary.WriteMessage(websocket.TextMessage, []byte("sub:a")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	readPrimary("primary:1", "primary:2")
+}
+
+func TestShadowCloseBeforeConnect(t *testing.T) {
+	url, closeListener := newHangingListener(t)
+	defer closeListener()
+
+	primary := New().SetURL(url)
+	shadow := NewShadow(primary, url)
+
+	done := make(chan error, 1)
+	go func() { done <- shadow.Close() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close must abort the dial")
+	}
+	// Internal goroutines exit after 'Close'
+	stopped := make(chan struct{})
+	go func() {
+		shadow.Conn().goroutines.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("internal goroutines weren't stopped, got %d", shadow.Conn().goroutines.Count())
+	}
+}
+
+func TestSequenceWindow(t *testing.T) {
+	var w sequenceWindow
+	w.Init(3)
+	for _, seq := range []uint64{1, 2, 2, 3, 4} {
+		w.Add(seq)
+	}
+	if w.Has(1) {
+		t.Error("1 must be evicted")
+	}
+	for _, seq := range []uint64{2, 3, 4} {
+		if !w.Has(seq) {
+			t.Errorf("%d must be in the window", seq)
+		}
+	}
+	if got := w.Copy(); len(got) != 3 {
+		t.Errorf("unexpected window: %v", got)
+	}
+}
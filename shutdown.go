@@ -0,0 +1,105 @@
+package reconnect
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Shutdown closes the instance gracefully:
+//
+//  1. the instance is marked as closing, so no new reconnects start
+//  2. messages buffered by 'SetWriteBuffer' are flushed to the current connection, and in-flight
+//     writes ('WriteMessage', 'WriteJSON' and etc.) are awaited
+//  3. the websocket close handshake is performed like by 'CloseGracefully' with 'websocket.CloseNormalClosure'.
+//     A read in progress returns after the close frame of the peer
+//  4. the connection is closed like by 'Close', and in-flight reads and writes are awaited
+//
+// All steps are bounded by 'ctx'. If 'ctx' is done before the shutdown is completed, the instance
+// is closed like by 'Close' and 'ctx.Err()' is returned. Buffered messages are dropped if there's
+// no connection. Writers returned by 'NextWriter' aren't awaited, but the close frame is written only
+// after they are closed
+func (r *ReConn) Shutdown(ctx context.Context) error {
+	untrack(r)
+
+	r.lifecycleMu.Lock()
+	defer r.lifecycleMu.Unlock()
+
+	if r.dialed.Get() && !r.closed.Get() {
+		r.callPreCloseHook()
+		r.sendFarewell()
+		if !r.closed.Get() {
+			// The close frame wasn't sent after the farewell ack
+			r.closed.Set(true)
+			r.flushLateWrites()
+			if waitCalls(ctx, &r.writeCalls) == nil {
+				r.closeHandshake(ctx, websocket.CloseNormalClosure, "")
+			}
+		}
+	}
+	closeErr := r.closeLocked()
+
+	if err := waitCalls(ctx, &r.readCalls); err != nil {
+		return err
+	}
+	if err := waitCalls(ctx, &r.writeCalls); err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// waitCalls waits until all calls tracked by 't' return or 'ctx' is done. It returns 'ctx.Err()'
+func waitCalls(ctx context.Context, t *callTracker) error {
+	idle := t.Idle()
+	if idle == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-idle:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// callTracker tracks calls of read or write methods in progress
+type callTracker struct {
+	mu sync.Mutex
+	n  int
+	// idle is closed when the last call returns. It's created by 'Idle', so calls don't allocate
+	idle chan struct{}
+}
+
+func (t *callTracker) Enter() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.n++
+}
+
+func (t *callTracker) Exit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.n--
+	if t.n == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// Idle returns a channel that is closed when all calls return. It returns nil if there are
+// no calls in progress
+func (t *callTracker) Idle() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.n == 0 {
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	return t.idle
+}
@@ -0,0 +1,135 @@
+package reconnect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdown(t *testing.T) {
+	url, closeFrames, stop := newCloseFrameServer()
+	defer stop()
+
+	conn := New().SetURL(url)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	waitActiveRead(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := conn.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The read has returned before 'Shutdown'
+	select {
+	case err := <-readErr:
+		if err != ErrConnClosed {
+			t.Errorf("expected ErrConnClosed for the concurrent read, got: %v", err)
+		}
+	default:
+		t.Fatal("'Shutdown' must wait for the read")
+	}
+	if frame := <-closeFrames; frame.Code != websocket.CloseNormalClosure {
+		t.Errorf("unexpected close frame: %+v", frame)
+	}
+	if state := conn.State(); state != StateClosed {
+		t.Errorf("expected closed state, got %s", state)
+	}
+}
+
+func TestShutdownWaitsForWrites(t *testing.T) {
+	url, closeFrames, stop := newCloseFrameServer()
+	defer stop()
+
+	conn := New().SetURL(url).SetWriteRateLimit(10, time.Second, 1)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("1")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The write waits for a token
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- conn.WriteMessage(websocket.TextMessage, []byte("2"))
+	}()
+	for conn.writeCalls.Idle() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := conn.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	select {
+	case err := <-writeErr:
+		if err != nil {
+			t.Errorf("in-flight write must be completed, got: %v", err)
+		}
+	default:
+		t.Fatal("'Shutdown' must wait for the write")
+	}
+	if frame := <-closeFrames; frame.Code != websocket.CloseNormalClosure {
+		t.Errorf("unexpected close frame: %+v", frame)
+	}
+}
+
+func TestShutdownContextDone(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	// The server never reads, so the close frame isn't answered
+	release := make(chan struct{})
+	server := newWsServer(func(conn *websocket.Conn) {
+		<-release
+	})
+	defer server.Close()
+	defer close(release)
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	waitActiveRead(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := conn.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Errorf("the shutdown must be bounded by the context, elapsed: %s", elapsed)
+	}
+	if err := <-readErr; err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed for the interrupted read, got: %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed after the shutdown, got: %v", err)
+	}
+}
+
+func TestShutdownNotDialed(t *testing.T) {
+	if err := New().Shutdown(context.Background()); err != ErrNotDialed {
+		t.Errorf("expected ErrNotDialed, got: %v", err)
+	}
+}
@@ -0,0 +1,414 @@
+//go:build sim
+// +build sim
+
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// The simulation is run with 'go test -tags sim -run Simulation'. The harness (the clock, the scripted
+// server and the invariant checker) is independent of the checked features, so new features can be
+// added to 'TestSimulation24h' or to a new simulation
+
+// errSimServerDown is returned by dials during an outage
+var errSimServerDown = errors.New("sim: server is down")
+
+// simServer is a scripted server model. Every connection answers pings with pongs and receives
+// a data message on every 'Tick' unless the server is slow
+type simServer struct {
+	mu    sync.Mutex
+	down  bool
+	slow  bool
+	conns []*simConn
+}
+
+// simConn is a server connection. Pongs are delivered synchronously, like a server with zero latency
+type simConn struct {
+	*FakeConn
+
+	mu          sync.Mutex
+	pongHandler func(string) error
+	pings       int
+}
+
+func (c *simConn) SetPongHandler(h func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pongHandler = h
+}
+
+func (c *simConn) WriteMessage(messageType int, data []byte) error {
+	if err := c.FakeConn.WriteMessage(messageType, data); err != nil {
+		return err
+	}
+	if messageType != websocket.PingMessage {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.pings++
+	h := c.pongHandler
+	c.mu.Unlock()
+
+	if h != nil {
+		return h(string(data))
+	}
+	return nil
+}
+
+// Broken reports whether the connection was failed by the server
+func (c *simConn) Broken() bool {
+	c.FakeConn.mu.Lock()
+	defer c.FakeConn.mu.Unlock()
+
+	return c.FakeConn.err != nil
+}
+
+// Pings returns the number of pings received by the connection
+func (c *simConn) Pings() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pings
+}
+
+// Dial can be passed to 'SetDialFunc'
+func (s *simServer) Dial(string, http.Header) (WsConnection, *http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.down {
+		return nil, nil, errSimServerDown
+	}
+	conn := &simConn{FakeConn: NewFakeConn()}
+	s.conns = append(s.conns, conn)
+	return conn, &http.Response{StatusCode: http.StatusSwitchingProtocols, Header: make(http.Header)}, nil
+}
+
+// Current returns the last accepted connection. It returns nil if there were no connections
+func (s *simServer) Current() *simConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conns) == 0 {
+		return nil
+	}
+	return s.conns[len(s.conns)-1]
+}
+
+// Connections returns the number of accepted connections
+func (s *simServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// SetDown starts or ends an outage. The current connection is broken at the start
+func (s *simServer) SetDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+
+	if current := s.Current(); down && current != nil {
+		current.Fail(errors.New("sim: connection reset"))
+	}
+}
+
+// SetSlow starts or ends a period without data messages. Pongs are still sent
+func (s *simServer) SetSlow(slow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.slow = slow
+}
+
+// Rotate closes the current connection with 'CloseGoingAway', like a server restart
+func (s *simServer) Rotate() {
+	if current := s.Current(); current != nil {
+		current.Fail(&websocket.CloseError{Code: websocket.CloseGoingAway, Text: "rotation"})
+	}
+}
+
+// Tick sends a data message to the current connection
+func (s *simServer) Tick(n int) {
+	s.mu.Lock()
+	skip := s.down || s.slow
+	s.mu.Unlock()
+
+	if current := s.Current(); !skip && current != nil {
+		current.Push(websocket.TextMessage, []byte(fmt.Sprintf(`{"tick":%d}`, n)))
+	}
+}
+
+// simEvent is a scripted change of the server at the simulated time 'At'
+type simEvent struct {
+	At    time.Duration
+	Name  string
+	Apply func(s *simServer)
+}
+
+// simChecker collects invariant violations. A simulation continues after a violation, so all
+// of them are reported
+type simChecker struct {
+	t          *testing.T
+	now        time.Duration
+	counters   map[string]uint64
+	violations int
+}
+
+func newSimChecker(t *testing.T) *simChecker {
+	return &simChecker{t: t, counters: make(map[string]uint64)}
+}
+
+// Check reports a violation if 'ok' is false
+func (c *simChecker) Check(ok bool, format string, args ...interface{}) {
+	if ok {
+		return
+	}
+	c.violations++
+	if c.violations <= 20 {
+		c.t.Errorf("%s: %s", c.now, fmt.Sprintf(format, args...))
+	}
+}
+
+// Monotonic checks that the counter never decreases
+func (c *simChecker) Monotonic(name string, value uint64) {
+	prev, ok := c.counters[name]
+	c.Check(!ok || value >= prev, "'%s' decreased from %d to %d", name, prev, value)
+	c.counters[name] = value
+}
+
+// Done must be called at the end of a simulation
+func (c *simChecker) Done() {
+	if c.violations > 20 {
+		c.t.Errorf("%d more violations", c.violations-20)
+	}
+}
+
+// simulation runs a 'ReConn' against the scripted server with the fake clock
+type simulation struct {
+	t       *testing.T
+	clock   *fakeClock
+	server  *simServer
+	conn    *ReConn
+	checker *simChecker
+
+	mu       sync.Mutex
+	messages uint64
+	readDone chan struct{}
+}
+
+// newSimulation dials 'conn' and starts a reader. 'conn' must not be dialed
+func newSimulation(t *testing.T, conn *ReConn) *simulation {
+	s := &simulation{
+		t:        t,
+		clock:    &fakeClock{now: time.Unix(0, 0)},
+		server:   &simServer{},
+		conn:     conn,
+		checker:  newSimChecker(t),
+		readDone: make(chan struct{}),
+	}
+	conn.SetURL("ws://sim").SetDialFunc(s.server.Dial)
+	conn.scheduler = newScheduler(s.clock, conn.closeCh, &conn.goroutines)
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	go s.read()
+	return s
+}
+
+func (s *simulation) read() {
+	defer close(s.readDone)
+
+	for !s.conn.closed.Get() {
+		if _, _, err := s.conn.ReadMessage(); err == nil {
+			s.mu.Lock()
+			s.messages++
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Messages returns the number of read messages
+func (s *simulation) Messages() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.messages
+}
+
+// Settle waits until the client is connected to the current server connection, if the server is up.
+// Reconnects aren't driven by the fake clock, so they must complete before the next step
+func (s *simulation) Settle() {
+	s.server.mu.Lock()
+	down := s.server.down
+	s.server.mu.Unlock()
+	if down {
+		return
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		current := s.server.Current()
+		if s.conn.IsConnected() && current != nil && !current.Broken() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.t.Fatalf("%s: the client hasn't reconnected", s.checker.now)
+}
+
+// Step moves the fake clock and waits until the scheduler has run due tasks
+func (s *simulation) Step(d time.Duration) {
+	s.clock.Advance(d)
+	s.clock.waitTimer()
+	s.checker.now += d
+}
+
+// Close closes the connection and checks that no goroutines are leaked
+func (s *simulation) Close(goroutinesBefore int) {
+	// Release the blocked reader: 'Close' waits for the lock held by the read
+	s.server.SetDown(true)
+	s.conn.Close()
+
+	select {
+	case <-s.readDone:
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("the reader hasn't stopped")
+	}
+
+	waitGoroutines := make(chan struct{})
+	go func() {
+		s.conn.goroutines.Wait()
+		close(waitGoroutines)
+	}()
+	select {
+	case <-waitGoroutines:
+	case <-time.After(5 * time.Second):
+		s.t.Fatalf("internal goroutines haven't stopped: %d", s.conn.InternalGoroutines())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutinesBefore && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.checker.Check(runtime.NumGoroutine() <= goroutinesBefore, "goroutine leak: %d goroutines, %d before the simulation",
+		runtime.NumGoroutine(), goroutinesBefore)
+	s.checker.Done()
+}
+
+func TestSimulation24h(t *testing.T) {
+	const (
+		duration     = 24 * time.Hour
+		pingInterval = 30 * time.Second
+	)
+
+	var (
+		outages   = [][2]time.Duration{{2 * time.Hour, 2*time.Hour + 10*time.Minute}, {13 * time.Hour, 13*time.Hour + 45*time.Minute}}
+		slow      = [][2]time.Duration{{5 * time.Hour, 6 * time.Hour}, {20 * time.Hour, 20*time.Hour + 30*time.Minute}}
+		rotations = []time.Duration{4 * time.Hour, 8 * time.Hour, 12 * time.Hour, 16 * time.Hour, 20*time.Hour + 15*time.Minute}
+	)
+
+	var script []simEvent
+	for _, o := range outages {
+		script = append(script,
+			simEvent{At: o[0], Name: "outage start", Apply: func(s *simServer) { s.SetDown(true) }},
+			simEvent{At: o[1], Name: "outage end", Apply: func(s *simServer) { s.SetDown(false) }},
+		)
+	}
+	for _, p := range slow {
+		script = append(script,
+			simEvent{At: p[0], Name: "slow start", Apply: func(s *simServer) { s.SetSlow(true) }},
+			simEvent{At: p[1], Name: "slow end", Apply: func(s *simServer) { s.SetSlow(false) }},
+		)
+	}
+	for _, at := range rotations {
+		script = append(script, simEvent{At: at, Name: "rotation", Apply: func(s *simServer) { s.Rotate() }})
+	}
+	sort.SliceStable(script, func(i, j int) bool { return script[i].At < script[j].At })
+
+	inOutage := func(now time.Duration) bool {
+		for _, o := range outages {
+			if now >= o[0] && now < o[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	goroutinesBefore := runtime.NumGoroutine()
+
+	conn := New().
+		SetKeepAlive(pingInterval, 10*pingInterval).
+		SetBackoff(time.Millisecond, 10*time.Millisecond, 2, false)
+	sim := newSimulation(t, conn)
+	checker := sim.checker
+
+	// The only expected reconnects outside outages are caused by rotations and outage ends
+	maxReconnects := len(rotations) + len(outages)
+
+	var (
+		next             int
+		connectionsAfter = sim.server.Connections()
+		reconnects       int
+		tick             int
+	)
+	for now := time.Duration(0); now < duration; now += pingInterval {
+		for ; next < len(script) && script[next].At <= now; next++ {
+			script[next].Apply(sim.server)
+		}
+		sim.Settle()
+
+		if !inOutage(now) {
+			reconnects += sim.server.Connections() - connectionsAfter
+		}
+
+		tick++
+		sim.server.Tick(tick)
+
+		current := sim.server.Current()
+		pings := current.Pings()
+		connected := conn.IsConnected()
+
+		sim.Step(pingInterval)
+
+		// No missed heartbeats while connected
+		if connected && sim.server.Current() == current && conn.IsConnected() {
+			checker.Check(current.Pings() == pings+1, "expected 1 ping per interval, got %d", current.Pings()-pings)
+		}
+
+		checker.Monotonic("connections", uint64(sim.server.Connections()))
+		checker.Monotonic("messages", sim.Messages())
+		checker.Monotonic("starvations", conn.WriteStarvations())
+		for cause, counts := range conn.ReconnectTrend() {
+			checker.Check(counts.Last5m <= counts.Last1h && counts.Last1h <= counts.Last24h,
+				"inconsistent '%s' reconnect counts: %+v", cause, counts)
+		}
+		connectionsAfter = sim.server.Connections()
+	}
+
+	checker.Check(reconnects <= maxReconnects, "%d reconnects outside outages, expected at most %d", reconnects, maxReconnects)
+	checker.Check(sim.Messages() > 0, "no messages were read")
+
+	var trendTotal uint64
+	for _, counts := range conn.ReconnectTrend() {
+		trendTotal += counts.Last24h
+	}
+	checker.Check(trendTotal >= uint64(len(rotations)+len(outages)), "the reconnect trend has %d reconnects in the last 24h, expected at least %d",
+		trendTotal, len(rotations)+len(outages))
+
+	sim.Close(goroutinesBefore)
+}
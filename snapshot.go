@@ -0,0 +1,49 @@
+package reconnect
+
+import (
+	"time"
+)
+
+// Diagnostic getters must neither race with 'connect' nor block behind 'r.mu' during
+// a reconnect. So the connect, read and write paths publish immutable snapshots, and
+// getters only load the latest one.
+//
+// Getter results are eventually consistent: a getter returns the state published by the last
+// completed step (for example, the dial body of the last finished handshake), even if
+// a newer attempt is in progress.
+
+// snapshot contains diagnostic state. Published snapshots must never be modified
+type snapshot struct {
+	dialBody          []byte
+	dialBodyTruncated bool
+	dialResponse      *DialResponse
+	nextReconnectTime time.Time
+	// generation is a copy of 'r.generation' for streaming readers and writers
+	generation uint64
+	// dialErr is the error of the last failed connect attempt (see 'LastDialError')
+	dialErr error
+}
+
+// loadSnapshot returns the latest published snapshot
+func (r *ReConn) loadSnapshot() *snapshot {
+	if s, ok := r.snapshot.Load().(*snapshot); ok {
+		return s
+	}
+	return &snapshot{}
+}
+
+// updateSnapshot publishes a modified copy of the latest snapshot. It must be called
+// under 'r.mu' to serialize publishers
+func (r *ReConn) updateSnapshot(update func(s *snapshot)) {
+	s := *r.loadSnapshot()
+	update(&s)
+	r.snapshot.Store(&s)
+}
+
+// publishGeneration must be called under 'r.mu' after 'r.generation' is changed
+func (r *ReConn) publishGeneration() {
+	generation := r.generation
+	r.updateSnapshot(func(s *snapshot) {
+		s.generation = generation
+	})
+}
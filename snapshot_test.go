@@ -0,0 +1,100 @@
+package reconnect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestGettersDuringReconnects(t *testing.T) {
+	// The server drops every connection, so the reader reconnects all the time
+	server := newWsServer(func(conn *websocket.Conn) {})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetAdaptiveReadBuffer(512, 4096)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			conn.ReadMessage()
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				conn.GetDialBody()
+				conn.GetDialResponse()
+				conn.ViewDialBody(func([]byte) {})
+				conn.LastAttempt()
+				conn.AbandonedCloses()
+				conn.IsFlapping()
+				conn.ReadBufferSize()
+				conn.AutoReplies()
+				conn.FirstMessageGap()
+				conn.LastOutage()
+				conn.DroppedEmptyMessages()
+				conn.LastReconnectResumed()
+			}
+		}()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	conn.Close()
+	wg.Wait()
+}
+
+func TestGetDialBodyDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetHandshakeTimeout(5 * time.Second)
+	go conn.Dial()
+	defer func() {
+		// 'connect' holds the lock during the dial, so release the server before 'Close'
+		close(release)
+		conn.Close()
+	}()
+
+	// Wait for 'connect' to take the lock
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		conn.GetDialBody()
+		conn.ViewDialBody(func([]byte) {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("getters must not block during a connect")
+	}
+}
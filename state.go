@@ -0,0 +1,165 @@
+package reconnect
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is a state of 'ReConn'
+type State int32
+
+const (
+	// StateIdle means 'Dial' wasn't called
+	StateIdle State = iota
+	StateConnecting
+	StateConnected
+	// StateDisconnected means the connection was lost or a connect attempt failed. The next
+	// read or write reconnects
+	StateDisconnected
+	// StateClosed means 'Close' was called. It's left only by 'Redial'
+	StateClosed
+	// StateReconnecting is like 'StateConnecting', but a connection was already established before
+	StateReconnecting
+)
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	case StateReconnecting:
+		return "reconnecting"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChange describes a state transition
+type StateChange struct {
+	Old  State
+	New  State
+	Time time.Time
+	// Reason is an optional description of the transition (for example, an error of a failed attempt)
+	Reason string
+}
+
+// stateSubscriber receives state changes
+type stateSubscriber struct {
+	ch      chan StateChange
+	dropped uint64
+}
+
+// stateSubscribers contains subscribers of state changes
+type stateSubscribers struct {
+	mu     sync.Mutex
+	subs   map[<-chan StateChange]*stateSubscriber
+	closed bool
+}
+
+// State returns the current state. It doesn't block during reconnects
+func (r *ReConn) State() State {
+	return State(atomic.LoadInt32(&r.state))
+}
+
+// IsConnected reports whether there's a live connection. It doesn't block during reconnects
+func (r *ReConn) IsConnected() bool {
+	return r.State() == StateConnected
+}
+
+// SubscribeState returns a channel that receives state changes. A subscriber that doesn't keep up
+// with 'buffer' pending changes misses new ones (see 'DroppedStateChanges'), state transitions are
+// never blocked. The returned function unsubscribes and closes the channel. All channels are closed
+// after the transition to 'StateClosed'
+func (r *ReConn) SubscribeState(buffer int) (<-chan StateChange, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	sub := &stateSubscriber{ch: make(chan StateChange, buffer)}
+
+	s := &r.stateSubscribers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	if s.subs == nil {
+		s.subs = make(map[<-chan StateChange]*stateSubscriber)
+	}
+	s.subs[sub.ch] = sub
+
+	return sub.ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, ok := s.subs[sub.ch]; ok {
+			delete(s.subs, sub.ch)
+			close(sub.ch)
+		}
+	}
+}
+
+// DroppedStateChanges returns the number of state changes dropped for a subscriber because its channel
+// was full. It returns 0 for unsubscribed channels
+func (r *ReConn) DroppedStateChanges(ch <-chan StateChange) uint64 {
+	s := &r.stateSubscribers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sub, ok := s.subs[ch]; ok {
+		return atomic.LoadUint64(&sub.dropped)
+	}
+	return 0
+}
+
+// setState changes the state and notifies subscribers. 'StateClosed' can't be left (see 'reopenState')
+func (r *ReConn) setState(state State, reason string) {
+	s := &r.stateSubscribers
+
+	// Hold the lock to deliver changes in order
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := r.State()
+	if old == state || old == StateClosed {
+		return
+	}
+	atomic.StoreInt32(&r.state, int32(state))
+
+	change := StateChange{Old: old, New: state, Time: time.Now(), Reason: reason}
+	for _, sub := range s.subs {
+		select {
+		case sub.ch <- change:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+
+	if state == StateClosed {
+		for ch, sub := range s.subs {
+			close(sub.ch)
+			delete(s.subs, ch)
+		}
+		s.closed = true
+	}
+}
+
+// reopenState leaves 'StateClosed' for 'StateDisconnected'. Subscribers of the closed instance were
+// already removed, so there's nobody to notify. It's used only by 'Redial'
+func (r *ReConn) reopenState() {
+	s := &r.stateSubscribers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	atomic.StoreInt32(&r.state, int32(StateDisconnected))
+	s.closed = false
+}
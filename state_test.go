@@ -0,0 +1,129 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscribeState(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	changes, _ := conn.SubscribeState(16)
+	slow, _ := conn.SubscribeState(0)
+	unsubscribed, unsubscribe := conn.SubscribeState(16)
+	unsubscribe()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if state := conn.State(); state != StateConnected {
+		t.Errorf("unexpected state: %s", state)
+	}
+	// Nobody reads the channel of the slow subscriber
+	if n := conn.DroppedStateChanges(slow); n != 2 {
+		t.Errorf("expected 2 dropped changes, got %d", n)
+	}
+	conn.Close()
+
+	var got []State
+	for change := range changes {
+		if change.Old == change.New || change.Time.IsZero() {
+			t.Errorf("unexpected change: %+v", change)
+		}
+		got = append(got, change.New)
+	}
+	want := []State{StateConnecting, StateConnected, StateClosed}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	select {
+	case _, ok := <-unsubscribed:
+		if ok {
+			t.Error("channel of the unsubscribed subscriber must be closed without changes")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel of the unsubscribed subscriber must be closed")
+	}
+}
+
+func TestStateDisconnected(t *testing.T) {
+	conn := New().SetURL("ws://127.0.0.1:1")
+	changes, _ := conn.SubscribeState(16)
+
+	if err := conn.Dial(); err == nil {
+		t.Fatal("'Dial' must fail")
+	}
+	if state := conn.State(); state != StateDisconnected {
+		t.Errorf("unexpected state: %s", state)
+	}
+	conn.Close()
+
+	var last StateChange
+	for change := range changes {
+		if change.New == StateDisconnected {
+			last = change
+		}
+	}
+	if last.Reason == "" {
+		t.Error("transition to 'StateDisconnected' after a failed attempt must have a reason")
+	}
+}
+
+func TestStateReconnecting(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// Drop the first connection
+			return
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	changes, _ := conn.SubscribeState(16)
+
+	if conn.IsConnected() {
+		t.Error("'IsConnected' must be false before 'Dial'")
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The read fails and triggers a reconnect
+	conn.ReadMessage()
+	if !conn.IsConnected() {
+		t.Error("'IsConnected' must be true after the reconnect")
+	}
+	conn.Close()
+	if conn.IsConnected() {
+		t.Error("'IsConnected' must be false after 'Close'")
+	}
+
+	var got []State
+	for change := range changes {
+		got = append(got, change.New)
+	}
+	want := []State{StateConnecting, StateConnected, StateDisconnected, StateReconnecting, StateConnected, StateClosed}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
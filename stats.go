@@ -0,0 +1,151 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of counters of an instance
+type Stats struct {
+	// Reconnects is the number of successful connects except the first one
+	Reconnects uint64
+	// LastConnectedAt is the time of the last successful connect
+	LastConnectedAt time.Time
+	// LastDisconnectedAt is the time the last connection was lost or closed
+	LastDisconnectedAt time.Time
+	// LastError is the cause of the last lost connection or the error of the last failed connect attempt
+	LastError error
+	// MessagesRead is the number of data messages read by 'ReadMessage', 'ReadMessageHandle', 'ReadJSON'
+	// and etc. Messages read by the subscribe handler aren't counted
+	MessagesRead uint64
+	// MessagesWritten is the number of data messages written to the connection, including
+	// subscribe messages and other internal ones. Control frames aren't counted
+	MessagesWritten uint64
+	// BytesRead and BytesWritten are the payload sizes of the counted messages
+	BytesRead    uint64
+	BytesWritten uint64
+	// Replays is the number of replays of registered subscriptions after connects (see 'AddSubscription'),
+	// including failed ones. ReplayedSubscriptions is the total number of subscriptions in them
+	Replays               uint64
+	ReplayedSubscriptions uint64
+	// LastReplayDuration is the time of the last replay including the time of waiting for acks
+	LastReplayDuration time.Duration
+	// BufferedWrites is the number of messages in the write buffer (see 'SetWriteBuffer'). During
+	// a flush it's the number of messages that aren't written yet
+	BufferedWrites int
+	// ReadBufferSize is the read buffer size applied to the current connection by the adaptive read buffer
+	// (see 'SetAdaptiveReadBuffer'). It's 0 if the adaptive read buffer is disabled
+	ReadBufferSize int
+	// Memory is the size of payloads held by internal buffers (see 'SetMemoryBudget')
+	Memory MemoryUsage
+}
+
+// stats contains counters of 'Stats'. All fields are accessed atomically, so the lock isn't needed
+type stats struct {
+	reconnects      uint64
+	messagesRead    uint64
+	messagesWritten uint64
+	bytesRead       uint64
+	bytesWritten    uint64
+	// lastConnectedAt and lastDisconnectedAt are unix times in nanoseconds
+	lastConnectedAt    int64
+	lastDisconnectedAt int64
+	// firstConnectedAt is a unix time in nanoseconds, connectedSince is the unix time of the current
+	// connect or 0 if there's no connection, connectedTotal is the duration of all previous connections
+	firstConnectedAt int64
+	connectedSince   int64
+	connectedTotal   int64
+	// lastReplayDuration is in nanoseconds
+	replays               uint64
+	replayedSubscriptions uint64
+	lastReplayDuration    int64
+	// lastError contains 'statsError'
+	lastError atomic.Value
+}
+
+// statsError allows to store errors of different types in 'atomic.Value'
+type statsError struct {
+	err error
+}
+
+func (s *stats) addRead(n int) {
+	atomic.AddUint64(&s.messagesRead, 1)
+	atomic.AddUint64(&s.bytesRead, uint64(n))
+}
+
+func (s *stats) addWritten(n int) {
+	atomic.AddUint64(&s.messagesWritten, 1)
+	atomic.AddUint64(&s.bytesWritten, uint64(n))
+}
+
+func (s *stats) replayed(count int, d time.Duration) {
+	atomic.AddUint64(&s.replays, 1)
+	atomic.AddUint64(&s.replayedSubscriptions, uint64(count))
+	atomic.StoreInt64(&s.lastReplayDuration, int64(d))
+}
+
+func (s *stats) connected(reconnect bool) {
+	if reconnect {
+		atomic.AddUint64(&s.reconnects, 1)
+	}
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&s.lastConnectedAt, now)
+	atomic.CompareAndSwapInt64(&s.firstConnectedAt, 0, now)
+	atomic.StoreInt64(&s.connectedSince, now)
+}
+
+// disconnected records a lost or closed connection. 'err' is nil for 'Close'
+func (s *stats) disconnected(err error) {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&s.lastDisconnectedAt, now)
+	if since := atomic.SwapInt64(&s.connectedSince, 0); since != 0 {
+		atomic.AddInt64(&s.connectedTotal, now-since)
+	}
+	if err != nil {
+		s.setError(err)
+	}
+}
+
+// uptime returns the total time of all connections including the current one
+func (s *stats) uptime(now time.Time) time.Duration {
+	total := atomic.LoadInt64(&s.connectedTotal)
+	if since := atomic.LoadInt64(&s.connectedSince); since != 0 {
+		total += now.UnixNano() - since
+	}
+	return time.Duration(total)
+}
+
+func (s *stats) setError(err error) {
+	s.lastError.Store(statsError{err: err})
+}
+
+// Stats returns a snapshot of the counters. It doesn't block during reconnects. The counters
+// aren't updated together, so a snapshot taken during a read or a reconnect can be slightly inconsistent
+func (r *ReConn) Stats() Stats {
+	s := &r.stats
+	lastErr, _ := s.lastError.Load().(statsError)
+	return Stats{
+		Reconnects:            atomic.LoadUint64(&s.reconnects),
+		LastConnectedAt:       unixNanoTime(atomic.LoadInt64(&s.lastConnectedAt)),
+		LastDisconnectedAt:    unixNanoTime(atomic.LoadInt64(&s.lastDisconnectedAt)),
+		LastError:             lastErr.err,
+		MessagesRead:          atomic.LoadUint64(&s.messagesRead),
+		MessagesWritten:       atomic.LoadUint64(&s.messagesWritten),
+		BytesRead:             atomic.LoadUint64(&s.bytesRead),
+		BytesWritten:          atomic.LoadUint64(&s.bytesWritten),
+		Replays:               atomic.LoadUint64(&s.replays),
+		ReplayedSubscriptions: atomic.LoadUint64(&s.replayedSubscriptions),
+		LastReplayDuration:    time.Duration(atomic.LoadInt64(&s.lastReplayDuration)),
+		BufferedWrites:        r.writeBuffer.Len(),
+		ReadBufferSize:        r.ReadBufferSize(),
+		Memory:                r.memory.Usage(),
+	}
+}
+
+// unixNanoTime returns the zero time for 0
+func unixNanoTime(nsec int64) time.Time {
+	if nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nsec)
+}
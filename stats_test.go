@@ -0,0 +1,68 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestStats(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+
+	if stats := conn.Stats(); stats != (Stats{}) {
+		t.Fatalf("expected empty stats, got %+v", stats)
+	}
+
+	start := time.Now()
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialer.Last().Push(websocket.TextMessage, []byte("hello"))
+	dialer.Last().Push(websocket.TextMessage, []byte(`{"a":1}`))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var v map[string]int
+	if err := conn.ReadJSON(&v); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("abc")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stats := conn.Stats()
+	if stats.MessagesRead != 2 || stats.BytesRead != 12 || stats.MessagesWritten != 1 || stats.BytesWritten != 3 {
+		t.Errorf("unexpected message counters: %+v", stats)
+	}
+	if stats.Reconnects != 0 || stats.LastConnectedAt.Before(start) || !stats.LastDisconnectedAt.IsZero() || stats.LastError != nil {
+		t.Errorf("unexpected connect stats: %+v", stats)
+	}
+
+	// Lost connection
+	connErr := errors.New("connection reset")
+	dialer.Last().Fail(connErr)
+	conn.ReadMessage()
+
+	stats = conn.Stats()
+	if stats.Reconnects != 1 || stats.LastDisconnectedAt.Before(start) || stats.LastConnectedAt.Before(stats.LastDisconnectedAt) {
+		t.Errorf("unexpected reconnect stats: %+v", stats)
+	}
+	if !errors.Is(stats.LastError, connErr) {
+		t.Errorf("expected the cause of the lost connection, got: %v", stats.LastError)
+	}
+
+	// Failed connect attempt
+	dialErr := errors.New("connection refused")
+	dialer.SetError(dialErr)
+	dialer.Last().Fail(connErr)
+	conn.ReadMessage()
+
+	if stats := conn.Stats(); stats.Reconnects != 1 || !errors.Is(stats.LastError, dialErr) {
+		t.Errorf("unexpected stats after a failed attempt: %+v", stats)
+	}
+}
@@ -0,0 +1,114 @@
+package reconnect
+
+import (
+	"bufio"
+	"context"
+)
+
+// defaultMaxLineSize is a max size of a line read by 'StreamLines' when it isn't set
+const defaultMaxLineSize = bufio.MaxScanTokenSize
+
+// SetMaxLineSize sets a max size of a line read by 'StreamLines'. After 'Dial' call it does nothing
+func (r *ReConn) SetMaxLineSize(size int) *ReConn {
+	r.configure(func() {
+		r.maxLineSize = size
+	})
+	return r
+}
+
+// StreamLines reads the next message incrementally and calls 'handler' for every newline-delimited
+// line as soon as it arrives. It's intended for servers that stream data over a single never-ending
+// message. The end of the message is the end of the stream: 'StreamLines' returns nil.
+//
+// Transport errors trigger a reconnect like 'ReadMessage' errors. An error returned by 'handler'
+// stops the stream and is returned as is, without a reconnect. So is 'bufio.ErrTooLong' for a line
+// longer than the limit set by 'SetMaxLineSize'. The line passed to 'handler' is valid
+// only until it returns. Every line is treated as a read message for activity tracking.
+//
+// The connection must support streaming reads (for example, '*websocket.Conn'). A cancellation
+// of 'ctx' interrupts a blocked read, so the next read reconnects
+func (r *ReConn) StreamLines(ctx context.Context, handler func(line []byte) error) error {
+	if !r.dialed.Get() {
+		return ErrNotDialed
+	}
+	if err := r.terminalError(); err != nil {
+		return err
+	}
+	r.enterRead()
+	defer r.exitRead()
+
+	// Lines are passed to the handler under 'r.mu', so the callbacks can't be awaited for every line
+	r.waitCallbacks()
+	generation, transportErr, err := r.streamLines(ctx, handler)
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case transportErr:
+		return r.reconnectOnError(generation, wrapReadLimitError(err))
+	default:
+		return err
+	}
+}
+
+// streamLines returns the generation of the used connection, whether an error is a transport error
+// and the error itself
+func (r *ReConn) streamLines(ctx context.Context, handler func(line []byte) error) (generation uint64, transportErr bool, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	transportErr, err = r.streamLinesLocked(ctx, handler)
+	return r.generation, transportErr, err
+}
+
+// streamLinesLocked must be called under 'r.mu' held for reading
+func (r *ReConn) streamLinesLocked(ctx context.Context, handler func(line []byte) error) (transportErr bool, err error) {
+	if r.closingGracefully() {
+		return true, ErrConnClosed
+	}
+	if r.conn == nil {
+		return true, ErrNotConnected
+	}
+
+	conn, ok := r.conn.(nextReader)
+	if !ok || r.readAhead != nil {
+		return false, ErrStreamingNotSupported
+	}
+
+	defer interruptReadOnDone(ctx, r.conn)()
+
+	_, reader, err := conn.NextReader()
+	if err != nil {
+		return true, err
+	}
+
+	maxLineSize := r.maxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = defaultMaxLineSize
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, maxLineSize)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		line := scanner.Bytes()
+		r.countRead(len(line))
+		r.onMessageRead(line)
+		if err := handler(line); err != nil {
+			return false, err
+		}
+	}
+	switch err := scanner.Err(); err {
+	case nil:
+		return false, nil
+	case bufio.ErrTooLong:
+		// The rest of the message is discarded by the next read, no need to reconnect
+		return false, err
+	default:
+		return true, err
+	}
+}
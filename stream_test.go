@@ -0,0 +1,84 @@
+package reconnect
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newLineStreamServer starts a server that streams 'lines' over a single binary message
+func newLineStreamServer(lines ...string) func(conn *websocket.Conn) {
+	return func(conn *websocket.Conn) {
+		w, err := conn.NextWriter(websocket.BinaryMessage)
+		if err != nil {
+			return
+		}
+		for _, line := range lines {
+			w.Write([]byte(line + "\n"))
+		}
+		w.Close()
+		conn.ReadMessage()
+	}
+}
+
+func TestStreamLines(t *testing.T) {
+	server := newWsServer(newLineStreamServer(`{"a":1}`, `{"a":2}`, `{"a":3}`))
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	var lines []string
+	err := conn.StreamLines(context.Background(), func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(lines, ",") != `{"a":1},{"a":2},{"a":3}` {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+}
+
+func TestStreamLinesErrors(t *testing.T) {
+	server := newWsServer(newLineStreamServer("short", strings.Repeat("x", 100)))
+	defer server.Close()
+
+	errHandler := errors.New("stop")
+	tests := []struct {
+		name    string
+		handler func([]byte) error
+		wantErr error
+	}{
+		{name: "handler error", handler: func([]byte) error { return errHandler }, wantErr: errHandler},
+		{name: "line too long", handler: func([]byte) error { return nil }, wantErr: bufio.ErrTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subscribes int
+			conn := New().SetURL(wsURL(server)).SetMaxLineSize(64).SetSubscribeHandler(func(WsConnection) error {
+				subscribes++
+				return nil
+			})
+			if err := conn.Dial(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer conn.Close()
+
+			if err := conn.StreamLines(context.Background(), tt.handler); err != tt.wantErr {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if subscribes != 1 {
+				t.Errorf("stream must stop without a reconnect, got %d subscribes", subscribes)
+			}
+		})
+	}
+}
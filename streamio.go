@@ -0,0 +1,241 @@
+package reconnect
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// nextWriter is implemented by connections that support streaming writes (for example, '*websocket.Conn')
+type nextWriter interface {
+	NextWriter(messageType int) (io.WriteCloser, error)
+}
+
+// NextReader returns a reader of the next message, so a large message can be decoded without reading
+// it into memory. Errors of 'NextReader' trigger a reconnect like 'ReadMessage' errors. If the connection
+// breaks while the message is being read, the reader returns the transport error and the next 'NextReader'
+// call reconnects. A reader of a replaced connection returns 'ErrStreamReplaced'. The previous message is
+// discarded by the next call, so the reader must not be used after that.
+//
+// Messages read by 'NextReader' are counted by 'Stats', but they aren't passed to subscription acks, taps
+// and other features that need the whole message. It must not be used concurrently with other reads.
+// The connection must support streaming reads (for example, '*websocket.Conn'), otherwise
+// 'ErrStreamingNotSupported' is returned
+func (r *ReConn) NextReader() (messageType int, reader io.Reader, err error) {
+	if !r.dialed.Get() {
+		return 0, nil, ErrNotDialed
+	}
+	if err := r.terminalError(); err != nil {
+		return 0, nil, err
+	}
+	r.enterRead()
+	defer r.exitRead()
+
+	var retried bool
+	for {
+		messageType, reader, generation, transportErr, err := r.nextReader()
+		if err == nil {
+			r.waitCallbacks()
+			return messageType, reader, nil
+		}
+		if !transportErr {
+			return 0, nil, err
+		}
+
+		err = r.reconnectOnError(generation, r.checkPostUpgradeRejection(wrapReadLimitError(err)))
+		if r.retryRead(generation, &retried) {
+			continue
+		}
+		return 0, nil, err
+	}
+}
+
+// nextReader returns a reader of the next message, the generation of the used connection and whether
+// an error is a transport error
+func (r *ReConn) nextReader() (messageType int, reader io.Reader, generation uint64, transportErr bool, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	generation = r.generation
+	if r.closingGracefully() {
+		return 0, nil, generation, true, ErrConnClosed
+	}
+	if r.conn == nil {
+		return 0, nil, generation, true, ErrNotConnected
+	}
+
+	if msg, ok := r.replayBuffer.Pop(); ok {
+		reader = &streamReader{conn: r, reader: bytes.NewReader(msg.Data), generation: generation}
+		return msg.Type, reader, generation, false, nil
+	}
+
+	conn, ok := r.conn.(nextReader)
+	if !ok || r.readAhead != nil {
+		return 0, nil, generation, false, ErrStreamingNotSupported
+	}
+
+	r.applyReadTimeout(r.conn)
+	messageType, connReader, err := conn.NextReader()
+	if err != nil {
+		return 0, nil, generation, true, err
+	}
+	reader = &streamReader{conn: r, reader: connReader, generation: generation}
+	return messageType, reader, generation, false, nil
+}
+
+// NextWriter returns a writer of a new message, so a large message can be encoded without building it
+// in memory. The message is sent by 'Close'. Other writes, including heartbeats and 'Close' of the instance,
+// wait until the writer is closed, so it must always be closed. Errors of 'NextWriter' trigger a reconnect
+// like 'WriteMessage' errors. If the connection breaks while the message is being written, the writer
+// returns the transport error and the next write reconnects. A writer of a replaced connection never writes
+// to the new one: it returns 'ErrStreamReplaced'.
+//
+// The write timeout (see 'SetWriteTimeout') is set when the writer is created. The message is counted
+// by 'Stats' after it's sent. The connection must support streaming writes (for example, '*websocket.Conn'), otherwise
+// 'ErrStreamingNotSupported' is returned
+func (r *ReConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if !r.dialed.Get() {
+		return nil, ErrNotDialed
+	}
+	if err := r.terminalError(); err != nil {
+		return nil, err
+	}
+	if r.writeGate != nil {
+		if err := r.writeGate.Wait(); err != nil {
+			return nil, err
+		}
+	}
+	if handled, err := r.applyWriteDuringReconnect(context.Background(), messageType, nil); handled {
+		return nil, err
+	}
+	if err := r.waitWriteRate(context.Background(), messageType); err != nil {
+		return nil, err
+	}
+
+	writer, generation, transportErr, err := r.nextWriter(messageType)
+	if err == nil {
+		return writer, nil
+	}
+	if !transportErr {
+		return nil, err
+	}
+	return nil, r.reconnectOnError(generation, err)
+}
+
+// nextWriter returns a writer of a new message. On success 'r.writeMu' stays locked until the writer
+// is closed
+func (r *ReConn) nextWriter(messageType int) (writer io.WriteCloser, generation uint64, transportErr bool, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	generation = r.generation
+	if r.conn == nil {
+		return nil, generation, true, ErrNotConnected
+	}
+	conn, ok := r.conn.(nextWriter)
+	if !ok {
+		return nil, generation, false, ErrStreamingNotSupported
+	}
+
+	// The lock order is the same as for other writes: 'r.mu' and then 'r.writeMu'. The writer
+	// doesn't take 'r.mu' anymore, so a reconnect isn't blocked by an unclosed writer
+	r.writeMu.Lock(false)
+	r.applyWriteTimeout(r.conn)
+	connWriter, err := conn.NextWriter(messageType)
+	if err != nil {
+		r.writeMu.Unlock()
+		return nil, generation, true, err
+	}
+	return &streamWriter{conn: r, writer: connWriter, generation: generation}, generation, false, nil
+}
+
+// isCurrentGeneration reports whether the connection of the passed generation wasn't replaced.
+// It doesn't take 'r.mu'
+func (r *ReConn) isCurrentGeneration(generation uint64) bool {
+	return r.loadSnapshot().generation == generation
+}
+
+// streamReader is returned by 'NextReader'
+type streamReader struct {
+	conn       *ReConn
+	reader     io.Reader
+	generation uint64
+	// size is a number of read bytes
+	size int
+	done bool
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	if s.done {
+		return 0, io.EOF
+	}
+	if !s.conn.isCurrentGeneration(s.generation) {
+		return 0, ErrStreamReplaced
+	}
+
+	n, err := s.reader.Read(p)
+	if n > 0 && s.size == 0 {
+		s.conn.stopFirstMessageTracking(p[:n])
+	}
+	s.size += n
+
+	switch {
+	case err == io.EOF:
+		s.done = true
+		s.conn.countRead(s.size)
+		s.conn.onMessageReadSize(s.size)
+	case err != nil && !s.conn.isCurrentGeneration(s.generation):
+		// The connection was closed by a reconnect
+		err = ErrStreamReplaced
+	}
+	return n, err
+}
+
+// streamWriter is returned by 'NextWriter'. It holds 'r.writeMu' until 'Close'
+type streamWriter struct {
+	conn       *ReConn
+	writer     io.WriteCloser
+	generation uint64
+	// size is a number of written bytes
+	size   int
+	closed bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, ErrConnClosed
+	}
+	if !s.conn.isCurrentGeneration(s.generation) {
+		return 0, ErrStreamReplaced
+	}
+
+	n, err := s.writer.Write(p)
+	s.size += n
+	if err != nil && !s.conn.isCurrentGeneration(s.generation) {
+		// The connection was closed by a reconnect
+		err = ErrStreamReplaced
+	}
+	return n, err
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	defer s.conn.writeMu.Unlock()
+
+	if !s.conn.isCurrentGeneration(s.generation) {
+		return ErrStreamReplaced
+	}
+	if err := s.writer.Close(); err != nil {
+		if !s.conn.isCurrentGeneration(s.generation) {
+			return ErrStreamReplaced
+		}
+		return err
+	}
+
+	s.conn.countWritten(s.size)
+	s.conn.resetBackoff()
+	return nil
+}
@@ -0,0 +1,164 @@
+package reconnect
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNextReaderWriter(t *testing.T) {
+	server := newEchoServer(websocket.Upgrader{})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	payload := strings.Repeat("chunk;", 10000)
+
+	w, err := conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for i := 0; i < 10000; i++ {
+		if _, err := io.WriteString(w, "chunk;"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	messageType, r, err := conn.NextReader()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if messageType != websocket.TextMessage || string(data) != payload {
+		t.Errorf("unexpected message: %d, %d bytes", messageType, len(data))
+	}
+
+	stats := conn.Stats()
+	if stats.MessagesRead != 1 || stats.MessagesWritten != 1 || stats.BytesRead != uint64(len(payload)) || stats.BytesWritten != uint64(len(payload)) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Other writes are possible after the writer is closed
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "msg" {
+		t.Errorf("unexpected read result: %q, %v", data, err)
+	}
+}
+
+func TestNextReaderBrokenStream(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			conn.WriteMessage(websocket.TextMessage, []byte("second"))
+			conn.ReadMessage()
+			return
+		}
+
+		// Break the connection in the middle of a message
+		w, _ := conn.NextWriter(websocket.TextMessage)
+		w.Write([]byte(strings.Repeat("x", 10000)))
+		conn.UnderlyingConn().Close()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	_, r, err := conn.NextReader()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatal("read of the broken message must fail")
+	}
+
+	// Like 'ReadMessage', the call that reconnects returns the error of the broken connection
+	if _, _, err := conn.NextReader(); err == nil {
+		t.Fatal("error of the broken connection is expected")
+	}
+	_, r, err = conn.NextReader()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if data, err := ioutil.ReadAll(r); err != nil || string(data) != "second" {
+		t.Errorf("unexpected message: %q, %v", data, err)
+	}
+}
+
+func TestNextWriterReplacedConn(t *testing.T) {
+	server := newEchoServer(websocket.Upgrader{})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	w, err := conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := w.Write([]byte("part")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A reconnect isn't blocked by the open writer
+	if err := conn.ForceReconnect(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := w.Write([]byte("part")); err != ErrStreamReplaced {
+		t.Errorf("expected ErrStreamReplaced, got %v", err)
+	}
+	if err := w.Close(); err != ErrStreamReplaced {
+		t.Errorf("expected ErrStreamReplaced, got %v", err)
+	}
+
+	// The new connection is usable
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "msg" {
+		t.Errorf("unexpected read result: %q, %v", data, err)
+	}
+}
+
+func TestNextReaderNotSupported(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if _, _, err := conn.NextReader(); !errors.Is(err, ErrStreamingNotSupported) {
+		t.Errorf("expected ErrStreamingNotSupported, got %v", err)
+	}
+	if _, err := conn.NextWriter(websocket.TextMessage); !errors.Is(err, ErrStreamingNotSupported) {
+		t.Errorf("expected ErrStreamingNotSupported, got %v", err)
+	}
+	if dialer.Dials() != 1 {
+		t.Errorf("no reconnects are expected, got %d dials", dialer.Dials())
+	}
+}
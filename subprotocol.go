@@ -0,0 +1,39 @@
+package reconnect
+
+import (
+	"net/http"
+)
+
+// SetSubprotocols sets subprotocols offered by every dial in the order of preference (the
+// 'Sec-WebSocket-Protocol' header). It's used only by the default dialer (see 'SetDialFunc').
+// After 'Dial' call it does nothing
+func (r *ReConn) SetSubprotocols(protocols ...string) *ReConn {
+	r.configure(func() {
+		r.subprotocols = append([]string(nil), protocols...)
+	})
+	return r
+}
+
+// Subprotocol returns the subprotocol selected by the server for the current connection.
+// It returns an empty string if the server didn't select any or there's no connection
+func (r *ReConn) Subprotocol() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil {
+		return ""
+	}
+	return r.connectedSubprotocol
+}
+
+// negotiatedSubprotocol returns the subprotocol of a new connection. Connections that don't
+// report it (see 'SetDialFunc') fall back to the handshake response
+func negotiatedSubprotocol(conn WsConnection, resp *http.Response) string {
+	if c, ok := conn.(interface{ Subprotocol() string }); ok {
+		return c.Subprotocol()
+	}
+	if resp == nil {
+		return ""
+	}
+	return resp.Header.Get("Sec-WebSocket-Protocol")
+}
@@ -0,0 +1,67 @@
+package reconnect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubprotocols(t *testing.T) {
+	offered := make(chan []string, 2)
+	upgrader := websocket.Upgrader{Subprotocols: []string{"mqtt", "graphql-ws"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offered <- websocket.Subprotocols(r)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	t.Run("negotiated", func(t *testing.T) {
+		conn := New().SetURL(wsURL(server)).SetSubprotocols("graphql-ws", "mqtt")
+		if got := conn.Subprotocol(); got != "" {
+			t.Errorf("expected no subprotocol before dial, got %q", got)
+		}
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		if got := <-offered; len(got) != 2 || got[0] != "graphql-ws" || got[1] != "mqtt" {
+			t.Errorf("unexpected offered subprotocols: %q", got)
+		}
+		// The server prefers its own order
+		if got := conn.Subprotocol(); got != "mqtt" {
+			t.Errorf("expected 'mqtt', got %q", got)
+		}
+	})
+
+	t.Run("not offered", func(t *testing.T) {
+		conn := New().SetURL(wsURL(server))
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		<-offered
+		if got := conn.Subprotocol(); got != "" {
+			t.Errorf("expected no subprotocol, got %q", got)
+		}
+	})
+}
+
+func TestNegotiatedSubprotocolFallback(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Sec-Websocket-Protocol": {"mqtt"}}}
+	if got := negotiatedSubprotocol(NewFakeConn(), resp); got != "mqtt" {
+		t.Errorf("expected 'mqtt', got %q", got)
+	}
+	if got := negotiatedSubprotocol(NewFakeConn(), nil); got != "" {
+		t.Errorf("expected no subprotocol, got %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SubscribeHandlerContext is like 'SubscribeHandler', but it gets a context. The context is canceled
+// when the subscribe or the connect timeout expires (see 'SetSubscribeTimeout' and 'SetConnectTimeout'),
+// 'Close' is called or the context passed to 'DialContext' is done
+type SubscribeHandlerContext func(ctx context.Context, conn WsConnection) error
+
+// SetSubscribeHandlerContext sets subscribe handler that gets a context. It replaces the handler
+// set by 'SetSubscribeHandler', 'SetSubscribeHandlerV2' and 'SetResumeSubscribeHandler' and vice versa.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetSubscribeHandlerContext(f SubscribeHandlerContext) *ReConn {
+	r.configure(func() {
+		r.subscribeHandler = nil
+		r.subscribeHandlerContext = f
+	})
+	return r
+}
+
+// SetSubscribeTimeout sets a max duration of the subscribe handler. When it expires, the connection
+// is closed, so blocked reads and writes of the handler fail, and the connect attempt fails with
+// 'ErrSubscribe' that wraps 'context.DeadlineExceeded'. The next attempt is scheduled as after any
+// other failure. 0 means no timeout. After 'Dial' call it does nothing
+func (r *ReConn) SetSubscribeTimeout(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.subscribeTimeout = d
+	})
+	return r
+}
+
+// subscribeHandlerOf returns the subscribe handler to use with 'cfg'. It returns nil if there's no handler
+func (r *ReConn) subscribeHandlerOf(cfg *MutableConfig) SubscribeHandlerContext {
+	if cfg.SubscribeHandler != nil {
+		handler := cfg.SubscribeHandler
+		return func(_ context.Context, conn WsConnection) error {
+			return handler(conn)
+		}
+	}
+	return r.subscribeHandlerContext
+}
+
+// runSubscribeHandler calls the handler with a new connection. It must be called under 'r.mu'
+func (r *ReConn) runSubscribeHandler(conn WsConnection, handler SubscribeHandlerContext) error {
+	ctx, cancel := r.closeContext(r.dialCtx)
+	defer cancel()
+	if r.subscribeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.subscribeTimeout)
+		defer cancel()
+	}
+	if !r.attemptDeadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, r.attemptDeadline)
+		defer cancel()
+	}
+	ctx = withResumeInfo(ctx, r.resumeInfoLocked())
+
+	// Close the connection to interrupt blocked reads and writes
+	stop := onDone(ctx, func() {
+		conn.Close()
+	})
+	// Pass raw connection to prevent deadlock
+	err := handler(ctx, r.egressConn(conn))
+	stop()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("subscribe handler: %w", ctxErr)
+	}
+	return err
+}
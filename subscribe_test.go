@@ -0,0 +1,104 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscribeTimeout(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		// Never ack
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	t.Run("context handler", func(t *testing.T) {
+		var gotDeadline int32
+		conn := New().SetURL(wsURL(server)).SetSubscribeTimeout(100 * time.Millisecond).
+			SetSubscribeHandlerContext(func(ctx context.Context, conn WsConnection) error {
+				if _, ok := ctx.Deadline(); ok {
+					atomic.StoreInt32(&gotDeadline, 1)
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, []byte("subscribe")); err != nil {
+					return err
+				}
+				// Wait for the ack
+				_, _, err := conn.ReadMessage()
+				return err
+			})
+		defer conn.Close()
+
+		start := time.Now()
+		err := conn.Dial()
+		if !errors.Is(err, ErrSubscribe) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected ErrSubscribe with context.DeadlineExceeded, got %v", err)
+		}
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("handler must be interrupted, dial took %s", d)
+		}
+		if atomic.LoadInt32(&gotDeadline) != 1 {
+			t.Error("context must have the deadline")
+		}
+		if decisions := conn.ExplainLastSchedule(); len(decisions) != 1 || decisions[0].Source != ScheduleAfterFailure {
+			t.Errorf("reconnect must be scheduled after failure, got %+v", decisions)
+		}
+
+		// The instance isn't frozen
+		if state := conn.State(); state != StateDisconnected {
+			t.Errorf("unexpected state: %s", state)
+		}
+	})
+
+	t.Run("handler without context", func(t *testing.T) {
+		conn := New().SetURL(wsURL(server)).SetSubscribeTimeout(100 * time.Millisecond).
+			SetSubscribeHandler(func(conn WsConnection) error {
+				_, _, err := conn.ReadMessage()
+				return err
+			})
+		defer conn.Close()
+
+		if err := conn.Dial(); !errors.Is(err, ErrSubscribe) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected ErrSubscribe with context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestSubscribeHandlerContext(t *testing.T) {
+	var dialer FakeDialer
+
+	var calls int32
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).
+		SetSubscribeHandler(func(WsConnection) error {
+			t.Error("replaced handler must not be called")
+			return nil
+		}).
+		SetSubscribeHandlerContext(func(ctx context.Context, conn WsConnection) error {
+			atomic.AddInt32(&calls, 1)
+			if _, ok := ctx.Deadline(); ok {
+				t.Error("no deadline is expected without the timeout")
+			}
+			return conn.WriteMessage(websocket.TextMessage, []byte("subscribe"))
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected 1 call, got %d", atomic.LoadInt32(&calls))
+	}
+	if written := dialer.Last().Written(); len(written) != 1 || string(written[0].Data) != "subscribe" {
+		t.Errorf("unexpected written messages: %+v", written)
+	}
+}
@@ -0,0 +1,113 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SubscribeHandlerV2 is like 'SubscribeHandlerContext', but it gets '*SubscribeConn'
+type SubscribeHandlerV2 func(ctx context.Context, conn *SubscribeConn) error
+
+// SetSubscribeHandlerV2 sets subscribe handler that gets '*SubscribeConn'. It replaces the handler
+// set by 'SetSubscribeHandler', 'SetSubscribeHandlerContext' and 'SetResumeSubscribeHandler' and vice versa.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetSubscribeHandlerV2(f SubscribeHandlerV2) *ReConn {
+	r.configure(func() {
+		r.subscribeHandler = nil
+		r.subscribeHandlerContext = func(ctx context.Context, conn WsConnection) error {
+			subConn := &SubscribeConn{conn: conn, valid: newAtomicBool()}
+			subConn.valid.Set(true)
+			defer subConn.valid.Set(false)
+
+			return f(ctx, subConn)
+		}
+	})
+	return r
+}
+
+// SubscribeConn is a connection passed to the subscribe handler set by 'SetSubscribeHandlerV2'.
+// Its writes can't interleave with other writes of the instance: the connection isn't published
+// until the handler returns. After the handler returns, all methods fail with 'ErrConnClosed', so
+// a retained reference can't be used to write to a stale connection.
+//
+// A read or write interrupted by a timeout breaks the connection (like an expired deadline of
+// gorilla's connection does), so the handler should return the error
+type SubscribeConn struct {
+	conn  WsConnection
+	valid *atomicBool
+}
+
+var _ WsConnection = (*SubscribeConn)(nil)
+
+// ReadMessage reads a message. Messages are processed as by the subscribe handler
+// of 'SetSubscribeHandler': transforms are applied, the write gate is notified and etc.
+func (c *SubscribeConn) ReadMessage() (messageType int, data []byte, err error) {
+	return c.ReadMessageTimeout(0)
+}
+
+// ReadMessageTimeout is like 'ReadMessage', but fails with an error that wraps 'context.DeadlineExceeded'
+// if no message is read in 'd'. 0 means no timeout
+func (c *SubscribeConn) ReadMessageTimeout(d time.Duration) (messageType int, data []byte, err error) {
+	err = c.withTimeout("read", d, func() error {
+		messageType, data, err = c.conn.ReadMessage()
+		return err
+	})
+	return messageType, data, err
+}
+
+// WriteMessage writes a message
+func (c *SubscribeConn) WriteMessage(messageType int, data []byte) error {
+	return c.WriteMessageTimeout(messageType, data, 0)
+}
+
+// WriteMessageTimeout is like 'WriteMessage', but fails with an error that wraps 'context.DeadlineExceeded'
+// if the message isn't written in 'd'. 0 means no timeout
+func (c *SubscribeConn) WriteMessageTimeout(messageType int, data []byte, d time.Duration) error {
+	return c.withTimeout("write", d, func() error {
+		return c.conn.WriteMessage(messageType, data)
+	})
+}
+
+// ReadJSON reads the next message and unmarshals it into 'v'. An unmarshal error is wrapped into 'ErrJSON'
+func (c *SubscribeConn) ReadJSON(v interface{}) error {
+	return ReadJSONFrom(c, v)
+}
+
+// WriteJSON marshals 'v' and writes it as a text message. A marshal error is wrapped into 'ErrJSON'
+func (c *SubscribeConn) WriteJSON(v interface{}) error {
+	return WriteJSONTo(c, v)
+}
+
+// Close closes the connection, so the connect attempt fails
+func (c *SubscribeConn) Close() error {
+	if !c.valid.Get() {
+		return ErrConnClosed
+	}
+	return c.conn.Close()
+}
+
+// withTimeout calls 'f' if the connection is still valid. The connection is closed if 'f' doesn't
+// return in 'd'
+func (c *SubscribeConn) withTimeout(op string, d time.Duration, f func() error) error {
+	if !c.valid.Get() {
+		return ErrConnClosed
+	}
+	if d <= 0 {
+		return f()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	stop := onDone(ctx, func() {
+		c.conn.Close()
+	})
+	err := f()
+	stop()
+
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscribeHandlerV2(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		var req map[string]string
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		conn.WriteJSON(map[string]string{"ack": req["subscribe"]})
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var retained *SubscribeConn
+	conn := New().SetURL(wsURL(server)).SetSubscribeHandlerV2(func(ctx context.Context, conn *SubscribeConn) error {
+		retained = conn
+		if err := conn.WriteJSON(map[string]string{"subscribe": "trades"}); err != nil {
+			return err
+		}
+		var ack map[string]string
+		if err := conn.ReadJSON(&ack); err != nil {
+			return err
+		}
+		if ack["ack"] != "trades" {
+			return errors.New("unexpected ack")
+		}
+		return nil
+	})
+	defer conn.Close()
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The connection is invalid after the handler has returned
+	if err := retained.WriteMessage(websocket.TextMessage, []byte("late")); err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed, got %v", err)
+	}
+	if _, _, err := retained.ReadMessage(); err != ErrConnClosed {
+		t.Errorf("expected ErrConnClosed, got %v", err)
+	}
+	if !conn.IsConnected() {
+		t.Error("instance must stay connected")
+	}
+}
+
+func TestSubscribeConnTimeout(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		// Never ack
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetSubscribeHandlerV2(func(ctx context.Context, conn *SubscribeConn) error {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("subscribe")); err != nil {
+			return err
+		}
+		_, _, err := conn.ReadMessageTimeout(50 * time.Millisecond)
+		return err
+	})
+	defer conn.Close()
+
+	start := time.Now()
+	err := conn.Dial()
+	if !errors.Is(err, ErrSubscribe) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrSubscribe with context.DeadlineExceeded, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("read must be interrupted, dial took %s", d)
+	}
+}
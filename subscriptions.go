@@ -0,0 +1,544 @@
+package reconnect
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// AckMatcher reports whether a message is an acknowledgment of a subscription. A non-nil error
+// means the server acknowledged the subscription with an error (for example, '{"error":"unknown symbol"}')
+type AckMatcher func(messageType int, data []byte) (matched bool, err error)
+
+type subscription struct {
+	// key is empty for subscriptions added by 'AddSubscription'. They are identified by the message
+	key         string
+	messageType int
+	payload     []byte
+	ackMatch    AckMatcher
+	ackTimeout  time.Duration
+	// scope identifies a subscription added by 'SubscribeScoped', it's 0 for other subscriptions
+	scope uint64
+	// removed is set only for notifications of observers
+	removed bool
+}
+
+// subscriptionNameLen is a max number of payload bytes used as a name of a subscription without a key
+const subscriptionNameLen = 32
+
+func (s subscription) String() string {
+	if s.key != "" {
+		return s.key
+	}
+	if len(s.payload) > subscriptionNameLen {
+		return string(s.payload[:subscriptionNameLen]) + "..."
+	}
+	return string(s.payload)
+}
+
+// sameAs reports whether the subscriptions must replace each other
+func (s subscription) sameAs(other subscription) bool {
+	if s.key != "" || other.key != "" {
+		return s.key == other.key
+	}
+	return s.messageType == other.messageType && bytes.Equal(s.payload, other.payload)
+}
+
+// subscriptions contains registered subscriptions in the registration order
+type subscriptions struct {
+	mu   sync.Mutex
+	list []subscription
+	// observers are called after every change (see shadow.go)
+	observers    map[int]func(sub subscription)
+	nextObserver int
+	nextScope    uint64
+}
+
+// Set replaces a subscription with the same key or adds a new one
+func (s *subscriptions) Set(sub subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.notifyLocked(sub)
+
+	for i := range s.list {
+		if s.list[i].sameAs(sub) {
+			s.list[i] = sub
+			return
+		}
+	}
+	s.list = append(s.list, sub)
+}
+
+// Remove removes a subscription. It returns false if the subscription isn't registered
+func (s *subscriptions) Remove(sub subscription) bool {
+	return s.remove(sub, false)
+}
+
+// RemoveScoped is like 'Remove', but it removes the subscription only if it wasn't replaced
+// after it was registered by 'SubscribeScoped'
+func (s *subscriptions) RemoveScoped(sub subscription) bool {
+	return s.remove(sub, true)
+}
+
+func (s *subscriptions) remove(sub subscription, sameScope bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.list {
+		if s.list[i].sameAs(sub) {
+			if sameScope && s.list[i].scope != sub.scope {
+				return false
+			}
+			s.list = append(s.list[:i], s.list[i+1:]...)
+
+			sub.removed = true
+			s.notifyLocked(sub)
+			return true
+		}
+	}
+	return false
+}
+
+// Observe calls 'f' for every registered subscription and then after every change of the registry.
+// 'f' is called under the registry lock, so changes are observed in order; it must not block.
+// The returned function unregisters the observer
+func (s *subscriptions) Observe(f func(sub subscription)) (stop func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range s.list {
+		f(sub)
+	}
+
+	if s.observers == nil {
+		s.observers = make(map[int]func(subscription))
+	}
+	id := s.nextObserver
+	s.nextObserver++
+	s.observers[id] = f
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.observers, id)
+	}
+}
+
+func (s *subscriptions) notifyLocked(sub subscription) {
+	for _, f := range s.observers {
+		f(sub)
+	}
+}
+
+// NewScope returns a new id for 'subscription.scope'
+func (s *subscriptions) NewScope() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextScope++
+	return s.nextScope
+}
+
+func (s *subscriptions) Copy() []subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]subscription, len(s.list))
+	copy(res, s.list)
+	return res
+}
+
+// SubscribeWithAck registers a subscription that is written after every successful dial, including
+// reconnects. If 'ackMatch' isn't nil, the connect waits until the subscription is acknowledged. Messages
+// read while waiting for acks are delivered by 'ReadMessage' afterwards in order. An ack with an error
+// or a missing ack after 'timeout' fails the connect with 'ErrSubscribe'. A subscription with the same key
+// is replaced.
+//
+// Before 'Dial' the subscription is only registered. After 'Dial' it's also written on the current
+// connection and the call waits for the ack. The ack is consumed by 'ReadMessage', so the messages must
+// be read concurrently
+func (r *ReConn) SubscribeWithAck(key string, payload []byte, ackMatch AckMatcher, timeout time.Duration) error {
+	return r.subscribe(subscription{
+		key:         key,
+		messageType: websocket.TextMessage,
+		payload:     payload,
+		ackMatch:    ackMatch,
+		ackTimeout:  timeout,
+	})
+}
+
+// AddSubscription registers a message that is written after every successful dial, including reconnects,
+// before messages of the new connection are returned. Messages are written in the registration order after
+// the subscribe handler (see 'SetSubscribeHandler'). A write error fails the connect with 'ErrSubscribe'.
+// Adding the same message again does nothing.
+//
+// Before 'Dial' the message is only registered. After 'Dial' it's also written on the current connection.
+// If there's no connection, it will be written after the reconnect
+func (r *ReConn) AddSubscription(messageType int, data []byte) error {
+	return r.subscribe(subscription{
+		messageType: messageType,
+		payload:     append([]byte(nil), data...),
+	})
+}
+
+// RemoveSubscription unregisters a message added by 'AddSubscription', so it's not written after
+// next reconnects. Nothing is written on the current connection. It returns false if the message
+// isn't registered
+func (r *ReConn) RemoveSubscription(messageType int, data []byte) bool {
+	return r.subscriptions.Remove(subscription{messageType: messageType, payload: data})
+}
+
+// SubscribeScoped registers a subscription that lives until 'ctx' is done. Until then it's written
+// after every successful dial like subscriptions added by 'AddSubscription' (see it for details).
+// When 'ctx' is done, the subscription is removed, so next reconnects don't write it, and 'unsubscribePayload'
+// is written on the current connection. If there's no connection, the subscription is just removed.
+// The subscription is removed on 'Close' as well. A subscription with the same key is replaced, and
+// a replaced scoped subscription isn't removed by its context. If 'ctx' is already done, 'ctx.Err()'
+// is returned and nothing is registered. An error of the write on the current connection is returned,
+// but the subscription stays registered until 'ctx' is done
+func (r *ReConn) SubscribeScoped(ctx context.Context, key string, subscribePayload, unsubscribePayload []byte, messageType int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sub := subscription{
+		key:         key,
+		messageType: messageType,
+		payload:     append([]byte(nil), subscribePayload...),
+		scope:       r.subscriptions.NewScope(),
+	}
+	unsubscribePayload = append([]byte(nil), unsubscribePayload...)
+	closeCh := r.closeSignal()
+
+	err := r.subscribe(sub)
+	r.goroutines.Go(func() {
+		select {
+		case <-ctx.Done():
+			r.unsubscribeScoped(sub, unsubscribePayload)
+		case <-closeCh:
+			r.subscriptions.RemoveScoped(sub)
+		}
+	})
+	return err
+}
+
+// unsubscribeScoped removes a scoped subscription and writes the unsubscribe message on the current
+// connection. The lock guarantees that a concurrent connect either doesn't replay the subscription
+// or finishes before the unsubscribe message is written
+func (r *ReConn) unsubscribeScoped(sub subscription, unsubscribePayload []byte) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.subscriptions.RemoveScoped(sub) {
+		// The subscription was replaced
+		return
+	}
+	if r.conn == nil || r.closed.Get() {
+		return
+	}
+	if err := r.writeMessageLocked(context.Background(), WriteSourceSubscribe, sub.messageType, unsubscribePayload); err != nil {
+		r.log.Error(fmt.Sprintf("unsubscribe '%s': %s", sub, err))
+	}
+}
+
+// subscribe is like 'SubscribeWithAck'
+func (r *ReConn) subscribe(sub subscription) error {
+	if !r.dialed.Get() {
+		r.subscriptions.Set(sub)
+		return nil
+	}
+
+	waiter, err := r.subscribeLive(sub)
+	if err != nil || waiter == nil {
+		return err
+	}
+	defer r.ackWaiters.Remove(waiter)
+
+	timer := time.NewTimer(sub.ackTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-waiter.done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("subscription '%s': %w", sub, ErrAckTimeout)
+	case <-r.closeSignal():
+		return ErrConnClosed
+	}
+}
+
+// subscribeLive registers a subscription and writes it on the current connection. The lock guarantees
+// that a concurrent connect either replays the subscription or finishes before the write
+func (r *ReConn) subscribeLive(sub subscription) (*ackWaiter, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.subscriptions.Set(sub)
+	if r.conn == nil {
+		// The subscription will be replayed after the reconnect
+		return nil, nil
+	}
+
+	var waiter *ackWaiter
+	if sub.ackMatch != nil {
+		waiter = r.ackWaiters.Add(sub)
+	}
+	if err := r.writeMessageLocked(context.Background(), WriteSourceSubscribe, sub.messageType, sub.payload); err != nil {
+		if waiter != nil {
+			r.ackWaiters.Remove(waiter)
+		}
+		return nil, err
+	}
+	return waiter, nil
+}
+
+// replaySubscriptions writes subscriptions to a new connection and waits for their acks
+func (r *ReConn) replaySubscriptions(conn WsConnection, subs []subscription) (err error) {
+	start := time.Now()
+	defer func() {
+		r.countReplay(len(subs), time.Since(start), err)
+	}()
+
+	var pending []subscription
+	for _, sub := range subs {
+		if err := conn.WriteMessage(sub.messageType, sub.payload); err != nil {
+			return fmt.Errorf("subscription '%s': %w", sub, err)
+		}
+		if sub.ackMatch != nil {
+			pending = append(pending, sub)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return r.waitReplayAcks(conn, pending)
+}
+
+// waitReplayAcks reads messages until all pending subscriptions are acknowledged. Other messages are
+// buffered. A missed ack breaks the connection, but the connect fails anyway
+func (r *ReConn) waitReplayAcks(conn WsConnection, pending []subscription) error {
+	c, ok := conn.(interface{ SetReadDeadline(t time.Time) error })
+	if ok {
+		defer c.SetReadDeadline(time.Time{})
+	}
+
+	start := time.Now()
+	for len(pending) > 0 {
+		first := 0
+		for i := range pending {
+			if pending[i].ackTimeout < pending[first].ackTimeout {
+				first = i
+			}
+		}
+		deadline := start.Add(pending[first].ackTimeout)
+		if ok {
+			_ = c.SetReadDeadline(deadline)
+		}
+
+		messageType, data, err := conn.ReadMessage()
+		if isTransformError(err) {
+			// The connection is healthy, the message can't be an ack
+			r.log.Error(fmt.Sprintf("skip message while waiting for subscription acks: %s", err))
+			continue
+		}
+		if err != nil {
+			if !time.Now().Before(deadline) {
+				return fmt.Errorf("subscription '%s': %w", pending[first], ErrAckTimeout)
+			}
+			return err
+		}
+
+		matched := false
+		for i, sub := range pending {
+			isAck, ackErr := sub.ackMatch(messageType, data)
+			if !isAck {
+				continue
+			}
+			if ackErr != nil {
+				return fmt.Errorf("subscription '%s': %w", sub, ackErr)
+			}
+			pending = append(pending[:i], pending[i+1:]...)
+			matched = true
+			break
+		}
+		if !matched && !r.replayBuffer.TryPush(bufferedMessage{Type: messageType, Data: data}) {
+			r.dropMessage(DropFeatureMemoryBudget, data)
+		}
+	}
+	return nil
+}
+
+// ackWaiter waits for an ack of a subscription written on a live connection
+type ackWaiter struct {
+	sub  subscription
+	done chan error
+}
+
+type ackWaiters struct {
+	mu      sync.Mutex
+	waiters []*ackWaiter
+}
+
+func (w *ackWaiters) Add(sub subscription) *ackWaiter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	waiter := &ackWaiter{sub: sub, done: make(chan error, 1)}
+	w.waiters = append(w.waiters, waiter)
+	return waiter
+}
+
+func (w *ackWaiters) Remove(waiter *ackWaiter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.waiters {
+		if w.waiters[i] == waiter {
+			w.waiters = append(w.waiters[:i], w.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (w *ackWaiters) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.waiters)
+}
+
+// Match reports whether a message is an ack of a waiting subscription. The matched waiter is removed
+func (w *ackWaiters) Match(messageType int, data []byte) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, waiter := range w.waiters {
+		ok, err := waiter.sub.ackMatch(messageType, data)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			err = newConnectError(PhaseSubscribe, fmt.Errorf("subscription '%s': %w", waiter.sub, err))
+		}
+		waiter.done <- err
+		w.waiters = append(w.waiters[:i], w.waiters[i+1:]...)
+		return true
+	}
+	return false
+}
+
+type bufferedMessage struct {
+	Type int
+	Data []byte
+}
+
+// messageBuffer is a FIFO queue of messages. If 'budget' is set, sizes of payloads are accounted
+// as 'component' (see 'SetMemoryBudget')
+type messageBuffer struct {
+	budget    *memoryBudget
+	component MemoryComponent
+
+	mu   sync.Mutex
+	msgs []bufferedMessage
+}
+
+// Push pushes the message even if it doesn't fit into the memory budget
+func (b *messageBuffer) Push(msg bufferedMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reserve(len(msg.Data), true)
+	b.msgs = append(b.msgs, msg)
+}
+
+// TryPush pushes the message only if it fits into the memory budget
+func (b *messageBuffer) TryPush(msg bufferedMessage) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.reserve(len(msg.Data), false) {
+		return false
+	}
+	b.msgs = append(b.msgs, msg)
+	return true
+}
+
+// PushLimited pushes the message only if the buffer contains less than 'limit' messages
+// and the message fits into the memory budget
+func (b *messageBuffer) PushLimited(msg bufferedMessage, limit int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.msgs) >= limit {
+		return false
+	}
+	if !b.reserve(len(msg.Data), false) {
+		return false
+	}
+	b.msgs = append(b.msgs, msg)
+	return true
+}
+
+// Peek returns the first message without removing it
+func (b *messageBuffer) Peek() (bufferedMessage, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.msgs) == 0 {
+		return bufferedMessage{}, false
+	}
+	return b.msgs[0], true
+}
+
+func (b *messageBuffer) Pop() (bufferedMessage, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.msgs) == 0 {
+		return bufferedMessage{}, false
+	}
+	msg := b.msgs[0]
+	b.msgs[0] = bufferedMessage{}
+	b.msgs = b.msgs[1:]
+	b.release(len(msg.Data))
+	return msg, true
+}
+
+func (b *messageBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var size int
+	for _, msg := range b.msgs {
+		size += len(msg.Data)
+	}
+	b.release(size)
+	b.msgs = nil
+}
+
+func (b *messageBuffer) reserve(n int, force bool) bool {
+	if b.budget == nil {
+		return true
+	}
+	return b.budget.TryReserve(b.component, n, force)
+}
+
+func (b *messageBuffer) release(n int) {
+	if b.budget != nil {
+		b.budget.Release(b.component, n)
+	}
+}
+
+func (b *messageBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.msgs)
+}
@@ -0,0 +1,451 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func matchAck(ack, errAck string) AckMatcher {
+	return func(_ int, data []byte) (bool, error) {
+		switch string(data) {
+		case ack:
+			return true, nil
+		case errAck:
+			return true, errors.New("unknown symbol")
+		default:
+			return false, nil
+		}
+	}
+}
+
+func TestSubscribeWithAckReplay(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		n := atomic.AddInt32(&connections, 1)
+		for _, ack := range []string{"ack:a", "ack:b"} {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			// Not an ack, must be delivered after the connect
+			conn.WriteMessage(websocket.TextMessage, []byte("update"))
+			conn.WriteMessage(websocket.TextMessage, []byte(ack))
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("last"))
+		if n == 1 {
+			// Force a reconnect
+			return
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	conn.SubscribeWithAck("a", []byte("sub:a"), matchAck("ack:a", ""), time.Second)
+	conn.SubscribeWithAck("b", []byte("sub:b"), matchAck("ack:b", ""), time.Second)
+
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		for _, want := range []string{"update", "update", "last"} {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(data) != want {
+				t.Fatalf("got %q, want %q", data, want)
+			}
+		}
+		if i == 0 {
+			// The connection is dropped, the subscriptions are replayed
+			if _, _, err := conn.ReadMessage(); err == nil {
+				t.Fatal("expected an error")
+			}
+		}
+	}
+	if n := atomic.LoadInt32(&connections); n != 2 {
+		t.Errorf("expected 2 connections, got %d", n)
+	}
+}
+
+func TestSubscribeWithAckErrors(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if string(data) == "sub:bad" {
+			conn.WriteMessage(websocket.TextMessage, []byte("error:bad"))
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	t.Run("ack with error", func(t *testing.T) {
+		conn := New().SetURL(wsURL(server))
+		conn.SubscribeWithAck("bad", []byte("sub:bad"), matchAck("ack:bad", "error:bad"), time.Second)
+		defer conn.Close()
+
+		err := conn.Dial()
+		if !errors.Is(err, ErrSubscribe) {
+			t.Fatalf("expected ErrSubscribe, got: %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		conn := New().SetURL(wsURL(server))
+		conn.SubscribeWithAck("silent", []byte("sub:silent"), matchAck("ack:silent", ""), 50*time.Millisecond)
+		defer conn.Close()
+
+		err := conn.Dial()
+		if !errors.Is(err, ErrSubscribe) || !errors.Is(err, ErrAckTimeout) {
+			t.Fatalf("expected ErrSubscribe and ErrAckTimeout, got: %v", err)
+		}
+	})
+}
+
+func TestSubscribeWithAckLive(t *testing.T) {
+	server := newWsServer(func(conn *websocket.Conn) {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("update"))
+		conn.WriteMessage(websocket.TextMessage, []byte("ack:live"))
+		conn.WriteMessage(websocket.TextMessage, []byte("last"))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	messages := make(chan string, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- string(data)
+		}
+	}()
+
+	if err := conn.SubscribeWithAck("live", []byte("sub:live"), matchAck("ack:live", ""), time.Second); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The ack is consumed
+	for _, want := range []string{"update", "last"} {
+		if got := <-messages; got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if n := conn.DroppedMessages()[DropFeatureAck]; n != 1 {
+		t.Errorf("expected 1 consumed ack, got %d", n)
+	}
+}
+
+func TestAddSubscription(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetSubscribeHandler(func(conn WsConnection) error {
+		return conn.WriteMessage(websocket.TextMessage, []byte("auth"))
+	})
+
+	for _, msg := range []string{"sub-1", "sub-2", "sub-1"} {
+		if err := conn.AddSubscription(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	checkWritten := func(want ...string) {
+		t.Helper()
+
+		written := dialer.Last().Written()
+		if len(written) != len(want) {
+			t.Fatalf("expected %d messages, got %+v", len(want), written)
+		}
+		for i := range want {
+			if string(written[i].Data) != want[i] {
+				t.Errorf("expected message %d to be %q, got %q", i, want[i], written[i].Data)
+			}
+		}
+	}
+
+	// The handler is called first, duplicates are ignored
+	checkWritten("auth", "sub-1", "sub-2")
+
+	// Added on the live connection
+	if err := conn.AddSubscription(websocket.TextMessage, []byte("sub-3")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkWritten("auth", "sub-1", "sub-2", "sub-3")
+
+	if !conn.RemoveSubscription(websocket.TextMessage, []byte("sub-2")) {
+		t.Error("subscription must be removed")
+	}
+	if conn.RemoveSubscription(websocket.BinaryMessage, []byte("sub-1")) {
+		t.Error("message type must match")
+	}
+
+	// All subscriptions are replayed after the reconnect
+	dialer.Last().Fail(errors.New("connection reset"))
+	conn.ReadMessage()
+	if dialer.Dials() != 2 {
+		t.Fatalf("expected 2 dials, got %d", dialer.Dials())
+	}
+	checkWritten("auth", "sub-1", "sub-3")
+}
+
+func TestAddSubscriptionReplayError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	conn := New().SetURL("ws://fake").SetDialFunc(func(string, http.Header) (WsConnection, *http.Response, error) {
+		c := NewFakeConn()
+		c.Fail(writeErr)
+		return c, nil, nil
+	})
+	if err := conn.AddSubscription(websocket.TextMessage, []byte("sub")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := conn.Dial(); !errors.Is(err, ErrSubscribe) || !errors.Is(err, writeErr) {
+		t.Errorf("expected ErrSubscribe, got %v", err)
+	}
+}
+
+// writtenData returns payloads of messages written on a fake connection
+func writtenData(conn *FakeConn) []string {
+	var res []string
+	for _, msg := range conn.Written() {
+		res = append(res, string(msg.Data))
+	}
+	return res
+}
+
+// waitNoSubscriptions waits until the registry is empty and a concurrent unsubscribe write is finished
+func waitNoSubscriptions(t *testing.T, conn *ReConn) {
+	for i := 0; i < 500 && len(conn.subscriptions.Copy()) > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := len(conn.subscriptions.Copy()); n > 0 {
+		t.Fatalf("expected no subscriptions, got %d", n)
+	}
+	// The unsubscribe message is written under the lock
+	conn.mu.Lock()
+	conn.mu.Unlock()
+}
+
+func TestSubscribeScopedCancelConnected(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := conn.SubscribeScoped(ctx, "a", []byte("sub:a"), []byte("unsub:a"), websocket.TextMessage)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	first := dialer.Last()
+
+	// The subscription is replayed after a reconnect
+	first.Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	second := dialer.Last()
+	if got, want := writtenData(second), []string{"sub:a", "msg"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got messages %q, want %q", got, want)
+	}
+
+	cancel()
+	waitNoSubscriptions(t, conn)
+	if got, want := writtenData(second), []string{"sub:a", "msg", "unsub:a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+
+	// The subscription isn't replayed anymore
+	second.Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := writtenData(dialer.Last()); len(got) != 0 {
+		t.Errorf("expected no messages, got %q", got)
+	}
+
+	if err := conn.SubscribeScoped(ctx, "b", []byte("sub:b"), nil, websocket.TextMessage); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSubscribeScopedCancelDisconnected(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := conn.SubscribeScoped(ctx, "a", []byte("sub:a"), []byte("unsub:a"), websocket.TextMessage)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The reconnect fails
+	dialer.SetError(errors.New("connection refused"))
+	first := dialer.Last()
+	first.Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	cancel()
+	waitNoSubscriptions(t, conn)
+
+	// The read reconnects
+	dialer.SetError(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.ReadMessage()
+	}()
+	for i := 0; i < 500 && dialer.Dials() != 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	dialer.Last().Push(websocket.TextMessage, []byte("msg"))
+	<-done
+
+	if got := writtenData(dialer.Last()); len(got) != 0 {
+		t.Errorf("expected no messages, got %q", got)
+	}
+	if got, want := writtenData(first), []string{"sub:a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q on the broken connection", got, want)
+	}
+}
+
+func TestSubscribeScopedCancelRacingReplay(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		sub, unsub := fmt.Sprintf("sub:%d", i), fmt.Sprintf("unsub:%d", i)
+		if err := conn.SubscribeScoped(ctx, fmt.Sprint(i), []byte(sub), []byte(unsub), websocket.TextMessage); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// The cancel races with the replay of the reconnect
+		dialer.Last().Fail(errors.New("connection reset"))
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			conn.ReadMessage()
+		}()
+		go func() {
+			defer wg.Done()
+			cancel()
+		}()
+		wg.Wait()
+		waitNoSubscriptions(t, conn)
+
+		// If the subscription was replayed on the live connection, it must be unsubscribed
+		var last string
+		for _, data := range writtenData(dialer.Last()) {
+			if data == sub || data == unsub {
+				last = data
+			}
+		}
+		if last == sub {
+			t.Fatalf("subscription %d was replayed after the cancel", i)
+		}
+	}
+}
+
+type replayRecorder struct {
+	NoopMetricsCollector
+	mu      sync.Mutex
+	counts  []int
+	lastErr error
+}
+
+func (c *replayRecorder) SubscriptionsReplayed(count int, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts = append(c.counts, count)
+	c.lastErr = err
+}
+
+func TestReplayStats(t *testing.T) {
+	const ackDelay = 30 * time.Millisecond
+
+	server := newWsServer(func(conn *websocket.Conn) {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		time.Sleep(ackDelay)
+		conn.WriteMessage(websocket.TextMessage, []byte("ack:a"))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	var metrics replayRecorder
+	conn := New().SetURL(wsURL(server)).SetMetricsCollector(&metrics)
+	conn.SubscribeWithAck("a", []byte("sub:a"), matchAck("ack:a", ""), time.Second)
+	conn.AddSubscription(websocket.TextMessage, []byte("sub:b"))
+
+	if stats := conn.Stats(); stats.Replays != 0 {
+		t.Fatalf("expected no replays, got %d", stats.Replays)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	stats := conn.Stats()
+	if stats.Replays != 1 || stats.ReplayedSubscriptions != 2 {
+		t.Errorf("unexpected replay counters: %d, %d", stats.Replays, stats.ReplayedSubscriptions)
+	}
+	if stats.LastReplayDuration < ackDelay {
+		t.Errorf("the replay must include the wait for the ack, got %s", stats.LastReplayDuration)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if !reflect.DeepEqual(metrics.counts, []int{2}) || metrics.lastErr != nil {
+		t.Errorf("unexpected reported replays: %v, %v", metrics.counts, metrics.lastErr)
+	}
+}
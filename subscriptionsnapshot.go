@@ -0,0 +1,83 @@
+package reconnect
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// SubscriptionSnapshotVersion is a version of the format of 'ExportSubscriptions'. It's incremented
+// on every incompatible change, 'ImportSubscriptions' accepts snapshots of all known versions
+const SubscriptionSnapshotVersion = 1
+
+// ErrSubscriptionSnapshot is returned by 'ImportSubscriptions' for malformed snapshots and snapshots
+// of unknown versions
+var ErrSubscriptionSnapshot = newCodedError(CodeInvalidConfig, "invalid subscription snapshot")
+
+type subscriptionSnapshot struct {
+	Version       int                         `json:"version"`
+	Subscriptions []subscriptionSnapshotEntry `json:"subscriptions"`
+}
+
+type subscriptionSnapshotEntry struct {
+	// Key is empty for subscriptions added by 'AddSubscription'
+	Key         string `json:"key,omitempty"`
+	MessageType int    `json:"message_type"`
+	// Payload is encoded as base64, so binary payloads are kept as is
+	Payload []byte `json:"payload"`
+}
+
+// ExportSubscriptions encodes the registered subscriptions ('AddSubscription', 'SubscribeWithAck' and etc.)
+// as JSON: keys, message types and payloads in the registration order. The snapshot can be persisted
+// and passed to 'ImportSubscriptions' of an instance created after a process restart. Ack matchers are
+// functions and can't be encoded, so imported subscriptions don't wait for acks. Subscriptions added
+// by 'SubscribeScoped' belong to contexts of this process and aren't exported
+func (r *ReConn) ExportSubscriptions() ([]byte, error) {
+	subs := r.subscriptions.Copy()
+
+	snapshot := subscriptionSnapshot{
+		Version:       SubscriptionSnapshotVersion,
+		Subscriptions: make([]subscriptionSnapshotEntry, 0, len(subs)),
+	}
+	for _, sub := range subs {
+		if sub.scope != 0 {
+			continue
+		}
+		snapshot.Subscriptions = append(snapshot.Subscriptions, subscriptionSnapshotEntry{
+			Key:         sub.key,
+			MessageType: sub.messageType,
+			Payload:     sub.payload,
+		})
+	}
+	return json.Marshal(snapshot)
+}
+
+// ImportSubscriptions registers subscriptions of a snapshot created by 'ExportSubscriptions' in their order.
+// Subscriptions with the same keys (or the same messages for subscriptions without keys) are replaced.
+// Nothing is written on the current connection: the subscriptions are written after the next connect like
+// all registered ones. A malformed snapshot or a snapshot of an unknown version isn't imported at all,
+// 'ErrSubscriptionSnapshot' is returned
+func (r *ReConn) ImportSubscriptions(data []byte) error {
+	var snapshot subscriptionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("%w: %s", ErrSubscriptionSnapshot, err)
+	}
+	if snapshot.Version != SubscriptionSnapshotVersion {
+		return fmt.Errorf("%w: unknown version %d", ErrSubscriptionSnapshot, snapshot.Version)
+	}
+	for i, entry := range snapshot.Subscriptions {
+		if entry.MessageType != websocket.TextMessage && entry.MessageType != websocket.BinaryMessage {
+			return fmt.Errorf("%w: subscription %d has invalid message type %d", ErrSubscriptionSnapshot, i, entry.MessageType)
+		}
+	}
+
+	for _, entry := range snapshot.Subscriptions {
+		r.subscriptions.Set(subscription{
+			key:         entry.Key,
+			messageType: entry.MessageType,
+			payload:     entry.Payload,
+		})
+	}
+	return nil
+}
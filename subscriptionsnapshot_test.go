@@ -0,0 +1,98 @@
+package reconnect
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestExportImportSubscriptions(t *testing.T) {
+	src := New()
+	src.AddSubscription(websocket.TextMessage, []byte("sub:a"))
+	src.SubscribeWithAck("b", []byte("sub:b"), matchAck("ack:b", ""), time.Second)
+	src.AddSubscription(websocket.BinaryMessage, []byte{0, 1, 2})
+
+	data, err := src.ExportSubscriptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	const want = `{"version":1,"subscriptions":[` +
+		`{"message_type":1,"payload":"c3ViOmE="},` +
+		`{"key":"b","message_type":1,"payload":"c3ViOmI="},` +
+		`{"message_type":2,"payload":"AAEC"}]}`
+	if string(data) != want {
+		t.Fatalf("unexpected snapshot:\ngot:  %s\nwant: %s", data, want)
+	}
+
+	// A restarted process restores the subscriptions
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial)
+	if err := conn.ImportSubscriptions(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	again, err := conn.ExportSubscriptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("round-trip changed the snapshot:\ngot:  %s\nwant: %s", again, data)
+	}
+
+	// Imported subscriptions are replayed on every connect
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	wantMessages := []Message{
+		{Type: websocket.TextMessage, Data: []byte("sub:a")},
+		{Type: websocket.TextMessage, Data: []byte("sub:b")},
+		{Type: websocket.BinaryMessage, Data: []byte{0, 1, 2}},
+	}
+	if got := dialer.Last().Written(); !reflect.DeepEqual(got, wantMessages) {
+		t.Fatalf("got messages %v, want %v", got, wantMessages)
+	}
+
+	// The read reconnects
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := dialer.Dials(); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+	if got := dialer.Last().Written(); !reflect.DeepEqual(got, wantMessages) {
+		t.Errorf("got messages %v, want %v after the reconnect", got, wantMessages)
+	}
+}
+
+func TestImportSubscriptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "malformed", data: `{"version":1,"subscriptions":[`},
+		{name: "unknown version", data: `{"version":2,"subscriptions":[]}`},
+		{name: "no version", data: `{"subscriptions":[]}`},
+		{
+			name: "invalid message type",
+			data: `{"version":1,"subscriptions":[{"key":"a","message_type":1,"payload":""},{"message_type":9,"payload":""}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := New()
+			err := conn.ImportSubscriptions([]byte(tt.data))
+			if !errors.Is(err, ErrSubscriptionSnapshot) {
+				t.Fatalf("expected ErrSubscriptionSnapshot, got %v", err)
+			}
+			if subs := conn.subscriptions.Copy(); len(subs) != 0 {
+				t.Errorf("invalid snapshot must not be imported, got %d subscriptions", len(subs))
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package reconnect
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SupportBundleVersion is a version of the support bundle schema. It's incremented on every
+// incompatible change
+const SupportBundleVersion = 1
+
+// redacted replaces secret values in support bundles
+const redacted = "REDACTED"
+
+// ExportSupportBundle writes all diagnostic state of the instance as a single JSON document that
+// can be attached to issue reports. URL credentials, query values and values of sensitive headers
+// are redacted, bodies are represented by their sizes.
+//
+// Every section is a consistent snapshot, but sections are collected one by one, so a reconnect
+// during the export can be reflected only by some of them
+func (r *ReConn) ExportSupportBundle(w io.Writer) error {
+	cfg := r.loadConfig()
+	sizes := r.PayloadSizes()
+
+	bundle := supportBundle{
+		Version:     SupportBundleVersion,
+		GeneratedAt: time.Now().UTC(),
+		Config: supportConfig{
+			URL:    redactURL(cfg.URL),
+			Header: redactHeader(cfg.Header),
+		},
+		State: supportState{
+			State:              r.State().String(),
+			Flapping:           r.IsFlapping(),
+			ReaderStalls:       r.ReaderStalls(),
+			SuspendsDetected:   r.SuspendsDetected(),
+			AbandonedCloses:    r.AbandonedCloses(),
+			AutoReplies:        r.AutoReplies(),
+			ReadBufferSize:     r.ReadBufferSize(),
+			InternalGoroutines: r.InternalGoroutines(),
+		},
+		DroppedMessages:   r.DroppedMessages(),
+		PayloadSizes:      supportPayloadSizes{Current: sizes.Current, Total: sizes.Total},
+		ScheduleDecisions: []supportScheduleDecision{},
+		ConfigFindings:    []string{},
+		ReconnectTrend:    map[string]supportWindows{},
+	}
+	if attempt, ok := r.LastAttempt(); ok {
+		bundle.LastAttempt = newSupportAttempt(attempt)
+	}
+	if resp := r.GetDialResponse(); resp != nil {
+		bundle.LastDialResponse = &supportDialResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			BodySize:   len(resp.Body),
+		}
+	}
+	if outage, ok := r.LastOutage(); ok {
+		bundle.LastOutage = &supportOutage{
+			LastMessageAt:  outage.LastMessageAt.UTC(),
+			FirstMessageAt: outage.FirstMessageAt.UTC(),
+			Duration:       outage.Duration().String(),
+		}
+	}
+	for _, d := range r.ExplainLastSchedule() {
+		bundle.ScheduleDecisions = append(bundle.ScheduleDecisions, supportScheduleDecision{
+			Time:      d.Time.UTC(),
+			Source:    string(d.Source),
+			BaseDelay: d.BaseDelay.String(),
+			Jitter:    d.Jitter.String(),
+			Wait:      d.Wait.String(),
+		})
+	}
+	for cause, counts := range r.ReconnectTrend() {
+		bundle.ReconnectTrend[string(cause)] = supportWindows{
+			Last5m:  counts.Last5m,
+			Last1h:  counts.Last1h,
+			Last24h: counts.Last24h,
+		}
+	}
+	for _, finding := range r.Validate() {
+		bundle.ConfigFindings = append(bundle.ConfigFindings, finding.Message)
+	}
+	if bundle.DroppedMessages == nil {
+		bundle.DroppedMessages = map[string]uint64{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bundle)
+}
+
+type supportBundle struct {
+	Version           int                       `json:"version"`
+	GeneratedAt       time.Time                 `json:"generated_at"`
+	Config            supportConfig             `json:"config"`
+	State             supportState              `json:"state"`
+	LastAttempt       *supportAttempt           `json:"last_attempt"`
+	LastDialResponse  *supportDialResponse      `json:"last_dial_response"`
+	LastOutage        *supportOutage            `json:"last_outage"`
+	ScheduleDecisions []supportScheduleDecision `json:"schedule_decisions"`
+	DroppedMessages   map[string]uint64         `json:"dropped_messages"`
+	PayloadSizes      supportPayloadSizes       `json:"payload_sizes"`
+	ConfigFindings    []string                  `json:"config_findings"`
+	ReconnectTrend    map[string]supportWindows `json:"reconnect_trend"`
+}
+
+type supportConfig struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+}
+
+type supportState struct {
+	State              string `json:"state"`
+	Flapping           bool   `json:"flapping"`
+	ReaderStalls       uint64 `json:"reader_stalls"`
+	SuspendsDetected   uint64 `json:"suspends_detected"`
+	AbandonedCloses    uint64 `json:"abandoned_closes"`
+	AutoReplies        uint64 `json:"auto_replies"`
+	ReadBufferSize     int    `json:"read_buffer_size"`
+	InternalGoroutines int    `json:"internal_goroutines"`
+}
+
+type supportAttempt struct {
+	Time       time.Time `json:"time"`
+	Duration   string    `json:"duration"`
+	StatusCode int       `json:"status_code"`
+	Phase      string    `json:"phase"`
+	Error      string    `json:"error"`
+	Code       string    `json:"code"`
+}
+
+func newSupportAttempt(attempt AttemptInfo) *supportAttempt {
+	res := &supportAttempt{
+		Time:       attempt.Time.UTC(),
+		Duration:   attempt.Duration.String(),
+		StatusCode: attempt.StatusCode,
+		Phase:      attempt.Phase.String(),
+		Code:       CodeOf(attempt.Err).String(),
+	}
+	if attempt.Err != nil {
+		res.Error = attempt.Err.Error()
+	}
+	return res
+}
+
+type supportDialResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	BodySize   int         `json:"body_size"`
+}
+
+type supportOutage struct {
+	LastMessageAt  time.Time `json:"last_message_at"`
+	FirstMessageAt time.Time `json:"first_message_at"`
+	Duration       string    `json:"duration"`
+}
+
+type supportPayloadSizes struct {
+	Current PayloadSizeBuckets `json:"current"`
+	Total   PayloadSizeBuckets `json:"total"`
+}
+
+type supportWindows struct {
+	Last5m  uint64 `json:"last_5m"`
+	Last1h  uint64 `json:"last_1h"`
+	Last24h uint64 `json:"last_24h"`
+}
+
+type supportScheduleDecision struct {
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source"`
+	BaseDelay string    `json:"base_delay"`
+	Jitter    string    `json:"jitter"`
+	Wait      string    `json:"wait"`
+}
+
+// redactURL removes the password and query values from a url
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{redacted}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// sensitiveHeaderParts are parts of names of headers which values are redacted
+var sensitiveHeaderParts = []string{"auth", "cookie", "token", "secret", "key", "session", "password"}
+
+// redactHeader returns a copy of a header with redacted values of sensitive headers
+func redactHeader(header http.Header) http.Header {
+	res := make(http.Header, len(header))
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		sensitive := false
+		for _, part := range sensitiveHeaderParts {
+			if strings.Contains(lower, part) {
+				sensitive = true
+				break
+			}
+		}
+		if !sensitive {
+			res[name] = append([]string(nil), values...)
+			continue
+		}
+		res[name] = make([]string, len(values))
+		for i := range values {
+			res[name][i] = redacted
+		}
+	}
+	return res
+}
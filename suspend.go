@@ -0,0 +1,111 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// defaultSuspendProbeTimeout is used when a liveness probe timeout isn't set
+const defaultSuspendProbeTimeout = 5 * time.Second
+
+// SetSuspendDetection enables detection of process or VM suspends: if internal timers fire later
+// than 'threshold' after the expected time, the missed periodic work (watchdog, checkpoints and etc.)
+// is skipped, and a liveness probe is run instead. The probe is a ping that must be answered with
+// a pong within 'probeTimeout'. If it fails, the connection is closed, so the next read or write
+// reconnects. Pongs are handled by read methods, so the probe of a connection nobody reads from
+// always fails.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetSuspendDetection(threshold, probeTimeout time.Duration) *ReConn {
+	r.configure(func() {
+		r.suspendThreshold = threshold
+		r.suspendProbeTimeout = probeTimeout
+	})
+	return r
+}
+
+// OnResumeDetectedFunc is called when the process resumes after a suspend. 'gap' is the time
+// the timers were late for
+type OnResumeDetectedFunc func(gap time.Duration)
+
+// SetOnResumeDetected sets a callback called when a suspend is detected (see 'SetSuspendDetection').
+// It's called before the liveness probe finishes. See 'SetOnConnect' for details. After 'Dial' call
+// it does nothing
+func (r *ReConn) SetOnResumeDetected(f OnResumeDetectedFunc) *ReConn {
+	r.configure(func() {
+		r.onResumeDetected = f
+	})
+	return r
+}
+
+// SuspendsDetected returns the number of detected suspends
+func (r *ReConn) SuspendsDetected() uint64 {
+	return atomic.LoadUint64(&r.suspendsDetected)
+}
+
+// startSuspendDetection must be called once after 'r.dialed' is set
+func (r *ReConn) startSuspendDetection() {
+	if r.suspendThreshold <= 0 {
+		return
+	}
+	r.scheduler.OnGap(r.suspendThreshold, r.onSuspendDetected)
+	// A gap can be detected only by a timer, so keep one even if no other periodic work is scheduled
+	r.scheduler.Every(r.suspendThreshold, func() {})
+}
+
+// onSuspendDetected is called by the scheduler
+func (r *ReConn) onSuspendDetected(gap time.Duration) {
+	atomic.AddUint64(&r.suspendsDetected, 1)
+	r.log.Info(fmt.Sprintf("timers are late for %s, the process seems to be suspended", gap.Round(time.Millisecond)))
+	if f := r.onResumeDetected; f != nil {
+		r.callbacks.Push(func() { f(gap) })
+	}
+
+	go r.probeLiveness()
+}
+
+// probeLiveness pings the current connection and reconnects if there's no pong
+func (r *ReConn) probeLiveness() {
+	r.mu.RLock()
+	connected, generation := r.conn != nil, r.generation
+	r.mu.RUnlock()
+
+	if !connected {
+		// The next read or write reconnects
+		return
+	}
+
+	timeout := r.suspendProbeTimeout
+	if timeout <= 0 {
+		timeout = defaultSuspendProbeTimeout
+	}
+	ctx, cancel := r.closeContext(context.Background())
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+
+	err := r.ping(ctx)
+	if err == nil {
+		r.log.Debug("liveness probe succeeded")
+		return
+	}
+	if r.closed.Get() {
+		return
+	}
+
+	r.closeDeadConn(generation, ReconnectCauseLivenessProbe, fmt.Sprintf("liveness probe failed: %s", err))
+}
+
+// closeDeadConn closes the connection of the passed generation, so a blocked read fails and
+// reconnects. The connection isn't replaced here: a reader holds the lock during a read
+func (r *ReConn) closeDeadConn(generation uint64, cause ReconnectCause, reason string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn != nil && r.generation == generation {
+		r.log.Error("close connection: " + reason)
+		r.setPendingCause(generation, cause)
+		r.conn.Close()
+	}
+}
@@ -0,0 +1,92 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSuspendDetection(t *testing.T) {
+	tests := []struct {
+		name            string
+		answerPings     bool
+		wantConnections int32
+	}{
+		{name: "alive", answerPings: true, wantConnections: 1},
+		{name: "dead", answerPings: false, wantConnections: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				connections int32
+				release     = make(chan struct{})
+			)
+			server := newWsServer(func(conn *websocket.Conn) {
+				if atomic.AddInt32(&connections, 1) == 1 && !tt.answerPings {
+					// Don't read, so pings aren't answered
+					<-release
+					return
+				}
+				go func() {
+					// Unblock the client reader
+					<-release
+					conn.Close()
+				}()
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			})
+			defer server.Close()
+
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			gaps := make(chan time.Duration, 10)
+			conn := New().SetURL(wsURL(server)).
+				SetReaderLivenessTimeout(time.Minute, false).
+				SetSuspendDetection(10*time.Second, 500*time.Millisecond).
+				SetOnResumeDetected(func(gap time.Duration) { gaps <- gap })
+			conn.scheduler = newScheduler(clock, conn.closeCh, &conn.goroutines)
+			if err := conn.Dial(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer func() {
+				close(release)
+				conn.Close()
+			}()
+
+			go func() {
+				for !conn.closed.Get() {
+					conn.ReadMessage()
+				}
+			}()
+
+			clock.waitTimer()
+			clock.Advance(2 * time.Hour)
+
+			// Wait for the probe and the possible reconnect
+			for i := 0; i < 200 && atomic.LoadInt32(&connections) != tt.wantConnections; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(time.Second)
+
+			if n := conn.SuspendsDetected(); n != 1 {
+				t.Errorf("expected 1 detected suspend, got %d", n)
+			}
+			if n := len(gaps); n != 1 {
+				t.Fatalf("callback must be called once, got %d calls", n)
+			}
+			if gap := <-gaps; gap < time.Hour {
+				t.Errorf("unexpected gap: %s", gap)
+			}
+			if n := conn.ReaderStalls(); n != 0 {
+				t.Errorf("missed watchdog runs must be skipped, got %d stalls", n)
+			}
+			if n := atomic.LoadInt32(&connections); n != tt.wantConnections {
+				t.Errorf("expected %d connections, got %d", tt.wantConnections, n)
+			}
+		})
+	}
+}
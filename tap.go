@@ -0,0 +1,140 @@
+package reconnect
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// tapBufferSize is a max capacity of a tap channel. Messages that don't fit are dropped
+const tapBufferSize = 64
+
+// tap receives copies of delivered messages
+type tap struct {
+	ch chan Message
+	// remaining is a number of messages left before the tap is detached. It's negative for
+	// a tap without a limit
+	remaining int
+}
+
+// taps contains the attached taps. 'active' allows to skip the lock on the read path
+// when there are no taps
+type taps struct {
+	dropped uint64
+	active  int32
+
+	mu     sync.Mutex
+	list   map[*tap]struct{}
+	closed bool
+}
+
+// Attach returns nil after 'CloseAll' call
+func (t *taps) Attach(n int) *tap {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	size := tapBufferSize
+	if n > 0 && n < size {
+		size = n
+	}
+	if n <= 0 {
+		n = -1
+	}
+	tp := &tap{ch: make(chan Message, size), remaining: n}
+
+	if t.list == nil {
+		t.list = make(map[*tap]struct{})
+	}
+	t.list[tp] = struct{}{}
+	atomic.StoreInt32(&t.active, int32(len(t.list)))
+	return tp
+}
+
+// Reopen allows to attach taps again after 'CloseAll' call
+func (t *taps) Reopen() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = false
+}
+
+// Detach closes the tap channel. It can be called multiple times
+func (t *taps) Detach(tp *tap) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.detachLocked(tp)
+}
+
+func (t *taps) detachLocked(tp *tap) {
+	if _, ok := t.list[tp]; !ok {
+		return
+	}
+	delete(t.list, tp)
+	close(tp.ch)
+	atomic.StoreInt32(&t.active, int32(len(t.list)))
+}
+
+// Publish copies the message to all taps. It never blocks: if a tap channel is full,
+// the copy is dropped
+func (t *taps) Publish(messageType int, data []byte) {
+	if atomic.LoadInt32(&t.active) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.list) == 0 {
+		return
+	}
+	msg := Message{Type: messageType, Data: append([]byte(nil), data...)}
+	for tp := range t.list {
+		select {
+		case tp.ch <- msg:
+		default:
+			atomic.AddUint64(&t.dropped, 1)
+		}
+		// Dropped messages count too: the limit bounds the tap lifetime
+		if tp.remaining > 0 {
+			tp.remaining--
+			if tp.remaining == 0 {
+				t.detachLocked(tp)
+			}
+		}
+	}
+}
+
+// CloseAll detaches all taps. New taps are detached immediately
+func (t *taps) CloseAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	for tp := range t.list {
+		t.detachLocked(tp)
+	}
+}
+
+// Tap attaches a temporary observer: copies of the next 'n' messages returned by 'ReadMessage'
+// and 'ReadMessageHandle' are sent to the returned channel. If 'n' isn't positive, there's no limit.
+// The channel is buffered; copies that don't fit are dropped and counted by 'TapDrops'.
+// The channel is closed after 'n' messages, a call of the returned function or 'Close'.
+// Messages sent to the channel must not be released. Multiple taps can be attached at once
+func (r *ReConn) Tap(n int) (<-chan Message, func()) {
+	tp := r.taps.Attach(n)
+	if tp == nil {
+		ch := make(chan Message)
+		close(ch)
+		return ch, func() {}
+	}
+	return tp.ch, func() { r.taps.Detach(tp) }
+}
+
+// TapDrops returns the number of message copies that were dropped because a tap channel was full
+func (r *ReConn) TapDrops() uint64 {
+	return atomic.LoadUint64(&r.taps.dropped)
+}
@@ -0,0 +1,90 @@
+package reconnect
+
+import (
+	"testing"
+)
+
+func readN(t *testing.T, conn *ReConn, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func drain(ch <-chan Message) (n int) {
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestTap(t *testing.T) {
+	t.Run("limit", func(t *testing.T) {
+		conn := newStaticReConns(1, []byte("hello"))[0]
+
+		ch, _ := conn.Tap(3)
+		readN(t, conn, 5)
+
+		msg := <-ch
+		if msg.Type != 1 || string(msg.Data) != "hello" {
+			t.Errorf("got unexpected message %d %q", msg.Type, msg.Data)
+		}
+		// The channel is closed after 3 messages
+		if n := drain(ch); n != 2 {
+			t.Errorf("expected 2 more messages, got %d", n)
+		}
+	})
+
+	t.Run("multiple", func(t *testing.T) {
+		conn := newStaticReConns(1, []byte("hello"))[0]
+
+		ch1, cancel1 := conn.Tap(0)
+		ch2, cancel2 := conn.Tap(0)
+		readN(t, conn, 2)
+		cancel1()
+		readN(t, conn, 2)
+		cancel2()
+		// Repeated calls are no-ops
+		cancel2()
+
+		if n := drain(ch1); n != 2 {
+			t.Errorf("expected 2 messages in the canceled tap, got %d", n)
+		}
+		if n := drain(ch2); n != 4 {
+			t.Errorf("expected 4 messages, got %d", n)
+		}
+	})
+
+	t.Run("drop on full", func(t *testing.T) {
+		conn := newStaticReConns(1, []byte("hello"))[0]
+
+		ch, cancel := conn.Tap(0)
+		readN(t, conn, tapBufferSize+10)
+		cancel()
+
+		if n := drain(ch); n != tapBufferSize {
+			t.Errorf("expected %d messages, got %d", tapBufferSize, n)
+		}
+		if n := conn.TapDrops(); n != 10 {
+			t.Errorf("expected 10 dropped copies, got %d", n)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		conn := newStaticReConns(1, []byte("hello"))[0]
+
+		ch, _ := conn.Tap(0)
+		readN(t, conn, 1)
+		conn.Close()
+		if n := drain(ch); n != 1 {
+			t.Errorf("expected 1 message, got %d", n)
+		}
+
+		ch, _ = conn.Tap(0)
+		if n := drain(ch); n != 0 {
+			t.Errorf("tap attached after 'Close' must be closed, got %d messages", n)
+		}
+	})
+}
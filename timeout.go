@@ -0,0 +1,45 @@
+package reconnect
+
+import (
+	"time"
+)
+
+// SetReadTimeout sets a max time 'ReadMessage' and 'ReadMessageHandle' wait for a message. The deadline
+// is set before every read, so the server must send a data message at least once per 'd'. An expired
+// deadline is a connection error: the read returns it and reconnects. 0 means no timeout.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetReadTimeout(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.readTimeout = d
+	})
+	return r
+}
+
+// SetWriteTimeout sets a max duration of a write, including internal ones. An expired deadline is
+// a connection error: the write returns it and reconnects. 0 means no timeout. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteTimeout(d time.Duration) *ReConn {
+	r.configure(func() {
+		r.writeTimeout = d
+	})
+	return r
+}
+
+// applyReadTimeout must be called before every read of 'conn'
+func (r *ReConn) applyReadTimeout(conn WsConnection) {
+	if r.readTimeout <= 0 {
+		return
+	}
+	if c, ok := conn.(interface{ SetReadDeadline(t time.Time) error }); ok {
+		_ = c.SetReadDeadline(time.Now().Add(r.readTimeout))
+	}
+}
+
+// applyWriteTimeout must be called under 'r.writeMu' before every write to 'conn'
+func (r *ReConn) applyWriteTimeout(conn WsConnection) {
+	if r.writeTimeout <= 0 {
+		return
+	}
+	if c, ok := conn.(interface{ SetWriteDeadline(t time.Time) error }); ok {
+		_ = c.SetWriteDeadline(time.Now().Add(r.writeTimeout))
+	}
+}
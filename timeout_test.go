@@ -0,0 +1,125 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReadTimeout(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		// Never send anything
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetReadTimeout(50 * time.Millisecond)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, _, err := conn.ReadMessageContext(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+		}
+		if n := atomic.LoadInt32(&connections); n != 1 {
+			t.Fatalf("the context must not cause a reconnect, connections: %d", n)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		_, _, err := conn.ReadMessage()
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("expected a timeout error, got: %v", err)
+		}
+		if n := atomic.LoadInt32(&connections); n != 2 {
+			t.Fatalf("the deadline must cause a reconnect, connections: %d", n)
+		}
+	})
+}
+
+func TestWriteTimeout(t *testing.T) {
+	var connections int32
+	done := make(chan struct{})
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		// Never read, so the client's writes block when the socket buffers are full
+		<-done
+	})
+	defer server.Close()
+	defer close(done)
+
+	conn := New().SetURL(wsURL(server)).SetWriteTimeout(100 * time.Millisecond)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	payload := make([]byte, 1<<20)
+	var err error
+	for i := 0; i < 256 && err == nil; i++ {
+		err = conn.WriteMessage(websocket.BinaryMessage, payload)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout error, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&connections); n != 2 {
+		t.Fatalf("the deadline must cause a reconnect, connections: %d", n)
+	}
+}
+
+func TestWriteMessageContextCanceled(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := conn.WriteMessageContext(ctx, websocket.TextMessage, []byte("hello")); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+
+	// The connection is still usable
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected read: %q, %v", data, err)
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("expected no reconnects, connections: %d", n)
+	}
+}
@@ -0,0 +1,81 @@
+package reconnect
+
+import (
+	"context"
+	"runtime/trace"
+	"strconv"
+	"sync/atomic"
+)
+
+// Names of 'runtime/trace' tasks and regions
+const (
+	traceTaskConnection = "ws.connection"
+
+	traceRegionRead      = "ws.read"
+	traceRegionFilter    = "ws.read.filter"
+	traceRegionWrite     = "ws.write"
+	traceRegionStage     = "ws.write.stage"
+	traceRegionWriteConn = "ws.write.conn"
+	traceRegionReconnect = "ws.reconnect"
+)
+
+var traceRegionsEnabled int32
+
+// SetTraceRegions enables 'runtime/trace' instrumentation of all instances: every connection
+// generation is a task, major stages of the read and write paths are regions, so they show up
+// in 'go tool trace'. It's disabled by default; disabled instrumentation costs one atomic load
+func SetTraceRegions(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&traceRegionsEnabled, v)
+}
+
+func traceRegionsOn() bool {
+	return atomic.LoadInt32(&traceRegionsEnabled) == 1
+}
+
+func endNothing() {}
+
+// traceRegion starts a region. The returned function ends it and must be called
+// by the same goroutine
+func traceRegion(ctx context.Context, name string) (end func()) {
+	if !traceRegionsOn() {
+		return endNothing
+	}
+	return trace.StartRegion(ctx, name).End
+}
+
+// traceConnRegion starts a region of the current connection task. It must be called under 'r.mu'
+func (r *ReConn) traceConnRegion(name string) (end func()) {
+	if !traceRegionsOn() {
+		return endNothing
+	}
+	ctx := r.traceCtx
+	if ctx == nil {
+		// Tracing was enabled after the connect
+		ctx = context.Background()
+	}
+	return trace.StartRegion(ctx, name).End
+}
+
+// startConnTrace starts a task for the current connection. It must be called under 'r.mu'
+// after the connection is published
+func (r *ReConn) startConnTrace() {
+	r.endConnTrace()
+	if !traceRegionsOn() {
+		return
+	}
+	r.traceCtx, r.traceTask = trace.NewTask(context.Background(), traceTaskConnection)
+	trace.Log(r.traceCtx, "generation", strconv.FormatUint(r.generation, 10))
+}
+
+// endConnTrace ends the task of the current connection. It must be called under 'r.mu'
+func (r *ReConn) endConnTrace() {
+	if r.traceTask == nil {
+		return
+	}
+	r.traceTask.End()
+	r.traceCtx, r.traceTask = nil, nil
+}
@@ -0,0 +1,73 @@
+package reconnect
+
+import (
+	"bytes"
+	"runtime/trace"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTraceRegions(t *testing.T) {
+	names := []string{traceTaskConnection, traceRegionRead, traceRegionFilter, traceRegionWrite, traceRegionWriteConn}
+
+	run := func(t *testing.T, enabled bool) []byte {
+		if trace.IsEnabled() {
+			t.Skip("tracing is already enabled")
+		}
+
+		SetTraceRegions(enabled)
+		defer SetTraceRegions(false)
+
+		server := newWsServer(func(conn *websocket.Conn) {
+			for {
+				typ, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if err := conn.WriteMessage(typ, data); err != nil {
+					return
+				}
+			}
+		})
+		defer server.Close()
+
+		var buf bytes.Buffer
+		if err := trace.Start(&buf); err != nil {
+			t.Fatalf("couldn't start tracing: %s", err)
+		}
+
+		conn := New().SetURL(wsURL(server))
+		if err := conn.Dial(); err != nil {
+			trace.Stop()
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			t.Errorf("unexpected write error: %s", err)
+		}
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Errorf("unexpected read error: %s", err)
+		}
+		conn.Close()
+
+		trace.Stop()
+		return buf.Bytes()
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		data := run(t, true)
+		for _, name := range names {
+			if !bytes.Contains(data, []byte(name)) {
+				t.Errorf("trace doesn't contain %q", name)
+			}
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		data := run(t, false)
+		for _, name := range names {
+			if bytes.Contains(data, []byte(name)) {
+				t.Errorf("trace contains %q", name)
+			}
+		}
+	})
+}
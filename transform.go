@@ -0,0 +1,107 @@
+package reconnect
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TransformFunc transforms a data message, for example, decompresses it or unwraps it from an envelope
+type TransformFunc func(messageType int, data []byte) (int, []byte, error)
+
+// TransformError is returned by reads and writes when a transform fails (see 'SetReadTransform' and
+// 'SetWriteTransform'). The connection is healthy, so the error doesn't cause a reconnect. It matches
+// 'ErrTransform' and unwraps to the error of the transform
+type TransformError struct {
+	Err error
+}
+
+func (e *TransformError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTransform, e.Err)
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TransformError) Is(target error) bool {
+	return target == ErrTransform
+}
+
+// SetReadTransform sets a transform of read data messages. It's applied by 'ReadMessage', 'ReadMessageHandle',
+// 'ReadJSON' and by the connection passed to the subscribe handler, before the message is seen by other
+// features (acks, taps, auto responders and etc.). If the transform fails, the message is consumed and
+// '*TransformError' is returned. Streaming reads ('NextReader', 'StreamLines') return raw messages.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetReadTransform(f TransformFunc) *ReConn {
+	r.configure(func() {
+		r.readTransform = f
+	})
+	return r
+}
+
+// SetWriteTransform sets a transform of written data messages. It's applied to user writes, replayed
+// subscriptions, flushed buffered messages and writes of the subscribe handler. If the transform fails,
+// nothing is written and '*TransformError' is returned, a buffered message is dropped. Streaming writes
+// ('NextWriter') aren't transformed. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteTransform(f TransformFunc) *ReConn {
+	r.configure(func() {
+		r.writeTransform = f
+	})
+	return r
+}
+
+// transformRead applies the read transform
+func (r *ReConn) transformRead(messageType int, data []byte) (int, []byte, error) {
+	return applyTransform(r.readTransform, messageType, data)
+}
+
+// transformWrite applies the write transform. It's the first write stage (see pipeline.go)
+func (r *ReConn) transformWrite(messageType int, data []byte) (int, []byte, error) {
+	return applyTransform(r.writeTransform, messageType, data)
+}
+
+// applyTransform applies 'f' to data messages. Control frames are returned as is
+func applyTransform(f TransformFunc, messageType int, data []byte) (int, []byte, error) {
+	if f == nil || (messageType != websocket.TextMessage && messageType != websocket.BinaryMessage) {
+		return messageType, data, nil
+	}
+	newType, newData, err := f(messageType, data)
+	if err != nil {
+		return messageType, nil, &TransformError{Err: err}
+	}
+	return newType, newData, nil
+}
+
+// isTransformError reports whether an error of a read or a write was caused by a transform
+func isTransformError(err error) bool {
+	_, ok := err.(*TransformError)
+	return ok
+}
+
+// transformConn is passed to the subscribe handler when transforms are set
+type transformConn struct {
+	WsConnection
+	r *ReConn
+}
+
+func (c transformConn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType, data, err = c.WsConnection.ReadMessage()
+	if err != nil {
+		return messageType, data, err
+	}
+	return c.r.transformRead(messageType, data)
+}
+
+func (c transformConn) WriteMessage(messageType int, data []byte) error {
+	messageType, data, err := c.r.transformWrite(messageType, data)
+	if err != nil {
+		return err
+	}
+	return c.WsConnection.WriteMessage(messageType, data)
+}
+
+func (c transformConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return writeControl(c.WsConnection, messageType, data, deadline)
+}
@@ -0,0 +1,115 @@
+package reconnect
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	envelopePrefix      = []byte("env:")
+	errMissingEnvelope  = errors.New("missing envelope")
+	errRejectedByPolicy = errors.New("rejected")
+)
+
+// wrapEnvelope and unwrapEnvelope are test transforms
+func wrapEnvelope(messageType int, data []byte) (int, []byte, error) {
+	if string(data) == "reject" {
+		return 0, nil, errRejectedByPolicy
+	}
+	return messageType, append(append([]byte(nil), envelopePrefix...), data...), nil
+}
+
+func unwrapEnvelope(messageType int, data []byte) (int, []byte, error) {
+	if !bytes.HasPrefix(data, envelopePrefix) {
+		return 0, nil, errMissingEnvelope
+	}
+	return messageType, data[len(envelopePrefix):], nil
+}
+
+func TestTransforms(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().
+		SetDialFunc(dialer.Dial).
+		SetReadTransform(unwrapEnvelope).
+		SetWriteTransform(wrapEnvelope).
+		SetSubscribeHandler(func(conn WsConnection) error {
+			return conn.WriteMessage(websocket.TextMessage, []byte("auth"))
+		})
+	if err := conn.AddSubscription(websocket.TextMessage, []byte("sub")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.WriteJSON("json"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Write errors don't cause a reconnect
+	err := conn.WriteMessage(websocket.TextMessage, []byte("reject"))
+	if !errors.Is(err, ErrTransform) || !errors.Is(err, errRejectedByPolicy) || CodeOf(err) != CodeTransform {
+		t.Errorf("expected the transform error, got %v", err)
+	}
+
+	written := dialer.Last().Written()
+	want := []string{"env:auth", "env:sub", "env:hello", `env:"json"`}
+	if len(written) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), written)
+	}
+	for i := range want {
+		if string(written[i].Data) != want[i] {
+			t.Errorf("expected message %d to be %q, got %q", i, want[i], written[i].Data)
+		}
+	}
+
+	// Read errors don't cause a reconnect, the message is consumed
+	dialer.Last().Push(websocket.TextMessage, []byte("raw"))
+	dialer.Last().Push(websocket.TextMessage, []byte("env:msg"))
+	dialer.Last().Push(websocket.TextMessage, []byte(`env:"json"`))
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrTransform) || !errors.Is(err, errMissingEnvelope) {
+		t.Errorf("expected the transform error, got %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "msg" {
+		t.Errorf("unexpected message: %q, error: %v", data, err)
+	}
+	var v string
+	if err := conn.ReadJSON(&v); err != nil || v != "json" {
+		t.Errorf("unexpected json: %q, error: %v", v, err)
+	}
+
+	if n := dialer.Dials(); n != 1 {
+		t.Errorf("expected 1 dial, got %d", n)
+	}
+}
+
+func TestTransformReadMessageHandle(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetReadTransform(unwrapEnvelope)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	dialer.Last().Push(websocket.BinaryMessage, []byte("raw"))
+	dialer.Last().Push(websocket.BinaryMessage, []byte("env:msg"))
+	if _, err := conn.ReadMessageHandle(); !errors.Is(err, ErrTransform) {
+		t.Errorf("expected the transform error, got %v", err)
+	}
+	msg, err := conn.ReadMessageHandle()
+	if err != nil || string(msg.Data) != "msg" {
+		t.Fatalf("unexpected message: %+v, error: %v", msg, err)
+	}
+	msg.Release()
+
+	if n := dialer.Dials(); n != 1 {
+		t.Errorf("expected 1 dial, got %d", n)
+	}
+}
@@ -0,0 +1,147 @@
+package reconnect
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// trendBucketWidth is a width of a bucket of reconnect trend counters
+	trendBucketWidth = time.Minute
+	// trendBuckets is a number of buckets that cover the longest window (24h)
+	trendBuckets = int(24 * time.Hour / trendBucketWidth)
+)
+
+// ReconnectCause classifies why an established connection was lost
+type ReconnectCause string
+
+const (
+	// ReconnectCauseServerClose means the server sent a close frame
+	ReconnectCauseServerClose ReconnectCause = "server_close"
+	// ReconnectCauseNetworkError means a read or write failed without a close frame
+	ReconnectCauseNetworkError ReconnectCause = "network_error"
+	// ReconnectCauseKeepAlive means no pong was received in time (see 'SetKeepAlive')
+	ReconnectCauseKeepAlive ReconnectCause = "keepalive"
+	// ReconnectCauseLivenessProbe means the liveness probe after a suspend failed (see 'SetSuspendDetection')
+	ReconnectCauseLivenessProbe ReconnectCause = "liveness_probe"
+	// ReconnectCauseMessageTooBig means a message exceeded the limit set by 'SetMaxMessageSize'
+	ReconnectCauseMessageTooBig ReconnectCause = "message_too_big"
+	// ReconnectCauseConfigUpdate means the connection was closed to apply a new config (see 'UpdateConfig')
+	ReconnectCauseConfigUpdate ReconnectCause = "config_update"
+	// ReconnectCauseForced means the connection was closed by 'ForceReconnect'
+	ReconnectCauseForced ReconnectCause = "forced"
+	// ReconnectCauseReadInactivity means no messages and pongs were read in time (see 'SetReadInactivityTimeout')
+	ReconnectCauseReadInactivity ReconnectCause = "read_inactivity"
+)
+
+// WindowCounts contains numbers of events in the last time windows. Windows are rounded up
+// to whole minutes
+type WindowCounts struct {
+	Last5m  uint64
+	Last1h  uint64
+	Last24h uint64
+}
+
+// reconnectTrend counts reconnects per cause in per-minute buckets. Buckets are rotated by the scheduler
+type reconnectTrend struct {
+	mu sync.Mutex
+	// current is an index of the bucket of the current minute
+	current int
+	counts  map[ReconnectCause]*[trendBuckets]uint64
+}
+
+func (t *reconnectTrend) Add(cause ReconnectCause) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counts == nil {
+		t.counts = make(map[ReconnectCause]*[trendBuckets]uint64)
+	}
+	buckets, ok := t.counts[cause]
+	if !ok {
+		buckets = &[trendBuckets]uint64{}
+		t.counts[cause] = buckets
+	}
+	buckets[t.current]++
+}
+
+// Rotate starts a new bucket. The oldest one is dropped
+func (t *reconnectTrend) Rotate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.current = (t.current + 1) % trendBuckets
+	for _, buckets := range t.counts {
+		buckets[t.current] = 0
+	}
+}
+
+func (t *reconnectTrend) Snapshot() map[ReconnectCause]WindowCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	const (
+		buckets5m = int(5 * time.Minute / trendBucketWidth)
+		buckets1h = int(time.Hour / trendBucketWidth)
+	)
+
+	res := make(map[ReconnectCause]WindowCounts, len(t.counts))
+	for cause, buckets := range t.counts {
+		var counts WindowCounts
+		for i := 0; i < trendBuckets; i++ {
+			n := buckets[(t.current-i+trendBuckets)%trendBuckets]
+			if i < buckets5m {
+				counts.Last5m += n
+			}
+			if i < buckets1h {
+				counts.Last1h += n
+			}
+			counts.Last24h += n
+		}
+		res[cause] = counts
+	}
+	return res
+}
+
+// ReconnectTrend returns numbers of lost connections per cause in the last 5 minutes, hour and 24 hours.
+// A shift of the mix (for example, keepalive misses growing relative to server closes) can precede
+// a bigger incident. Causes without reconnects in the last 24 hours can be omitted
+func (r *ReConn) ReconnectTrend() map[ReconnectCause]WindowCounts {
+	return r.trend.Snapshot()
+}
+
+// startReconnectTrend must be called once after 'r.dialed' is set
+func (r *ReConn) startReconnectTrend() {
+	r.scheduler.Every(trendBucketWidth, r.trend.Rotate)
+}
+
+// pendingCause is a cause of a connection closed by the package itself
+type pendingCause struct {
+	generation uint64
+	cause      ReconnectCause
+}
+
+// setPendingCause records the cause of closing the connection of the passed generation. It's used
+// by 'reconnectCause' when the failed read or write reconnects
+func (r *ReConn) setPendingCause(generation uint64, cause ReconnectCause) {
+	r.pendingCause.Store(pendingCause{generation: generation, cause: cause})
+}
+
+// reconnectCause classifies the error that caused the reconnect. It must be called under 'r.mu'
+func (r *ReConn) reconnectCause(err error) ReconnectCause {
+	if p, ok := r.pendingCause.Load().(pendingCause); ok && p.generation == r.generation {
+		return p.cause
+	}
+	if errors.Is(err, ErrMessageTooBig) {
+		return ReconnectCauseMessageTooBig
+	}
+	// gorilla/websocket reports a connection closed without a close frame as 'CloseAbnormalClosure'
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Code != websocket.CloseAbnormalClosure {
+		return ReconnectCauseServerClose
+	}
+	return ReconnectCauseNetworkError
+}
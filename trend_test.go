@@ -0,0 +1,138 @@
+package reconnect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReconnectTrendRollover(t *testing.T) {
+	var trend reconnectTrend
+	trend.Add(ReconnectCauseKeepAlive)
+	trend.Add(ReconnectCauseServerClose)
+
+	rotate := func(n int) {
+		for i := 0; i < n; i++ {
+			trend.Rotate()
+		}
+	}
+	check := func(want WindowCounts) {
+		t.Helper()
+		if got := trend.Snapshot()[ReconnectCauseKeepAlive]; got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+
+	check(WindowCounts{Last5m: 1, Last1h: 1, Last24h: 1})
+
+	rotate(4) // 4m
+	check(WindowCounts{Last5m: 1, Last1h: 1, Last24h: 1})
+	rotate(1) // 5m
+	check(WindowCounts{Last5m: 0, Last1h: 1, Last24h: 1})
+
+	trend.Add(ReconnectCauseKeepAlive)
+	check(WindowCounts{Last5m: 1, Last1h: 2, Last24h: 2})
+
+	rotate(54) // 59m
+	check(WindowCounts{Last5m: 0, Last1h: 2, Last24h: 2})
+	rotate(1) // 1h
+	check(WindowCounts{Last5m: 0, Last1h: 1, Last24h: 2})
+	rotate(5) // 1h5m
+	check(WindowCounts{Last5m: 0, Last1h: 0, Last24h: 2})
+
+	rotate(trendBuckets - 65 - 1) // 23h59m
+	check(WindowCounts{Last5m: 0, Last1h: 0, Last24h: 2})
+	rotate(1) // 24h: the first bucket is reused
+	check(WindowCounts{Last5m: 0, Last1h: 0, Last24h: 1})
+
+	trend.Add(ReconnectCauseKeepAlive)
+	check(WindowCounts{Last5m: 1, Last1h: 1, Last24h: 2})
+	rotate(5) // 24h5m
+	check(WindowCounts{Last5m: 0, Last1h: 1, Last24h: 1})
+
+	if got := trend.Snapshot()[ReconnectCauseServerClose]; got != (WindowCounts{}) {
+		t.Errorf("old reconnects must be dropped, got %+v", got)
+	}
+}
+
+func TestReconnectTrendScheduler(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	conn := New()
+	conn.scheduler = newScheduler(clock, conn.closeCh, &conn.goroutines)
+	conn.startReconnectTrend()
+	defer close(conn.closeCh)
+
+	conn.trend.Add(ReconnectCauseNetworkError)
+	for i := 0; i < 5; i++ {
+		clock.waitTimer()
+		if got := conn.ReconnectTrend()[ReconnectCauseNetworkError].Last5m; got != 1 {
+			t.Fatalf("minute %d: expected 1 reconnect in the last 5m, got %d", i, got)
+		}
+		clock.Advance(trendBucketWidth)
+	}
+	clock.waitTimer()
+
+	if got := conn.ReconnectTrend()[ReconnectCauseNetworkError]; got != (WindowCounts{Last1h: 1, Last24h: 1}) {
+		t.Errorf("unexpected counts after 5 minutes: %+v", got)
+	}
+}
+
+func TestReconnectCauses(t *testing.T) {
+	var connections int32
+	server := newWsServer(func(conn *websocket.Conn) {
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "restart"))
+		case 2:
+			// Drop the connection without a close frame
+		default:
+			conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+			conn.ReadMessage()
+		}
+	})
+	defer server.Close()
+
+	var reasons []DisconnectReason
+	conn := New().SetURL(wsURL(server))
+	conn.SetSubscribeHandler(func(WsConnection) error {
+		conn.OnThisConnectionClosed(func(reason DisconnectReason) { reasons = append(reasons, reason) })
+		return nil
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := conn.ReadMessage(); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected read result: %q, %v", data, err)
+	}
+
+	// Close the current connection to apply a config
+	conn.UpdateConfig(func(c *MutableConfig) { c.ForceReconnect = true })
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	want := []ReconnectCause{ReconnectCauseServerClose, ReconnectCauseNetworkError, ReconnectCauseConfigUpdate}
+	trend := conn.ReconnectTrend()
+	for _, cause := range want {
+		if got := trend[cause]; got != (WindowCounts{Last5m: 1, Last1h: 1, Last24h: 1}) {
+			t.Errorf("%s: unexpected counts: %+v", cause, got)
+		}
+	}
+	if len(reasons) != len(want) {
+		t.Fatalf("expected %d cleanups, got %d", len(want), len(reasons))
+	}
+	for i, cause := range want {
+		if reasons[i].Cause != cause {
+			t.Errorf("cleanup %d: expected cause %s, got %s", i, cause, reasons[i].Cause)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package reconnect
+
+import (
+	"fmt"
+)
+
+// SetURLs sets a list of urls to connect to, for example, a primary endpoint and backups.
+// The first url is used by 'Dial'. Every failed connect attempt advances to the next url
+// in the list; a working url is kept until an attempt to connect to it fails.
+// The list overrides 'SetURL' and the url of 'UpdateConfig'. After 'Dial' call it does nothing
+func (r *ReConn) SetURLs(urls ...string) *ReConn {
+	r.configure(func() {
+		r.urls = append([]string(nil), urls...)
+		r.urlIndex = 0
+		if len(urls) > 0 {
+			r.url = urls[0]
+		}
+	})
+	return r
+}
+
+// CurrentURL returns the url of the current connection. It returns an empty string
+// if there's no connection
+func (r *ReConn) CurrentURL() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil {
+		return ""
+	}
+	return r.connectedURL
+}
+
+// SetURLFunc sets a callback called before every dial, including reconnects, to build the url of
+// the attempt, for example, with a fresh token in the query. The url overrides 'SetURLs', 'SetURL'
+// and the url of 'UpdateConfig'. An error fails the attempt with 'ErrDial' and 'ErrURLFunc', and
+// the attempt is retried as usual. After 'Dial' call it does nothing
+func (r *ReConn) SetURLFunc(f URLFunc) *ReConn {
+	r.configure(func() {
+		r.urlFunc = f
+	})
+	return r
+}
+
+// callURLFunc calls the url callback. It returns an empty string if the callback isn't set.
+// It must be called without holding 'r.mu'
+func (r *ReConn) callURLFunc() (string, error) {
+	if r.urlFunc == nil {
+		return "", nil
+	}
+	url, err := r.urlFunc()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrURLFunc, err)
+	}
+	return url, nil
+}
+
+// attemptConfig returns the config for a connect attempt with the url returned by the url callback
+// or selected from the url list. It must be called under 'r.mu'
+func (r *ReConn) attemptConfig(cfg *MutableConfig, funcURL string) *MutableConfig {
+	switch {
+	case funcURL != "":
+		res := *cfg
+		res.URL = funcURL
+		return &res
+	case len(r.urls) != 0:
+		res := *cfg
+		res.URL = r.urls[r.urlIndex]
+		return &res
+	default:
+		return cfg
+	}
+}
+
+// advanceURL selects the next url of the list after a failed attempt. It must be called under 'r.mu'
+func (r *ReConn) advanceURL() {
+	if len(r.urls) < 2 {
+		return
+	}
+	r.urlIndex = (r.urlIndex + 1) % len(r.urls)
+	r.log.Info(fmt.Sprintf("fail over to '%s'", r.urls[r.urlIndex]))
+}
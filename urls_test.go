@@ -0,0 +1,147 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSetURLs(t *testing.T) {
+	type server struct {
+		*httptest.Server
+		reject      int32
+		connections int32
+		conns       chan *websocket.Conn
+	}
+	newServer := func(reject bool) *server {
+		s := &server{conns: make(chan *websocket.Conn, 10)}
+		if reject {
+			s.reject = 1
+		}
+		upgrader := websocket.Upgrader{}
+		s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&s.reject) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.connections, 1)
+			s.conns <- conn
+			conn.ReadMessage()
+		}))
+		return s
+	}
+	primary, backup1, backup2 := newServer(true), newServer(false), newServer(false)
+	defer primary.Close()
+	defer backup1.Close()
+	defer backup2.Close()
+
+	conn := New().SetURLs(wsURL(primary.Server), wsURL(backup1.Server), wsURL(backup2.Server)).SetRetryConnect(true)
+	if got := conn.CurrentURL(); got != "" {
+		t.Errorf("expected no url before 'Dial', got %q", got)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// The primary url failed
+	if got, want := conn.CurrentURL(), wsURL(backup1.Server); got != want {
+		t.Errorf("got url %q, want %q", got, want)
+	}
+	if resp := conn.GetDialResponse(); resp == nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("dial response must belong to the connected url, got %+v", resp)
+	}
+
+	// The working url is kept
+	(<-backup1.conns).Close()
+	conn.ReadMessage()
+	if got, want := conn.CurrentURL(), wsURL(backup1.Server); got != want {
+		t.Errorf("got url %q, want %q", got, want)
+	}
+
+	// The next url is used after a failure
+	atomic.StoreInt32(&backup1.reject, 1)
+	(<-backup1.conns).Close()
+	conn.ReadMessage()
+	if got, want := conn.CurrentURL(), wsURL(backup2.Server); got != want {
+		t.Errorf("got url %q, want %q", got, want)
+	}
+
+	if n := atomic.LoadInt32(&primary.connections); n != 0 {
+		t.Errorf("expected no connections to the primary url, got %d", n)
+	}
+	if n := atomic.LoadInt32(&backup1.connections); n != 2 {
+		t.Errorf("expected 2 connections to the first backup url, got %d", n)
+	}
+
+	// Unblock the server handler
+	(<-backup2.conns).Close()
+}
+
+func TestSetURLFunc(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(r.URL.Query().Get("token")))
+	}))
+	defer server.Close()
+
+	var (
+		log   recordLogger
+		calls int32
+	)
+	conn := New().
+		SetURL("ws://127.0.0.1:1").
+		SetLogger(&log).
+		SetURLFunc(func() (string, error) {
+			n := atomic.AddInt32(&calls, 1)
+			if n == 2 {
+				return "", errors.New("signer is unavailable")
+			}
+			return fmt.Sprintf("%s?token=token-%d", wsURL(server), n), nil
+		})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	want := wsURL(server) + "?token=token-1"
+	if got := conn.CurrentURL(); got != want {
+		t.Errorf("got url %q, want %q", got, want)
+	}
+	if !log.Contains(fmt.Sprintf("connect to '%s'", want)) {
+		t.Error("the url of the attempt must be logged")
+	}
+	if _, data, _ := conn.ReadMessage(); string(data) != "token-1" {
+		t.Errorf("got %q, want %q", data, "token-1")
+	}
+
+	// The server dropped the connection, the reconnect fails because of the callback
+	_, _, err := conn.ReadMessage()
+	if !errors.Is(err, ErrURLFunc) || !errors.Is(err, ErrDial) {
+		t.Fatalf("expected ErrURLFunc and ErrDial, got: %v", err)
+	}
+
+	// The next attempt gets a fresh url. The read that triggered the reconnect returns an error
+	conn.ReadMessage()
+	if _, data, _ := conn.ReadMessage(); string(data) != "token-3" {
+		t.Errorf("got %q, want %q", data, "token-3")
+	}
+	if got, want := conn.CurrentURL(), wsURL(server)+"?token=token-3"; got != want {
+		t.Errorf("got url %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package reconnect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// handshakeToReconnectRatio is a ratio of handshake and reconnect timeouts
+// above which the configuration is considered likely unintended
+const handshakeToReconnectRatio = 10
+
+// ConfigFinding describes a likely unintended configuration
+type ConfigFinding struct {
+	Message string
+}
+
+func (f ConfigFinding) String() string {
+	return f.Message
+}
+
+// SetStrictConfig makes 'Dial' return 'ErrInvalidConfig' when 'Validate' reports any findings.
+// Otherwise findings are only logged as warnings (see 'WarnLogger'). After 'Dial' call it does nothing
+func (r *ReConn) SetStrictConfig(strict bool) *ReConn {
+	r.configure(func() {
+		r.strictConfig = strict
+	})
+	return r
+}
+
+// Validate checks the configuration for likely unintended values. It doesn't dial
+func (r *ReConn) Validate() []ConfigFinding {
+	var findings []ConfigFinding
+	add := func(format string, args ...interface{}) {
+		findings = append(findings, ConfigFinding{Message: fmt.Sprintf(format, args...)})
+	}
+
+	if r.url == "" && r.urlFunc == nil {
+		add("url is empty")
+	}
+
+	switch {
+	case r.handshakeTimeout > 0 && r.reconnectTimeout <= 0:
+		add(
+			"reconnect timeout is zero, but handshake timeout is %s: a down host is retried every %s",
+			r.handshakeTimeout, r.handshakeTimeout,
+		)
+	case r.reconnectTimeout > 0 && r.handshakeTimeout >= handshakeToReconnectRatio*r.reconnectTimeout:
+		add(
+			"handshake timeout (%s) is much greater than reconnect timeout (%s): a down host is retried every %s",
+			r.handshakeTimeout, r.reconnectTimeout, r.handshakeTimeout+r.reconnectTimeout,
+		)
+	}
+
+	return findings
+}
+
+// checkConfig logs findings of 'Validate'. It returns an error only in strict mode
+func (r *ReConn) checkConfig() error {
+	findings := r.Validate()
+	if len(findings) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(findings))
+	for _, f := range findings {
+		r.warn("config: " + f.Message)
+		msgs = append(msgs, f.Message)
+	}
+
+	if r.strictConfig {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(msgs, "; "))
+	}
+	return nil
+}
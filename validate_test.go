@@ -0,0 +1,69 @@
+package reconnect
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name             string
+		handshakeTimeout time.Duration
+		reconnectTimeout time.Duration
+		findings         int
+	}{
+		{name: "ok", handshakeTimeout: 5 * time.Second, reconnectTimeout: time.Second, findings: 0},
+		{name: "no timeouts", findings: 0},
+		{name: "zero reconnect", handshakeTimeout: 5 * time.Second, findings: 1},
+		{name: "long handshake", handshakeTimeout: 30 * time.Second, reconnectTimeout: time.Second, findings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := New().
+				SetURL("ws://localhost").
+				SetHandshakeTimeout(tt.handshakeTimeout).
+				SetReconnectTimeout(tt.reconnectTimeout)
+
+			if findings := conn.Validate(); len(findings) != tt.findings {
+				t.Errorf("expected %d findings, got %v", tt.findings, findings)
+			}
+		})
+	}
+}
+
+// warnLogger records warnings separately
+type warnLogger struct {
+	recordLogger
+	warnings recordLogger
+}
+
+func (l *warnLogger) Warn(msg string) { l.warnings.record(msg) }
+
+func TestValidateWarnings(t *testing.T) {
+	var (
+		dialer FakeDialer
+		log    warnLogger
+	)
+	conn := New().SetURL("ws://fake").SetDialFunc(dialer.Dial).SetLogger(&log).
+		SetHandshakeTimeout(time.Second)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("findings must not fail 'Dial' by default: %s", err)
+	}
+	defer conn.Close()
+
+	if !log.warnings.Contains("config: reconnect timeout is zero") {
+		t.Error("findings must be logged as warnings")
+	}
+	if log.Contains("config:") {
+		t.Error("findings must not be logged with other levels")
+	}
+}
+
+func TestStrictConfig(t *testing.T) {
+	conn := New().SetHandshakeTimeout(time.Second).SetStrictConfig(true)
+
+	if err := conn.Dial(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected 'ErrInvalidConfig', got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigPollFunc returns the desired config. A nil config means "no changes".
+// Nil handlers keep the current ones
+type ConfigPollFunc func(ctx context.Context) (*MutableConfig, error)
+
+// configField describes a field of 'MutableConfig' for 'WatchConfig'
+type configField struct {
+	name  string
+	equal func(a, b *MutableConfig) bool
+	// requiresReconnect is true if the field affects an established connection:
+	// the new value must not wait for the next natural reconnect
+	requiresReconnect bool
+}
+
+var configFields = []configField{
+	{
+		name:              "url",
+		equal:             func(a, b *MutableConfig) bool { return a.URL == b.URL },
+		requiresReconnect: true,
+	},
+	{
+		name:              "header",
+		equal:             func(a, b *MutableConfig) bool { return headersEqual(a.Header, b.Header) },
+		requiresReconnect: true,
+	},
+	{
+		name:  "ping_handler",
+		equal: func(a, b *MutableConfig) bool { return sameFunc(a.PingHandler, b.PingHandler) },
+	},
+	{
+		name:  "subscribe_handler",
+		equal: func(a, b *MutableConfig) bool { return sameFunc(a.SubscribeHandler, b.SubscribeHandler) },
+	},
+	{
+		name:  "handshake_timeout",
+		equal: func(a, b *MutableConfig) bool { return a.HandshakeTimeout == b.HandshakeTimeout },
+	},
+	{
+		name:  "reconnect_timeout",
+		equal: func(a, b *MutableConfig) bool { return a.ReconnectTimeout == b.ReconnectTimeout },
+	},
+}
+
+// diffConfig returns names of changed fields and whether any of them requires a reconnect
+func diffConfig(cur, next *MutableConfig) (changed []string, reconnect bool) {
+	for _, f := range configFields {
+		if f.equal(cur, next) {
+			continue
+		}
+		changed = append(changed, f.name)
+		reconnect = reconnect || f.requiresReconnect
+	}
+	return changed, reconnect
+}
+
+func headersEqual(a, b map[string][]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// sameFunc reports whether both functions are nil or point to the same code.
+// Closures created by the same literal are considered equal
+func sameFunc(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.IsNil() || vb.IsNil() {
+		return va.IsNil() == vb.IsNil()
+	}
+	return va.Pointer() == vb.Pointer()
+}
+
+// WatchConfig calls 'poll' every 'interval' until 'ctx' is canceled or the connection is closed.
+// A polled config is compared with the current one and all changes are applied at once as
+// with 'UpdateConfig'. If the url or headers changed (or 'ForceReconnect' is set), the current
+// connection is closed once per change set; other fields take effect at the next connect.
+// Poll errors are logged, the current config is kept.
+// It returns 'ErrNotDialed' before 'Dial' call and 'ErrInvalidConfig' if 'interval' isn't positive
+func (r *ReConn) WatchConfig(ctx context.Context, poll ConfigPollFunc, interval time.Duration) error {
+	if !r.dialed.Get() {
+		return ErrNotDialed
+	}
+	if interval <= 0 {
+		return fmt.Errorf("%w: config watch interval must be positive, got %s", ErrInvalidConfig, interval)
+	}
+
+	stop := r.closeSignal()
+	r.goroutines.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			r.pollConfig(ctx, poll, interval)
+		}
+	})
+	return nil
+}
+
+// pollConfig polls and applies a config. A poll is bounded by 'timeout'
+func (r *ReConn) pollConfig(ctx context.Context, poll ConfigPollFunc, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	next, err := poll(ctx)
+	if ctx.Err() != nil {
+		// The watcher was stopped or the poll timed out
+		return
+	}
+	if err != nil {
+		r.log.Error(fmt.Sprintf("poll config: %s", err))
+		return
+	}
+	if next == nil {
+		return
+	}
+	r.applyConfig(next)
+}
+
+// applyConfig replaces the current config with 'next'
+func (r *ReConn) applyConfig(next *MutableConfig) {
+	var changed []string
+	r.UpdateConfig(func(c *MutableConfig) {
+		cfg := next.clone()
+		if cfg.PingHandler == nil {
+			cfg.PingHandler = c.PingHandler
+		}
+		if cfg.SubscribeHandler == nil {
+			cfg.SubscribeHandler = c.SubscribeHandler
+		}
+
+		var reconnect bool
+		changed, reconnect = diffConfig(c, cfg)
+
+		*c = *cfg
+		c.ForceReconnect = reconnect || next.ForceReconnect
+	})
+	if len(changed) > 0 {
+		r.log.Info("config changed: " + strings.Join(changed, ", "))
+	}
+}
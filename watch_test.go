@@ -0,0 +1,212 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWatchConfig(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		tokens  []string
+		release = make(chan struct{})
+	)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("X-Token"))
+		mu.Unlock()
+
+		go func() {
+			// Unblock the client reader
+			<-release
+			conn.Close()
+		}()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	getTokens := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), tokens...)
+	}
+	waitTokens := func(n int) []string {
+		for i := 0; i < 200 && len(getTokens()) < n; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		// Catch extra reconnects
+		time.Sleep(100 * time.Millisecond)
+		return getTokens()
+	}
+	waitNoReconnect := func() []string {
+		time.Sleep(100 * time.Millisecond)
+		return getTokens()
+	}
+
+	var polled struct {
+		sync.Mutex
+		cfg *MutableConfig
+		err error
+	}
+	setPolled := func(cfg *MutableConfig, err error) {
+		polled.Lock()
+		polled.cfg, polled.err = cfg, err
+		polled.Unlock()
+	}
+	poll := func(context.Context) (*MutableConfig, error) {
+		polled.Lock()
+		defer polled.Unlock()
+		return polled.cfg, polled.err
+	}
+
+	conn := New().SetURL(wsURL(server)).SetHeader(http.Header{"X-Token": {"a"}})
+	if err := conn.WatchConfig(context.Background(), poll, time.Millisecond); err != ErrNotDialed {
+		t.Errorf("expected ErrNotDialed, got: %v", err)
+	}
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() {
+		close(release)
+		conn.Close()
+	}()
+
+	go func() {
+		for !conn.closed.Get() {
+			conn.ReadMessage()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := conn.WatchConfig(ctx, poll, 0); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got: %v", err)
+	}
+	if err := conn.WatchConfig(ctx, poll, 5*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Both classes of fields: a single reconnect
+	setPolled(&MutableConfig{
+		URL:              wsURL(server),
+		Header:           http.Header{"X-Token": {"b"}},
+		HandshakeTimeout: time.Second,
+		ReconnectTimeout: 10 * time.Millisecond,
+	}, nil)
+	if got := waitTokens(2); len(got) != 2 || got[1] != "b" {
+		t.Fatalf("expected a single reconnect with the new header, got connections %v", got)
+	}
+	if cfg := conn.loadConfig(); cfg.HandshakeTimeout != time.Second || cfg.ReconnectTimeout != 10*time.Millisecond {
+		t.Errorf("timeouts weren't applied: %s, %s", cfg.HandshakeTimeout, cfg.ReconnectTimeout)
+	}
+
+	// Only fields that don't require a reconnect
+	setPolled(&MutableConfig{
+		URL:              wsURL(server),
+		Header:           http.Header{"X-Token": {"b"}},
+		HandshakeTimeout: 2 * time.Second,
+	}, nil)
+	if got := waitNoReconnect(); len(got) != 2 {
+		t.Errorf("unexpected reconnect, got connections %v", got)
+	}
+	if cfg := conn.loadConfig(); cfg.HandshakeTimeout != 2*time.Second || cfg.ReconnectTimeout != 0 {
+		t.Errorf("timeouts weren't applied: %s, %s", cfg.HandshakeTimeout, cfg.ReconnectTimeout)
+	}
+
+	// Poll errors keep the current config
+	setPolled(nil, errors.New("config service is down"))
+	if got := waitNoReconnect(); len(got) != 2 {
+		t.Errorf("unexpected reconnect, got connections %v", got)
+	}
+	if cfg := conn.loadConfig(); cfg.Header.Get("X-Token") != "b" {
+		t.Errorf("config must be kept, got header %v", cfg.Header)
+	}
+
+	// The watcher stops with the context
+	cancel()
+	setPolled(&MutableConfig{URL: wsURL(server), Header: http.Header{"X-Token": {"c"}}}, nil)
+	if got := waitNoReconnect(); len(got) != 2 {
+		t.Errorf("stopped watcher must not apply configs, got connections %v", got)
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	handler := func(WsConnection) error { return nil }
+	cur := &MutableConfig{
+		URL:              "ws://a",
+		Header:           http.Header{"X-Token": {"a"}},
+		SubscribeHandler: handler,
+		ReconnectTimeout: time.Second,
+	}
+
+	tests := []struct {
+		name          string
+		update        func(c *MutableConfig)
+		wantChanged   []string
+		wantReconnect bool
+	}{
+		{name: "same", update: func(c *MutableConfig) {}},
+		{
+			name:   "empty header",
+			update: func(c *MutableConfig) { c.Header = http.Header{} },
+			// 'cur' has a non-empty header
+			wantChanged:   []string{"header"},
+			wantReconnect: true,
+		},
+		{
+			name:          "url and timeouts",
+			update:        func(c *MutableConfig) { c.URL, c.HandshakeTimeout, c.ReconnectTimeout = "ws://b", time.Second, 0 },
+			wantChanged:   []string{"url", "handshake_timeout", "reconnect_timeout"},
+			wantReconnect: true,
+		},
+		{
+			name:          "header",
+			update:        func(c *MutableConfig) { c.Header.Set("X-Token", "b") },
+			wantChanged:   []string{"header"},
+			wantReconnect: true,
+		},
+		{
+			name:        "handlers",
+			update:      func(c *MutableConfig) { c.SubscribeHandler, c.PingHandler = nil, func(string) error { return nil } },
+			wantChanged: []string{"ping_handler", "subscribe_handler"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := cur.clone()
+			tt.update(next)
+
+			changed, reconnect := diffConfig(cur, next)
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("got changed fields %v, want %v", changed, tt.wantChanged)
+			}
+			if reconnect != tt.wantReconnect {
+				t.Errorf("got reconnect %t, want %t", reconnect, tt.wantReconnect)
+			}
+		})
+	}
+}
+
+func TestDiffConfigEmptyHeader(t *testing.T) {
+	if changed, _ := diffConfig(&MutableConfig{}, &MutableConfig{Header: http.Header{}}); len(changed) != 0 {
+		t.Errorf("nil and empty headers must be equal, got changed fields %v", changed)
+	}
+}
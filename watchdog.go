@@ -0,0 +1,95 @@
+package reconnect
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SetReaderLivenessTimeout enables a watchdog that reports a stalled reader: if no read method
+// ('ReadMessage', 'ReadMessageHandle' or 'StreamLines') is running and none has returned for 'd',
+// an error is logged. If 'closeConn' is true, the connection is also closed, so the stall becomes
+// visible to writers and the server. After 'Dial' call it does nothing
+func (r *ReConn) SetReaderLivenessTimeout(d time.Duration, closeConn bool) *ReConn {
+	r.configure(func() {
+		r.readerLivenessTimeout = d
+		r.readerLivenessClose = closeConn
+	})
+	return r
+}
+
+// OnReaderStalledFunc is called when the watchdog detects a stalled reader. 'stalled' is the time
+// since the last read, 'closed' is true if the connection was closed because of the stall
+type OnReaderStalledFunc func(stalled time.Duration, closed bool)
+
+// SetOnReaderStalled sets a callback called when the watchdog detects a stalled reader (see
+// 'SetReaderLivenessTimeout'). It's called once per stall. See 'SetOnConnect' for details.
+// After 'Dial' call it does nothing
+func (r *ReConn) SetOnReaderStalled(f OnReaderStalledFunc) *ReConn {
+	r.configure(func() {
+		r.onReaderStalled = f
+	})
+	return r
+}
+
+// ReaderStalls returns the number of detected reader stalls
+func (r *ReConn) ReaderStalls() uint64 {
+	return atomic.LoadUint64(&r.readerStalls)
+}
+
+// enterRead must be called at the beginning of every read method. 'exitRead' must be called
+// when the method returns. A returned closure would cost an allocation per read
+func (r *ReConn) enterRead() {
+	atomic.StoreInt64(&r.lastReadStartAt, monoNow())
+	atomic.AddInt32(&r.activeReads, 1)
+	r.readCalls.Enter()
+}
+
+func (r *ReConn) exitRead() {
+	atomic.StoreInt64(&r.lastReadAt, monoNow())
+	atomic.AddInt32(&r.activeReads, -1)
+	r.readCalls.Exit()
+}
+
+// startReaderWatchdog must be called once after 'r.dialed' is set
+func (r *ReConn) startReaderWatchdog() {
+	atomic.StoreInt64(&r.lastReadAt, monoNow())
+	if r.readerLivenessTimeout <= 0 {
+		return
+	}
+	r.scheduler.Every(r.readerLivenessTimeout/4, r.checkReaderLiveness)
+}
+
+// checkReaderLiveness is run by the scheduler
+func (r *ReConn) checkReaderLiveness() {
+	lastReadAt := atomic.LoadInt64(&r.lastReadAt)
+	if atomic.LoadInt32(&r.activeReads) > 0 || lastReadAt == r.reportedStallAt {
+		return
+	}
+	if stalled := time.Duration(monoNow() - lastReadAt); stalled >= r.readerLivenessTimeout {
+		r.reportedStallAt = lastReadAt
+		r.reportReaderStall(stalled)
+	}
+}
+
+func (r *ReConn) reportReaderStall(stalled time.Duration) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil {
+		// Not connected, nothing can be read
+		return
+	}
+
+	atomic.AddUint64(&r.readerStalls, 1)
+	r.log.Error(fmt.Sprintf("no reads for %s, the reader seems to be stalled", stalled.Round(time.Millisecond)))
+
+	if r.readerLivenessClose {
+		r.log.Error("close connection because of the stalled reader")
+		r.conn.Close()
+	}
+	if f := r.onReaderStalled; f != nil {
+		closed := r.readerLivenessClose
+		r.callbacks.Push(func() { f(stalled, closed) })
+	}
+}
@@ -0,0 +1,72 @@
+package reconnect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReaderWatchdog(t *testing.T) {
+	t.Run("stalled reader", func(t *testing.T) {
+		closed := make(chan struct{}, 1)
+		server := newWsServer(func(conn *websocket.Conn) {
+			conn.ReadMessage()
+			closed <- struct{}{}
+		})
+		defer server.Close()
+
+		stalls := make(chan bool, 1)
+		conn := New().SetURL(wsURL(server)).SetReaderLivenessTimeout(40*time.Millisecond, true).
+			SetOnReaderStalled(func(stalled time.Duration, closed bool) {
+				if stalled < 40*time.Millisecond {
+					t.Errorf("unexpected stall duration: %s", stalled)
+				}
+				stalls <- closed
+			})
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		// Nobody reads, so the connection must be closed
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("connection of a stalled reader must be closed")
+		}
+		if n := conn.ReaderStalls(); n != 1 {
+			t.Errorf("expected 1 stall, got %d", n)
+		}
+		select {
+		case closed := <-stalls:
+			if !closed {
+				t.Error("callback must report the closed connection")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("callback wasn't called")
+		}
+	})
+
+	t.Run("blocked reader", func(t *testing.T) {
+		// The server sends nothing and drops the connection after a while
+		server := newWsServer(func(conn *websocket.Conn) {
+			time.Sleep(200 * time.Millisecond)
+		})
+		defer server.Close()
+
+		conn := New().SetURL(wsURL(server)).SetReaderLivenessTimeout(40*time.Millisecond, true)
+		if err := conn.Dial(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+
+		// The reader waits for messages, it isn't stalled
+		if _, _, err := conn.ReadMessage(); err == nil {
+			t.Fatal("read must fail after the server drops the connection")
+		}
+		if n := conn.ReaderStalls(); n != 0 {
+			t.Errorf("a reader waiting for messages isn't stalled, got %d stalls", n)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrWriteBufferFull is returned by 'WriteMessage' when the connection is down and the write buffer is full
+var ErrWriteBufferFull = newCodedError(CodeQueueFull, "write buffer is full")
+
+// SetWriteBuffer enables buffering of user data messages written while the connection is down
+// or reconnecting: such writes are queued instead of reconnecting and return immediately.
+// If the buffer already contains 'size' messages or the message doesn't fit into the memory budget
+// (see 'SetMemoryBudget'), 'ErrWriteBufferFull' is returned.
+// The buffer is flushed in order after the next successful connect (usually triggered by a read),
+// right after the subscribe handler and before any new writes (see egress.go).
+// Buffered messages are dropped on 'Close'. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteBuffer(size int) *ReConn {
+	r.configure(func() {
+		r.writeBufferSize = size
+	})
+	return r
+}
+
+// SetQueueFlushRate limits the flush of the write buffer (see 'SetWriteBuffer') to 'msgsPerSecond'
+// messages per second, so a long queue doesn't hit the rate limits of the server right after a reconnect.
+// The first message is written immediately, unless 'SetQueueFlushJitter' is enabled. The flush is
+// a part of the connect, so reads and writes wait for it. 'Close' interrupts the flush, the rest
+// of the messages are dropped. 'msgsPerSecond' <= 0 disables the limit. After 'Dial' call it does nothing
+func (r *ReConn) SetQueueFlushRate(msgsPerSecond float64) *ReConn {
+	r.configure(func() {
+		if msgsPerSecond <= 0 {
+			r.queueFlushInterval = 0
+			return
+		}
+		r.queueFlushInterval = time.Duration(float64(time.Second) / msgsPerSecond)
+	})
+	return r
+}
+
+// SetQueueFlushJitter delays the first message of a paced flush (see 'SetQueueFlushRate') by a random
+// duration up to the interval between messages. So instances reconnected at the same time don't write
+// their queues at the same time. After 'Dial' call it does nothing
+func (r *ReConn) SetQueueFlushJitter(enabled bool) *ReConn {
+	r.configure(func() {
+		r.queueFlushJitter = enabled
+	})
+	return r
+}
+
+// bufferWrite queues the message if the connection is down. It returns false if the message
+// must be written as usual
+func (r *ReConn) bufferWrite(messageType int, data []byte) (buffered bool, err error) {
+	if r.writeBufferSize <= 0 {
+		return false, nil
+	}
+	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+		// Control messages make sense only for the current connection
+		return false, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn != nil || r.closed.Get() {
+		return false, nil
+	}
+
+	// The caller can reuse the data
+	msg := bufferedMessage{Type: messageType, Data: append([]byte(nil), data...)}
+	if !r.writeBuffer.PushLimited(msg, r.writeBufferSize) {
+		return true, ErrWriteBufferFull
+	}
+	r.updatePressureLevel()
+	return true, nil
+}
+
+// flushWriteBuffer writes the buffered messages to a new connection. A message is removed from
+// the buffer only after it was written, so messages are kept in order if the connection fails.
+// It must be called under 'r.mu'
+func (r *ReConn) flushWriteBuffer(conn WsConnection) error {
+	r.writeMu.Lock(false)
+	defer r.writeMu.Unlock()
+
+	var limiter *rateLimiter
+	if r.queueFlushInterval > 0 && r.writeBuffer.Len() > 0 {
+		limiter = newRateLimiter(r.queueFlushInterval, 1)
+		if r.queueFlushJitter {
+			if err := r.waitQueueFlushJitter(); err != nil {
+				return fmt.Errorf("flush write buffer: %w", err)
+			}
+		}
+	}
+
+	var flushed int
+	for {
+		msg, ok := r.writeBuffer.Peek()
+		if !ok {
+			break
+		}
+		if limiter != nil {
+			if err := limiter.Wait(context.Background(), r.closeSignal()); err != nil {
+				return fmt.Errorf("flush write buffer: %w", err)
+			}
+		}
+		err := r.runWritePipeline(conn, WriteSourceQueueFlush, msg.Type, msg.Data)
+		if isTransformError(err) {
+			// The message can't be written to any connection
+			r.log.Error(fmt.Sprintf("drop buffered message: %s", err))
+			r.writeBuffer.Pop()
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("flush write buffer: %w", err)
+		}
+		r.writeBuffer.Pop()
+		flushed++
+	}
+
+	r.log.Info(fmt.Sprintf("%d buffered messages were flushed", flushed))
+	r.updatePressureLevel()
+	return nil
+}
+
+// waitQueueFlushJitter waits for a random part of the flush interval. It returns 'ErrConnClosed'
+// if 'Close' is called during the wait
+func (r *ReConn) waitQueueFlushJitter() error {
+	timer := time.NewTimer(randomDuration(r.queueFlushInterval))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.closeSignal():
+		return ErrConnClosed
+	}
+}
+
+// dropWriteBuffer must be called on 'Close'
+func (r *ReConn) dropWriteBuffer() {
+	if n := r.writeBuffer.Len(); n > 0 {
+		r.log.Info(fmt.Sprintf("drop %d buffered messages", n))
+	}
+	r.writeBuffer.Reset()
+}
@@ -0,0 +1,281 @@
+package reconnect
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	var (
+		reject   int32
+		mu       sync.Mutex
+		received [][]string
+		conns    = make(chan *websocket.Conn, 10)
+	)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&reject) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+
+		mu.Lock()
+		received = append(received, nil)
+		i := len(received) - 1
+		mu.Unlock()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			received[i] = append(received[i], string(data))
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	getReceived := func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		res := make([][]string, len(received))
+		for i := range received {
+			res[i] = append([]string(nil), received[i]...)
+		}
+		return res
+	}
+
+	conn := New().SetURL(wsURL(server)).SetWriteBuffer(2).SetSubscribeHandler(func(conn WsConnection) error {
+		return conn.WriteMessage(websocket.TextMessage, []byte("subscribe"))
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// Drop the connection, the reconnect fails
+	atomic.StoreInt32(&reject, 1)
+	(<-conns).Close()
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if state := conn.State(); state != StateDisconnected {
+		t.Fatalf("expected state %s, got %s", StateDisconnected, state)
+	}
+
+	data := []byte("a")
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The buffered message must not be affected
+	data[0] = 'x'
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("b")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err := conn.WriteMessage(websocket.TextMessage, []byte("c"))
+	if !errors.Is(err, ErrWriteBufferFull) {
+		t.Fatalf("expected ErrWriteBufferFull, got: %v", err)
+	}
+	if CodeOf(err) != CodeQueueFull {
+		t.Errorf("got code %s, want %s", CodeOf(err), CodeQueueFull)
+	}
+
+	// The next read reconnects and flushes the buffer
+	atomic.StoreInt32(&reject, 0)
+	go func() {
+		for !conn.closed.Get() {
+			conn.ReadMessage()
+		}
+	}()
+	for i := 0; i < 200 && conn.State() != StateConnected; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("d")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The buffered messages are written after the subscribe message and before new writes
+	want := []string{"subscribe", "a", "b", "d"}
+	lastConn := func() []string {
+		got := getReceived()
+		if len(got) != 2 {
+			return nil
+		}
+		return got[1]
+	}
+	for i := 0; i < 200 && !reflect.DeepEqual(lastConn(), want); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := getReceived(); len(got) != 2 || !reflect.DeepEqual(got[1], want) {
+		t.Errorf("got messages %q, want %q on the second connection", got, want)
+	}
+
+	// Unblock the reader
+	(<-conns).Close()
+}
+
+func TestWriteBufferDroppedOnClose(t *testing.T) {
+	conn := New().SetWriteBuffer(10)
+	conn.dialed.Set(true)
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("a")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// Control messages aren't buffered
+	if err := conn.WriteMessage(websocket.PingMessage, nil); err == nil {
+		t.Error("expected an error")
+	}
+	if n := conn.writeBuffer.Len(); n != 1 {
+		t.Fatalf("expected 1 buffered message, got %d", n)
+	}
+
+	conn.Close()
+	if n := conn.writeBuffer.Len(); n != 0 {
+		t.Errorf("buffered messages must be dropped on 'Close', got %d", n)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("a")); err == nil {
+		t.Error("writes after 'Close' must fail")
+	}
+}
+
+func TestWriteBufferFlushRate(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetWriteBuffer(10).SetQueueFlushRate(float64(time.Second / interval))
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	// Drop the connection, the reconnect fails
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	want := []string{"a", "b", "c", "d"}
+	for _, msg := range want {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if n := conn.Stats().BufferedWrites; n != len(want) {
+		t.Fatalf("expected %d buffered writes, got %d", len(want), n)
+	}
+
+	// The next read reconnects and flushes the buffer
+	dialer.SetError(nil)
+	start := time.Now()
+	go conn.ReadMessage()
+
+	var inProgress bool
+	for i := 0; i < 500 && (dialer.Dials() != 2 || len(dialer.Last().Written()) != len(want)); i++ {
+		if n := conn.Stats().BufferedWrites; n > 0 && n < len(want) {
+			inProgress = true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(start)
+
+	var got []string
+	for _, msg := range dialer.Last().Written() {
+		got = append(got, string(msg.Data))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got messages %q, want %q", got, want)
+	}
+	if min := time.Duration(len(want)-1) * interval; elapsed < min-10*time.Millisecond {
+		t.Errorf("the flush must take at least %s, took %s", min, elapsed)
+	}
+	if !inProgress {
+		t.Error("the remaining depth must be visible during the flush")
+	}
+	if n := conn.Stats().BufferedWrites; n != 0 {
+		t.Errorf("expected no buffered writes, got %d", n)
+	}
+}
+
+func TestWriteBufferFlushInterrupted(t *testing.T) {
+	var dialer FakeDialer
+	conn := New().SetDialFunc(dialer.Dial).SetWriteBuffer(10).SetQueueFlushRate(10).SetQueueFlushJitter(true)
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dialer.SetError(errors.New("connection refused"))
+	dialer.Last().Fail(errors.New("connection reset"))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, msg := range []string{"a", "b", "c"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	dialer.SetError(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Reconnect until a message is read
+		for {
+			if _, _, err := conn.ReadMessage(); err == nil {
+				return
+			}
+		}
+	}()
+
+	// The connection breaks after the first flushed message
+	waitWritten := func(conn *FakeConn, n int) []string {
+		var res []string
+		for i := 0; i < 500; i++ {
+			res = res[:0]
+			for _, msg := range conn.Written() {
+				res = append(res, string(msg.Data))
+			}
+			if len(res) >= n {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return res
+	}
+	for i := 0; i < 500 && dialer.Dials() != 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	broken := dialer.Last()
+	waitWritten(broken, 1)
+	broken.Fail(errors.New("connection reset"))
+
+	// The remaining messages are flushed in order to the next connection
+	for i := 0; i < 500 && dialer.Dials() != 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := waitWritten(dialer.Last(), 2), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q on the next connection", got, want)
+	}
+	if got, want := waitWritten(broken, 1), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q on the broken connection", got, want)
+	}
+
+	// Unblock the reader
+	dialer.Last().Push(websocket.TextMessage, []byte("msg"))
+	<-done
+	conn.Close()
+}
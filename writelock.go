@@ -0,0 +1,94 @@
+package reconnect
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// priorityWriteMaxWait is a max time a priority write waits behind ordinary writes.
+// After that it's the next to acquire the lock
+const priorityWriteMaxWait = 5 * time.Millisecond
+
+// writeWaiter is a goroutine waiting for 'writeLock'
+type writeWaiter struct {
+	ready    chan struct{}
+	priority bool
+	since    time.Time
+}
+
+// writeLock serializes writes. Waiters acquire the lock in FIFO order, except for priority
+// waiters (protocol-critical writes like heartbeats) that waited longer than 'priorityWriteMaxWait':
+// they jump the queue, so a heartbeat can't be starved by a flood of user writes
+type writeLock struct {
+	starvations uint64
+	// waiting is a number of waiters. It's updated under 'mu'
+	waiting int32
+
+	mu      sync.Mutex
+	locked  bool
+	waiters []*writeWaiter
+}
+
+func (l *writeLock) Lock(priority bool) {
+	l.mu.Lock()
+	if !l.locked {
+		l.locked = true
+		l.mu.Unlock()
+		return
+	}
+	w := &writeWaiter{ready: make(chan struct{}), priority: priority, since: time.Now()}
+	l.waiters = append(l.waiters, w)
+	atomic.StoreInt32(&l.waiting, int32(len(l.waiters)))
+	l.mu.Unlock()
+
+	// The lock is handed over by 'Unlock'
+	<-w.ready
+}
+
+func (l *writeLock) Unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.waiters) == 0 {
+		l.locked = false
+		return
+	}
+
+	next := 0
+	for i, w := range l.waiters {
+		if w.priority {
+			if i > 0 && time.Since(w.since) >= priorityWriteMaxWait {
+				next = i
+				atomic.AddUint64(&l.starvations, 1)
+			}
+			// Only the oldest priority waiter can jump the queue
+			break
+		}
+	}
+
+	w := l.waiters[next]
+	copy(l.waiters[next:], l.waiters[next+1:])
+	l.waiters[len(l.waiters)-1] = nil
+	l.waiters = l.waiters[:len(l.waiters)-1]
+	atomic.StoreInt32(&l.waiting, int32(len(l.waiters)))
+
+	// 'l.locked' stays true: the lock is owned by the waiter now
+	close(w.ready)
+}
+
+// Waiting returns the number of goroutines waiting for the lock
+func (l *writeLock) Waiting() int {
+	return int(atomic.LoadInt32(&l.waiting))
+}
+
+// Starvations returns the number of times a priority waiter jumped the queue
+func (l *writeLock) Starvations() uint64 {
+	return atomic.LoadUint64(&l.starvations)
+}
+
+// WriteStarvations returns the number of times a heartbeat write waited behind other writes
+// longer than the allowed time and was moved to the head of the write queue
+func (r *ReConn) WriteStarvations() uint64 {
+	return r.writeMu.Starvations()
+}
@@ -0,0 +1,164 @@
+package reconnect
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWriteLockOrder(t *testing.T) {
+	var (
+		l     writeLock
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	lock := func(name string, priority bool) {
+		defer wg.Done()
+
+		l.Lock(priority)
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+		l.Unlock()
+	}
+	// waitQueued waits until 'n' goroutines are queued
+	waitQueued := func(n int) {
+		for {
+			l.mu.Lock()
+			queued := len(l.waiters)
+			l.mu.Unlock()
+			if queued == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	l.Lock(false)
+	wg.Add(3)
+	go lock("user1", false)
+	waitQueued(1)
+	go lock("user2", false)
+	waitQueued(2)
+	go lock("heartbeat", true)
+	waitQueued(3)
+
+	// The heartbeat hasn't waited long enough: FIFO
+	l.Unlock()
+	wg.Wait()
+	if want := []string{"user1", "user2", "heartbeat"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("got order %v, want %v", order, want)
+	}
+	if n := l.Starvations(); n != 0 {
+		t.Errorf("expected no starvations, got %d", n)
+	}
+
+	// The heartbeat waited too long: it jumps the queue
+	order = nil
+	l.Lock(false)
+	wg.Add(3)
+	go lock("user1", false)
+	waitQueued(1)
+	go lock("user2", false)
+	waitQueued(2)
+	go lock("heartbeat", true)
+	waitQueued(3)
+	time.Sleep(2 * priorityWriteMaxWait)
+	l.Unlock()
+	wg.Wait()
+
+	if want := []string{"heartbeat", "user1", "user2"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("got order %v, want %v", order, want)
+	}
+	if n := l.Starvations(); n != 1 {
+		t.Errorf("expected 1 starvation, got %d", n)
+	}
+}
+
+func TestHeartbeatsUnderWriteLoad(t *testing.T) {
+	const (
+		writers   = 20
+		writeTime = 5 * time.Millisecond
+		interval  = 30 * time.Millisecond
+		tolerance = 60 * time.Millisecond
+	)
+
+	var (
+		mu    sync.Mutex
+		pings []time.Time
+	)
+	release := make(chan struct{})
+	server := newWsServer(func(conn *websocket.Conn) {
+		conn.SetPingHandler(func(data string) error {
+			mu.Lock()
+			pings = append(pings, time.Now())
+			mu.Unlock()
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		go func() {
+			<-release
+			conn.Close()
+		}()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	conn := New().SetURL(wsURL(server)).SetKeepAlive(interval, 10*time.Second)
+	// Slow user writes saturate the write lock
+	conn.writeStages = append(conn.writeStages, func(messageType int, data []byte) (int, []byte, error) {
+		if messageType != websocket.PingMessage {
+			time.Sleep(writeTime)
+		}
+		return messageType, data, nil
+	})
+	if err := conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() {
+		close(release)
+		conn.Close()
+	}()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+			}
+		}()
+	}
+	time.Sleep(20 * interval)
+	close(stop)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(pings) < 10 {
+		t.Fatalf("expected at least 10 pings, got %d", len(pings))
+	}
+	for i := 1; i < len(pings); i++ {
+		if gap := pings[i].Sub(pings[i-1]); gap > interval+tolerance {
+			t.Errorf("gap between pings %d and %d is %s, want at most %s", i-1, i, gap, interval+tolerance)
+		}
+	}
+	if conn.WriteStarvations() == 0 {
+		t.Error("expected starvations to be counted")
+	}
+}
@@ -0,0 +1,214 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WriteDuringReconnectMode is a policy of user writes called while a reconnect is in progress
+// (see 'SetWriteDuringReconnect')
+type WriteDuringReconnectMode int
+
+const (
+	// WriteDuringReconnectDefault keeps the original behavior: a write waits for the reconnect that holds
+	// the connection and is written to the connection that exists afterwards
+	WriteDuringReconnectDefault WriteDuringReconnectMode = iota
+	// WriteDuringReconnectFail makes a write return 'ErrReconnecting' immediately. A write is never written
+	// to a connection established after the write was called: if a reconnect completes before the write
+	// gets the connection, 'ErrReconnecting' is returned as well
+	WriteDuringReconnectFail
+	// WriteDuringReconnectWait makes a write wait until the reconnect is finished (including the subscribe
+	// handler). Then the message is written as usual. The wait is bounded by 'SetWriteDuringReconnectTimeout'
+	WriteDuringReconnectWait
+	// WriteDuringReconnectBuffer queues a data message into the write buffer (see 'SetWriteBuffer') and
+	// returns immediately. The buffer is flushed right after the reconnect. If the write buffer isn't enabled,
+	// writes wait as with 'WriteDuringReconnectWait'. Streamed messages ('NextWriter') always wait
+	WriteDuringReconnectBuffer
+)
+
+func (m WriteDuringReconnectMode) String() string {
+	switch m {
+	case WriteDuringReconnectDefault:
+		return "default"
+	case WriteDuringReconnectFail:
+		return "fail"
+	case WriteDuringReconnectWait:
+		return "wait"
+	case WriteDuringReconnectBuffer:
+		return "buffer"
+	default:
+		return "unknown"
+	}
+}
+
+// SetWriteDuringReconnect sets a policy of user writes ('WriteMessage', 'WriteJSON' and etc.) called while
+// a reconnect is in progress, i.e. from the drop of the previous connection to the end of the connect attempt
+// (the backoff wait, the dial and the subscribe handler). See 'WriteDuringReconnectMode' for the modes.
+// Writes that reconnect themselves aren't affected. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteDuringReconnect(mode WriteDuringReconnectMode) *ReConn {
+	r.configure(func() {
+		r.writeDuringReconnect = mode
+	})
+	return r
+}
+
+// SetWriteDuringReconnectTimeout limits the wait of 'WriteDuringReconnectWait'. If the reconnect isn't
+// finished in time, 'ErrReconnecting' is returned. 0 means no limit. After 'Dial' call it does nothing
+func (r *ReConn) SetWriteDuringReconnectTimeout(timeout time.Duration) *ReConn {
+	r.configure(func() {
+		r.writeDuringReconnectTimeout = timeout
+	})
+	return r
+}
+
+// reconnectFlight tracks connect attempts in progress
+type reconnectFlight struct {
+	mu sync.Mutex
+	n  int
+	// done is closed when the last attempt is finished
+	done chan struct{}
+}
+
+func (f *reconnectFlight) Begin() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.n == 0 {
+		f.done = make(chan struct{})
+	}
+	f.n++
+}
+
+// End finishes an attempt. 'beforeLast' is called before the last attempt is finished
+func (f *reconnectFlight) End(beforeLast func()) {
+	f.mu.Lock()
+	last := f.n == 1
+	f.mu.Unlock()
+
+	if last {
+		// Attempts are still in flight, so new writes are buffered and don't overtake the flushed ones
+		beforeLast()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.n--
+	if f.n == 0 {
+		close(f.done)
+	}
+}
+
+// InFlight returns a channel that is closed when all attempts are finished. It returns nil
+// if there are no attempts in progress
+func (f *reconnectFlight) InFlight() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.n == 0 {
+		return nil
+	}
+	return f.done
+}
+
+// endReconnectFlight must be called at the end of every connect attempt
+func (r *ReConn) endReconnectFlight() {
+	if r.writeDuringReconnect != WriteDuringReconnectBuffer {
+		r.reconnectFlight.End(func() {})
+		return
+	}
+	r.reconnectFlight.End(r.flushLateWrites)
+}
+
+// applyWriteDuringReconnect applies the policy set by 'SetWriteDuringReconnect'. It returns false
+// if the message must be written as usual. Streamed messages ('data' is nil) can't be buffered
+func (r *ReConn) applyWriteDuringReconnect(ctx context.Context, messageType int, data []byte) (handled bool, err error) {
+	if r.writeDuringReconnect == WriteDuringReconnectDefault {
+		return false, nil
+	}
+	done := r.reconnectFlight.InFlight()
+	if done == nil {
+		return false, nil
+	}
+
+	switch r.writeDuringReconnect {
+	case WriteDuringReconnectFail:
+		return true, ErrReconnecting
+	case WriteDuringReconnectBuffer:
+		if r.writeBufferSize > 0 && isDataMessage(messageType) && data != nil {
+			return true, r.bufferLateWrite(messageType, data)
+		}
+	}
+
+	if err := r.waitReconnectFlight(ctx, done); err != nil {
+		return true, err
+	}
+	return false, nil
+}
+
+// waitReconnectFlight waits until 'done' is closed
+func (r *ReConn) waitReconnectFlight(ctx context.Context, done <-chan struct{}) error {
+	var timeout <-chan time.Time
+	if r.writeDuringReconnectTimeout > 0 {
+		timer := time.NewTimer(r.writeDuringReconnectTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.closeSignal():
+		return ErrConnClosed
+	case <-timeout:
+		return fmt.Errorf("%w: wait timeout %s", ErrReconnecting, r.writeDuringReconnectTimeout)
+	}
+}
+
+// bufferLateWrite queues a message written during a reconnect. The message is flushed by the end
+// of the reconnect or, if the reconnect has already ended, right away
+func (r *ReConn) bufferLateWrite(messageType int, data []byte) error {
+	// The caller can reuse the data
+	msg := bufferedMessage{Type: messageType, Data: append([]byte(nil), data...)}
+	if !r.writeBuffer.PushLimited(msg, r.writeBufferSize) {
+		return ErrWriteBufferFull
+	}
+	r.updatePressureLevel()
+
+	if r.reconnectFlight.InFlight() == nil {
+		// The reconnect has ended after the check, so its flush could miss the message
+		r.flushLateWrites()
+	}
+	return nil
+}
+
+// flushLateWrites flushes messages buffered during a reconnect to the current connection. If there's
+// no connection, they are flushed after the next connect
+func (r *ReConn) flushLateWrites() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil || r.writeBuffer.Len() == 0 {
+		return
+	}
+	if err := r.flushWriteBuffer(r.conn); err != nil {
+		// The next read or write finds the broken connection and reconnects
+		r.log.Error(err.Error())
+	}
+}
+
+// writeMessagePinned is like 'writeMessageGeneration', but it returns 'ErrReconnecting' if the connection
+// of the passed generation was replaced
+func (r *ReConn) writeMessagePinned(ctx context.Context, generation uint64, messageType int, data []byte) (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.generation != generation {
+		return r.generation, ErrReconnecting
+	}
+	return r.generation, r.writeMessageLocked(ctx, WriteSourceUser, messageType, data)
+}
@@ -0,0 +1,233 @@
+package reconnect
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// delayedReconnect is a connection whose reconnects are blocked until 'release' is called
+type delayedReconnect struct {
+	conn     *ReConn
+	dialer   *FakeDialer
+	release  chan struct{}
+	readDone chan error
+}
+
+func newDelayedReconnect(t *testing.T, configure func(conn *ReConn)) *delayedReconnect {
+	d := &delayedReconnect{
+		dialer:   &FakeDialer{},
+		release:  make(chan struct{}),
+		readDone: make(chan error, 1),
+	}
+
+	var dials int32
+	d.conn = New().SetReconnectTimeout(time.Millisecond).
+		SetDialFunc(func(url string, header http.Header) (WsConnection, *http.Response, error) {
+			if atomic.AddInt32(&dials, 1) > 1 {
+				<-d.release
+			}
+			return d.dialer.Dial(url, header)
+		}).
+		SetSubscribeHandler(func(conn WsConnection) error {
+			return conn.WriteMessage(websocket.TextMessage, []byte("subscribe"))
+		})
+	configure(d.conn)
+
+	if err := d.conn.Dial(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return d
+}
+
+// startReconnect breaks the connection and waits until a read starts reconnecting
+func (d *delayedReconnect) startReconnect(t *testing.T) {
+	d.dialer.Last().Fail(errors.New("broken pipe"))
+	go func() {
+		_, _, err := d.conn.ReadMessage()
+		d.readDone <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for d.conn.State() != StateReconnecting {
+		if time.Now().After(deadline) {
+			t.Fatal("reconnect must be started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// finishReconnect releases the dial and waits for the read
+func (d *delayedReconnect) finishReconnect(t *testing.T) {
+	close(d.release)
+	select {
+	case <-d.readDone:
+	case <-time.After(time.Second):
+		t.Fatal("reconnect must be finished")
+	}
+	if n := d.dialer.Dials(); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+}
+
+func checkWritten(t *testing.T, conn *FakeConn, want ...string) {
+	t.Helper()
+
+	written := conn.Written()
+	if len(written) != len(want) {
+		t.Fatalf("expected %d written messages, got %d", len(want), len(written))
+	}
+	for i, msg := range written {
+		if string(msg.Data) != want[i] {
+			t.Errorf("message %d: expected %q, got %q", i, want[i], msg.Data)
+		}
+	}
+}
+
+func TestWriteDuringReconnectFail(t *testing.T) {
+	d := newDelayedReconnect(t, func(conn *ReConn) {
+		conn.SetWriteDuringReconnect(WriteDuringReconnectFail)
+	})
+	defer d.conn.Close()
+
+	d.startReconnect(t)
+
+	start := time.Now()
+	err := d.conn.WriteMessage(websocket.TextMessage, []byte("msg"))
+	if !errors.Is(err, ErrReconnecting) || CodeOf(err) != CodeReconnecting {
+		t.Fatalf("expected ErrReconnecting, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("write must fail immediately, took %s", elapsed)
+	}
+
+	d.finishReconnect(t)
+
+	// The reconnect is finished, so writes go to the new connection
+	if err := d.conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkWritten(t, d.dialer.Last(), "subscribe", "msg")
+}
+
+func TestWriteDuringReconnectWait(t *testing.T) {
+	t.Run("reconnected", func(t *testing.T) {
+		d := newDelayedReconnect(t, func(conn *ReConn) {
+			conn.SetWriteDuringReconnect(WriteDuringReconnectWait)
+		})
+		defer d.conn.Close()
+
+		d.startReconnect(t)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- d.conn.WriteMessage(websocket.TextMessage, []byte("msg"))
+		}()
+		select {
+		case err := <-done:
+			t.Fatalf("write must wait for the reconnect, got %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		d.finishReconnect(t)
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("write must be finished")
+		}
+		checkWritten(t, d.dialer.Last(), "subscribe", "msg")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		d := newDelayedReconnect(t, func(conn *ReConn) {
+			conn.SetWriteDuringReconnect(WriteDuringReconnectWait).SetWriteDuringReconnectTimeout(50 * time.Millisecond)
+		})
+		defer d.conn.Close()
+
+		d.startReconnect(t)
+
+		start := time.Now()
+		if err := d.conn.WriteMessage(websocket.TextMessage, []byte("msg")); !errors.Is(err, ErrReconnecting) {
+			t.Fatalf("expected ErrReconnecting, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+			t.Errorf("unexpected duration of the wait: %s", elapsed)
+		}
+
+		d.finishReconnect(t)
+		checkWritten(t, d.dialer.Last(), "subscribe")
+	})
+
+	t.Run("close", func(t *testing.T) {
+		d := newDelayedReconnect(t, func(conn *ReConn) {
+			conn.SetWriteDuringReconnect(WriteDuringReconnectWait)
+		})
+		d.startReconnect(t)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- d.conn.WriteMessage(websocket.TextMessage, []byte("msg"))
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		d.conn.Close()
+		select {
+		case err := <-done:
+			if err != ErrConnClosed {
+				t.Errorf("expected ErrConnClosed, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("'Close' must interrupt the wait")
+		}
+		close(d.release)
+	})
+}
+
+func TestWriteDuringReconnectBuffer(t *testing.T) {
+	d := newDelayedReconnect(t, func(conn *ReConn) {
+		conn.SetWriteDuringReconnect(WriteDuringReconnectBuffer).SetWriteBuffer(2)
+	})
+	defer d.conn.Close()
+
+	d.startReconnect(t)
+
+	start := time.Now()
+	for _, msg := range []string{"1", "2"} {
+		if err := d.conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := d.conn.WriteMessage(websocket.TextMessage, []byte("3")); !errors.Is(err, ErrWriteBufferFull) {
+		t.Fatalf("expected ErrWriteBufferFull, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("writes must not wait, took %s", elapsed)
+	}
+
+	d.finishReconnect(t)
+
+	// The buffered messages are written after the subscribe message and before new writes
+	if err := d.conn.WriteMessage(websocket.TextMessage, []byte("4")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkWritten(t, d.dialer.Last(), "subscribe", "1", "2", "4")
+}
+
+func TestWriteDuringReconnectDefault(t *testing.T) {
+	d := newDelayedReconnect(t, func(*ReConn) {})
+	defer d.conn.Close()
+
+	// Without a reconnect in progress the policy does nothing
+	if err := d.conn.WriteMessage(websocket.TextMessage, []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkWritten(t, d.dialer.Last(), "subscribe", "msg")
+}